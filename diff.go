@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// DiffSequences describes the musical changes from one sequence to another,
+// one change per line.  Lines, parts and sections are numbered from 1 as
+// they are shown in sq.
+func DiffSequences(old, new sequence.Sequence) []string {
+	stateDiff := createStateDiff(&old, &temporaryState{
+		lines:        new.Lines,
+		tempo:        new.Tempo,
+		subdivisions: new.Subdivisions,
+		accents:      new.Accents,
+	})
+	changes := stateDiff.Changes()
+	changes = append(changes, partChanges(partsOf(old), partsOf(new))...)
+	changes = append(changes, arrangementChanges(old.Arrangement, new.Arrangement)...)
+	changes = append(changes, snapshotChanges(old, new)...)
+	return changes
+}
+
+func snapshotChanges(old, new sequence.Sequence) []string {
+	var changes []string
+	for _, snapshot := range old.Snapshots {
+		if _, exists := new.FindSnapshot(snapshot.Name); !exists {
+			changes = append(changes, fmt.Sprintf("Snapshot %q removed", snapshot.Name))
+		}
+	}
+	for _, snapshot := range new.Snapshots {
+		previous, exists := old.FindSnapshot(snapshot.Name)
+		switch {
+		case !exists:
+			changes = append(changes, fmt.Sprintf("Snapshot %q added (%s)", snapshot.Name, describeSnapshot(snapshot)))
+		case !previous.Equal(snapshot):
+			changes = append(changes, fmt.Sprintf("Snapshot %q changed", snapshot.Name))
+		}
+	}
+	return changes
+}
+
+func describeSnapshot(snapshot sequence.Snapshot) string {
+	if snapshot.IsWhole() {
+		return "whole sequence"
+	}
+	return fmt.Sprintf("part %d", snapshot.Part+1)
+}
+
+func lineChanges(old, new []grid.LineDefinition) []string {
+	var changes []string
+	for i := range max(len(old), len(new)) {
+		switch {
+		case i >= len(new):
+			changes = append(changes, fmt.Sprintf("Line %d removed (%s)", i+1, describeLine(old[i])))
+		case i >= len(old):
+			changes = append(changes, fmt.Sprintf("Line %d added (%s)", i+1, describeLine(new[i])))
+		default:
+			changes = append(changes, lineDefinitionChanges(i, old[i], new[i])...)
+		}
+	}
+	return changes
+}
+
+func lineDefinitionChanges(index int, old, new grid.LineDefinition) []string {
+	var changes []string
+	if old.Name != new.Name {
+		changes = append(changes, fmt.Sprintf("Line %d name %q→%q", index+1, old.Name, new.Name))
+	}
+	if old.Channel != new.Channel {
+		changes = append(changes, fmt.Sprintf("Line %d channel %d→%d", index+1, old.Channel, new.Channel))
+	}
+	if old.MsgType != new.MsgType {
+		changes = append(changes, fmt.Sprintf("Line %d message type %s→%s", index+1, messageTypeName(old.MsgType), messageTypeName(new.MsgType)))
+	}
+	if old.Note != new.Note {
+		changes = append(changes, fmt.Sprintf("Line %d note %d→%d", index+1, old.Note, new.Note))
+	}
+	if old.Length != new.Length {
+		changes = append(changes, fmt.Sprintf("Line %d length %d→%d", index+1, old.Length, new.Length))
+	}
+	if old.Division != new.Division {
+		changes = append(changes, fmt.Sprintf("Line %d division %q→%q", index+1, old.Division, new.Division))
+	}
+	return changes
+}
+
+func describeLine(line grid.LineDefinition) string {
+	description := fmt.Sprintf("channel %d %s %d", line.Channel, messageTypeName(line.MsgType), line.Note)
+	if line.Name != "" {
+		description = fmt.Sprintf("%s %s", line.Name, description)
+	}
+	return description
+}
+
+func messageTypeName(msgType grid.MessageType) string {
+	switch msgType {
+	case grid.MessageTypeCc:
+		return "CC"
+	case grid.MessageTypeProgramChange:
+		return "Program Change"
+	default:
+		return "NOTE"
+	}
+}
+
+func accentChanges(old, new sequence.PatternAccents) []string {
+	var changes []string
+	if old.Target != new.Target {
+		changes = append(changes, fmt.Sprintf("Accent target %s→%s", accentTargetName(old.Target), accentTargetName(new.Target)))
+	}
+	if old.Start != new.Start {
+		changes = append(changes, fmt.Sprintf("Accent start %d→%d", old.Start, new.Start))
+	}
+	if old.End != new.End {
+		changes = append(changes, fmt.Sprintf("Accent end %d→%d", old.End, new.End))
+	}
+	// Accent values are calculated from the start and end, only values
+	// changed on their own are listed
+	if old.Start == new.Start && old.End == new.End {
+		for i := 1; i < max(len(old.Data), len(new.Data)); i++ {
+			switch {
+			case i >= len(new.Data):
+				changes = append(changes, fmt.Sprintf("Accent %d removed", i))
+			case i >= len(old.Data):
+				changes = append(changes, fmt.Sprintf("Accent %d added (%d)", i, new.Data[i]))
+			case old.Data[i] != new.Data[i]:
+				changes = append(changes, fmt.Sprintf("Accent %d %d→%d", i, old.Data[i], new.Data[i]))
+			}
+		}
+	}
+	return changes
+}
+
+func accentTargetName(target sequence.AccentTarget) string {
+	if target == sequence.AccentTargetVelocity {
+		return "VELOCITY"
+	}
+	return "NOTE"
+}
+
+func partsOf(s sequence.Sequence) []arrangement.Part {
+	if s.Parts == nil {
+		return nil
+	}
+	return *s.Parts
+}
+
+func partChanges(old, new []arrangement.Part) []string {
+	var changes []string
+	for i := range max(len(old), len(new)) {
+		name := fmt.Sprintf("Part %d", i+1)
+		switch {
+		case i >= len(new):
+			changes = append(changes, fmt.Sprintf("%s removed (%s)", name, old[i].Name))
+		case i >= len(old):
+			changes = append(changes, fmt.Sprintf("%s added (%s)", name, new[i].Name))
+			changes = append(changes, overlayTreeChanges(name, nil, new[i].Overlays)...)
+		default:
+			if old[i].Name != new[i].Name {
+				changes = append(changes, fmt.Sprintf("%s name %q→%q", name, old[i].Name, new[i].Name))
+			}
+			if old[i].Beats != new[i].Beats {
+				changes = append(changes, fmt.Sprintf("%s beats %d→%d", name, old[i].Beats, new[i].Beats))
+			}
+			if old[i].Meter != new[i].Meter {
+				changes = append(changes, fmt.Sprintf("%s meter %s→%s", name, describeMeter(old[i].Meter), describeMeter(new[i].Meter)))
+			}
+			if !old[i].Grooves.Equal(new[i].Grooves) {
+				changes = append(changes, fmt.Sprintf("%s grooves %s→%s", name, describeGrooves(old[i].Grooves), describeGrooves(new[i].Grooves)))
+			}
+			changes = append(changes, overlayTreeChanges(name, old[i].Overlays, new[i].Overlays)...)
+		}
+	}
+	return changes
+}
+
+func describeMeter(meter arrangement.Meter) string {
+	if !meter.IsSet() {
+		return "none"
+	}
+	return meter.String()
+}
+
+func describeGrooves(settings groove.Settings) string {
+	if len(settings) == 0 {
+		return "none"
+	}
+	descriptions := make([]string, len(settings))
+	for i, setting := range settings {
+		descriptions[i] = fmt.Sprintf("%s %d%%", setting.Name, setting.Amount)
+		if len(setting.Lines) > 0 {
+			lines := make([]string, len(setting.Lines))
+			for j, line := range setting.Lines {
+				lines[j] = fmt.Sprint(line + 1)
+			}
+			descriptions[i] += fmt.Sprintf(" lines %s", strings.Join(lines, ","))
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func overlayTreeChanges(partName string, old, new *overlays.Overlay) []string {
+	oldOverlays, newOverlays := overlaysByKey(old), overlaysByKey(new)
+	keys := make([]overlaykey.OverlayPeriodicity, 0, len(oldOverlays)+len(newOverlays))
+	for key := range oldOverlays {
+		keys = append(keys, key)
+	}
+	for key := range newOverlays {
+		if _, exists := oldOverlays[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, overlaykey.Compare)
+
+	var changes []string
+	for _, key := range keys {
+		name := fmt.Sprintf("%s overlay %s", partName, overlaykey.View(key))
+		oldOverlay, inOld := oldOverlays[key]
+		newOverlay, inNew := newOverlays[key]
+		switch {
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("%s removed", name))
+			continue
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("%s added", name))
+			oldOverlay = overlays.InitOverlay(key, nil)
+		}
+		for _, change := range overlays.DiffOverlays(oldOverlay, newOverlay).Changes() {
+			changes = append(changes, fmt.Sprintf("%s: %s", name, change))
+		}
+	}
+	return changes
+}
+
+func overlaysByKey(overlay *overlays.Overlay) map[overlaykey.OverlayPeriodicity]*overlays.Overlay {
+	byKey := make(map[overlaykey.OverlayPeriodicity]*overlays.Overlay)
+	for ; overlay != nil; overlay = overlay.Below {
+		byKey[overlay.Key] = overlay
+	}
+	return byKey
+}
+
+// arrangementChanges compares the sections of two arrangements in the order
+// they play, and the groups around them in the order they start.
+func arrangementChanges(old, new *arrangement.Arrangement) []string {
+	var changes []string
+	if old != nil && new != nil && old.Iterations != new.Iterations {
+		changes = append(changes, fmt.Sprintf("Arrangement iterations %d→%d", old.Iterations, new.Iterations))
+	}
+
+	oldSections, oldGroups := flattenArrangement(old)
+	newSections, newGroups := flattenArrangement(new)
+
+	for i := range max(len(oldSections), len(newSections)) {
+		name := fmt.Sprintf("Section %d", i+1)
+		switch {
+		case i >= len(newSections):
+			changes = append(changes, fmt.Sprintf("%s removed (part %d)", name, oldSections[i].Section.Part+1))
+		case i >= len(oldSections):
+			changes = append(changes, fmt.Sprintf("%s added (part %d)", name, newSections[i].Section.Part+1))
+		default:
+			changes = append(changes, sectionChanges(name, oldSections[i], newSections[i])...)
+		}
+	}
+
+	for i := range max(len(oldGroups), len(newGroups)) {
+		name := fmt.Sprintf("Group %d", i+1)
+		switch {
+		case i >= len(newGroups):
+			changes = append(changes, fmt.Sprintf("%s removed (%d sections)", name, oldGroups[i].CountEndNodes()))
+		case i >= len(oldGroups):
+			changes = append(changes, fmt.Sprintf("%s added (%d sections)", name, newGroups[i].CountEndNodes()))
+		case oldGroups[i].Iterations != newGroups[i].Iterations:
+			changes = append(changes, fmt.Sprintf("%s iterations %d→%d", name, oldGroups[i].Iterations, newGroups[i].Iterations))
+		}
+	}
+	return changes
+}
+
+func describeRamp(ramp arrangement.Ramp) string {
+	if ramp.Tempo == 0 {
+		return "none"
+	}
+	cycles := "the section"
+	if ramp.Cycles > 0 {
+		cycles = fmt.Sprintf("%d cycles", ramp.Cycles)
+	}
+	return fmt.Sprintf("%s to %d over %s", ramp.Curve, ramp.Tempo, cycles)
+}
+
+func sectionChanges(name string, old, new *arrangement.Arrangement) []string {
+	var changes []string
+	if old.Section.Part != new.Section.Part {
+		changes = append(changes, fmt.Sprintf("%s part %d→%d", name, old.Section.Part+1, new.Section.Part+1))
+	}
+	if old.Section.Cycles != new.Section.Cycles {
+		changes = append(changes, fmt.Sprintf("%s cycles %d→%d", name, old.Section.Cycles, new.Section.Cycles))
+	}
+	if old.Section.StartBeat != new.Section.StartBeat {
+		changes = append(changes, fmt.Sprintf("%s start beat %d→%d", name, old.Section.StartBeat, new.Section.StartBeat))
+	}
+	if old.Section.StartCycles != new.Section.StartCycles {
+		changes = append(changes, fmt.Sprintf("%s start cycles %d→%d", name, old.Section.StartCycles, new.Section.StartCycles))
+	}
+	if old.Section.KeepCycles != new.Section.KeepCycles {
+		changes = append(changes, fmt.Sprintf("%s keep cycles %t→%t", name, old.Section.KeepCycles, new.Section.KeepCycles))
+	}
+	if old.Section.Tempo != new.Section.Tempo {
+		changes = append(changes, fmt.Sprintf("%s tempo %d→%d", name, old.Section.Tempo, new.Section.Tempo))
+	}
+	if old.Section.Subdivisions != new.Section.Subdivisions {
+		changes = append(changes, fmt.Sprintf("%s subdivisions %d→%d", name, old.Section.Subdivisions, new.Section.Subdivisions))
+	}
+	if old.Section.Ramp != new.Section.Ramp {
+		changes = append(changes, fmt.Sprintf("%s ramp %s→%s", name, describeRamp(old.Section.Ramp), describeRamp(new.Section.Ramp)))
+	}
+	if old.Iterations != new.Iterations {
+		changes = append(changes, fmt.Sprintf("%s iterations %d→%d", name, old.Iterations, new.Iterations))
+	}
+	return changes
+}
+
+func flattenArrangement(root *arrangement.Arrangement) (sections, groups []*arrangement.Arrangement) {
+	if root == nil {
+		return nil, nil
+	}
+	var walk func(node *arrangement.Arrangement)
+	walk = func(node *arrangement.Arrangement) {
+		for _, child := range node.Nodes {
+			if child.IsEndNode() {
+				sections = append(sections, child)
+			} else {
+				groups = append(groups, child)
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return sections, groups
+}
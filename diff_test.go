@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+)
+
+func diffSequence() sequence.Sequence {
+	verse := arrangement.InitPart("Verse")
+	verse.Overlays.AddNote(grid.GK(0, 0), grid.InitNote())
+	chorus := arrangement.InitPart("Chorus")
+	parts := []arrangement.Part{verse, chorus}
+	return sequence.Sequence{
+		Parts:        &parts,
+		Arrangement:  sequence.InitArrangement(parts),
+		Tempo:        120,
+		Subdivisions: 2,
+		Lines: []grid.LineDefinition{
+			{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote, Name: "Kick"},
+			{Channel: 10, Note: 38, MsgType: grid.MessageTypeNote, Name: "Snare"},
+		},
+		Accents: sequence.PatternAccents{Start: 120, End: 15, Data: []config.Accent{0, 120, 105, 90}},
+	}
+}
+
+func TestDiffSequences(t *testing.T) {
+	t.Run("No changes", func(t *testing.T) {
+		assert.Empty(t, DiffSequences(diffSequence(), diffSequence()))
+	})
+
+	t.Run("Settings, lines and accents", func(t *testing.T) {
+		old, new := diffSequence(), diffSequence()
+		new.Tempo = 128
+		new.Lines[1].Note = 40
+		new.Lines = append(new.Lines, grid.LineDefinition{Channel: 10, Note: 42, MsgType: grid.MessageTypeNote, Name: "Hat"})
+		new.Accents.Data[2] = 100
+
+		assert.Equal(t, []string{
+			"Tempo 120→128",
+			"Line 2 note 38→40",
+			"Line 3 added (Hat channel 10 NOTE 42)",
+			"Accent 2 105→100",
+		}, DiffSequences(old, new))
+	})
+
+	t.Run("Notes of an overlay", func(t *testing.T) {
+		old, new := diffSequence(), diffSequence()
+		key := overlaykey.OverlayPeriodicity{Shift: 3, Interval: 1, Width: 0, StartCycle: 0}
+		for _, s := range []sequence.Sequence{old, new} {
+			part := &(*s.Parts)[1]
+			part.Overlays = part.Overlays.Add(key)
+		}
+		note := grid.InitNote()
+		note.AccentIndex = 3
+		(*new.Parts)[1].Overlays.FindOverlay(key).AddNote(grid.GK(3, 8), note)
+
+		assert.Equal(t, []string{"Part 2 overlay 3/1: added note line 4 beat 9 (accent 3)"}, DiffSequences(old, new))
+	})
+
+	t.Run("Parts", func(t *testing.T) {
+		old, new := diffSequence(), diffSequence()
+		(*new.Parts)[0].Beats = 16
+		(*new.Parts)[0].Grooves = groove.Settings{{Name: "Swing 58%", Amount: 100, Lines: []uint8{0, 1}}}
+		added := arrangement.InitPart("Bridge")
+		added.Overlays = added.Overlays.Add(overlaykey.OverlayPeriodicity{Shift: 2, Interval: 2, Width: 0, StartCycle: 0})
+		parts := append(*new.Parts, added)
+		new.Parts = &parts
+
+		assert.Equal(t, []string{
+			"Part 1 beats 32→16",
+			"Part 1 grooves none→Swing 58% 100% lines 1,2",
+			"Part 3 added (Bridge)",
+			"Part 3 overlay 2/2 added",
+			"Part 3 overlay 1/1 added",
+		}, DiffSequences(old, new))
+	})
+
+	t.Run("Arrangement", func(t *testing.T) {
+		old, new := diffSequence(), diffSequence()
+		new.Arrangement.Nodes[1].Section.Cycles = 4
+		new.Arrangement.Nodes = append(new.Arrangement.Nodes, &arrangement.Arrangement{Section: arrangement.InitSongSection(0), Iterations: 1})
+
+		assert.Equal(t, []string{
+			"Section 2 cycles 1→4",
+			"Section 3 added (part 1)",
+		}, DiffSequences(old, new))
+	})
+
+	t.Run("Snapshots", func(t *testing.T) {
+		old, new := diffSequence(), diffSequence()
+		old.SetSnapshot(old.Capture("Verse", 0))
+		old.SetSnapshot(old.Capture("Old", sequence.WholeSequence))
+		new.SetSnapshot(new.Capture("Verse", 1))
+		new.SetSnapshot(new.Capture("Chorus", 1))
+
+		assert.Equal(t, []string{
+			`Snapshot "Old" removed`,
+			`Snapshot "Verse" changed`,
+			`Snapshot "Chorus" added (part 2)`,
+		}, DiffSequences(old, new))
+	})
+}
+
+func TestOverlayTreeChanges(t *testing.T) {
+	removed := overlays.InitOverlay(overlaykey.ROOT, nil)
+	assert.Equal(t, []string{"Part 1 overlay 1/1 removed"}, overlayTreeChanges("Part 1", removed, nil))
+}
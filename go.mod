@@ -13,6 +13,7 @@ require (
 	github.com/spf13/cobra v1.10.1
 	github.com/stretchr/testify v1.10.0
 	gitlab.com/gomidi/midi/v2 v2.3.16
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 replace github.com/aarzilli/golua => ./vndr/github.com/aarzilli/golua
@@ -42,5 +43,4 @@ require (
 	golang.org/x/exp v0.0.0-20251009144603-d2f985daa21b // indirect
 	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -26,6 +26,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/chriserin/sq/internal/overlaykey"
 	"github.com/chriserin/sq/internal/overlays"
 )
@@ -417,6 +418,11 @@ const (
 	SectionStartCycle
 	SectionStartBeat
 	SectionKeepCycles
+	SectionTempo
+	SectionSubdivisions
+	SectionRampTempo
+	SectionRampCycles
+	SectionRampCurve
 )
 
 type Model struct {
@@ -427,6 +433,16 @@ type Model struct {
 	Root              *Arrangement
 	parts             *[]Part
 	depthCursor       int
+	// The tempo and subdivisions of the sequence, that a section without
+	// its own starts from
+	tempo        int
+	subdivisions int
+}
+
+// SetTempo sets the tempo and subdivisions of the sequence.
+func (m *Model) SetTempo(tempo int, subdivisions int) {
+	m.tempo = tempo
+	m.subdivisions = subdivisions
 }
 
 func (m *Model) Escape() {
@@ -676,6 +692,7 @@ type keymap struct {
 	MovePartDown key.Binding
 	MovePartUp   key.Binding
 	RenamePart   key.Binding
+	ClearTempo   key.Binding
 }
 
 var keys = keymap{
@@ -690,6 +707,7 @@ var keys = keymap{
 	MovePartDown: Key("Move Part Down", "J"),
 	MovePartUp:   Key("Move Part Up", "K"),
 	RenamePart:   Key("Rename Part", "R"),
+	ClearTempo:   Key("Clear Tempo", "x"),
 }
 
 func Key(help string, keyboardKey ...string) key.Binding {
@@ -702,7 +720,7 @@ func IsSectionChangeMessage(msg tea.Msg, isEndNode bool) bool {
 	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		return key.Matches(msg, keys.Increase, keys.Decrease)
+		return key.Matches(msg, keys.Increase, keys.Decrease, keys.ClearTempo)
 	}
 	return false
 }
@@ -846,7 +864,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				m.oldCursor.attribute--
 			}
 		case Is(msg, keys.CursorRight):
-			if m.oldCursor.attribute < 3 {
+			if m.oldCursor.attribute < SectionRampCurve {
 				m.oldCursor.attribute++
 			}
 		case Is(msg, keys.Increase):
@@ -862,6 +880,16 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					currentNode.Section.IncreaseCycles()
 				case SectionKeepCycles:
 					currentNode.Section.ToggleKeepCycles()
+				case SectionTempo:
+					currentNode.Section.IncreaseTempo(m.tempo, 1)
+				case SectionSubdivisions:
+					currentNode.Section.IncreaseSubdivisions(m.subdivisions, 1)
+				case SectionRampTempo:
+					currentNode.Section.IncreaseRampTempo(m.tempo, 1)
+				case SectionRampCycles:
+					currentNode.Section.IncreaseRampCycles(1)
+				case SectionRampCurve:
+					currentNode.Section.ToggleRampCurve()
 				}
 			} else {
 				// For parent nodes, increase iterations
@@ -879,10 +907,24 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					currentNode.Section.DecreaseCycles()
 				case SectionKeepCycles:
 					currentNode.Section.ToggleKeepCycles()
+				case SectionTempo:
+					currentNode.Section.IncreaseTempo(m.tempo, -1)
+				case SectionSubdivisions:
+					currentNode.Section.IncreaseSubdivisions(m.subdivisions, -1)
+				case SectionRampTempo:
+					currentNode.Section.IncreaseRampTempo(m.tempo, -1)
+				case SectionRampCycles:
+					currentNode.Section.IncreaseRampCycles(-1)
+				case SectionRampCurve:
+					currentNode.Section.ToggleRampCurve()
 				}
 			} else {
 				m.Cursor[m.depthCursor].DecreaseIterations()
 			}
+		case Is(msg, keys.ClearTempo):
+			if m.depthCursor+1 == len(m.Cursor) {
+				m.Cursor.GetCurrentNode().Section.ClearTempo()
+			}
 		case Is(msg, keys.GroupNodes):
 			m.GroupNodes()
 		case Is(msg, keys.DeleteNode):
@@ -913,6 +955,12 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					m.SetSectionStartCycles(currentNode, number)
 				case SectionCycles:
 					m.SetSectionCycles(currentNode, number)
+				case SectionTempo:
+					m.SetSectionTempo(currentNode, number)
+				case SectionRampTempo:
+					m.SetSectionRampTempo(currentNode, number)
+				case SectionRampCycles:
+					m.SetSectionRampCycles(currentNode, number)
 				}
 			} else {
 				m.SetGroupIterations(m.Cursor[m.depthCursor], number)
@@ -948,6 +996,18 @@ func (m Model) SetSectionStartCycles(arr *Arrangement, number int) {
 	arr.Section.StartCycles = m.clamp(m.UnshiftDigit(arr.Section.StartCycles, number), 1, 999)
 }
 
+func (m *Model) SetSectionTempo(arr *Arrangement, number int) {
+	arr.Section.Tempo = m.clamp(m.UnshiftDigit(arr.Section.Tempo, number), minTempo, maxTempo)
+}
+
+func (m *Model) SetSectionRampTempo(arr *Arrangement, number int) {
+	arr.Section.Ramp.Tempo = m.clamp(m.UnshiftDigit(arr.Section.Ramp.Tempo, number), minTempo, maxTempo)
+}
+
+func (m *Model) SetSectionRampCycles(arr *Arrangement, number int) {
+	arr.Section.Ramp.Cycles = m.clamp(m.UnshiftDigit(arr.Section.Ramp.Cycles, number), 0, 127)
+}
+
 func (m *Model) SetSectionStartBeats(arr *Arrangement, number int) {
 	arr.Section.StartBeat = m.clamp(m.UnshiftDigit(arr.Section.StartBeat, number), 1, 999)
 }
@@ -998,6 +1058,10 @@ type SongSection struct {
 	StartBeat   int
 	StartCycles int
 	KeepCycles  bool
+	// A tempo and subdivisions of 0 play at those of the sequence
+	Tempo        int
+	Subdivisions int
+	Ramp         Ramp
 }
 
 func InitSongSection(part int) SongSection {
@@ -1060,6 +1124,8 @@ type Part struct {
 	Overlays *overlays.Overlay
 	Beats    uint8
 	Name     string
+	Grooves  groove.Settings
+	Meter    Meter
 }
 
 func InitPart(name string) Part {
@@ -1493,3 +1493,122 @@ func TestAllLastSiblings(t *testing.T) {
 		})
 	}
 }
+
+// TestUndoRecord tests that undos keep referring to the same nodes once read back
+func TestUndoRecord(t *testing.T) {
+	kept := &Arrangement{Section: SongSection{Part: 0, Cycles: 1}, Iterations: 1}
+	removed := &Arrangement{Section: SongSection{Part: 1, Cycles: 2}, Iterations: 1}
+	root := &Arrangement{Iterations: 1, Nodes: []*Arrangement{kept, removed}}
+	undo := TreeUndo{undoTree: CreateUndoTree(root), Cursor: ArrCursor{root, removed}, depthCursor: 1}
+	root.Nodes = []*Arrangement{kept}
+
+	ids := NodeIDs{}
+	rootID := ids.ID(root)
+	record := ids.Record(undo)
+	records := ids.Records()
+
+	t.Run("Binds the current tree and creates removed nodes", func(t *testing.T) {
+		loadedKept := &Arrangement{Section: kept.Section, Iterations: 1}
+		loadedRoot := &Arrangement{Iterations: 1, Nodes: []*Arrangement{loadedKept}}
+
+		nodes, err := ResolveNodes(records, rootID, loadedRoot)
+		assert.NoError(t, err)
+		restored, err := nodes.Undoable(record)
+		assert.NoError(t, err)
+
+		restoredRoot := Convert(restored.(TreeUndo).undoTree)
+		assert.Same(t, loadedRoot, restoredRoot)
+		assert.Same(t, loadedKept, restoredRoot.Nodes[0])
+		assert.Equal(t, removed.Section, restoredRoot.Nodes[1].Section)
+		assert.Same(t, restoredRoot.Nodes[1], restored.(TreeUndo).Cursor[1])
+	})
+
+	t.Run("A tree of another shape is not bound", func(t *testing.T) {
+		_, err := ResolveNodes(records, rootID, &Arrangement{Iterations: 1})
+		assert.Error(t, err)
+	})
+}
+
+func TestTempoAt(t *testing.T) {
+	t.Run("section without a tempo plays the sequence tempo", func(t *testing.T) {
+		assert.Equal(t, 120, SongSection{Cycles: 4}.TempoAt(120, 2))
+		assert.Equal(t, 90, SongSection{Cycles: 4, Tempo: 90}.TempoAt(120, 2))
+	})
+
+	t.Run("linear ramp over all the cycles", func(t *testing.T) {
+		section := SongSection{Cycles: 4, Tempo: 100, Ramp: Ramp{Tempo: 140}}
+		assert.Equal(t, 100, section.TempoAt(120, 0))
+		assert.Equal(t, 120, section.TempoAt(120, 2))
+		assert.Equal(t, 140, section.TempoAt(120, 4))
+	})
+
+	t.Run("ramp holds its tempo after its cycles", func(t *testing.T) {
+		section := SongSection{Cycles: 4, Ramp: Ramp{Tempo: 60, Cycles: 2}}
+		assert.Equal(t, 90, section.TempoAt(120, 1))
+		assert.Equal(t, 60, section.TempoAt(120, 3))
+	})
+
+	t.Run("exponential ramp", func(t *testing.T) {
+		section := SongSection{Cycles: 2, Tempo: 60, Ramp: Ramp{Tempo: 240, Curve: CurveExponential}}
+		assert.Equal(t, 120, section.TempoAt(120, 1))
+		assert.Equal(t, 240, section.TempoAt(120, 2))
+	})
+
+	t.Run("increase from the sequence tempo", func(t *testing.T) {
+		section := SongSection{}
+		section.IncreaseTempo(120, 5)
+		assert.Equal(t, 125, section.Tempo)
+		section.IncreaseRampTempo(120, -1)
+		assert.Equal(t, 124, section.Ramp.Tempo)
+		section.IncreaseSubdivisions(2, 10)
+		assert.Equal(t, 8, section.Subdivisions)
+		section.ClearTempo()
+		assert.False(t, section.HasTempo())
+	})
+}
+
+func TestMeter(t *testing.T) {
+	t.Run("String and ParseMeter round trip", func(t *testing.T) {
+		for _, meter := range []Meter{{4, 4}, {7, 8}, {5, 4}, {6, 8}, {12, 16}} {
+			parsed, ok := ParseMeter(meter.String())
+			assert.True(t, ok)
+			assert.Equal(t, meter, parsed)
+		}
+	})
+
+	t.Run("ParseMeter rejects unknown meters", func(t *testing.T) {
+		for _, s := range []string{"4", "0/4", "4/3", "33/4", "4/"} {
+			_, ok := ParseMeter(s)
+			assert.False(t, ok, s)
+		}
+	})
+
+	t.Run("Grouping", func(t *testing.T) {
+		assert.Equal(t, []uint8{1, 1, 1, 1}, Meter{4, 4}.Grouping())
+		assert.Equal(t, []uint8{3, 3}, Meter{6, 8}.Grouping())
+		assert.Equal(t, []uint8{2, 2, 3}, Meter{7, 8}.Grouping())
+		assert.Equal(t, []uint8{1, 1, 1, 1, 1}, Meter{5, 4}.Grouping())
+	})
+
+	t.Run("Downbeats and pulses of 7/8 in eighths", func(t *testing.T) {
+		meter := Meter{7, 8}
+		var downbeats, pulses []uint8
+		for step := range uint8(14) {
+			downbeat, pulse := meter.Downbeat(step, 2)
+			if downbeat {
+				downbeats = append(downbeats, step)
+			}
+			if pulse {
+				pulses = append(pulses, step)
+			}
+		}
+		assert.Equal(t, []uint8{0, 7}, downbeats)
+		assert.Equal(t, []uint8{0, 2, 4, 7, 9, 11}, pulses)
+		assert.Equal(t, 1, meter.Bar(8, 2))
+	})
+
+	t.Run("Bars that do not fall on whole steps", func(t *testing.T) {
+		assert.Equal(t, 0, Meter{7, 8}.BarSteps(1))
+		assert.Equal(t, 16, Meter{4, 4}.BarSteps(4))
+	})
+}
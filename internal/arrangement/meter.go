@@ -0,0 +1,151 @@
+package arrangement
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Meter is the time signature of a part.  The zero Meter is no time
+// signature, a part without one is an undivided row of beats.
+type Meter struct {
+	Numerator   uint8
+	Denominator uint8
+}
+
+// The denominators that can be chosen, as note values
+var denominators = []uint8{2, 4, 8, 16}
+
+const maxNumerator = 32
+
+func (m Meter) IsSet() bool {
+	return m.Numerator > 0 && m.Denominator > 0
+}
+
+func (m Meter) String() string {
+	return fmt.Sprintf("%d/%d", m.Numerator, m.Denominator)
+}
+
+// ParseMeter returns the meter written by String.
+func ParseMeter(s string) (Meter, bool) {
+	numerator, denominator, found := strings.Cut(s, "/")
+	if !found {
+		return Meter{}, false
+	}
+	n, err := strconv.ParseUint(numerator, 10, 8)
+	if err != nil || n < 1 || n > maxNumerator {
+		return Meter{}, false
+	}
+	d, err := strconv.ParseUint(denominator, 10, 8)
+	if err != nil || !slices.Contains(denominators, uint8(d)) {
+		return Meter{}, false
+	}
+	return Meter{Numerator: uint8(n), Denominator: uint8(d)}, true
+}
+
+// Grouping returns how the notes of a bar are grouped into pulses.  Compound
+// meters such as 6/8 and 12/8 group in threes, odd meters of eighths and
+// sixteenths group in twos ending with a three, 7/8 as 2+2+3, and any other
+// meter pulses on every note.
+func (m Meter) Grouping() []uint8 {
+	if !m.IsSet() {
+		return nil
+	}
+	n := int(m.Numerator)
+	var groups []uint8
+	switch {
+	case m.Denominator >= 8 && n > 3 && n%3 == 0:
+		for range n / 3 {
+			groups = append(groups, 3)
+		}
+	case m.Denominator >= 8 && n > 3 && n%2 == 1:
+		for range (n - 3) / 2 {
+			groups = append(groups, 2)
+		}
+		groups = append(groups, 3)
+	default:
+		for range n {
+			groups = append(groups, 1)
+		}
+	}
+	return groups
+}
+
+// noteSteps returns the number of grid steps a note of the meter lasts when a
+// beat of the tempo is divided into subdivisions, or false when a note does
+// not fall on a whole step.
+func (m Meter) noteSteps(subdivisions int) (int, bool) {
+	steps := 4 * subdivisions
+	if !m.IsSet() || steps%int(m.Denominator) != 0 {
+		return 0, false
+	}
+	return steps / int(m.Denominator), true
+}
+
+// BarSteps returns the number of grid steps of a bar, or 0 when the meter does
+// not divide the grid into whole steps.
+func (m Meter) BarSteps(subdivisions int) int {
+	noteSteps, ok := m.noteSteps(subdivisions)
+	if !ok {
+		return 0
+	}
+	return noteSteps * int(m.Numerator)
+}
+
+// Downbeat reports whether a step is the first step of a bar, and pulse
+// whether it is the first step of a group of the bar.
+func (m Meter) Downbeat(step uint8, subdivisions int) (downbeat bool, pulse bool) {
+	noteSteps, ok := m.noteSteps(subdivisions)
+	if !ok || int(step)%noteSteps != 0 {
+		return false, false
+	}
+	note := (int(step) / noteSteps) % int(m.Numerator)
+	if note == 0 {
+		return true, true
+	}
+	start := 0
+	for _, group := range m.Grouping() {
+		if start == note {
+			return false, true
+		}
+		start += int(group)
+	}
+	return false, false
+}
+
+// Bar returns the bar a step falls in, counting from 0.
+func (m Meter) Bar(step uint8, subdivisions int) int {
+	barSteps := m.BarSteps(subdivisions)
+	if barSteps == 0 {
+		return 0
+	}
+	return int(step) / barSteps
+}
+
+// IncreaseNumerator changes the number of notes of a bar, a part without a
+// meter starts at 4/4 and decreasing below one note removes the meter.
+func (m *Meter) IncreaseNumerator(modifier int) {
+	if !m.IsSet() {
+		if modifier > 0 {
+			*m = Meter{Numerator: 4, Denominator: 4}
+		}
+		return
+	}
+	numerator := int(m.Numerator) + modifier
+	if numerator < 1 {
+		*m = Meter{}
+		return
+	}
+	m.Numerator = uint8(min(numerator, maxNumerator))
+}
+
+// IncreaseDenominator moves to the next or previous note value.
+func (m *Meter) IncreaseDenominator(modifier int) {
+	if !m.IsSet() {
+		return
+	}
+	index := slices.Index(denominators, m.Denominator)
+	index = min(max(index+modifier, 0), len(denominators)-1)
+	m.Denominator = denominators[index]
+}
@@ -0,0 +1,140 @@
+package arrangement
+
+import (
+	"math"
+)
+
+// Curve is the shape of a tempo ramp.
+type Curve uint8
+
+const (
+	CurveLinear Curve = iota
+	CurveExponential
+)
+
+func (c Curve) String() string {
+	switch c {
+	case CurveExponential:
+		return "exponential"
+	}
+	return "linear"
+}
+
+// ParseCurve returns the curve written by String.
+func ParseCurve(s string) (Curve, bool) {
+	switch s {
+	case "linear":
+		return CurveLinear, true
+	case "exponential":
+		return CurveExponential, true
+	}
+	return CurveLinear, false
+}
+
+// Ramp moves the tempo of a section to the tempo of the ramp over a number of
+// cycles, and keeps it there for the rest of the section.
+type Ramp struct {
+	// 0 is no ramp
+	Tempo int
+	// 0 ramps over all the cycles of the section
+	Cycles int
+	Curve  Curve
+}
+
+// The tempos that can be chosen for a section, as for the sequence
+const (
+	minTempo = 1
+	maxTempo = 300
+)
+
+// TempoAt returns the tempo of the section after it has played a number of
+// cycles, in fractions of a cycle.  A section without a tempo of its own
+// starts at the tempo of the sequence.
+func (ss SongSection) TempoAt(tempo int, cycles float64) int {
+	if ss.Tempo > 0 {
+		tempo = ss.Tempo
+	}
+	if ss.Ramp.Tempo <= 0 || tempo <= 0 {
+		return tempo
+	}
+
+	rampCycles := ss.Ramp.Cycles
+	if rampCycles <= 0 {
+		rampCycles = max(ss.Cycles, 1)
+	}
+	progress := min(max(cycles/float64(rampCycles), 0), 1)
+
+	start, end := float64(tempo), float64(ss.Ramp.Tempo)
+	var ramped float64
+	switch ss.Ramp.Curve {
+	case CurveExponential:
+		ramped = start * math.Pow(end/start, progress)
+	default:
+		ramped = start + (end-start)*progress
+	}
+	return int(math.Round(ramped))
+}
+
+// SubdivisionsOr returns the subdivisions of the section, or the subdivisions
+// of the sequence when the section has none of its own.
+func (ss SongSection) SubdivisionsOr(subdivisions int) int {
+	if ss.Subdivisions > 0 {
+		return ss.Subdivisions
+	}
+	return subdivisions
+}
+
+// HasTempo reports whether the section changes the tempo or subdivisions of
+// the sequence.
+func (ss SongSection) HasTempo() bool {
+	return ss.Tempo > 0 || ss.Subdivisions > 0 || ss.Ramp.Tempo > 0
+}
+
+// A section without a tempo of its own increases from the tempo of the
+// sequence
+func (ss *SongSection) IncreaseTempo(tempo int, modifier int) {
+	ss.Tempo = changeTempo(ss.Tempo, tempo, modifier)
+}
+
+func (ss *SongSection) IncreaseSubdivisions(subdivisions int, modifier int) {
+	if ss.Subdivisions == 0 {
+		ss.Subdivisions = subdivisions
+	}
+	ss.Subdivisions = min(max(ss.Subdivisions+modifier, 1), 8)
+}
+
+// IncreaseRampTempo changes the tempo the section ramps to, a ramp starts
+// from the tempo of the section.
+func (ss *SongSection) IncreaseRampTempo(tempo int, modifier int) {
+	if ss.Tempo > 0 {
+		tempo = ss.Tempo
+	}
+	ss.Ramp.Tempo = changeTempo(ss.Ramp.Tempo, tempo, modifier)
+}
+
+func (ss *SongSection) IncreaseRampCycles(modifier int) {
+	ss.Ramp.Cycles = min(max(ss.Ramp.Cycles+modifier, 0), 127)
+}
+
+func (ss *SongSection) ToggleRampCurve() {
+	if ss.Ramp.Curve == CurveLinear {
+		ss.Ramp.Curve = CurveExponential
+	} else {
+		ss.Ramp.Curve = CurveLinear
+	}
+}
+
+// ClearTempo removes the tempo, subdivisions and ramp of the section, so that
+// it plays at the tempo of the sequence.
+func (ss *SongSection) ClearTempo() {
+	ss.Tempo = 0
+	ss.Subdivisions = 0
+	ss.Ramp = Ramp{}
+}
+
+func changeTempo(value int, tempo int, modifier int) int {
+	if value == 0 {
+		value = tempo
+	}
+	return min(max(value+modifier, minTempo), maxTempo)
+}
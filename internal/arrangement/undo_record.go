@@ -0,0 +1,250 @@
+package arrangement
+
+import (
+	"fmt"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+)
+
+// NodeIDs numbers the nodes that undos refer to, so that undos referring to
+// the same node still do once they are read back.  Nodes removed from the
+// tree keep their number, a later undo puts them back.
+type NodeIDs map[*Arrangement]int
+
+// ID returns the number of a node, numbering it when it is new.  Nil is 0.
+func (ids NodeIDs) ID(node *Arrangement) int {
+	if node == nil {
+		return 0
+	}
+	id, exists := ids[node]
+	if !exists {
+		id = len(ids) + 1
+		ids[node] = id
+	}
+	return id
+}
+
+// Cursor returns the numbers of the nodes of a cursor.
+func (ids NodeIDs) Cursor(cursor ArrCursor) []int {
+	nodeIDs := make([]int, len(cursor))
+	for i, node := range cursor {
+		nodeIDs[i] = ids.ID(node)
+	}
+	return nodeIDs
+}
+
+// NodeRecord is a numbered node with its children as they are now.
+type NodeRecord struct {
+	ID         int         `json:"id"`
+	Section    SongSection `json:"section"`
+	Iterations int         `json:"iterations"`
+	Nodes      []int       `json:"nodes,omitempty"`
+}
+
+// Records returns every numbered node, numbering the children of the nodes
+// as well.
+func (ids NodeIDs) Records() []NodeRecord {
+	nodes := make([]*Arrangement, 0, len(ids))
+	for node := range ids {
+		nodes = append(nodes, node)
+	}
+	byID := make([]*Arrangement, len(ids)+1)
+	for _, node := range nodes {
+		byID[ids[node]] = node
+	}
+
+	var records []NodeRecord
+	for id := 1; id < len(byID); id++ {
+		node := byID[id]
+		record := NodeRecord{ID: id, Section: node.Section, Iterations: node.Iterations}
+		for _, child := range node.Nodes {
+			childID := ids.ID(child)
+			if childID == len(byID) {
+				byID = append(byID, child)
+			}
+			record.Nodes = append(record.Nodes, childID)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Nodes are the nodes of read back records by their number.
+type Nodes map[int]*Arrangement
+
+// ResolveNodes creates the nodes of records.  The nodes of the tree the
+// records were made from are the nodes of root, which must have the same
+// shape; the other nodes are created from their records.
+func ResolveNodes(records []NodeRecord, rootID int, root *Arrangement) (Nodes, error) {
+	byID := make(map[int]NodeRecord, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+
+	nodes := Nodes{}
+	var bind func(id int, node *Arrangement) error
+	bind = func(id int, node *Arrangement) error {
+		record, exists := byID[id]
+		if !exists || len(record.Nodes) != len(node.Nodes) {
+			return invalidRecord("the arrangement does not match node %d", id)
+		}
+		nodes[id] = node
+		for i, childID := range record.Nodes {
+			if err := bind(childID, node.Nodes[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := bind(rootID, root); err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if _, exists := nodes[record.ID]; !exists {
+			nodes[record.ID] = &Arrangement{Section: record.Section, Iterations: record.Iterations}
+		}
+	}
+	for _, record := range records {
+		node := nodes[record.ID]
+		if len(node.Nodes) > 0 {
+			continue
+		}
+		for _, childID := range record.Nodes {
+			child, exists := nodes[childID]
+			if !exists {
+				return nil, invalidRecord("node %d has an unknown child %d", record.ID, childID)
+			}
+			node.Nodes = append(node.Nodes, child)
+		}
+	}
+	return nodes, nil
+}
+
+func (nodes Nodes) node(id int) (*Arrangement, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	node, exists := nodes[id]
+	if !exists {
+		return nil, invalidRecord("node %d is unknown", id)
+	}
+	return node, nil
+}
+
+// Cursor returns the cursor of the numbers of its nodes.
+func (nodes Nodes) Cursor(nodeIDs []int) (ArrCursor, error) {
+	cursor := make(ArrCursor, len(nodeIDs))
+	for i, id := range nodeIDs {
+		node, err := nodes.node(id)
+		if err != nil {
+			return nil, err
+		}
+		cursor[i] = node
+	}
+	return cursor, nil
+}
+
+// UndoRecord is the serializable form of an arrangement undo.
+type UndoRecord struct {
+	Kind        string      `json:"kind"`
+	Tree        *TreeRecord `json:"tree,omitempty"`
+	Node        int         `json:"node,omitempty"`
+	Iterations  int         `json:"iterations,omitempty"`
+	Section     SongSection `json:"section"`
+	Cursor      []int       `json:"cursor"`
+	DepthCursor int         `json:"depthCursor"`
+}
+
+// TreeRecord is the shape of a tree at the time of an undo.
+type TreeRecord struct {
+	Node  int          `json:"node"`
+	Nodes []TreeRecord `json:"nodes,omitempty"`
+}
+
+const (
+	undoKindTree    = "tree"
+	undoKindGroup   = "group"
+	undoKindSection = "section"
+)
+
+// Record returns the serializable form of an undo, numbering the nodes it
+// refers to.
+func (ids NodeIDs) Record(undo Undoable) UndoRecord {
+	switch undo := undo.(type) {
+	case TreeUndo:
+		tree := ids.tree(undo.undoTree)
+		return UndoRecord{Kind: undoKindTree, Tree: &tree, Cursor: ids.Cursor(undo.Cursor), DepthCursor: undo.depthCursor}
+	case GroupUndo:
+		return UndoRecord{Kind: undoKindGroup, Node: ids.ID(undo.arr), Iterations: undo.iterations, Cursor: ids.Cursor(undo.Cursor), DepthCursor: undo.depthCursor}
+	case SectionUndo:
+		return UndoRecord{Kind: undoKindSection, Node: ids.ID(undo.arr), Section: undo.section, Cursor: ids.Cursor(undo.Cursor), DepthCursor: undo.depthCursor}
+	}
+	return UndoRecord{}
+}
+
+func (ids NodeIDs) tree(undoTree UndoTree) TreeRecord {
+	record := TreeRecord{Node: ids.ID(undoTree.arrRef)}
+	for _, node := range undoTree.nodes {
+		record.Nodes = append(record.Nodes, ids.tree(node))
+	}
+	return record
+}
+
+// Undoable returns the undo of a record.  A record without a kind is no undo.
+func (nodes Nodes) Undoable(record UndoRecord) (Undoable, error) {
+	if record.Kind == "" {
+		return nil, nil
+	}
+	cursor, err := nodes.Cursor(record.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.Kind {
+	case undoKindTree:
+		if record.Tree == nil {
+			return nil, invalidRecord("tree undo without a tree")
+		}
+		tree, err := nodes.tree(*record.Tree)
+		if err != nil {
+			return nil, err
+		}
+		return TreeUndo{undoTree: tree, Cursor: cursor, depthCursor: record.DepthCursor}, nil
+	case undoKindGroup:
+		node, err := nodes.node(record.Node)
+		if err != nil {
+			return nil, err
+		}
+		return GroupUndo{arr: node, iterations: record.Iterations, Cursor: cursor, depthCursor: record.DepthCursor}, nil
+	case undoKindSection:
+		node, err := nodes.node(record.Node)
+		if err != nil {
+			return nil, err
+		}
+		return SectionUndo{arr: node, section: record.Section, Cursor: cursor, depthCursor: record.DepthCursor}, nil
+	}
+	return nil, invalidRecord("unknown arrangement undo %q", record.Kind)
+}
+
+func (nodes Nodes) tree(record TreeRecord) (UndoTree, error) {
+	node, err := nodes.node(record.Node)
+	if err != nil {
+		return UndoTree{}, err
+	}
+	undoTree := UndoTree{arrRef: node, nodes: make([]UndoTree, 0, len(record.Nodes))}
+	for _, child := range record.Nodes {
+		childTree, err := nodes.tree(child)
+		if err != nil {
+			return UndoTree{}, err
+		}
+		undoTree.nodes = append(undoTree.nodes, childTree)
+	}
+	return undoTree, nil
+}
+
+func invalidRecord(format string, a ...any) error {
+	message := fmt.Sprintf(format, a...)
+	return fault.New(message, fmsg.WithDesc("invalid undo history", message))
+}
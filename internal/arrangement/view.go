@@ -19,6 +19,11 @@ func (m Model) View(loopingNode *Arrangement) string {
 		lipgloss.PlaceHorizontal(12, lipgloss.Right, themes.AppTitleStyle.Render("⟳ Start"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
 		lipgloss.PlaceHorizontal(12, lipgloss.Right, themes.AppTitleStyle.Render("Start Beat"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
 		lipgloss.PlaceHorizontal(12, lipgloss.Right, themes.AppTitleStyle.Render("⟳ Keep"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
+		lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, themes.AppTitleStyle.Render("Tempo"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
+		lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, themes.AppTitleStyle.Render("Subdiv"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
+		lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, themes.AppTitleStyle.Render("Ramp"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
+		lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, themes.AppTitleStyle.Render("⟳ Ramp"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
+		lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, themes.AppTitleStyle.Render("Curve"), lipgloss.WithWhitespaceChars("─"), lipgloss.WithWhitespaceForeground(themes.ArrangementSelectedLineColor)),
 	)
 	buf.WriteString(header)
 	buf.WriteString("\n")
@@ -28,6 +33,10 @@ func (m Model) View(loopingNode *Arrangement) string {
 	return buf.String()
 }
 
+// The tempo columns are narrower than the cycle columns, to keep the
+// view from growing too wide
+const tempoWidth = 9
+
 // Style definitions for node rendering
 
 // Recursively render a node and its children
@@ -77,6 +86,9 @@ func (m Model) renderNode(buf *strings.Builder, node *Arrangement, depth int, is
 			lipgloss.PlaceHorizontal(12, lipgloss.Right, "", options...),
 			lipgloss.PlaceHorizontal(12, lipgloss.Right, "", options...),
 		)
+		for range SectionRampCurve - SectionKeepCycles {
+			row = lipgloss.JoinHorizontal(lipgloss.Top, row, lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, "", options...))
+		}
 
 		buf.WriteString(themes.NodeRowStyle.Render(row))
 		buf.WriteString("\n")
@@ -182,6 +194,19 @@ func (m Model) renderNode(buf *strings.Builder, node *Arrangement, depth int, is
 		row = lipgloss.JoinHorizontal(lipgloss.Top, row,
 			lipgloss.PlaceHorizontal(12, lipgloss.Right, keepText, options...))
 
+		// Handle tempo and ramp
+		for _, attribute := range []SectionAttribute{SectionTempo, SectionSubdivisions, SectionRampTempo, SectionRampCycles, SectionRampCurve} {
+			value := tempoAttribute(songSection, attribute)
+			var text string
+			if isSelected && m.Focus && m.oldCursor.attribute == attribute {
+				text = selectedStyle.Render(value)
+			} else {
+				text = themes.NumberStyle.Render(value)
+			}
+			row = lipgloss.JoinHorizontal(lipgloss.Top, row,
+				lipgloss.PlaceHorizontal(tempoWidth, lipgloss.Right, text, options...))
+		}
+
 		buf.WriteString(themes.NodeRowStyle.Render(row))
 		buf.WriteString("\n")
 	} else {
@@ -191,6 +216,36 @@ func (m Model) renderNode(buf *strings.Builder, node *Arrangement, depth int, is
 	}
 }
 
+// tempoAttribute returns the value of a tempo attribute of a section, a dash
+// for a value the section takes from the sequence.
+func tempoAttribute(section SongSection, attribute SectionAttribute) string {
+	dashed := func(value int) string {
+		if value == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%d", value)
+	}
+	switch attribute {
+	case SectionTempo:
+		return dashed(section.Tempo)
+	case SectionSubdivisions:
+		return dashed(section.Subdivisions)
+	case SectionRampTempo:
+		return dashed(section.Ramp.Tempo)
+	case SectionRampCycles:
+		if section.Ramp.Cycles == 0 {
+			return "all"
+		}
+		return fmt.Sprintf("%d", section.Ramp.Cycles)
+	case SectionRampCurve:
+		if section.Ramp.Curve == CurveExponential {
+			return "exp"
+		}
+		return "lin"
+	}
+	return ""
+}
+
 func (p Part) GetName() string {
 	return p.Name
 }
@@ -0,0 +1,105 @@
+// Package autosave keeps the edits of a sequence safe between explicit saves.
+// Unsaved changes are written periodically to a recovery file next to the
+// sequence file, which is offered back on the next launch when it is newer
+// than the sequence file.  Explicit saves keep a number of rotating backups
+// of the previous versions of the file.
+package autosave
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+)
+
+// The recovery file of a sequence that has not been saved yet
+const untitled = "untitled.sq"
+
+// RecoveryFile returns the hidden sidecar file that the unsaved changes of a
+// sequence file are written to.
+func RecoveryFile(filename string) string {
+	if filename == "" {
+		filename = untitled
+	}
+	dir, base := filepath.Split(filename)
+	return filepath.Join(dir, fmt.Sprintf(".%s.recovery", base))
+}
+
+// HasRecovery is true when the recovery file of a sequence file exists and
+// was written after the sequence file was last saved.
+func HasRecovery(filename string) bool {
+	recoveryInfo, err := os.Stat(RecoveryFile(filename))
+	if err != nil || recoveryInfo.IsDir() {
+		return false
+	}
+	fileInfo, err := os.Stat(filename)
+	if err != nil {
+		return true
+	}
+	// Saving removes the recovery file, one written in the same instant
+	// as the save was written after it
+	return !recoveryInfo.ModTime().Before(fileInfo.ModTime())
+}
+
+// RemoveRecovery removes the recovery file of a sequence file, once its
+// changes are saved.
+func RemoveRecovery(filename string) error {
+	err := os.Remove(RecoveryFile(filename))
+	if err != nil && !os.IsNotExist(err) {
+		return fault.Wrap(err, fmsg.WithDesc("cannot remove recovery file", fmt.Sprintf("Could not remove the recovery file of %s", filename)))
+	}
+	return nil
+}
+
+// BackupFile returns the file of the nth most recent backup of a sequence
+// file, keeping the extension so that the backup opens in the same format.
+func BackupFile(filename string, n int) string {
+	extension := filepath.Ext(filename)
+	return fmt.Sprintf("%s~%d%s", strings.TrimSuffix(filename, extension), n, extension)
+}
+
+// RotateBackups copies a sequence file to its first backup before it is
+// overwritten, moving each older backup one place along and dropping the
+// oldest of count backups.
+func RotateBackups(filename string, count int) error {
+	if count <= 0 || filename == "" {
+		return nil
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+
+	for n := count - 1; n >= 1; n-- {
+		err := os.Rename(BackupFile(filename, n), BackupFile(filename, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fault.Wrap(err, fmsg.WithDesc("cannot rotate backups", fmt.Sprintf("Could not move backup %s", BackupFile(filename, n))))
+		}
+	}
+
+	if err := copyFile(filename, BackupFile(filename, 1)); err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot write backup", fmt.Sprintf("Could not back up %s", filename)))
+	}
+	return nil
+}
+
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
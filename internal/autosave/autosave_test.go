@@ -0,0 +1,83 @@
+package autosave
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryFile(t *testing.T) {
+	assert.Equal(t, filepath.Join("songs", ".song.sq.recovery"), RecoveryFile(filepath.Join("songs", "song.sq")))
+	assert.Equal(t, ".untitled.sq.recovery", RecoveryFile(""))
+}
+
+func TestHasRecovery(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "song.sq")
+
+	t.Run("No recovery file", func(t *testing.T) {
+		assert.False(t, HasRecovery(filename))
+	})
+
+	t.Run("Recovery file of a sequence that was never saved", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(RecoveryFile(filename), []byte("recovered"), 0644))
+		assert.True(t, HasRecovery(filename))
+	})
+
+	t.Run("Recovery file older than the saved file", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(filename, []byte("saved"), 0644))
+		past := time.Now().Add(-time.Minute)
+		assert.NoError(t, os.Chtimes(RecoveryFile(filename), past, past))
+		assert.False(t, HasRecovery(filename))
+	})
+
+	t.Run("Recovery file newer than the saved file", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		assert.NoError(t, os.Chtimes(filename, past, past))
+		assert.NoError(t, os.WriteFile(RecoveryFile(filename), []byte("recovered"), 0644))
+		assert.True(t, HasRecovery(filename))
+	})
+
+	t.Run("Removing the recovery file", func(t *testing.T) {
+		assert.NoError(t, RemoveRecovery(filename))
+		assert.False(t, HasRecovery(filename))
+		assert.NoError(t, RemoveRecovery(filename))
+	})
+}
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "song.json")
+
+	assert.Equal(t, filepath.Join(dir, "song~2.json"), BackupFile(filename, 2))
+
+	t.Run("No file to back up", func(t *testing.T) {
+		assert.NoError(t, RotateBackups(filename, 2))
+		assert.NoFileExists(t, BackupFile(filename, 1))
+	})
+
+	t.Run("Keeps the most recent versions", func(t *testing.T) {
+		for _, version := range []string{"one", "two", "three", "four"} {
+			assert.NoError(t, RotateBackups(filename, 2))
+			assert.NoError(t, os.WriteFile(filename, []byte(version), 0644))
+		}
+
+		first, err := os.ReadFile(BackupFile(filename, 1))
+		assert.NoError(t, err)
+		assert.Equal(t, "three", string(first))
+		second, err := os.ReadFile(BackupFile(filename, 2))
+		assert.NoError(t, err)
+		assert.Equal(t, "two", string(second))
+		assert.NoFileExists(t, BackupFile(filename, 3))
+	})
+
+	t.Run("No backups", func(t *testing.T) {
+		other := filepath.Join(dir, "other.sq")
+		assert.NoError(t, os.WriteFile(other, []byte("saved"), 0644))
+		assert.NoError(t, RotateBackups(other, 0))
+		assert.NoFileExists(t, BackupFile(other, 1))
+	})
+}
@@ -3,20 +3,16 @@ package beats
 import (
 	"context"
 	"fmt"
-	"maps"
-	"math/rand"
 	"os"
 	"runtime/debug"
-	"slices"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/chriserin/sq/internal/arrangement"
-	"github.com/chriserin/sq/internal/config"
 	"github.com/chriserin/sq/internal/grid"
 	"github.com/chriserin/sq/internal/notereg"
-	"github.com/chriserin/sq/internal/overlays"
 	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/renderer"
 	"github.com/chriserin/sq/internal/seqmidi"
 	"github.com/chriserin/sq/internal/sequence"
 	midi "gitlab.com/gomidi/midi/v2"
@@ -26,12 +22,19 @@ type ModelMsg struct {
 	PlayState playstate.PlayState
 	Sequence  sequence.Sequence
 	Cursor    arrangement.ArrCursor
+	// A sequence that takes the place of Sequence with the next key cycle
+	Queued *sequence.Sequence
 }
 
 type ModelPlayedMsg struct {
 	PerformStop bool
 	PlayState   playstate.PlayState
 	Cursor      arrangement.ArrCursor
+	// The queued sequence started playing with this beat
+	PlayedQueued bool
+	// When and how long the beat played, for recording notes against it
+	Time     time.Time
+	Interval time.Duration
 }
 
 type AnticipatoryStop struct{}
@@ -42,6 +45,32 @@ type BeatsLooper struct {
 	UpdateChannel chan ModelMsg
 	PlayQueue     chan seqmidi.Message
 	ErrChan       chan error
+	// Changes of tempo of the sections, for the timing loops to follow
+	TempoChannel chan TempoMsg
+	// Decides which notes with a chance or a condition play
+	Trigs *renderer.Trigs
+	// The start of the key cycle that the beats are scheduled from
+	cycle *cycleClock
+}
+
+// cycleClock keeps when the current cycle of the keyline started and how long
+// the beats played in it have lasted, so that each beat is placed at its
+// position in the cycle rather than at the time the beat message arrived.
+type cycleClock struct {
+	start   time.Time
+	elapsed time.Duration
+}
+
+// BeatStart returns the time the beat starts at, starting a new cycle at now
+// when the beat is the first of a cycle.
+func (c *cycleClock) BeatStart(now time.Time, startsCycle bool, interval time.Duration) time.Time {
+	if startsCycle || c.start.IsZero() {
+		c.start = now
+		c.elapsed = 0
+	}
+	beatStart := c.start.Add(c.elapsed)
+	c.elapsed += interval
+	return beatStart
 }
 
 func InitBeatsLooper() BeatsLooper {
@@ -50,6 +79,7 @@ func InitBeatsLooper() BeatsLooper {
 	updateChannel := make(chan ModelMsg)
 	playQueue := make(chan seqmidi.Message)
 	errChan := make(chan error)
+	tempoChannel := make(chan TempoMsg)
 
 	return BeatsLooper{
 		ClockChannel:  clockChannel,
@@ -57,6 +87,9 @@ func InitBeatsLooper() BeatsLooper {
 		UpdateChannel: updateChannel,
 		PlayQueue:     playQueue,
 		ErrChan:       errChan,
+		TempoChannel:  tempoChannel,
+		Trigs:         renderer.NewTrigs(time.Now().UnixNano()),
+		cycle:         &cycleClock{},
 	}
 }
 
@@ -73,6 +106,7 @@ func (bl BeatsLooper) Loop(sendFn func(tea.Msg), midiConn *seqmidi.MidiConnectio
 		}()
 		var playState playstate.PlayState
 		var definition sequence.Sequence
+		var queued *sequence.Sequence
 		var cursor arrangement.ArrCursor
 		for {
 			if !playState.Playing {
@@ -82,6 +116,7 @@ func (bl BeatsLooper) Loop(sendFn func(tea.Msg), midiConn *seqmidi.MidiConnectio
 					copiedPlayState := playstate.Copy(modelMsg.PlayState)
 					playState = copiedPlayState
 					definition = modelMsg.Sequence
+					queued = modelMsg.Queued
 					cursor = modelMsg.Cursor
 				case <-ctx.Done():
 					return
@@ -92,11 +127,16 @@ func (bl BeatsLooper) Loop(sendFn func(tea.Msg), midiConn *seqmidi.MidiConnectio
 				case modelMsg := <-bl.UpdateChannel:
 					playState = modelMsg.PlayState
 					definition = modelMsg.Sequence
+					queued = modelMsg.Queued
 					cursor = modelMsg.Cursor
 				case <-bl.ClockChannel:
-					bl.PlayQueue <- seqmidi.Message{Msg: midi.TimingClock(), Delay: 0}
+					bl.PlayQueue <- seqmidi.Message{Msg: midi.TimingClock()}
 				case BeatMsg := <-bl.BeatChannel:
-					bl.Beat(BeatMsg, playState, definition, cursor, sendFn)
+					played := bl.Beat(BeatMsg, ModelMsg{PlayState: playState, Sequence: definition, Cursor: cursor, Queued: queued}, sendFn)
+					playState = played.PlayState
+					definition = played.Sequence
+					queued = played.Queued
+					cursor = played.Cursor
 				case <-ctx.Done():
 					return
 				case err := <-bl.ErrChan:
@@ -127,27 +167,37 @@ func (bl BeatsLooper) Loop(sendFn func(tea.Msg), midiConn *seqmidi.MidiConnectio
 	}()
 }
 
-func IsDone(playState playstate.PlayState, currentNode *arrangement.Arrangement, currentSection arrangement.SongSection, cursor *arrangement.ArrCursor) bool {
-	return playState.LoopedArrangement != currentNode &&
-		currentSection.Cycles+currentSection.StartCycles <= (*playState.Iterations)[currentNode] &&
-		!(cursor.AllLastSiblings() && playState.Iterations.IsFull(cursor) && playState.PlayMode == playstate.PlayReceiver && playState.LoopMode != playstate.LoopWholeSequence)
-}
-
-func (bl BeatsLooper) Beat(msg BeatMsg, playState playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor, sendFn func(tea.Msg)) {
+// Beat plays a beat of the model and returns the model to play the next beat
+// of.
+func (bl BeatsLooper) Beat(msg BeatMsg, model ModelMsg, sendFn func(tea.Msg)) ModelMsg {
+	playState, definition, queued, cursor := model.PlayState, model.Sequence, model.Queued, model.Cursor
 	if playState.Playing {
-		AdvancePlayState(&playState, definition, &cursor)
+		renderer.AdvancePlayState(&playState, definition, &cursor)
+	}
+
+	startsCycle := playState.Playing && StartsKeyCycle(playState, definition, cursor)
+	playedQueued := false
+	if queued != nil && startsCycle {
+		if len(playState.LineStates) != len(queued.Lines) {
+			startBeat := playState.LineStates[definition.Keyline].CurrentBeat
+			playState.LineStates = playstate.InitLineStates(len(queued.Lines), playState.LineStates, startBeat)
+		}
+		definition = *queued
+		queued = nil
+		playedQueued = true
 	}
 
 	if !playState.Playing {
 		sendFn(ModelPlayedMsg{PerformStop: true, PlayState: playState, Cursor: cursor})
-		return
+		// Beats that arrive before the model stops are played from the
+		// model as it was
+		return model
 	} else {
-		bl.PlaySequence(&playState, definition, cursor, msg)
-		go func() {
-			sendFn(ModelPlayedMsg{PlayState: playState, Cursor: cursor})
-		}()
+		playedAt := time.Now()
+		beatStart := bl.cycle.BeatStart(playedAt, startsCycle, msg.Interval)
+		bl.PlaySequence(&playState, definition, cursor, msg, beatStart)
 		go func() {
-			bl.UpdateChannel <- ModelMsg{PlayState: playState, Sequence: definition, Cursor: cursor}
+			sendFn(ModelPlayedMsg{PlayState: playState, Cursor: cursor, PlayedQueued: playedQueued, Time: playedAt, Interval: msg.Interval})
 		}()
 	}
 
@@ -156,211 +206,70 @@ func (bl BeatsLooper) Beat(msg BeatMsg, playState playstate.PlayState, definitio
 	copiedPlayState := playstate.Copy(playState)
 	copiedCursor := make(arrangement.ArrCursor, len(cursor))
 	copy(copiedCursor, cursor)
-	AdvancePlayState(&copiedPlayState, definition, &copiedCursor)
+	renderer.AdvancePlayState(&copiedPlayState, definition, &copiedCursor)
 	if !copiedPlayState.Playing {
 		sendFn(AnticipatoryStop{})
-	}
-}
-
-func (bl BeatsLooper) PlaySequence(playState *playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor, msg BeatMsg) {
-
-	currentNode := cursor[len(cursor)-1]
-	currentSection := cursor[len(cursor)-1].Section
-	var partID int
-	var currentCycles int
-	var currentPart arrangement.Part
-	var playingOverlay *overlays.Overlay
-
-	partID = currentSection.Part
-	currentPart = (*definition.Parts)[partID]
-	currentCycles = (*playState.Iterations)[currentNode]
-	playingOverlay = currentPart.Overlays.HighestMatchingOverlay(currentCycles)
-
-	noteLineStates := make([]playstate.LineState, 0, len(playState.LineStates))
-	metaLineStates := make([]playstate.LineState, 0, len(playState.LineStates))
-	for i, ls := range playState.LineStates {
-		if definition.Lines[i].MsgType == grid.MessageTypeNote {
-			noteLineStates = append(noteLineStates, ls)
-		} else {
-			metaLineStates = append(metaLineStates, ls)
+	} else {
+		// The tempo of the next beat is sent while this beat plays, so that
+		// the next beat is timed at its own tempo
+		tempo, subdivisions := renderer.Tempo(playState, definition, cursor)
+		nextTempo, nextSubdivisions := renderer.Tempo(copiedPlayState, definition, copiedCursor)
+		if nextTempo != tempo || nextSubdivisions != subdivisions {
+			go func() {
+				bl.TempoChannel <- TempoMsg{Tempo: nextTempo, Subdivisions: nextSubdivisions}
+			}()
 		}
 	}
 
-	// Play the CC/PC Messages
-	gridKeys := make([]grid.GridKey, 0, len(playState.LineStates))
-	CurrentBeatGridKeys(&gridKeys, metaLineStates, playState.HasSolo)
-
-	pattern := make(grid.Pattern)
-	playingOverlay.CurrentBeatOverlayPattern(&pattern, currentCycles, gridKeys)
-
-	bl.PlayBeat(msg.Interval, pattern, definition)
-
-	// Play the Note Messages
-	gridKeys = make([]grid.GridKey, 0, len(playState.LineStates))
-	CurrentBeatGridKeys(&gridKeys, noteLineStates, playState.HasSolo)
-
-	pattern = make(grid.Pattern)
-	playingOverlay.CurrentBeatOverlayPattern(&pattern, currentCycles, gridKeys)
-
-	bl.PlayBeat(msg.Interval, pattern, definition)
-
-	if !playState.AllowAdvance {
-		playState.AllowAdvance = true
-	}
+	return ModelMsg{PlayState: playState, Sequence: definition, Cursor: cursor, Queued: queued}
 }
 
-func AdvancePlayState(playState *playstate.PlayState, definition sequence.Sequence, cursor *arrangement.ArrCursor) {
-	currentNode := (*cursor)[len(*cursor)-1]
-	currentSection := (*cursor)[len(*cursor)-1].Section
-	partID := currentSection.Part
-	currentPart := (*definition.Parts)[partID]
-	currentCycles := (*playState.Iterations)[currentNode]
-	playingOverlay := currentPart.Overlays.HighestMatchingOverlay(currentCycles)
-
-	if playState.Playing {
-		// NOTE: Only advance if we've already played the first beat.
-		if playState.AllowAdvance {
-			advanceCurrentBeat(currentCycles, *playingOverlay, playState.LineStates, currentPart.Beats, playState.BoundedLoop, playState.LoopMode)
-			advanceKeyCycle(definition.Keyline, playState.LineStates, playState.LoopMode, currentNode, playState.Iterations)
-			if IsDone(*playState, currentNode, currentSection, cursor) && playState.LoopMode != playstate.LoopOverlay {
-				if PlayMove(cursor, playState.Iterations, playState.LoopedArrangement) || playState.PlayMode == playstate.PlayReceiver {
-					currentSection = (*cursor)[len(*cursor)-1].Section
-					currentNode = (*cursor)[len(*cursor)-1]
-					if !currentSection.KeepCycles {
-						(*playState.Iterations)[currentNode] = currentSection.StartCycles
-					}
-					playState.LineStates = playstate.InitLineStates(len(definition.Lines), playState.LineStates, uint8((*cursor)[len(*cursor)-1].Section.StartBeat))
-				} else {
-					playState.Playing = false
-					return
-				}
-			}
-		}
+// StartsKeyCycle reports whether the beat about to be played is the first
+// beat of a cycle of the keyline, the first beat played included.
+func StartsKeyCycle(playState playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor) bool {
+	if int(definition.Keyline) >= len(playState.LineStates) {
+		return false
 	}
-
-}
-
-func CurrentBeatGridKeys(gridKeys *[]grid.GridKey, lineStates []playstate.LineState, hasSolo bool) {
-	for _, linestate := range lineStates {
-		if linestate.IsSolo() || (!linestate.IsMuted() && !hasSolo) {
-			*gridKeys = append(*gridKeys, linestate.GridKey())
-		}
+	if !playState.AllowAdvance {
+		return true
 	}
-}
-
-func advanceCurrentBeat(keyCycles int, playingOverlay overlays.Overlay, lineStates []playstate.LineState, partBeats uint8, boundedLoop playstate.BoundedLoop, loopMode playstate.LoopMode) {
+	node := cursor[len(cursor)-1]
+	part := (*definition.Parts)[node.Section.Part]
+	cycles := (*playState.Iterations)[node]
 	pattern := make(grid.Pattern)
-	playingOverlay.CombineActionPattern(&pattern, keyCycles)
-	for i := range lineStates {
-		doContinue := lineStates[i].AdvancePlayState(pattern, i, partBeats, lineStates, boundedLoop, loopMode)
-		if !doContinue {
-			break
-		}
-	}
-}
-
-func advanceKeyCycle(keyline uint8, lineStates []playstate.LineState, loopMode playstate.LoopMode, node *arrangement.Arrangement, iterations *playstate.Iterations) {
-	if lineStates[keyline].CurrentBeat == 0 && loopMode != playstate.LoopOverlay {
-		(*iterations)[node]++
-	}
-}
-
-func PlayMove(cursor *arrangement.ArrCursor, iterations *playstate.Iterations, loopNode *arrangement.Arrangement) bool {
-	if cursor.IsRoot() {
-		cursor.MoveNext()
-		return false
-	} else if cursor.IsLastSibling() {
-		(*iterations)[cursor.GetParentNode()]++
-		hasParentIterations := (*iterations)[cursor.GetParentNode()] < cursor.GetParentNode().Iterations
-		if hasParentIterations || loopNode == cursor.GetParentNode() {
-			cursor.MoveToFirstSibling()
-			if cursor.GetCurrentNode().IsGroup() {
-				cursor.MoveNext()
-			}
+	part.Overlays.HighestMatchingOverlay(cycles).CombineActionPattern(&pattern, cycles)
+	return renderer.KeylineStarts(playState.LineStates, definition, pattern, part.Beats, playState.BoundedLoop, playState.LoopMode)
+}
+
+// PlaySequence sends the events of the beat, each due at the tick it is placed
+// on counted from beatStart, the position of the beat in its key cycle.
+func (bl BeatsLooper) PlaySequence(playState *playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor, msg BeatMsg, beatStart time.Time) {
+	// CC/PC messages are rendered ahead of the notes they affect
+	_, subdivisions := renderer.Tempo(*playState, definition, cursor)
+	for _, event := range renderer.BeatEvents(*playState, definition, cursor, bl.Trigs) {
+		at := beatStart.Add(renderer.TickDuration(event.Tick, msg.Interval, subdivisions) + event.Delay)
+		if event.Message.Is(midi.NoteOnMsg) {
+			bl.PlayOnMessage(at, event.Message)
 		} else {
-			iterations.ResetIterations(*cursor)
-			cursor.Up()
-			return PlayMove(cursor, iterations, loopNode)
-		}
-	} else {
-		cursor.MoveToSibling()
-		iterations.ResetIterations(*cursor)
-	}
-	return true
-}
-
-func (bl BeatsLooper) PlayBeat(beatInterval time.Duration, pattern grid.Pattern, definition sequence.Sequence) {
-	lines := definition.Lines
-
-	keys := maps.Keys(pattern)
-	sortedKeys := slices.SortedFunc(keys, grid.Compare)
-
-	for _, gridKey := range sortedKeys {
-		note := pattern[gridKey]
-		if note.Action != grid.ActionNothing {
-			continue
-		}
-		line := lines[gridKey.Line]
-		if note.Ratchets.Length > 0 {
-			bl.ProcessRatchets(note, beatInterval, line, definition)
-		} else if note != grid.ZeroNote {
-			accents := definition.Accents
-
-			delay := Delay(note.WaitIndex, beatInterval)
-			gateLength := GateLength(note.GateIndex, beatInterval)
-
-			switch line.MsgType {
-			case grid.MessageTypeNote:
-				onMessage, offMessage := NoteMessages(
-					line,
-					uint8(definition.Accents.Data[note.AccentIndex]),
-					gateLength,
-					accents.Target,
-					delay,
-				)
-				bl.PlayOnMessage(onMessage)
-				bl.PlayOffMessage(offMessage)
-			case grid.MessageTypeCc:
-				ccMessage := CCMessage(line, note, accents.Data, delay, true, definition.Instrument)
-
-				bl.PlayMessage(ccMessage.delay, ccMessage.MidiMessage())
-			case grid.MessageTypeProgramChange:
-				pcMessage := PCMessage(line, note, accents.Data, delay, true, definition.Instrument)
-				bl.PlayMessage(pcMessage.delay, pcMessage.MidiMessage())
-			}
+			bl.PlayMessage(at, event.Message)
 		}
 	}
-}
 
-func (bl BeatsLooper) ProcessRatchets(note grid.Note, beatInterval time.Duration, line grid.LineDefinition, definition sequence.Sequence) {
-	ratchetInterval := note.Ratchets.Interval(beatInterval)
-	for i := range note.Ratchets.Length + 1 {
-		if note.Ratchets.HitAt(i) {
-			shortGateLength := 20 * time.Millisecond
-			ratchetDelay := time.Duration(i) * ratchetInterval
-			onMessage, offMessage := NoteMessages(line, uint8(definition.Accents.Data[note.AccentIndex]), shortGateLength, definition.Accents.Target, ratchetDelay)
-			bl.PlayOnMessage(onMessage)
-			bl.PlayOffMessage(offMessage)
-		}
+	if !playState.AllowAdvance {
+		playState.AllowAdvance = true
 	}
 }
 
-func (bl BeatsLooper) PlayMessage(delay time.Duration, message midi.Message) {
-	bl.PlayQueue <- seqmidi.Message{Msg: message, Delay: delay}
+func (bl BeatsLooper) PlayMessage(at time.Time, message midi.Message) {
+	bl.PlayQueue <- seqmidi.Message{Msg: message, At: at}
 }
 
-func (bl BeatsLooper) PlayOnMessage(nm NoteMsg) {
-	key := notereg.GetKey(nm.GetOnMidi())
+func (bl BeatsLooper) PlayOnMessage(at time.Time, message midi.Message) {
+	key := notereg.GetKey(message)
 	if notereg.HasKey(key) {
-		bl.PlayQueue <- seqmidi.Message{Msg: nm.GetOffMidi(), Delay: nm.delay}
-		bl.PlayQueue <- seqmidi.Message{Msg: nm.GetOnMidi(), Delay: nm.delay}
-	} else {
-		bl.PlayQueue <- seqmidi.Message{Msg: nm.GetOnMidi(), Delay: nm.delay}
+		bl.PlayQueue <- seqmidi.Message{Msg: midi.NoteOff(key.Channel, key.Note), At: at}
 	}
-}
-
-func (bl BeatsLooper) PlayOffMessage(nm NoteMsg) {
-	bl.PlayQueue <- seqmidi.Message{Msg: nm.GetOffMidi(), Delay: nm.delay}
+	bl.PlayQueue <- seqmidi.Message{Msg: message, At: at}
 }
 
 type BeatMsg struct {
@@ -369,146 +278,8 @@ type BeatMsg struct {
 
 type ClockMsg struct{}
 
-func NoteMessages(l grid.LineDefinition, accentValue uint8, gateLength time.Duration, accentTarget sequence.AccentTarget, delay time.Duration) (NoteMsg, NoteMsg) {
-	var noteValue uint8
-	var velocityValue uint8
-
-	switch accentTarget {
-	case sequence.AccentTargetNote:
-		noteValue = l.Note + accentValue
-		velocityValue = 96
-	case sequence.AccentTargetVelocity:
-		noteValue = l.Note
-		velocityValue = accentValue
-	}
-
-	id := rand.Int()
-	onMsg := NoteMsg{id: id, midiType: midi.NoteOnMsg, channel: l.Channel - 1, noteValue: noteValue, velocity: velocityValue, delay: delay}
-	offMsg := NoteMsg{id: id, midiType: midi.NoteOffMsg, channel: l.Channel - 1, noteValue: noteValue, velocity: 0, delay: delay + gateLength}
-
-	return onMsg, offMsg
-}
-
-func CCMessage(l grid.LineDefinition, note grid.Note, accents []config.Accent, delay time.Duration, includeDelay bool, instrument string) controlChangeMsg {
-	if note.Action == grid.ActionSpecificValue {
-		return controlChangeMsg{l.Channel - 1, l.Note, note.AccentIndex, delay}
-	} else {
-		cc, _ := config.FindCC(l.Note, instrument)
-		var ccValue uint8
-		if cc.UpperLimit == 1 && note.AccentIndex > 4 {
-			ccValue = 0
-		} else if cc.UpperLimit == 1 {
-			ccValue = 1
-		} else {
-			ccValue = uint8((float32((len(accents))-int(note.AccentIndex)) / float32(len(accents)-1)) * float32(cc.UpperLimit))
-		}
-
-		return controlChangeMsg{l.Channel - 1, l.Note, ccValue, delay}
-	}
-}
-
-func PCMessage(l grid.LineDefinition, note grid.Note, accents []config.Accent, delay time.Duration, includeDelay bool, instrument string) programChangeMsg {
-	if note.Action == grid.ActionSpecificValue {
-		return programChangeMsg{l.Channel - 1, note.AccentIndex, delay}
-	} else {
-		pcValue := uint8((float32((len(accents))-int(note.AccentIndex)) / float32(len(accents)-1)) * float32(127))
-		return programChangeMsg{l.Channel - 1, pcValue, delay}
-	}
-}
-
-type Delayable interface {
-	Delay() time.Duration
-}
-
-type NoteMsg struct {
-	channel   uint8
-	noteValue uint8
-	velocity  uint8
-	midiType  midi.Type
-	delay     time.Duration
-	id        int
-}
-
-func (nm NoteMsg) Delay() time.Duration {
-	return nm.delay
-}
-
-type programChangeMsg struct {
-	channel uint8
-	pcValue uint8
-	delay   time.Duration
-}
-
-func (pcm programChangeMsg) MidiMessage() midi.Message {
-	return midi.ProgramChange(pcm.channel, pcm.pcValue)
-}
-
-func (pcm programChangeMsg) Delay() time.Duration {
-	return pcm.delay
-}
-
-type controlChangeMsg struct {
-	channel uint8
-	control uint8
-	ccValue uint8
-	delay   time.Duration
-}
-
-func (ccm controlChangeMsg) MidiMessage() midi.Message {
-	return midi.ControlChange(ccm.channel, ccm.control, ccm.ccValue)
-}
-
-func (ccm controlChangeMsg) Delay() time.Duration {
-	return ccm.delay
-}
-
-func (nm NoteMsg) GetKey() notereg.NoteRegKey {
-	return notereg.NoteRegKey{
-		Channel: nm.channel,
-		Note:    nm.noteValue,
-	}
-}
-
-func (nm NoteMsg) GetID() int {
-	return nm.id
-}
-
-func (nm NoteMsg) GetOnMidi() midi.Message {
-	return midi.NoteOn(nm.channel, nm.noteValue, nm.velocity)
-}
-
-func (nm NoteMsg) GetOffMidi() midi.Message {
-	return midi.NoteOff(nm.channel, nm.noteValue)
-}
-
-func (nm NoteMsg) OffMessage() midi.Message {
-	return midi.NoteOff(nm.channel, nm.noteValue)
-}
-
-func Delay(waitIndex uint8, beatInterval time.Duration) time.Duration {
-	var delay time.Duration
-	if waitIndex != 0 {
-		delay = time.Duration((float64(config.WaitPercentages[waitIndex])) / float64(100) * float64(beatInterval))
-	} else {
-		delay = 0
-	}
-	return delay
-}
-
-func GateLength(gateIndex int16, beatInterval time.Duration) time.Duration {
-	var delay time.Duration
-	if gateIndex < 8 {
-		var delay time.Duration
-		var value = config.ShortGates[gateIndex].Value
-		if value > 1 {
-			delay = time.Duration(config.ShortGates[gateIndex].Value) * time.Millisecond
-		} else {
-			delay = time.Duration(config.ShortGates[gateIndex].Value * float32(beatInterval))
-		}
-		return delay
-	} else if gateIndex >= 8 {
-		shortGatesLen := int16(len(config.ShortGates))
-		return time.Duration(float64(config.LongGates[gateIndex-shortGatesLen].Value) * float64(beatInterval))
-	}
-	return delay
+// TempoMsg is the tempo and subdivisions of the section about to play.
+type TempoMsg struct {
+	Tempo        int
+	Subdivisions int
 }
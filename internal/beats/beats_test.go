@@ -2,6 +2,7 @@ package beats
 
 import (
 	"context"
+	"slices"
 	"testing"
 	"time"
 
@@ -40,6 +41,10 @@ func TestSimpleSequenceBeats(t *testing.T) {
 	}
 }
 
+// cycleStart stands in for the start of the played cycle in the expected
+// messages; the first message of each test is due at the start of the cycle.
+var cycleStart time.Time
+
 func TestOneNote(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -51,7 +56,7 @@ func TestOneNote(t *testing.T) {
 			"Part with 1 note",
 			1,
 			1,
-			[]seqmidi.Message{{Msg: midi.NoteOn(4, 5, 5), Delay: 0}, {Msg: midi.NoteOff(4, 5), Delay: 20 * time.Millisecond}},
+			[]seqmidi.Message{{Msg: midi.NoteOn(4, 5, 5), At: cycleStart}, {Msg: midi.NoteOff(4, 5), At: cycleStart.Add(20 * time.Millisecond)}},
 		},
 	}
 
@@ -67,7 +72,7 @@ func TestOneNote(t *testing.T) {
 
 			if assert.Len(t, testMessages, len(tt.expectedMidiMessages), "Number of MIDI messages") {
 				for i, msg := range tt.expectedMidiMessages {
-					assert.Equal(t, msg.Delay, testMessages[i].Delay, "Delay")
+					assert.Equal(t, msg.At.Sub(cycleStart), testMessages[i].At.Sub(testMessages[0].At), "Deadline")
 
 					switch msg.Msg.Type() {
 					case midi.NoteOnMsg:
@@ -93,6 +98,26 @@ func TestOneNote(t *testing.T) {
 	}
 }
 
+func TestDeadlinesFollowTheCycle(t *testing.T) {
+	sequence, cursor := SimpleSequence()
+
+	(*sequence.Parts)[0].Beats = 2
+	(*sequence.Parts)[0].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 0}, grid.Note{AccentIndex: 5})
+	(*sequence.Parts)[0].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 1}, grid.Note{AccentIndex: 5})
+
+	// The test sends the beats without waiting out their interval, the second
+	// beat is still due an interval after the start of the cycle
+	beatsPlayed, testMessages := PlayTestLoop(sequence, cursor, 5, playstate.PlayState{Playing: true}, t.Context())
+	assert.Equal(t, 2, beatsPlayed)
+
+	expected := []time.Duration{0, 20 * time.Millisecond, 250 * time.Millisecond, 270 * time.Millisecond}
+	if assert.Len(t, testMessages, len(expected), "Number of MIDI messages") {
+		for i, offset := range expected {
+			assert.Equal(t, offset, testMessages[i].At.Sub(testMessages[0].At), "Deadline")
+		}
+	}
+}
+
 func TestRatchet(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -105,10 +130,10 @@ func TestRatchet(t *testing.T) {
 			1,
 			1,
 			[]seqmidi.Message{
-				{Msg: midi.NoteOn(4, 5, 5), Delay: 0},
-				{Msg: midi.NoteOff(4, 5), Delay: 20 * time.Millisecond},
-				{Msg: midi.NoteOn(4, 5, 5), Delay: 125 * time.Millisecond},
-				{Msg: midi.NoteOff(4, 5), Delay: 145 * time.Millisecond},
+				{Msg: midi.NoteOn(4, 5, 5), At: cycleStart},
+				{Msg: midi.NoteOff(4, 5), At: cycleStart.Add(20 * time.Millisecond)},
+				{Msg: midi.NoteOn(4, 5, 5), At: cycleStart.Add(125 * time.Millisecond)},
+				{Msg: midi.NoteOff(4, 5), At: cycleStart.Add(145 * time.Millisecond)},
 			},
 		},
 	}
@@ -128,7 +153,7 @@ func TestRatchet(t *testing.T) {
 
 			if assert.Len(t, testMessages, len(tt.expectedMidiMessages), "Number of MIDI messages") {
 				for i, msg := range tt.expectedMidiMessages {
-					assert.Equal(t, msg.Delay, testMessages[i].Delay, "Delay")
+					assert.Equal(t, msg.At.Sub(cycleStart), testMessages[i].At.Sub(testMessages[0].At), "Deadline")
 
 					switch msg.Msg.Type() {
 					case midi.NoteOnMsg:
@@ -310,6 +335,45 @@ func TestPartGroupSiblingSequence(t *testing.T) {
 	}
 }
 
+func TestQueuedSequence(t *testing.T) {
+	definition, cursor := SimpleSequence()
+	(*definition.Parts)[0].Beats = 4
+	cursor[1].Section.Cycles = 3
+
+	queued := definition
+	queued.Lines = append(slices.Clone(definition.Lines), grid.LineDefinition{Channel: 6, Note: 6, MsgType: grid.MessageTypeNote, Name: "Line 2"})
+
+	played := make(chan ModelPlayedMsg)
+	sendFn := func(msg tea.Msg) {
+		if msg, ok := msg.(ModelPlayedMsg); ok {
+			played <- msg
+		}
+	}
+	beatsLooper := InitBeatsLooper()
+	midiConnection := seqmidi.MidiConnection{Test: true, TestQueue: &[]seqmidi.Message{}}
+	beatsLooper.Loop(sendFn, &midiConnection, t.Context())
+
+	iterations := make(playstate.Iterations)
+	playstate.BuildIterationsMap(definition.Arrangement, &iterations)
+	playState := playstate.PlayState{Playing: true, Iterations: &iterations, LineStates: playstate.InitLineStates(1, []playstate.LineState{}, 0)}
+	beatsLooper.UpdateChannel <- ModelMsg{PlayState: playState, Sequence: definition, Cursor: cursor}
+
+	var update ModelPlayedMsg
+	for range 2 {
+		beatsLooper.BeatChannel <- BeatMsg{Interval: 250 * time.Millisecond}
+		update = <-played
+	}
+	beatsLooper.UpdateChannel <- ModelMsg{PlayState: update.PlayState, Sequence: definition, Cursor: update.Cursor, Queued: &queued}
+
+	// The queued sequence waits for the cycle of the keyline to end
+	for _, expected := range []bool{false, false, true, false} {
+		beatsLooper.BeatChannel <- BeatMsg{Interval: 250 * time.Millisecond}
+		update = <-played
+		assert.Equal(t, expected, update.PlayedQueued)
+	}
+	assert.Len(t, update.PlayState.LineStates, 2)
+}
+
 func PlayTestLoop(sequence sequence.Sequence, cursor arrangement.ArrCursor, limit int, playState playstate.PlayState, ctx context.Context) (int, []seqmidi.Message) {
 	testMessageChan := make(chan ModelPlayedMsg)
 	beatsPlayedCounter := 0
@@ -371,10 +435,12 @@ func SimpleSequence() (sequence.Sequence, arrangement.ArrCursor) {
 	root.Nodes = append(root.Nodes, nodeA)
 
 	testSequence := sequence.Sequence{
-		Arrangement: root,
-		Parts:       &parts,
-		Keyline:     0,
-		Lines:       []grid.LineDefinition{{Channel: 5, Note: 5, MsgType: grid.MessageTypeNote, Name: "Line 1"}},
+		Arrangement:  root,
+		Parts:        &parts,
+		Tempo:        120,
+		Subdivisions: 2,
+		Keyline:      0,
+		Lines:        []grid.LineDefinition{{Channel: 5, Note: 5, MsgType: grid.MessageTypeNote, Name: "Line 1"}},
 		Accents: sequence.PatternAccents{
 			Start:  0,
 			End:    8,
@@ -553,150 +619,3 @@ func PartGroupSiblingSequence() (sequence.Sequence, arrangement.ArrCursor) {
 
 	return testSequence, arrangement.ArrCursor{root, nodeB}
 }
-
-func TestCCMessage(t *testing.T) {
-	tests := []struct {
-		name          string
-		line          grid.LineDefinition
-		note          grid.Note
-		accents       []config.Accent
-		delay         time.Duration
-		instrument    string
-		expectedChan  uint8
-		expectedCtrl  uint8
-		expectedValue uint8
-		expectedDelay time.Duration
-	}{
-		{
-			name:          "Specific value action",
-			line:          grid.LineDefinition{Channel: 1, Note: 10},
-			note:          grid.Note{Action: grid.ActionSpecificValue, AccentIndex: 42},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         100 * time.Millisecond,
-			instrument:    "",
-			expectedChan:  0,
-			expectedCtrl:  10,
-			expectedValue: 42,
-			expectedDelay: 100 * time.Millisecond,
-		},
-		{
-			name:          "Accent-based value mid-range",
-			line:          grid.LineDefinition{Channel: 3, Note: 7},
-			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 2},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         0,
-			instrument:    "",
-			expectedChan:  2,
-			expectedCtrl:  7,
-			expectedValue: 95, // (5-2)/4 * 127 = 95.25 -> 95
-			expectedDelay: 0,
-		},
-		{
-			name:          "Accent-based value at end",
-			line:          grid.LineDefinition{Channel: 4, Note: 11},
-			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 4},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         10 * time.Millisecond,
-			instrument:    "",
-			expectedChan:  3,
-			expectedCtrl:  11,
-			expectedValue: 31, // (5-4)/4 * 127 = 31.75 -> 31
-			expectedDelay: 10 * time.Millisecond,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := CCMessage(tt.line, tt.note, tt.accents, tt.delay, true, tt.instrument)
-
-			assert.Equal(t, tt.expectedChan, result.channel, "Channel")
-			assert.Equal(t, tt.expectedCtrl, result.control, "Control")
-			assert.Equal(t, tt.expectedValue, result.ccValue, "CC Value")
-			assert.Equal(t, tt.expectedDelay, result.delay, "Delay")
-
-			// Test that MidiMessage generates correct MIDI message
-			msg := result.MidiMessage()
-			var channel, control, value uint8
-			assert.True(t, msg.GetControlChange(&channel, &control, &value), "MIDI message should be control change")
-			assert.Equal(t, tt.expectedChan, channel, "MIDI channel")
-			assert.Equal(t, tt.expectedCtrl, control, "MIDI control")
-			assert.Equal(t, tt.expectedValue, value, "MIDI value")
-		})
-	}
-}
-
-func TestPCMessage(t *testing.T) {
-	tests := []struct {
-		name          string
-		line          grid.LineDefinition
-		note          grid.Note
-		accents       []config.Accent
-		delay         time.Duration
-		instrument    string
-		expectedChan  uint8
-		expectedValue uint8
-		expectedDelay time.Duration
-	}{
-		{
-			name:          "Specific value action",
-			line:          grid.LineDefinition{Channel: 1, Note: 10},
-			note:          grid.Note{Action: grid.ActionSpecificValue, AccentIndex: 64},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         100 * time.Millisecond,
-			instrument:    "",
-			expectedChan:  0,
-			expectedValue: 64,
-			expectedDelay: 100 * time.Millisecond,
-		},
-		{
-			name:          "Accent-based value mid-range",
-			line:          grid.LineDefinition{Channel: 3, Note: 30},
-			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 2},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         0,
-			instrument:    "",
-			expectedChan:  2,
-			expectedValue: 95, // (5-2)/4 * 127 = 95.25 -> 95
-			expectedDelay: 0,
-		},
-		{
-			name:          "Accent-based value at end",
-			line:          grid.LineDefinition{Channel: 4, Note: 40},
-			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 4},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         10 * time.Millisecond,
-			instrument:    "",
-			expectedChan:  3,
-			expectedValue: 31, // (5-4)/4 * 127 = 31.75 -> 31
-			expectedDelay: 10 * time.Millisecond,
-		},
-		{
-			name:          "Zero delay",
-			line:          grid.LineDefinition{Channel: 5, Note: 50},
-			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 1},
-			accents:       []config.Accent{0, 30, 60, 90, 120},
-			delay:         0,
-			instrument:    "",
-			expectedChan:  4,
-			expectedValue: 127, // (5-1)/4 * 127 = 127 exactly
-			expectedDelay: 0,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := PCMessage(tt.line, tt.note, tt.accents, tt.delay, true, tt.instrument)
-
-			assert.Equal(t, tt.expectedChan, result.channel, "Channel")
-			assert.Equal(t, tt.expectedValue, result.pcValue, "PC Value")
-			assert.Equal(t, tt.expectedDelay, result.delay, "Delay")
-
-			// Test that MidiMessage generates correct MIDI message
-			msg := result.MidiMessage()
-			var channel, value uint8
-			assert.True(t, msg.GetProgramChange(&channel, &value), "MIDI message should be program change")
-			assert.Equal(t, tt.expectedChan, channel, "MIDI channel")
-			assert.Equal(t, tt.expectedValue, value, "MIDI value")
-		})
-	}
-}
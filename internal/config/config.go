@@ -9,11 +9,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/aarzilli/golua/lua"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/chriserin/sq/internal/operation"
 )
 
@@ -384,6 +386,127 @@ func addInstrument(L *lua.State) int {
 	return 0
 }
 
+var grooves []groove.Groove
+
+// GetGroove returns the groove with the given name, from the lua config or
+// else from the default grooves.
+func GetGroove(name string) (groove.Groove, bool) {
+	if g, ok := groove.Find(grooves, name); ok {
+		return g, true
+	}
+	return groove.Find(groove.Defaults, name)
+}
+
+func GetGrooveNames() []string {
+	var names []string
+	for _, g := range slices.Concat(groove.Defaults, grooves) {
+		if !slices.Contains(names, g.Name) {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// Lua Function
+func addGroove(L *lua.State) int {
+	if L.IsTable(1) {
+		L.GetField(1, "name")
+		newGroove := groove.Groove{Name: L.ToString(2)}
+
+		L.Pop(1)
+		L.GetField(1, "steps")
+		if L.IsTable(2) {
+			for i := 1; true; i++ {
+				L.PushInteger(int64(i))
+				L.GetTable(2)
+				if L.IsTable(3) {
+					step := groove.Step{Velocity: 100}
+					for i := range 2 {
+						L.PushInteger(int64(i + 1))
+						L.GetTable(3)
+						switch i + 1 {
+						case 1:
+							step.Offset = uint8(L.ToNumber(4))
+						case 2:
+							if !L.IsNil(4) {
+								step.Velocity = uint8(L.ToNumber(4))
+							}
+						}
+						L.Pop(1)
+					}
+					newGroove.Steps = append(newGroove.Steps, step)
+				} else {
+					break
+				}
+				L.Pop(1)
+			}
+		}
+		grooves = append(grooves, newGroove)
+	} else {
+		panic("Groove not formatted correctly")
+	}
+	return 0
+}
+
+// GrooveLua returns the lua that adds the groove to the config.
+func GrooveLua(g groove.Groove) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "sq.addgroove({\n\tname = %q,\n\tsteps = {\n", g.Name)
+	for _, step := range g.Steps {
+		fmt.Fprintf(&buf, "\t\t{ %d, %d },\n", step.Offset, step.Velocity)
+	}
+	buf.WriteString("\t},\n})\n")
+	return buf.String()
+}
+
+// Control binds a note or control change of a midi controller to a command or
+// to a parameter by name.
+type Control struct {
+	Type      string
+	Channel   uint8
+	Number    uint8
+	Command   string
+	Parameter string
+}
+
+var controls []Control
+
+func GetControls() []Control {
+	return controls
+}
+
+// Lua Function
+func addControl(L *lua.State) int {
+	if L.IsTable(1) {
+		control := Control{}
+		L.GetField(1, "type")
+		control.Type = strings.ToUpper(L.ToString(2))
+		if control.Type == "" {
+			control.Type = "CC"
+		}
+		L.Pop(1)
+		L.GetField(1, "channel")
+		control.Channel = uint8(L.ToInteger(2))
+		if control.Channel == 0 {
+			control.Channel = 1
+		}
+		L.Pop(1)
+		L.GetField(1, "number")
+		control.Number = uint8(L.ToInteger(2))
+		L.Pop(1)
+		L.GetField(1, "command")
+		control.Command = L.ToString(2)
+		L.Pop(1)
+		L.GetField(1, "parameter")
+		control.Parameter = L.ToString(2)
+		L.Pop(1)
+		controls = append(controls, control)
+	} else {
+		panic("Control not formatted correctly")
+	}
+	return 0
+}
+
 // Lua Function
 func addTemplate(L *lua.State) int {
 	if L.IsTable(1) {
@@ -465,4 +588,6 @@ type LuaFn = lua.LuaGoFunction
 var seqFunctions = map[string]LuaFn{
 	"addtemplate":   addTemplate,
 	"addinstrument": addInstrument,
+	"addcontrol":    addControl,
+	"addgroove":     addGroove,
 }
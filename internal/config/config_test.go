@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,4 +30,21 @@ func TestProcessConfig(t *testing.T) {
 		assert.Equal(t, uint8(26), instrument.CCs[11].Value)
 		assert.Equal(t, uint8(120), instrument.CCs[11].UpperLimit)
 	})
+
+	t.Run("adds controls", func(t *testing.T) {
+		ProcessConfig("./testdata/AddControl.lua")
+		controls := GetControls()
+		assert.Equal(t, []Control{
+			{Type: "NOTE", Channel: 10, Number: 36, Command: "PlayStop"},
+			{Type: "CC", Channel: 1, Number: 20, Parameter: "Tempo"},
+		}, controls)
+	})
+
+	t.Run("adds grooves", func(t *testing.T) {
+		ProcessConfig("./testdata/AddGroove.lua")
+		loose, ok := GetGroove("Loose")
+		assert.True(t, ok)
+		assert.Equal(t, []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 20, Velocity: 80}, {Offset: 5, Velocity: 100}}, loose.Steps)
+		assert.Contains(t, GetGrooveNames(), "Swing 58%")
+	})
 }
@@ -0,0 +1,2 @@
+sq.addcontrol({ type = "note", channel = 10, number = 36, command = "PlayStop" })
+sq.addcontrol({ number = 20, parameter = "Tempo" })
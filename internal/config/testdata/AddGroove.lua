@@ -0,0 +1,10 @@
+local sq = require("sq")
+
+sq.addgroove({
+	name = "Loose",
+	steps = {
+		{ 0, 100 },
+		{ 20, 80 },
+		{ 5 },
+	},
+})
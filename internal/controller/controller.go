@@ -0,0 +1,220 @@
+// Package controller binds the notes and control changes of a midi controller
+// to sq commands and parameters.  Bindings are learned from the controller
+// while sq runs (midi learn), saved with the sequence and can also be defined
+// in the lua config.
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/mappings"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+type Kind uint8
+
+const (
+	KindNote Kind = iota
+	KindCC
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindCC:
+		return "CC"
+	default:
+		return "NOTE"
+	}
+}
+
+func ParseKind(value string) (Kind, bool) {
+	switch value {
+	case "NOTE":
+		return KindNote, true
+	case "CC":
+		return KindCC, true
+	}
+	return KindNote, false
+}
+
+// Parameter is a value of the sequence that follows the position of a
+// continuous control.
+type Parameter uint8
+
+const (
+	ParameterNone Parameter = iota
+	ParameterTempo
+	ParameterAccentStart
+	ParameterAccentEnd
+)
+
+var parameterNames = []string{"", "Tempo", "AccentStart", "AccentEnd"}
+
+func (p Parameter) String() string {
+	return parameterNames[p]
+}
+
+func ParseParameter(value string) (Parameter, bool) {
+	index := slices.Index(parameterNames, value)
+	if index <= 0 {
+		return ParameterNone, false
+	}
+	return Parameter(index), true
+}
+
+// The tempo range of a continuous control matches the tempo range of
+// the tempo input
+const (
+	MinTempo = 30
+	MaxTempo = 300
+)
+
+// Scale maps the value of a continuous control onto the range of the
+// parameter.
+func (p Parameter) Scale(value uint8) int {
+	switch p {
+	case ParameterTempo:
+		return MinTempo + int(value)*(MaxTempo-MinTempo)/127
+	default:
+		return int(value)
+	}
+}
+
+// Control is a note or control change on a channel of the controller.
+// Channels are one based, the same as line channels.
+type Control struct {
+	Kind    Kind
+	Channel uint8
+	Number  uint8
+}
+
+func (c Control) String() string {
+	return fmt.Sprintf("%s %d/%d", c.Kind, c.Channel, c.Number)
+}
+
+// ControlMsg is a note played or control moved on the controller.
+type ControlMsg struct {
+	Control Control
+	Value   uint8
+}
+
+// Pressed is true for a played note or a button sending a control change at
+// or above the middle of its range.
+func (cm ControlMsg) Pressed() bool {
+	return cm.Value >= 64 || (cm.Control.Kind == KindNote && cm.Value > 0)
+}
+
+// ReadControl returns the control of a message from the controller.  Note
+// ends are not controls.
+func ReadControl(msg []byte) (ControlMsg, bool) {
+	message := midi.Message(msg)
+	var channel, number, value uint8
+	switch {
+	case message.GetNoteStart(&channel, &number, &value):
+		return ControlMsg{Control: Control{KindNote, channel + 1, number}, Value: value}, true
+	case message.GetControlChange(&channel, &number, &value):
+		return ControlMsg{Control: Control{KindCC, channel + 1, number}, Value: value}, true
+	}
+	return ControlMsg{}, false
+}
+
+// Binding runs a command when its control is pressed, or sets a parameter
+// when its control moves.
+type Binding struct {
+	Control   Control
+	Command   mappings.Command
+	Parameter Parameter
+}
+
+func (b Binding) Target() string {
+	if b.Parameter != ParameterNone {
+		return b.Parameter.String()
+	}
+	return b.Command.String()
+}
+
+type Bindings []Binding
+
+// Find returns the binding of a control.
+func (bs Bindings) Find(control Control) (Binding, bool) {
+	index := slices.IndexFunc(bs, func(binding Binding) bool {
+		return binding.Control == control
+	})
+	if index < 0 {
+		return Binding{}, false
+	}
+	return bs[index], true
+}
+
+// Bind returns the bindings with the control bound to the binding, replacing
+// the binding the control had before.
+func (bs Bindings) Bind(binding Binding) Bindings {
+	bindings := slices.DeleteFunc(slices.Clone(bs), func(existing Binding) bool {
+		return existing.Control == binding.Control
+	})
+	return append(bindings, binding)
+}
+
+// Merge returns the bindings with other bound on top of them.
+func (bs Bindings) Merge(other Bindings) Bindings {
+	bindings := slices.Clone(bs)
+	for _, binding := range other {
+		bindings = bindings.Bind(binding)
+	}
+	return bindings
+}
+
+// FromConfig returns the bindings of the lua config.  A control with an
+// unknown command or parameter is left out.
+func FromConfig(controls []config.Control) Bindings {
+	var bindings Bindings
+	for _, control := range controls {
+		binding, ok := NewBinding(control.Type, control.Channel, control.Number, control.Command, control.Parameter)
+		if ok {
+			bindings = append(bindings, binding)
+		}
+	}
+	return bindings
+}
+
+// NewBinding creates a binding from the names used in files and the config.
+func NewBinding(kind string, channel, number uint8, command, parameter string) (Binding, bool) {
+	controlKind, ok := ParseKind(kind)
+	if !ok {
+		return Binding{}, false
+	}
+	binding := Binding{Control: Control{Kind: controlKind, Channel: channel, Number: number}}
+	if parameter != "" {
+		binding.Parameter, ok = ParseParameter(parameter)
+	} else {
+		binding.Command, ok = mappings.ParseCommand(command)
+	}
+	return binding, ok
+}
+
+// Learn is the state of midi learn, waiting for a target and then for the
+// control to bind to it.
+type Learn struct {
+	Active    bool
+	HasTarget bool
+	Command   mappings.Command
+	Parameter Parameter
+}
+
+// Bind returns the binding of a control to the learn target.  Parameters can
+// only be bound to control changes.
+func (l Learn) Bind(control Control) (Binding, bool) {
+	if !l.Active || !l.HasTarget {
+		return Binding{}, false
+	}
+	if l.Parameter != ParameterNone && control.Kind != KindCC {
+		return Binding{}, false
+	}
+	return Binding{Control: control, Command: l.Command, Parameter: l.Parameter}, true
+}
+
+func (l Learn) Target() string {
+	return Binding{Command: l.Command, Parameter: l.Parameter}.Target()
+}
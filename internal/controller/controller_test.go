@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/stretchr/testify/assert"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+func TestReadControl(t *testing.T) {
+	t.Run("Note on", func(t *testing.T) {
+		control, ok := ReadControl(midi.NoteOn(9, 36, 100))
+		assert.True(t, ok)
+		assert.Equal(t, ControlMsg{Control: Control{KindNote, 10, 36}, Value: 100}, control)
+		assert.True(t, control.Pressed())
+	})
+
+	t.Run("Control change", func(t *testing.T) {
+		control, ok := ReadControl(midi.ControlChange(0, 20, 10))
+		assert.True(t, ok)
+		assert.Equal(t, ControlMsg{Control: Control{KindCC, 1, 20}, Value: 10}, control)
+		assert.False(t, control.Pressed())
+	})
+
+	t.Run("Note off is not a control", func(t *testing.T) {
+		_, ok := ReadControl(midi.NoteOff(9, 36))
+		assert.False(t, ok)
+	})
+}
+
+func TestScale(t *testing.T) {
+	assert.Equal(t, MinTempo, ParameterTempo.Scale(0))
+	assert.Equal(t, MaxTempo, ParameterTempo.Scale(127))
+	assert.Equal(t, 64, ParameterAccentStart.Scale(64))
+}
+
+func TestBindings(t *testing.T) {
+	pad := Control{KindNote, 10, 36}
+	knob := Control{KindCC, 1, 20}
+
+	t.Run("Bind replaces the binding of the control", func(t *testing.T) {
+		bindings := Bindings{{Control: pad, Command: mappings.PlayStop}}
+
+		bindings = bindings.Bind(Binding{Control: pad, Command: mappings.Mute})
+
+		assert.Equal(t, Bindings{{Control: pad, Command: mappings.Mute}}, bindings)
+	})
+
+	t.Run("Merge keeps bindings of other controls", func(t *testing.T) {
+		bindings := Bindings{{Control: pad, Command: mappings.PlayStop}, {Control: knob, Parameter: ParameterTempo}}
+
+		merged := bindings.Merge(Bindings{{Control: pad, Command: mappings.Solo}})
+
+		binding, ok := merged.Find(pad)
+		assert.True(t, ok)
+		assert.Equal(t, mappings.Solo, binding.Command)
+		binding, ok = merged.Find(knob)
+		assert.True(t, ok)
+		assert.Equal(t, ParameterTempo, binding.Parameter)
+	})
+
+	t.Run("From config leaves out unknown commands", func(t *testing.T) {
+		bindings := FromConfig([]config.Control{
+			{Type: "NOTE", Channel: 10, Number: 36, Command: "PlayStop"},
+			{Type: "NOTE", Channel: 10, Number: 37, Command: "NotACommand"},
+			{Type: "CC", Channel: 1, Number: 20, Parameter: "AccentEnd"},
+		})
+
+		assert.Equal(t, Bindings{
+			{Control: pad, Command: mappings.PlayStop},
+			{Control: knob, Parameter: ParameterAccentEnd},
+		}, bindings)
+	})
+}
+
+func TestLearn(t *testing.T) {
+	t.Run("Waits for a target", func(t *testing.T) {
+		_, ok := Learn{Active: true}.Bind(Control{KindNote, 1, 60})
+		assert.False(t, ok)
+	})
+
+	t.Run("Parameters are bound to control changes only", func(t *testing.T) {
+		learn := Learn{Active: true, HasTarget: true, Parameter: ParameterTempo}
+
+		_, ok := learn.Bind(Control{KindNote, 1, 60})
+		assert.False(t, ok)
+		binding, ok := learn.Bind(Control{KindCC, 1, 20})
+		assert.True(t, ok)
+		assert.Equal(t, "Tempo", binding.Target())
+	})
+}
@@ -0,0 +1,115 @@
+package grid
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Condition is a trig condition of a note, a note with a condition plays only
+// in the key cycles the condition holds for.
+type Condition uint8
+
+const (
+	ConditionNone Condition = iota
+	ConditionFill
+	ConditionNotFill
+	ConditionPrevious
+	ConditionNotPrevious
+	ConditionFirst
+	ConditionNotFirst
+	// The cycle conditions A:B follow, from 1:2 up to 8:8
+	conditionCycles
+)
+
+// The longest cycle of a cycle condition
+const maxConditionCycle = 8
+
+var conditionNames = []string{"", "fill", "not fill", "previous", "not previous", "first", "not first"}
+
+// Conditions returns every condition in the order they are edited in.
+func Conditions() []Condition {
+	conditions := make([]Condition, 0, int(conditionCycles)+35)
+	for c := range conditionCycles {
+		conditions = append(conditions, c)
+	}
+	for cycles := uint8(2); cycles <= maxConditionCycle; cycles++ {
+		for cycle := uint8(1); cycle <= cycles; cycle++ {
+			conditions = append(conditions, CycleCondition(cycle, cycles))
+		}
+	}
+	return conditions
+}
+
+// CycleCondition returns the condition that holds in the cycle-th key cycle
+// of every cycles key cycles, written cycle:cycles.
+func CycleCondition(cycle uint8, cycles uint8) Condition {
+	condition := conditionCycles
+	for c := uint8(2); c < cycles; c++ {
+		condition += Condition(c)
+	}
+	return condition + Condition(cycle-1)
+}
+
+// Cycle returns the cycle and the number of cycles of a cycle condition.
+func (c Condition) Cycle() (uint8, uint8, bool) {
+	if c < conditionCycles {
+		return 0, 0, false
+	}
+	index := uint8(c - conditionCycles)
+	for cycles := uint8(2); cycles <= maxConditionCycle; cycles++ {
+		if index < cycles {
+			return index + 1, cycles, true
+		}
+		index -= cycles
+	}
+	return 0, 0, false
+}
+
+// Holds reports whether the condition holds in a key cycle.  Cycles count from
+// 1, previous is whether the last note with a condition on the same line
+// played.
+func (c Condition) Holds(cycle int, first bool, fill bool, previous bool) bool {
+	switch c {
+	case ConditionNone:
+		return true
+	case ConditionFill:
+		return fill
+	case ConditionNotFill:
+		return !fill
+	case ConditionPrevious:
+		return previous
+	case ConditionNotPrevious:
+		return !previous
+	case ConditionFirst:
+		return first
+	case ConditionNotFirst:
+		return !first
+	}
+	a, b, ok := c.Cycle()
+	return ok && cycle > 0 && (cycle-1)%int(b) == int(a-1)
+}
+
+func (c Condition) String() string {
+	if c < conditionCycles {
+		return conditionNames[c]
+	}
+	if a, b, ok := c.Cycle(); ok {
+		return fmt.Sprintf("%d:%d", a, b)
+	}
+	return fmt.Sprintf("condition %d", c)
+}
+
+// ParseCondition returns the condition with the given name, the name given by
+// String.
+func ParseCondition(name string) (Condition, bool) {
+	i := slices.IndexFunc(Conditions(), func(c Condition) bool { return c.String() == name })
+	if i < 0 {
+		return ConditionNone, false
+	}
+	return Conditions()[i], true
+}
+
+// IsValid reports whether the condition is one of the known conditions.
+func (c Condition) IsValid() bool {
+	return int(c) < len(Conditions())
+}
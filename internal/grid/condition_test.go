@@ -0,0 +1,68 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition(t *testing.T) {
+	t.Run("Cycle conditions", func(t *testing.T) {
+		a, b, ok := CycleCondition(3, 4).Cycle()
+		assert.True(t, ok)
+		assert.Equal(t, uint8(3), a)
+		assert.Equal(t, uint8(4), b)
+
+		_, _, ok = ConditionFill.Cycle()
+		assert.False(t, ok)
+	})
+
+	t.Run("Names round trip", func(t *testing.T) {
+		for _, condition := range Conditions() {
+			parsed, ok := ParseCondition(condition.String())
+			assert.True(t, ok, condition.String())
+			assert.Equal(t, condition, parsed)
+		}
+		_, ok := ParseCondition("sometimes")
+		assert.False(t, ok)
+		assert.False(t, Condition(len(Conditions())).IsValid())
+	})
+
+	t.Run("Holds", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			condition Condition
+			cycles    []int
+			expected  []bool
+		}{
+			{"1:2 plays every other cycle", CycleCondition(1, 2), []int{1, 2, 3, 4}, []bool{true, false, true, false}},
+			{"3:4 plays the third of four cycles", CycleCondition(3, 4), []int{1, 2, 3, 4, 5, 6, 7, 8}, []bool{false, false, true, false, false, false, true, false}},
+			{"no condition plays always", ConditionNone, []int{1, 2}, []bool{true, true}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				for i, cycle := range tt.cycles {
+					assert.Equal(t, tt.expected[i], tt.condition.Holds(cycle, false, false, false), "cycle %d", cycle)
+				}
+			})
+		}
+
+		assert.True(t, ConditionFill.Holds(1, false, true, false))
+		assert.False(t, ConditionNotFill.Holds(1, false, true, false))
+		assert.True(t, ConditionFirst.Holds(1, true, false, false))
+		assert.True(t, ConditionNotFirst.Holds(2, false, false, false))
+		assert.True(t, ConditionPrevious.Holds(1, false, false, true))
+		assert.False(t, ConditionNotPrevious.Holds(1, false, false, true))
+	})
+
+	t.Run("Increment probability", func(t *testing.T) {
+		note := InitNote()
+		assert.Equal(t, uint8(100), note.Chance())
+		note = note.IncrementProbability(-1)
+		assert.Equal(t, uint8(90), note.Chance())
+		note = note.IncrementProbability(1).IncrementProbability(1)
+		assert.Equal(t, uint8(0), note.Probability)
+		note = note.IncrementProbability(-12)
+		assert.Equal(t, uint8(10), note.Chance())
+	})
+}
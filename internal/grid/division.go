@@ -0,0 +1,85 @@
+package grid
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Division is the clock division of a line.  A line with a division of n
+// steps once every n beats, and a line with a division of -n steps n times in
+// every beat, written /n and xn.  Zero steps once in every beat.
+type Division int8
+
+// The divisions in the order they are edited in, from fastest to slowest
+var divisions = []Division{-4, -3, -2, 0, 2, 3, 4, 5, 6, 7, 8}
+
+// BeatsPerStep returns the number of beats a step of the line lasts.
+func (d Division) BeatsPerStep() uint8 {
+	if d > 1 {
+		return uint8(d)
+	}
+	return 1
+}
+
+// StepsPerBeat returns the number of steps the line plays in every beat.
+func (d Division) StepsPerBeat() uint8 {
+	if d < -1 {
+		return uint8(-d)
+	}
+	return 1
+}
+
+func (d Division) String() string {
+	switch {
+	case d > 1:
+		return fmt.Sprintf("/%d", d)
+	case d < -1:
+		return fmt.Sprintf("x%d", -d)
+	}
+	return ""
+}
+
+// ParseDivision returns the division written by String.
+func ParseDivision(s string) (Division, bool) {
+	var division Division
+	if value, found := strings.CutPrefix(s, "/"); found {
+		n, err := strconv.ParseUint(value, 10, 7)
+		if err != nil {
+			return 0, false
+		}
+		division = Division(n)
+	} else if value, found := strings.CutPrefix(s, "x"); found {
+		n, err := strconv.ParseUint(value, 10, 7)
+		if err != nil {
+			return 0, false
+		}
+		division = -Division(n)
+	} else if s != "" {
+		return 0, false
+	}
+	if division == 1 || division == -1 {
+		division = 0
+	}
+	return division, slices.Contains(divisions, division)
+}
+
+// IncrementDivision moves the division of the line through the divisions, a
+// positive modifier slows the line down.
+func (l *LineDefinition) IncrementDivision(modifier int) {
+	index := slices.Index(divisions, l.Division)
+	if index < 0 {
+		index = slices.Index(divisions, 0)
+	}
+	l.Division = divisions[max(min(index+modifier, len(divisions)-1), 0)]
+}
+
+// Steps returns the number of steps of the line in a part of beats, the length
+// of the line when it is shorter than the part.
+func (l LineDefinition) Steps(beats uint8) uint8 {
+	if l.Length > 0 && l.Length < beats {
+		return l.Length
+	}
+	return beats
+}
@@ -0,0 +1,40 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDivision(t *testing.T) {
+	t.Run("String and ParseDivision round trip", func(t *testing.T) {
+		for _, division := range divisions {
+			parsed, ok := ParseDivision(division.String())
+			assert.True(t, ok)
+			assert.Equal(t, division, parsed)
+		}
+	})
+
+	t.Run("ParseDivision rejects unknown divisions", func(t *testing.T) {
+		for _, s := range []string{"/9", "x5", "2", "/", "x-2"} {
+			_, ok := ParseDivision(s)
+			assert.False(t, ok, s)
+		}
+	})
+
+	t.Run("IncrementDivision stops at the ends", func(t *testing.T) {
+		line := LineDefinition{}
+		line.IncrementDivision(1)
+		assert.Equal(t, Division(2), line.Division)
+		line.IncrementDivision(-2)
+		assert.Equal(t, Division(-2), line.Division)
+		line.IncrementDivision(-10)
+		assert.Equal(t, Division(-4), line.Division)
+	})
+
+	t.Run("Steps is the length of a shorter line", func(t *testing.T) {
+		assert.Equal(t, uint8(16), LineDefinition{}.Steps(16))
+		assert.Equal(t, uint8(5), LineDefinition{Length: 5}.Steps(16))
+		assert.Equal(t, uint8(8), LineDefinition{Length: 12}.Steps(8))
+	})
+}
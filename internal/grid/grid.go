@@ -65,16 +65,28 @@ type Note struct {
 	Action      Action
 	WaitIndex   uint8
 	GateIndex   int16
+	// The chance in percent that the note plays, 0 plays always
+	Probability uint8
+	Condition   Condition
+	Locks       Locks
 }
 
 var ZeroNote = Note{}
 
 func InitNote() Note {
-	return Note{5, InitRatchet(), ActionNothing, 0, 0}
+	return Note{AccentIndex: 5, Ratchets: InitRatchet(), Action: ActionNothing}
 }
 
 func InitActionNote(act Action) Note {
-	return Note{0, InitRatchet(), act, 0, 0}
+	return Note{AccentIndex: 0, Ratchets: InitRatchet(), Action: act}
+}
+
+// Chance returns the chance in percent that the note plays.
+func (n Note) Chance() uint8 {
+	if n.Probability == 0 || n.Probability > 100 {
+		return 100
+	}
+	return n.Probability
 }
 
 func (n Note) IncrementAccent(modifier int8, accentsLength uint8) Note {
@@ -110,6 +122,26 @@ func (n Note) IncrementWait(modifier int8) Note {
 	return n
 }
 
+// IncrementProbability changes the chance of the note playing by 10 percent
+// for each step of the modifier, a chance of 100 percent is kept as 0.
+func (n Note) IncrementProbability(modifier int8) Note {
+	chance := min(max(int(n.Chance())+10*int(modifier), 10), 100)
+	if chance == 100 {
+		n.Probability = 0
+	} else {
+		n.Probability = uint8(chance)
+	}
+	return n
+}
+
+// IncrementCondition moves the condition of the note through the conditions,
+// wrapping around at either end.
+func (n Note) IncrementCondition(modifier int8) Note {
+	count := len(Conditions())
+	n.Condition = Condition(((int(n.Condition)+int(modifier))%count + count) % count)
+	return n
+}
+
 func (n Note) IncrementRatchet(modifier int8) Note {
 	currentRatchet := n.Ratchets.Length
 	// TODO: remove hardcoded ratchets length
@@ -199,6 +231,10 @@ type LineDefinition struct {
 	Note    uint8
 	MsgType MessageType
 	Name    string
+	// The number of steps of the line, 0 steps through every beat of the
+	// part
+	Length   uint8
+	Division Division
 }
 
 func (l *LineDefinition) IncrementChannel() {
@@ -0,0 +1,100 @@
+package grid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lock is a parameter lock, a CC value sent just before the note it is on.
+type Lock struct {
+	Control uint8
+	Value   uint8
+}
+
+// MaxLocks is the number of locks of a note.  Notes are compared with ==, so
+// the locks of a note are kept in an array rather than a slice
+const MaxLocks = 4
+
+// Locks are the parameter locks of a note, at most one for each control.
+type Locks struct {
+	Count uint8
+	Locks [MaxLocks]Lock
+}
+
+// All returns the locks in the order they were added.
+func (l Locks) All() []Lock {
+	return l.Locks[:min(l.Count, MaxLocks)]
+}
+
+// Value returns the value a control is locked to.
+func (l Locks) Value(control uint8) (uint8, bool) {
+	for _, lock := range l.All() {
+		if lock.Control == control {
+			return lock.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Set locks a control to a value, replacing the value of a control that is
+// already locked.  It reports false when every lock of the note is taken.
+func (l Locks) Set(control uint8, value uint8) (Locks, bool) {
+	for i, lock := range l.All() {
+		if lock.Control == control {
+			l.Locks[i].Value = value
+			return l, true
+		}
+	}
+	if l.Count >= MaxLocks {
+		return l, false
+	}
+	l.Locks[l.Count] = Lock{Control: control, Value: value}
+	l.Count++
+	return l, true
+}
+
+// Remove unlocks a control.
+func (l Locks) Remove(control uint8) Locks {
+	var locks Locks
+	for _, lock := range l.All() {
+		if lock.Control != control {
+			locks, _ = locks.Set(lock.Control, lock.Value)
+		}
+	}
+	return locks
+}
+
+func (l Locks) String() string {
+	var locks []string
+	for _, lock := range l.All() {
+		locks = append(locks, fmt.Sprintf("%d:%d", lock.Control, lock.Value))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(locks, ","))
+}
+
+// ParseLocks returns the locks written by String.
+func ParseLocks(s string) (Locks, bool) {
+	var locks Locks
+	s, found := strings.CutPrefix(s, "{")
+	if !found {
+		return locks, false
+	}
+	s, found = strings.CutSuffix(s, "}")
+	if !found {
+		return locks, false
+	}
+	if s == "" {
+		return locks, true
+	}
+	for lockStr := range strings.SplitSeq(s, ",") {
+		var control, value uint8
+		if _, err := fmt.Sscanf(lockStr, "%d:%d", &control, &value); err != nil || control > 127 || value > 127 {
+			return Locks{}, false
+		}
+		var ok bool
+		if locks, ok = locks.Set(control, value); !ok {
+			return Locks{}, false
+		}
+	}
+	return locks, true
+}
@@ -0,0 +1,48 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocks(t *testing.T) {
+	t.Run("Set replaces the value of a locked control", func(t *testing.T) {
+		locks, ok := Locks{}.Set(74, 90)
+		assert.True(t, ok)
+		locks, _ = locks.Set(71, 30)
+		locks, _ = locks.Set(74, 100)
+		assert.Equal(t, []Lock{{74, 100}, {71, 30}}, locks.All())
+	})
+
+	t.Run("Set reports a full set of locks", func(t *testing.T) {
+		var locks Locks
+		for control := range uint8(MaxLocks) {
+			locks, _ = locks.Set(control, 1)
+		}
+		_, ok := locks.Set(100, 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("Remove keeps the locks comparable", func(t *testing.T) {
+		locks, _ := Locks{}.Set(74, 90)
+		locks, _ = locks.Set(71, 30)
+		expected, _ := Locks{}.Set(71, 30)
+		assert.Equal(t, expected, locks.Remove(74))
+		assert.Equal(t, Locks{}, expected.Remove(71))
+	})
+
+	t.Run("Parse what String writes", func(t *testing.T) {
+		locks, _ := Locks{}.Set(74, 90)
+		locks, _ = locks.Set(71, 30)
+		assert.Equal(t, "{74:90,71:30}", locks.String())
+		parsed, ok := ParseLocks(locks.String())
+		assert.True(t, ok)
+		assert.Equal(t, locks, parsed)
+
+		for _, invalid := range []string{"74:90", "{74:200}", "{74}", "{1:1,2:2,3:3,4:4,5:5}"} {
+			_, ok := ParseLocks(invalid)
+			assert.False(t, ok, invalid)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Package groove moves and scales notes by their position in a repeating
+// pattern of beats so that the timing feel of a part can be changed without
+// editing the wait of every note.
+package groove
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Groove is a named pattern of steps, one for each position of the pattern.
+type Groove struct {
+	Name  string
+	Steps []Step
+}
+
+// Step is the timing and velocity of the notes at one position of a groove.
+type Step struct {
+	// Percentage of a beat that the notes of the position are delayed
+	Offset uint8
+	// Percentage of the velocity that the notes of the position keep
+	Velocity uint8
+}
+
+var straight = Step{Offset: 0, Velocity: 100}
+
+// Step returns the step of a beat, the pattern of steps repeating from the
+// first beat of the part.
+func (g Groove) Step(beat uint8) Step {
+	if len(g.Steps) == 0 {
+		return straight
+	}
+	return g.Steps[int(beat)%len(g.Steps)]
+}
+
+// Swing delays every other beat so that the first beat of each pair takes
+// percent of the length of the pair, like the swing setting of a drum machine.
+func Swing(percent uint8) Groove {
+	return Groove{
+		Name:  fmt.Sprintf("Swing %d%%", percent),
+		Steps: []Step{straight, {Offset: (percent - 50) * 2, Velocity: 100}},
+	}
+}
+
+// Defaults are the grooves available without any configuration.
+var Defaults = []Groove{Swing(54), Swing(58), Swing(62), Swing(66), Swing(71)}
+
+// Find returns the groove with the given name.
+func Find(grooves []Groove, name string) (Groove, bool) {
+	index := slices.IndexFunc(grooves, func(g Groove) bool {
+		return g.Name == name
+	})
+	if index < 0 {
+		return Groove{}, false
+	}
+	return grooves[index], true
+}
+
+// MaxAmount is the amount of a setting that plays the groove as it is defined.
+const MaxAmount = 100
+
+// Setting applies a groove to a part, or to some lines of a part, with an
+// amount between straight and the full groove.
+type Setting struct {
+	Name   string
+	Amount uint8
+	// No lines applies the groove to every line of the part
+	Lines []uint8
+}
+
+// Offset returns the percentage of a beat that the notes of the step are
+// delayed by.
+func (s Setting) Offset(step Step) float64 {
+	return float64(step.Offset) * float64(s.Amount) / MaxAmount
+}
+
+// Velocity returns the velocity scaled by the step, staying within the range
+// of midi velocities.
+func (s Setting) Velocity(velocity uint8, step Step) uint8 {
+	if velocity == 0 || s.Amount == 0 {
+		return velocity
+	}
+	scale := 1 + (float64(step.Velocity)/100-1)*float64(s.Amount)/MaxAmount
+	return uint8(min(max(float64(velocity)*scale+0.5, 1), 127))
+}
+
+func (s Setting) appliesTo(line uint8) bool {
+	return len(s.Lines) == 0 || slices.Contains(s.Lines, line)
+}
+
+// Settings are the grooves of a part, a later setting taking precedence over
+// an earlier one for the lines they share.
+type Settings []Setting
+
+// Find returns the setting of a line.
+func (ss Settings) Find(line uint8) (Setting, bool) {
+	for i := len(ss) - 1; i >= 0; i-- {
+		if ss[i].appliesTo(line) {
+			return ss[i], true
+		}
+	}
+	return Setting{}, false
+}
+
+// Apply returns the settings with the setting applied.  A setting for the
+// whole part replaces every other setting, a setting for some lines takes
+// those lines from the other settings.  Applying no groove to the whole part
+// leaves no settings.
+func (ss Settings) Apply(setting Setting) Settings {
+	if len(setting.Lines) == 0 {
+		if setting.Name == "" {
+			return nil
+		}
+		return Settings{setting}
+	}
+
+	settings := make(Settings, 0, len(ss)+1)
+	for _, existing := range ss {
+		if len(existing.Lines) > 0 {
+			existing.Lines = slices.DeleteFunc(slices.Clone(existing.Lines), func(line uint8) bool {
+				return slices.Contains(setting.Lines, line)
+			})
+			if len(existing.Lines) == 0 {
+				continue
+			}
+		}
+		settings = append(settings, existing)
+	}
+	return append(settings, setting)
+}
+
+func (ss Settings) Equal(other Settings) bool {
+	return slices.EqualFunc(ss, other, func(a, b Setting) bool {
+		return a.Name == b.Name && a.Amount == b.Amount && slices.Equal(a.Lines, b.Lines)
+	})
+}
+
+// Names returns the names of the grooves the settings use.
+func (ss Settings) Names() []string {
+	var names []string
+	for _, setting := range ss {
+		if setting.Name != "" && !slices.Contains(names, setting.Name) {
+			names = append(names, setting.Name)
+		}
+	}
+	return names
+}
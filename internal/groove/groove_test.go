@@ -0,0 +1,96 @@
+package groove
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep(t *testing.T) {
+	t.Run("Steps repeat from the first beat", func(t *testing.T) {
+		g := Groove{Steps: []Step{{0, 100}, {20, 80}, {10, 90}}}
+		assert.Equal(t, Step{20, 80}, g.Step(1))
+		assert.Equal(t, Step{0, 100}, g.Step(3))
+		assert.Equal(t, Step{10, 90}, g.Step(5))
+	})
+
+	t.Run("No steps play straight", func(t *testing.T) {
+		assert.Equal(t, Step{0, 100}, Groove{}.Step(3))
+	})
+}
+
+func TestSwing(t *testing.T) {
+	swing := Swing(58)
+	assert.Equal(t, "Swing 58%", swing.Name)
+	assert.Equal(t, Step{0, 100}, swing.Step(0))
+	assert.Equal(t, Step{16, 100}, swing.Step(1))
+}
+
+func TestSetting(t *testing.T) {
+	step := Step{Offset: 20, Velocity: 50}
+
+	t.Run("Offset scales with the amount", func(t *testing.T) {
+		assert.Equal(t, 20.0, Setting{Amount: 100}.Offset(step))
+		assert.Equal(t, 10.0, Setting{Amount: 50}.Offset(step))
+	})
+
+	t.Run("Velocity scales with the amount", func(t *testing.T) {
+		assert.Equal(t, uint8(50), Setting{Amount: 100}.Velocity(100, step))
+		assert.Equal(t, uint8(75), Setting{Amount: 50}.Velocity(100, step))
+		assert.Equal(t, uint8(100), Setting{Amount: 0}.Velocity(100, step))
+	})
+
+	t.Run("Velocity stays in range", func(t *testing.T) {
+		assert.Equal(t, uint8(127), Setting{Amount: 100}.Velocity(120, Step{Velocity: 200}))
+		assert.Equal(t, uint8(1), Setting{Amount: 100}.Velocity(1, Step{Velocity: 10}))
+		assert.Equal(t, uint8(0), Setting{Amount: 100}.Velocity(0, step))
+	})
+}
+
+func TestSettings(t *testing.T) {
+	t.Run("A part setting replaces every setting", func(t *testing.T) {
+		settings := Settings{{Name: "A", Amount: 100, Lines: []uint8{1}}}
+		settings = settings.Apply(Setting{Name: "B", Amount: 50})
+		assert.Equal(t, Settings{{Name: "B", Amount: 50}}, settings)
+	})
+
+	t.Run("No groove for the part clears the settings", func(t *testing.T) {
+		settings := Settings{{Name: "A", Amount: 100}}
+		assert.Nil(t, settings.Apply(Setting{}))
+	})
+
+	t.Run("A line setting takes lines from other settings", func(t *testing.T) {
+		settings := Settings{{Name: "A", Amount: 100}, {Name: "B", Amount: 100, Lines: []uint8{1, 2}}}
+		settings = settings.Apply(Setting{Name: "C", Amount: 100, Lines: []uint8{2, 3}})
+
+		assert.Equal(t, Settings{
+			{Name: "A", Amount: 100},
+			{Name: "B", Amount: 100, Lines: []uint8{1}},
+			{Name: "C", Amount: 100, Lines: []uint8{2, 3}},
+		}, settings)
+
+		setting, ok := settings.Find(0)
+		assert.True(t, ok)
+		assert.Equal(t, "A", setting.Name)
+		setting, _ = settings.Find(1)
+		assert.Equal(t, "B", setting.Name)
+		setting, _ = settings.Find(3)
+		assert.Equal(t, "C", setting.Name)
+	})
+
+	t.Run("A setting without lines left is removed", func(t *testing.T) {
+		settings := Settings{{Name: "A", Amount: 100, Lines: []uint8{1}}}
+		settings = settings.Apply(Setting{Name: "B", Amount: 100, Lines: []uint8{1}})
+		assert.Equal(t, Settings{{Name: "B", Amount: 100, Lines: []uint8{1}}}, settings)
+	})
+
+	t.Run("No settings", func(t *testing.T) {
+		_, ok := Settings{}.Find(0)
+		assert.False(t, ok)
+	})
+
+	t.Run("Names", func(t *testing.T) {
+		settings := Settings{{Name: "A"}, {Name: "B", Lines: []uint8{1}}, {Name: "A", Lines: []uint8{2}}}
+		assert.Equal(t, []string{"A", "B"}, settings.Names())
+	})
+}
@@ -0,0 +1,77 @@
+// Package library lists the sequence files of a project directory, so that
+// the songs of a set can be opened one after the other without restarting.
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/renderer"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// Entry is a sequence file of the library with the settings read from it.
+type Entry struct {
+	Filename string
+	Tempo    int
+	Parts    int
+	// How long the whole arrangement plays when played once
+	Length time.Duration
+	// A file that cannot be read is listed with the reason
+	Err error
+}
+
+// Name returns the name of the file without the directory.
+func (e Entry) Name() string {
+	return filepath.Base(e.Filename)
+}
+
+// IsDir reports whether sq is started on a directory instead of a file.
+func IsDir(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.IsDir()
+}
+
+// Scan lists the sequence files of a directory in order of their names.
+func Scan(dir string) ([]Entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fault.Wrap(err, fmsg.WithDesc("cannot read directory", fmt.Sprintf("Could not list the sequences of %s", dir)))
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		// Recovery files and undo histories are hidden
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || !slices.Contains(sequence.Extensions, strings.ToLower(filepath.Ext(file.Name()))) {
+			continue
+		}
+		entries = append(entries, Read(filepath.Join(dir, file.Name())))
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Filename, b.Filename) })
+	return entries, nil
+}
+
+// Read returns the entry of a sequence file.
+func Read(filename string) Entry {
+	entry := Entry{Filename: filename}
+	definition, err := sequence.Read(filename)
+	if err != nil {
+		entry.Err = err
+		return entry
+	}
+	if definition.Parts == nil || len(*definition.Parts) == 0 || definition.Arrangement == nil {
+		entry.Err = fault.New("sequence without parts", fmsg.WithDesc("sequence without parts", fmt.Sprintf("%s has no parts to play", filename)))
+		return entry
+	}
+
+	entry.Tempo = definition.Tempo
+	entry.Parts = len(*definition.Parts)
+	entry.Length = renderer.SongDuration(definition)
+	return entry
+}
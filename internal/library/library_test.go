@@ -0,0 +1,38 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	song := sequence.InitSequence("", "")
+	song.Tempo = 60
+	assert.NoError(t, sequence.Write(song, filepath.Join(dir, "b.sq")))
+	assert.NoError(t, sequence.Write(song, filepath.Join(dir, "a.sq")))
+	assert.NoError(t, sequence.Write(song, filepath.Join(dir, "c.yaml")))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.sq"), []byte("GridKey(0,0)"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".a.sq.recovery"), []byte{}, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte{}, 0644))
+
+	entries, err := Scan(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, []string{"a.sq", "b.sq", "broken.sq", "c.yaml"}, []string{entries[0].Name(), entries[1].Name(), entries[2].Name(), entries[3].Name()})
+		assert.Equal(t, 60, entries[0].Tempo)
+		assert.Equal(t, 1, entries[0].Parts)
+		// 32 beats of 2 subdivisions at 60 bpm
+		assert.Equal(t, 16*time.Second, entries[0].Length)
+		assert.Error(t, entries[2].Err)
+		assert.Equal(t, 60, entries[3].Tempo)
+	}
+
+	_, err = Scan(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package link
+
+import (
+	"context"
+	"net"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+)
+
+// GroupAddress is an administratively scoped multicast group, which does not
+// leave the local network
+const GroupAddress = "239.255.83.81:20812"
+
+const maxMessageSize = 1024
+
+// Conn sends messages to and receives messages from every peer on the
+// multicast group, including the other peers on this machine.
+type Conn struct {
+	in  *net.UDPConn
+	out *net.UDPConn
+}
+
+func Listen() (*Conn, error) {
+	group, err := net.ResolveUDPAddr("udp4", GroupAddress)
+	if err != nil {
+		return nil, fault.Wrap(err, fmsg.With("cannot resolve link group address"))
+	}
+	in, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fault.Wrap(err, fmsg.WithDesc("cannot join link group", "Network sync needs a network interface that supports multicast"))
+	}
+	// ListenMulticastUDP turns off multicast loopback for its own socket
+	// only, sending from a second socket reaches the peers on this machine
+	out, err := net.DialUDP("udp4", nil, group)
+	if err != nil {
+		in.Close()
+		return nil, fault.Wrap(err, fmsg.With("cannot open link send socket"))
+	}
+	return &Conn{in: in, out: out}, nil
+}
+
+func (c *Conn) Send(message Message) error {
+	data, err := message.MarshalBinary()
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot send link message"))
+	}
+	_, err = c.out.Write(data)
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot send link message"))
+	}
+	return nil
+}
+
+// Receive reads messages until the connection is closed or the context is
+// done, passing them to messageChannel.  Anything that is not a link message
+// is ignored.
+func (c *Conn) Receive(ctx context.Context, messageChannel chan<- Message) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := c.in.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var message Message
+		if message.UnmarshalBinary(buf[:n]) != nil {
+			continue
+		}
+		select {
+		case messageChannel <- message:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Conn) Close() error {
+	errIn := c.in.Close()
+	errOut := c.out.Close()
+	if errIn != nil {
+		return fault.Wrap(errIn, fmsg.With("cannot close link connection"))
+	}
+	if errOut != nil {
+		return fault.Wrap(errOut, fmsg.With("cannot close link connection"))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package link
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+)
+
+type Kind uint8
+
+const (
+	KindState Kind = iota + 1
+	KindPing
+	KindPong
+	KindLeave
+)
+
+// Message is sent by a peer to every other peer of the session.  Pings and
+// pongs are addressed to a single peer with To.
+type Message struct {
+	Kind Kind
+	From NodeID
+	To   NodeID
+	// The reference the ghost times of the state were measured against,
+	// zero when they were not measured
+	Reference NodeID
+	State     State
+	// The local time of the peer that sent the ping
+	Sent time.Duration
+	// The ghost time of the peer that sent the pong
+	Ghost time.Duration
+}
+
+// Every message starts with this header and a protocol version so that
+// other traffic on the multicast group is ignored
+var header = []byte("sqlk")
+
+const protocolVersion = 1
+
+type wireMessage struct {
+	Kind      Kind
+	From      NodeID
+	To        NodeID
+	Reference NodeID
+	Version   uint64
+	Author    NodeID
+	Tempo     int32
+	Beat      float64
+	Time      int64
+	Playing   bool
+	StartBeat float64
+	Sent      int64
+	Ghost     int64
+}
+
+func (m Message) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte(protocolVersion)
+	wire := wireMessage{
+		Kind:      m.Kind,
+		From:      m.From,
+		To:        m.To,
+		Reference: m.Reference,
+		Version:   m.State.Version,
+		Author:    m.State.Author,
+		Tempo:     int32(m.State.Timeline.Tempo),
+		Beat:      m.State.Timeline.Beat,
+		Time:      int64(m.State.Timeline.Time),
+		Playing:   m.State.Playing,
+		StartBeat: m.State.StartBeat,
+		Sent:      int64(m.Sent),
+		Ghost:     int64(m.Ghost),
+	}
+	err := binary.Write(&buf, binary.BigEndian, wire)
+	if err != nil {
+		return nil, fault.Wrap(err, fmsg.With("cannot encode link message"))
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Message) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, header) {
+		return fault.New("not a link message")
+	}
+	data = data[len(header):]
+	if len(data) == 0 || data[0] != protocolVersion {
+		return fault.New("unsupported link protocol version")
+	}
+
+	var wire wireMessage
+	err := binary.Read(bytes.NewReader(data[1:]), binary.BigEndian, &wire)
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot decode link message"))
+	}
+	*m = Message{
+		Kind:      wire.Kind,
+		From:      wire.From,
+		To:        wire.To,
+		Reference: wire.Reference,
+		State: State{
+			Version:   wire.Version,
+			Author:    wire.Author,
+			Timeline:  Timeline{Tempo: int(wire.Tempo), Beat: wire.Beat, Time: time.Duration(wire.Time)},
+			Playing:   wire.Playing,
+			StartBeat: wire.StartBeat,
+		},
+		Sent:  time.Duration(wire.Sent),
+		Ghost: time.Duration(wire.Ghost),
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package link
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageBinary(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		message := Message{
+			Kind:      KindState,
+			From:      3,
+			Reference: 1,
+			State: State{
+				Version:   7,
+				Author:    3,
+				Timeline:  Timeline{Tempo: 128, Beat: 12.5, Time: 3 * time.Second},
+				Playing:   true,
+				StartBeat: 16,
+			},
+			Sent:  time.Second,
+			Ghost: 2 * time.Second,
+		}
+		data, err := message.MarshalBinary()
+		assert.NoError(t, err)
+
+		var decoded Message
+		err = decoded.UnmarshalBinary(data)
+		assert.NoError(t, err)
+		assert.Equal(t, message, decoded)
+	})
+
+	t.Run("other traffic is rejected", func(t *testing.T) {
+		var decoded Message
+		assert.Error(t, decoded.UnmarshalBinary([]byte("hello")))
+		assert.Error(t, decoded.UnmarshalBinary([]byte("sqlk\x09")))
+		assert.Error(t, decoded.UnmarshalBinary([]byte("sqlk\x01short")))
+	})
+}
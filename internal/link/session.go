@@ -0,0 +1,321 @@
+// Package link shares tempo, beat phase and start/stop between sq instances
+// on a local network, following the session model of Ableton Link.
+//
+// Every peer in a session agrees on a timeline that maps a shared clock, the
+// ghost time, to beats.  Any peer may change the tempo or start and stop the
+// session, and the most recent change wins.  Ghost time is the clock of the
+// peer with the lowest node id, which every other peer measures its offset to.
+package link
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Peers start together on the next bar of the timeline
+const Quantum = 4
+
+// HeartbeatInterval is how often peers announce state and measure ghost time
+const HeartbeatInterval = 250 * time.Millisecond
+
+// A peer that has not been heard from in this long has left the session
+const peerTimeout = 5 * HeartbeatInterval
+
+// A new peer listens this long for a session to join before founding one
+const discoveryInterval = 2 * HeartbeatInterval
+
+// The number of offset measurements averaged to find the ghost time
+const offsetSamples = 8
+
+type NodeID uint64
+
+// NewNodeID returns a random node id.  Zero is never a node id, it marks a
+// message whose ghost times were not measured.
+func NewNodeID() NodeID {
+	return NodeID(rand.Uint64N(1<<64-1) + 1)
+}
+
+// Timeline maps time to beats.  Beat is the beat of the timeline at Time and
+// the timeline moves forward at Tempo beats per minute.  Peers send timelines
+// in ghost time and keep them in local time.
+type Timeline struct {
+	Tempo int
+	Beat  float64
+	Time  time.Duration
+}
+
+func (tl Timeline) BeatAt(t time.Duration) float64 {
+	return tl.Beat + (t-tl.Time).Minutes()*float64(tl.Tempo)
+}
+
+func (tl Timeline) TimeAt(beat float64) time.Duration {
+	return tl.Time + time.Duration((beat-tl.Beat)/float64(tl.Tempo)*float64(time.Minute))
+}
+
+// WithTempo returns a timeline at the new tempo that is at the same beat at
+// t, so that changing the tempo never moves the beat phase.
+func (tl Timeline) WithTempo(tempo int, t time.Duration) Timeline {
+	return Timeline{Tempo: tempo, Beat: tl.BeatAt(t), Time: t}
+}
+
+func (tl Timeline) shift(offset time.Duration) Timeline {
+	tl.Time += offset
+	return tl
+}
+
+// State is everything the peers of a session agree on.  Version and Author
+// order the changes so that every peer settles on the same state.
+type State struct {
+	Version   uint64
+	Author    NodeID
+	Timeline  Timeline
+	Playing   bool
+	StartBeat float64
+}
+
+// Supersedes returns true if every peer should prefer this state to other.
+func (s State) Supersedes(other State) bool {
+	if s.Version != other.Version {
+		return s.Version > other.Version
+	}
+	return s.Author < other.Author
+}
+
+// Session is one peer's view of a session.  It does no networking of its own,
+// instead it receives the messages of other peers and returns the messages it
+// needs to send.
+type Session struct {
+	id        NodeID
+	clock     func() time.Duration
+	joined    time.Duration
+	founded   bool
+	state     State
+	peers     map[NodeID]time.Duration
+	reference NodeID
+	offset    time.Duration
+	samples   []time.Duration
+	// A state adopted before the ghost time was measured is placed in
+	// local time again by the next peer that has measured it
+	stateSynced bool
+}
+
+// NewSession creates a peer that has not yet joined a session.  clock returns
+// the local time, which must never move backwards.
+func NewSession(id NodeID, tempo int, clock func() time.Duration) *Session {
+	now := clock()
+	return &Session{
+		id:          id,
+		clock:       clock,
+		joined:      now,
+		state:       State{Author: id, Timeline: Timeline{Tempo: tempo, Time: now}},
+		peers:       make(map[NodeID]time.Duration),
+		reference:   id,
+		stateSynced: true,
+	}
+}
+
+// MonotonicClock returns a clock that counts up from the moment it is created.
+func MonotonicClock() func() time.Duration {
+	epoch := time.Now()
+	return func() time.Duration {
+		return time.Since(epoch)
+	}
+}
+
+func (s Session) ID() NodeID {
+	return s.id
+}
+
+// State returns the state of the session with the timeline in local time.
+func (s Session) State() State {
+	return s.state
+}
+
+func (s Session) Peers() int {
+	return len(s.peers)
+}
+
+// Synced returns true once the ghost time has been measured.
+func (s Session) Synced() bool {
+	return s.reference == s.id || len(s.samples) > 0
+}
+
+// Until returns how long it is until the timeline reaches beat.
+func (s Session) Until(beat float64) time.Duration {
+	return s.state.Timeline.TimeAt(beat) - s.clock()
+}
+
+// BeatNow returns the current beat of the timeline.
+func (s Session) BeatNow() float64 {
+	return s.state.Timeline.BeatAt(s.clock())
+}
+
+// SetTempo changes the tempo of the session, returning false if the tempo is
+// unchanged.  Until the session is founded or joined the tempo is only the
+// tempo this peer will found a session with.
+func (s *Session) SetTempo(tempo int) (Message, bool) {
+	if tempo <= 0 || tempo == s.state.Timeline.Tempo {
+		return Message{}, false
+	}
+	s.state.Timeline = s.state.Timeline.WithTempo(tempo, s.clock())
+	if !s.founded {
+		return Message{}, false
+	}
+	return s.change(), true
+}
+
+// Start starts the session on the next bar of the timeline.
+func (s *Session) Start() (Message, bool) {
+	if s.state.Playing {
+		return Message{}, false
+	}
+	s.state.Playing = true
+	s.state.StartBeat = math.Ceil(s.BeatNow()/Quantum) * Quantum
+	return s.change(), true
+}
+
+func (s *Session) Stop() (Message, bool) {
+	if !s.state.Playing {
+		return Message{}, false
+	}
+	s.state.Playing = false
+	return s.change(), true
+}
+
+func (s *Session) change() Message {
+	s.founded = true
+	s.state.Version++
+	s.state.Author = s.id
+	s.stateSynced = true
+	return s.stateMessage()
+}
+
+func (s Session) stateMessage() Message {
+	state := s.state
+	state.Timeline = state.Timeline.shift(s.offset)
+	message := Message{Kind: KindState, From: s.id, State: state}
+	if s.Synced() && s.stateSynced {
+		message.Reference = s.reference
+	}
+	return message
+}
+
+// inFrame returns true if the ghost times of the message were measured
+// against the same reference as this peer.
+func (s Session) inFrame(message Message) bool {
+	return s.Synced() && message.Reference == s.reference
+}
+
+// Heartbeat forgets the peers that have left and returns the messages to
+// send: this peer's state, once it belongs to a session, and a ping to the
+// reference peer to measure the ghost time.
+func (s *Session) Heartbeat() []Message {
+	now := s.clock()
+	for id, seen := range s.peers {
+		if now-seen > peerTimeout {
+			delete(s.peers, id)
+		}
+	}
+	s.updateReference()
+
+	if !s.founded && now-s.joined >= discoveryInterval {
+		// No session was heard, found a new one
+		s.founded = true
+		if s.state.Version == 0 {
+			s.state.Version = 1
+		}
+	}
+
+	var messages []Message
+	if s.founded {
+		messages = append(messages, s.stateMessage())
+	}
+	if s.reference != s.id {
+		messages = append(messages, Message{Kind: KindPing, From: s.id, To: s.reference, Sent: now})
+	}
+	return messages
+}
+
+// Leave returns the message that tells the other peers this peer has gone.
+func (s Session) Leave() Message {
+	return Message{Kind: KindLeave, From: s.id}
+}
+
+// Receive handles a message from another peer, returning any replies and
+// whether the state of the session changed.
+func (s *Session) Receive(message Message) ([]Message, bool) {
+	if message.From == s.id {
+		return nil, false
+	}
+	now := s.clock()
+
+	if message.Kind == KindLeave {
+		delete(s.peers, message.From)
+		s.updateReference()
+		return nil, false
+	}
+
+	s.peers[message.From] = now
+	s.updateReference()
+
+	switch message.Kind {
+	case KindState:
+		state := message.State
+		state.Timeline = state.Timeline.shift(-s.offset)
+		if state.Supersedes(s.state) {
+			s.state = state
+			s.stateSynced = s.inFrame(message)
+			s.founded = s.founded || state.Version > 0
+			return nil, true
+		}
+		if state.Version == s.state.Version && state.Author == s.state.Author && !s.stateSynced && s.inFrame(message) {
+			s.state = state
+			s.stateSynced = true
+			return nil, true
+		}
+	case KindPing:
+		if message.To == s.id {
+			return []Message{{Kind: KindPong, From: s.id, To: message.From, Sent: message.Sent, Ghost: now + s.offset}}, false
+		}
+	case KindPong:
+		if message.To == s.id && message.From == s.reference {
+			// Assume the reply took as long to arrive as the ping
+			s.measure(message.Ghost - (message.Sent+now)/2)
+		}
+	}
+	return nil, false
+}
+
+// updateReference finds the peer with the lowest node id, whose clock is the
+// ghost time.  Measurements to a previous reference no longer apply.
+func (s *Session) updateReference() {
+	reference := s.id
+	for id := range s.peers {
+		if id < reference {
+			reference = id
+		}
+	}
+	if reference != s.reference {
+		s.reference = reference
+		s.samples = s.samples[:0]
+		if reference == s.id {
+			s.offset = 0
+		}
+	}
+}
+
+// measure records a measurement of the offset from local time to ghost time.
+// The timeline is kept in local time so a new offset only changes the ghost
+// times sent to other peers.
+func (s *Session) measure(sample time.Duration) {
+	s.samples = append(s.samples, sample)
+	if len(s.samples) > offsetSamples {
+		s.samples = s.samples[1:]
+	}
+	// The median ignores the measurements delayed by a busy network
+	sorted := slices.Clone(s.samples)
+	slices.Sort(sorted)
+	s.offset = sorted[len(sorted)/2]
+}
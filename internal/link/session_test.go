@@ -0,0 +1,163 @@
+package link
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Duration
+}
+
+func (c *fakeClock) Now() time.Duration {
+	return c.now
+}
+
+// Exchange delivers messages to every other session and the replies back
+// until nothing more is sent.
+func Exchange(messages []Message, sessions ...*Session) {
+	for len(messages) > 0 {
+		var replies []Message
+		for _, message := range messages {
+			for _, session := range sessions {
+				reply, _ := session.Receive(message)
+				replies = append(replies, reply...)
+			}
+		}
+		messages = replies
+	}
+}
+
+func Heartbeat(sessions ...*Session) {
+	for _, session := range sessions {
+		Exchange(session.Heartbeat(), sessions...)
+	}
+}
+
+func TestSessionFounding(t *testing.T) {
+	clock := &fakeClock{}
+	session := NewSession(1, 120, clock.Now)
+
+	assert.Empty(t, session.Heartbeat(), "listens for a session before announcing")
+
+	clock.now = discoveryInterval
+	messages := session.Heartbeat()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, KindState, messages[0].Kind)
+	assert.Equal(t, uint64(1), messages[0].State.Version)
+	assert.Equal(t, 120, messages[0].State.Timeline.Tempo)
+}
+
+func TestSessionJoin(t *testing.T) {
+	clock := &fakeClock{}
+	founder := NewSession(1, 120, clock.Now)
+	clock.now = discoveryInterval
+	founder.Heartbeat()
+
+	joiner := NewSession(2, 90, clock.Now)
+	Heartbeat(founder, joiner)
+
+	assert.Equal(t, 120, joiner.State().Timeline.Tempo, "a joining peer takes the tempo of the session")
+	assert.Equal(t, 1, founder.Peers())
+	assert.Equal(t, 1, joiner.Peers())
+
+	t.Run("any peer changes the tempo", func(t *testing.T) {
+		message, ok := joiner.SetTempo(100)
+		assert.True(t, ok)
+		_, changed := founder.Receive(message)
+		assert.True(t, changed)
+		assert.Equal(t, 100, founder.State().Timeline.Tempo)
+	})
+
+	t.Run("an unchanged tempo is not sent", func(t *testing.T) {
+		_, ok := founder.SetTempo(100)
+		assert.False(t, ok)
+	})
+
+	t.Run("start and stop reach every peer", func(t *testing.T) {
+		message, ok := founder.Start()
+		assert.True(t, ok)
+		joiner.Receive(message)
+		assert.True(t, joiner.State().Playing)
+
+		message, ok = joiner.Stop()
+		assert.True(t, ok)
+		founder.Receive(message)
+		assert.False(t, founder.State().Playing)
+	})
+}
+
+func TestSessionStart(t *testing.T) {
+	clock := &fakeClock{}
+	session := NewSession(1, 120, clock.Now)
+	// 2.6 seconds at 120 bpm is beat 5.2
+	clock.now = 2600 * time.Millisecond
+	message, ok := session.Start()
+	assert.True(t, ok)
+	assert.Equal(t, float64(8), message.State.StartBeat, "starts on the next bar")
+	assert.Equal(t, 1400*time.Millisecond, session.Until(8))
+
+	_, ok = session.Start()
+	assert.False(t, ok, "cannot start twice")
+}
+
+func TestSessionSupersedes(t *testing.T) {
+	assert.True(t, State{Version: 2, Author: 9}.Supersedes(State{Version: 1, Author: 1}))
+	assert.True(t, State{Version: 1, Author: 1}.Supersedes(State{Version: 1, Author: 9}))
+	assert.False(t, State{Version: 1, Author: 9}.Supersedes(State{Version: 1, Author: 1}))
+}
+
+func TestSessionGhostTime(t *testing.T) {
+	// The peers started their clocks 10 seconds apart
+	founderClock := &fakeClock{now: 10 * time.Second}
+	joinerClock := &fakeClock{}
+	founder := NewSession(2, 120, founderClock.Now)
+	joiner := NewSession(3, 120, joinerClock.Now)
+	founderClock.now += discoveryInterval
+	joinerClock.now += discoveryInterval
+
+	message, _ := founder.Start()
+	Exchange([]Message{message}, joiner)
+	assert.False(t, joiner.Synced())
+	Heartbeat(joiner, founder)
+	assert.True(t, joiner.Synced())
+	Heartbeat(founder, joiner)
+
+	assert.Equal(t, founder.Until(4), joiner.Until(4), "both peers start at the same moment")
+	assert.Equal(t, founder.BeatNow(), joiner.BeatNow())
+
+	t.Run("a lower node id becomes the reference", func(t *testing.T) {
+		reference := NewSession(1, 120, (&fakeClock{now: time.Hour}).Now)
+		// The peers hear the new reference, measure it, then place the
+		// timeline for it
+		for range 3 {
+			Heartbeat(reference, founder, joiner)
+		}
+		assert.Equal(t, founder.Until(4), joiner.Until(4))
+		assert.Equal(t, founder.Until(4), reference.Until(4))
+	})
+}
+
+func TestSessionPeers(t *testing.T) {
+	clock := &fakeClock{}
+	first := NewSession(1, 120, clock.Now)
+	second := NewSession(2, 120, clock.Now)
+	third := NewSession(3, 120, clock.Now)
+	Heartbeat(first, second, third)
+	clock.now = discoveryInterval
+	Heartbeat(first, second, third)
+	assert.Equal(t, 2, first.Peers())
+
+	t.Run("a peer leaves", func(t *testing.T) {
+		Exchange([]Message{third.Leave()}, first, second)
+		assert.Equal(t, 1, first.Peers())
+	})
+
+	t.Run("a peer times out", func(t *testing.T) {
+		clock.now += peerTimeout + time.Millisecond
+		first.Heartbeat()
+		assert.Equal(t, 0, first.Peers())
+	})
+}
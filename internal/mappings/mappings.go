@@ -72,12 +72,30 @@ const (
 	ToggleAccentNoteMode
 	ToggleRatchetMode
 	ToggleRatchetNoteMode
+	ToggleChanceMode
+	ToggleChanceNoteMode
+	ToggleTrigMode
+	ToggleTrigNoteMode
+	ToggleFill
 	NextOverlay
 	PrevOverlay
 	Save
 	SaveAs
 	Undo
 	Redo
+	UndoEarlier
+	UndoLater
+	ShowUndoTree
+	CaptureSnapshot
+	CapturePartSnapshot
+	ShowSnapshots
+	RemoveSnapshot
+	ShowLibrary
+	LockInputSwitch
+	RemoveLock
+	LineClockInputSwitch
+	MeterInputSwitch
+	ToggleBarPattern
 	New
 	ToggleVisualMode
 	ToggleVisualLineMode
@@ -167,17 +185,24 @@ const (
 	RemoveChord
 	ConvertToNotes
 	ReloadFile
+	ImportMidi
 	OverlayKeyMessage
 	ArrKeyMessage
 	TextInputMessage
 	ConfirmOverlayKey
 	ConfirmRenamePart
 	ConfirmFileName
+	ConfirmImportFile
+	ConfirmRecoverFile
+	ConfirmSnapshotName
+	ConfirmSnapshot
+	ConfirmLibrary
 	ConfirmSelectPart
 	ConfirmChangePart
 	ConfirmConfirmNew
 	ConfirmConfirmReload
 	ConfirmConfirmQuit
+	ConfirmConfirmImport
 	ConfirmEuclidenHits
 	MidiPanic
 	IncreaseAllChannels
@@ -195,6 +220,14 @@ const (
 	Euclidean
 	Reverse
 	Duplicate
+	RecordNotes
+	ToggleRecordMode
+	ToggleRecordOverlay
+	ToggleStepInput
+	LearnControl
+	IncreaseTempo
+	DecreaseTempo
+	GrooveInputSwitch
 )
 
 // CommandDescriptions maps each command to its human-readable description
@@ -235,12 +268,30 @@ var CommandDescriptions = map[Command]string{
 	ToggleAccentNoteMode:   "Toggle accent note mode",
 	ToggleRatchetMode:      "Start Pattern Mode - Ratchet. Use the facilities of pattern mode to increase or decrease the ratchet values of the line",
 	ToggleRatchetNoteMode:  "Toggle ratchet note mode",
+	ToggleChanceMode:       "Start Pattern Mode - Chance. Use the facilities of pattern mode to increase or decrease the probability of the notes of the line playing",
+	ToggleChanceNoteMode:   "Toggle chance note mode",
+	ToggleTrigMode:         "Start Pattern Mode - Trig Condition. Use the facilities of pattern mode to move the notes of the line through the fill, previous, first and A:B key cycle conditions",
+	ToggleTrigNoteMode:     "Toggle trig condition note mode",
+	ToggleFill:             "Toggle fill, notes with the fill condition play only while fill is on",
 	NextOverlay:            "Move to next overlay",
 	PrevOverlay:            "Move to previous overlay",
 	Save:                   "Save the current sequence. If not previously saved, you will be prompted to name the new file. The file will be saved in the directory from which you opened sq",
 	SaveAs:                 "Save the current sequence with a new name",
 	Undo:                   "Undo last action",
 	Redo:                   "Redo last undone action",
+	UndoEarlier:            "Go back to the state before the last edit in time, whichever branch of the undo tree it is on",
+	UndoLater:              "Go forward to the state after the next edit in time, whichever branch of the undo tree it is on",
+	ShowUndoTree:           "Show the branches of the undo tree",
+	CaptureSnapshot:        "Save a named snapshot of the lines, tempo, accents and every part, a snapshot of the same name is replaced",
+	CapturePartSnapshot:    "Save a named snapshot of the current part, a snapshot of the same name is replaced",
+	ShowSnapshots:          "Choose a snapshot to recall with +/- and enter, during playback it is recalled with the next cycle of the keyline. Press x to remove the chosen snapshot",
+	RemoveSnapshot:         "Remove the snapshot chosen to recall",
+	ShowLibrary:            "Choose a sequence of the project directory to open with +/- and enter, unsaved changes are kept in the recovery file",
+	LockInputSwitch:        "Select the inputs that lock a CC to a value on the current note, sent just before the note. Press once to choose the CC, press again to choose the value",
+	RemoveLock:             "Remove the lock of the chosen CC from the current note",
+	LineClockInputSwitch:   "Select the inputs that control the length and clock division of the current line. Press once to select the length, press again to select the division",
+	MeterInputSwitch:       "Select the inputs that control the time signature of the current part. Press once to select the notes of a bar, press again to select the note value. Decrease the notes below one to remove the time signature",
+	ToggleBarPattern:       "Toggle counting the pattern mode numbers in bars of the time signature of the current part rather than in beats",
 	New:                    "Create a new sequence using the same template as the current sequence",
 	ToggleVisualMode:       "Toggle visual selection",
 	ToggleVisualLineMode:   "Toggle visual line selection",
@@ -330,6 +381,7 @@ var CommandDescriptions = map[Command]string{
 	RemoveChord:            "Remove chord at current position",
 	ConvertToNotes:         "Convert chord to individual notes",
 	ReloadFile:             "Reload current file, any changes since the last save will be lost",
+	ImportMidi:             "Import a midi file into a new sequence, any changes since the last save will be lost. Notes are quantized onto the grid with every 2 bars of the file becoming a part",
 	MidiPanic:              "Send MIDI panic (all notes off)",
 	IncreaseAllChannels:    "Increase all channels",
 	DecreaseAllChannels:    "Decrease all channels",
@@ -340,6 +392,14 @@ var CommandDescriptions = map[Command]string{
 	Euclidean:              "Apply Euclidean rhythm pattern to selection or pattern",
 	Reverse:                "Reverse notes from cursor to end of line, or reverse notes within visual selection",
 	Duplicate:              "Duplicate what is under the cursor to the next beat in the current line",
+	RecordNotes:            "Record the notes played on a midi input into the grid, playing the current part in a loop if stopped. Press again to stop recording",
+	ToggleRecordMode:       "Toggle recording between overdub, which keeps the notes in the grid, and replace, which removes the notes the playback cursor passes over",
+	ToggleRecordOverlay:    "Toggle recording into the current overlay instead of the root overlay of the part",
+	ToggleStepInput:        "Toggle step input, the keys played together on a midi input are put on the cursor beat, as a chord in polyphony mode, and the cursor moves to the next beat",
+	LearnControl:           "Learn a control of a midi controller. Select tempo or an accent value to bind a knob to it, otherwise press the keys of the command to bind, then move or press the control",
+	IncreaseTempo:          "Increase the tempo by one, for binding to a midi controller",
+	DecreaseTempo:          "Decrease the tempo by one, for binding to a midi controller",
+	GrooveInputSwitch:      "Select the inputs that control the groove of the current part, or of the selected lines in visual mode. Press once to choose the groove with +/-, press again to select its amount",
 	ToggleBoundedLoop:      "Toggle bounded loop mode. When enabled, overlay playback loops between left and right bounds instead of the full sequence",
 	ExpandLeftLoopBound:    "Expand the left loop bound one beat to the left, increasing the loop region size",
 	ExpandRightLoopBound:   "Expand the right loop bound one beat to the right, increasing the loop region size",
@@ -472,12 +532,30 @@ func (c Command) String() string {
 		"ToggleAccentNoteMode",
 		"ToggleRatchetMode",
 		"ToggleRatchetNoteMode",
+		"ToggleChanceMode",
+		"ToggleChanceNoteMode",
+		"ToggleTrigMode",
+		"ToggleTrigNoteMode",
+		"ToggleFill",
 		"NextOverlay",
 		"PrevOverlay",
 		"Save",
 		"SaveAs",
 		"Undo",
 		"Redo",
+		"UndoEarlier",
+		"UndoLater",
+		"ShowUndoTree",
+		"CaptureSnapshot",
+		"CapturePartSnapshot",
+		"ShowSnapshots",
+		"RemoveSnapshot",
+		"ShowLibrary",
+		"LockInputSwitch",
+		"RemoveLock",
+		"LineClockInputSwitch",
+		"MeterInputSwitch",
+		"ToggleBarPattern",
 		"New",
 		"ToggleVisualMode",
 		"ToggleVisualLineMode",
@@ -567,17 +645,24 @@ func (c Command) String() string {
 		"RemoveChord",
 		"ConvertToNotes",
 		"ReloadFile",
+		"ImportMidi",
 		"OverlayKeyMessage",
 		"ArrKeyMessage",
 		"TextInputMessage",
 		"ConfirmOverlayKey",
 		"ConfirmRenamePart",
 		"ConfirmFileName",
+		"ConfirmImportFile",
+		"ConfirmRecoverFile",
+		"ConfirmSnapshotName",
+		"ConfirmSnapshot",
+		"ConfirmLibrary",
 		"ConfirmSelectPart",
 		"ConfirmChangePart",
 		"ConfirmConfirmNew",
 		"ConfirmConfirmReload",
 		"ConfirmConfirmQuit",
+		"ConfirmConfirmImport",
 		"ConfirmEuclidenHits",
 		"MidiPanic",
 		"IncreaseAllChannels",
@@ -595,6 +680,14 @@ func (c Command) String() string {
 		"Euclidean",
 		"Reverse",
 		"Duplicate",
+		"RecordNotes",
+		"ToggleRecordMode",
+		"ToggleRecordOverlay",
+		"ToggleStepInput",
+		"LearnControl",
+		"IncreaseTempo",
+		"DecreaseTempo",
+		"GrooveInputSwitch",
 	}
 
 	if c >= 0 && int(c) < len(names) {
@@ -603,6 +696,16 @@ func (c Command) String() string {
 	return "Unknown"
 }
 
+// ParseCommand returns the command with the given name.
+func ParseCommand(name string) (Command, bool) {
+	for command := range CommandDescriptions {
+		if command.String() == name {
+			return command, true
+		}
+	}
+	return 0, false
+}
+
 func allCommands() registry {
 	// Combine all mappings into a single registry
 	all := make(registry)
@@ -624,8 +727,22 @@ var mappings = registry{
 	OperationKey{key: k("b", "p")}:                                          MidiPanic,
 	OperationKey{focus: operation.FocusAny, key: k(" ")}:                    PlayStop,
 	OperationKey{focus: operation.FocusAny, key: k("'", " ")}:               PlayOverlayLoop,
+	OperationKey{focus: operation.FocusAny, key: k("'", "-")}:               UndoEarlier,
+	OperationKey{focus: operation.FocusAny, key: k("'", "+")}:               UndoLater,
+	OperationKey{focus: operation.FocusAny, key: k("'", "=")}:               UndoLater,
+	OperationKey{focus: operation.FocusAny, key: k("'", "u")}:               ShowUndoTree,
+	OperationKey{focus: operation.FocusAny, key: k("'", "s")}:               CaptureSnapshot,
+	OperationKey{focus: operation.FocusAny, key: k("'", "S")}:               CapturePartSnapshot,
+	OperationKey{focus: operation.FocusAny, key: k("'", "r")}:               ShowSnapshots,
+	OperationKey{focus: operation.FocusAny, key: k("'", "o")}:               ShowLibrary,
+	OperationKey{focus: operation.FocusAny, key: k("'", "f")}:               ToggleFill,
 	OperationKey{focus: operation.FocusAny, key: k(":", " ")}:               PlayRecord,
 	OperationKey{focus: operation.FocusAny, key: k(";", " ")}:               PlayAlong,
+	OperationKey{focus: operation.FocusAny, key: k(":", "r")}:               RecordNotes,
+	OperationKey{focus: operation.FocusAny, key: k(":", "R")}:               ToggleRecordMode,
+	OperationKey{focus: operation.FocusAny, key: k(":", "o")}:               ToggleRecordOverlay,
+	OperationKey{focus: operation.FocusAny, key: k(":", "s")}:               ToggleStepInput,
+	OperationKey{focus: operation.FocusAny, key: k(":", "l")}:               LearnControl,
 	OperationKey{focus: operation.FocusGrid, key: k("+")}:                   Increase,
 	OperationKey{focus: operation.FocusGrid, key: k("=")}:                   Increase,
 	OperationKey{focus: operation.FocusGrid, key: k("-")}:                   Decrease,
@@ -641,6 +758,15 @@ var mappings = registry{
 	OperationKey{selection: operation.SelectChangePart, key: k("+")}:        Increase,
 	OperationKey{selection: operation.SelectChangePart, key: k("=")}:        Increase,
 	OperationKey{selection: operation.SelectChangePart, key: k("-")}:        Decrease,
+	OperationKey{selection: operation.SelectSnapshot, key: k("+")}:          Increase,
+	OperationKey{selection: operation.SelectSnapshot, key: k("=")}:          Increase,
+	OperationKey{selection: operation.SelectSnapshot, key: k("-")}:          Decrease,
+	OperationKey{selection: operation.SelectSnapshot, key: k("x")}:          RemoveSnapshot,
+	OperationKey{selection: operation.SelectLockControl, key: k("x")}:       RemoveLock,
+	OperationKey{selection: operation.SelectLockValue, key: k("x")}:         RemoveLock,
+	OperationKey{selection: operation.SelectLibrary, key: k("+")}:           Increase,
+	OperationKey{selection: operation.SelectLibrary, key: k("=")}:           Increase,
+	OperationKey{selection: operation.SelectLibrary, key: k("-")}:           Decrease,
 	OperationKey{focus: operation.FocusGrid, key: k("Z")}:                   CursorLineStart,
 	OperationKey{focus: operation.FocusGrid, key: k("z")}:                   CursorLineEnd,
 	OperationKey{focus: operation.FocusGrid, key: k("b", "l")}:              CursorLastLine,
@@ -675,6 +801,7 @@ var mappings = registry{
 	OperationKey{focus: operation.FocusAny, key: k("ctrl+@")}:               PlayPart,
 	OperationKey{focus: operation.FocusAny, key: k("ctrl+]")}:               NewSectionAfter,
 	OperationKey{focus: operation.FocusGrid, key: k("ctrl+b")}:              BeatInputSwitch,
+	OperationKey{focus: operation.FocusGrid, key: k("ctrl+g")}:              GrooveInputSwitch,
 	OperationKey{focus: operation.FocusGrid, key: k("ctrl+k")}:              CyclesInputSwitch,
 	OperationKey{focus: operation.FocusAny, key: k("ctrl+c")}:               ChangePart,
 	OperationKey{focus: operation.FocusGrid, key: k("ctrl+e")}:              AccentInputSwitch,
@@ -692,12 +819,17 @@ var mappings = registry{
 	OperationKey{focus: operation.FocusAny, key: k("ctrl+s")}:               Save,
 	OperationKey{focus: operation.FocusAny, key: k("ctrl+w")}:               SaveAs,
 	OperationKey{focus: operation.FocusGrid, key: k("ctrl+y")}:              RatchetInputSwitch,
+	OperationKey{focus: operation.FocusGrid, key: k("ctrl+v")}:              LockInputSwitch,
+	OperationKey{focus: operation.FocusGrid, key: k("ctrl+r")}:              LineClockInputSwitch,
+	OperationKey{focus: operation.FocusGrid, key: k("ctrl+f")}:              MeterInputSwitch,
+	OperationKey{focus: operation.FocusGrid, key: k("|")}:                   ToggleBarPattern,
 	OperationKey{focus: operation.FocusGrid, key: k("a")}:                   AccentDecrease,
 	OperationKey{focus: operation.FocusGrid, key: k("c")}:                   ClearLine,
 	OperationKey{focus: operation.FocusGrid, key: k("d")}:                   NoteRemove,
 	OperationKey{focus: operation.FocusGrid, key: k("b", "e")}:              TogglePlayEdit,
 	OperationKey{focus: operation.FocusGrid, key: k("f")}:                   NoteAdd,
 	OperationKey{focus: operation.FocusGrid, key: k("b", "r")}:              ReloadFile,
+	OperationKey{focus: operation.FocusGrid, key: k("b", "i")}:              ImportMidi,
 	OperationKey{focus: operation.FocusGrid, key: k("b", "v")}:              ActionAddSpecificValue,
 	OperationKey{focus: operation.FocusGrid, key: k("h")}:                   CursorLeft,
 	OperationKey{focus: operation.FocusGrid, key: k("j")}:                   CursorDown,
@@ -716,6 +848,10 @@ var mappings = registry{
 	OperationKey{focus: operation.FocusGrid, key: k("n", "G")}:              ToggleGateNoteMode,
 	OperationKey{focus: operation.FocusGrid, key: k("n", "r")}:              ToggleRatchetMode,
 	OperationKey{focus: operation.FocusGrid, key: k("n", "R")}:              ToggleRatchetNoteMode,
+	OperationKey{focus: operation.FocusGrid, key: k("n", "c")}:              ToggleChanceMode,
+	OperationKey{focus: operation.FocusGrid, key: k("n", "C")}:              ToggleChanceNoteMode,
+	OperationKey{focus: operation.FocusGrid, key: k("n", "t")}:              ToggleTrigMode,
+	OperationKey{focus: operation.FocusGrid, key: k("n", "T")}:              ToggleTrigNoteMode,
 	OperationKey{focus: operation.FocusGrid, key: k("n", "P")}:              PurposePanic,
 	OperationKey{focus: operation.FocusGrid, key: k("n", "v")}:              Reverse,
 	OperationKey{focus: operation.FocusGrid, key: k("p")}:                   Paste,
@@ -746,13 +882,21 @@ var mappings = registry{
 	OperationKey{focus: operation.FocusOverlayKey, key: k("enter")}:         ConfirmOverlayKey,
 	OperationKey{selection: operation.SelectRenamePart, key: k("enter")}:    ConfirmRenamePart,
 	OperationKey{selection: operation.SelectFileName, key: k("enter")}:      ConfirmFileName,
+	OperationKey{selection: operation.SelectImportFile, key: k("enter")}:    ConfirmImportFile,
+	OperationKey{selection: operation.SelectRecoverFile, key: k("enter")}:   ConfirmRecoverFile,
+	OperationKey{selection: operation.SelectSnapshotName, key: k("enter")}:  ConfirmSnapshotName,
+	OperationKey{selection: operation.SelectSnapshot, key: k("enter")}:      ConfirmSnapshot,
+	OperationKey{selection: operation.SelectLibrary, key: k("enter")}:       ConfirmLibrary,
 	OperationKey{selection: operation.SelectPart, key: k("enter")}:          ConfirmSelectPart,
 	OperationKey{selection: operation.SelectChangePart, key: k("enter")}:    ConfirmChangePart,
 	OperationKey{selection: operation.SelectConfirmNew, key: k("enter")}:    ConfirmConfirmNew,
 	OperationKey{selection: operation.SelectConfirmReload, key: k("enter")}: ConfirmConfirmReload,
 	OperationKey{selection: operation.SelectConfirmQuit, key: k("enter")}:   ConfirmConfirmQuit,
+	OperationKey{selection: operation.SelectConfirmImport, key: k("enter")}: ConfirmConfirmImport,
 	OperationKey{selection: operation.SelectEuclideanHits, key: k("enter")}: ConfirmEuclidenHits,
 	OperationKey{selection: operation.SelectFileName, key: k("esc")}:        Escape,
+	OperationKey{selection: operation.SelectImportFile, key: k("esc")}:      Escape,
+	OperationKey{selection: operation.SelectSnapshotName, key: k("esc")}:    Escape,
 	OperationKey{selection: operation.SelectSetupChannel, key: k("J")}:      DecreaseAllChannels,
 	OperationKey{selection: operation.SelectSetupChannel, key: k("K")}:      IncreaseAllChannels,
 	OperationKey{selection: operation.SelectSetupValue, key: k("J")}:        DecreaseAllNote,
@@ -796,6 +940,8 @@ var mappings = registry{
 	OperationKey{focus: operation.FocusOverlayKey, key: [3]string{}}:                                                                                              OverlayKeyMessage,
 	OperationKey{selection: operation.SelectRenamePart, key: [3]string{}}:                                                                                         TextInputMessage,
 	OperationKey{selection: operation.SelectFileName, key: [3]string{}}:                                                                                           TextInputMessage,
+	OperationKey{selection: operation.SelectImportFile, key: [3]string{}}:                                                                                         TextInputMessage,
+	OperationKey{selection: operation.SelectSnapshotName, key: [3]string{}}:                                                                                       TextInputMessage,
 	OperationKey{focus: operation.FocusArrangementEditor, selection: operation.SelectFileName, key: [3]string{}}:                                                  TextInputMessage,
 	OperationKey{focus: operation.FocusArrangementEditor, key: [3]string{}}:                                                                                       ArrKeyMessage,
 	OperationKey{focus: operation.FocusArrangementEditor, key: k("'")}:                                                                                            HoldingKeys,
@@ -0,0 +1,611 @@
+// Package merge combines two versions of a sequence that were changed from a
+// common base, the way git merges two branches.  Sequences are merged by
+// meaning rather than by line: settings one value at a time, parts by index,
+// overlays by key, notes by grid key, chords by root, the arrangement node by
+// node and snapshots by name.  A value changed differently on both sides is a conflict; the
+// merged sequence keeps our value and the conflict is reported.
+package merge
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/controller"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// Conflict is a value changed differently by both sides of a merge.
+type Conflict struct {
+	Path   string
+	Ours   string
+	Theirs string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: Ours=%s, Theirs=%s", c.Path, c.Ours, c.Theirs)
+}
+
+type merger struct {
+	conflicts []Conflict
+}
+
+func (m *merger) conflict(path, ours, theirs string) {
+	m.conflicts = append(m.conflicts, Conflict{Path: path, Ours: ours, Theirs: theirs})
+}
+
+// Sequences merges the changes from base to ours and from base to theirs.
+func Sequences(base, ours, theirs sequence.Sequence) (sequence.Sequence, []Conflict) {
+	m := &merger{}
+	merged := ours
+
+	merged.Tempo = value(m, "Tempo", base.Tempo, ours.Tempo, theirs.Tempo)
+	merged.Subdivisions = value(m, "Subdivisions", base.Subdivisions, ours.Subdivisions, theirs.Subdivisions)
+	merged.Keyline = value(m, "Keyline", base.Keyline, ours.Keyline, theirs.Keyline)
+	merged.Instrument = value(m, "Instrument", base.Instrument, ours.Instrument, theirs.Instrument)
+	merged.Template = value(m, "Template", base.Template, ours.Template, theirs.Template)
+	merged.TemplateUIStyle = value(m, "TemplateUIStyle", base.TemplateUIStyle, ours.TemplateUIStyle, theirs.TemplateUIStyle)
+
+	merged.Lines = m.mergeLines(base.Lines, ours.Lines, theirs.Lines)
+	merged.Accents = m.mergeAccents(base.Accents, ours.Accents, theirs.Accents)
+	merged.Controls = m.mergeControls(base.Controls, ours.Controls, theirs.Controls)
+	merged.Grooves = m.mergeGrooves(base.Grooves, ours.Grooves, theirs.Grooves)
+
+	parts := m.mergeParts(partsOf(base), partsOf(ours), partsOf(theirs))
+	merged.Parts = &parts
+	merged.Arrangement = m.mergeArrangement(base.Arrangement, ours.Arrangement, theirs.Arrangement)
+	merged.Snapshots = m.mergeSnapshots(base.Snapshots, ours.Snapshots, theirs.Snapshots)
+
+	return merged, m.conflicts
+}
+
+// value merges a single value, a change on one side wins over no change.
+func value[T comparable](m *merger, path string, base, ours, theirs T) T {
+	merged, ok := optional(m, path, &base, &ours, &theirs, func(a, b T) bool { return a == b }, func(v T) string { return fmt.Sprint(v) })
+	if !ok {
+		return ours
+	}
+	return merged
+}
+
+// optional merges a value that can be missing on any side.  A missing value
+// was never added or has been removed.
+func optional[T any](m *merger, path string, base, ours, theirs *T, equal func(a, b T) bool, describe func(T) string) (T, bool) {
+	same := func(a, b *T) bool {
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		return equal(*a, *b)
+	}
+	result := ours
+	switch {
+	case same(ours, base):
+		result = theirs
+	case same(theirs, base), same(ours, theirs):
+	default:
+		describeOptional := func(v *T) string {
+			if v == nil {
+				return "(removed)"
+			}
+			return describe(*v)
+		}
+		m.conflict(path, describeOptional(ours), describeOptional(theirs))
+	}
+	if result == nil {
+		var zero T
+		return zero, false
+	}
+	return *result, true
+}
+
+// list merges the items of a list by index.
+func list[T any](m *merger, path string, base, ours, theirs []T, equal func(a, b T) bool, describe func(T) string) []T {
+	at := func(items []T, i int) *T {
+		if i < len(items) {
+			return &items[i]
+		}
+		return nil
+	}
+	var merged []T
+	for i := range max(len(base), len(ours), len(theirs)) {
+		if item, ok := optional(m, fmt.Sprintf("%s %d", path, i), at(base, i), at(ours, i), at(theirs, i), equal, describe); ok {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// keyed merges the items of maps by key, in the order of the keys.
+func keyed[K comparable, T any](m *merger, path func(K) string, base, ours, theirs map[K]T, compare func(a, b K) int, equal func(a, b T) bool, describe func(T) string) ([]K, map[K]T) {
+	lookup := func(items map[K]T, key K) *T {
+		if item, exists := items[key]; exists {
+			return &item
+		}
+		return nil
+	}
+	var keys []K
+	for _, items := range []map[K]T{base, ours, theirs} {
+		for key := range items {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.SortFunc(keys, compare)
+
+	merged := make(map[K]T)
+	var mergedKeys []K
+	for _, key := range keys {
+		if item, ok := optional(m, path(key), lookup(base, key), lookup(ours, key), lookup(theirs, key), equal, describe); ok {
+			merged[key] = item
+			mergedKeys = append(mergedKeys, key)
+		}
+	}
+	return mergedKeys, merged
+}
+
+func (m *merger) mergeLines(base, ours, theirs []grid.LineDefinition) []grid.LineDefinition {
+	return list(m, "Line", base, ours, theirs, func(a, b grid.LineDefinition) bool { return a == b }, func(l grid.LineDefinition) string {
+		return fmt.Sprintf("{Channel=%d Note=%d MessageType=%d Length=%d Division=%s Name=%s}", l.Channel, l.Note, l.MsgType, l.Length, l.Division, l.Name)
+	})
+}
+
+func (m *merger) mergeAccents(base, ours, theirs sequence.PatternAccents) sequence.PatternAccents {
+	merged := ours
+	merged.Target = value(m, "Accents Target", base.Target, ours.Target, theirs.Target)
+	merged.Start = value(m, "Accents Start", base.Start, ours.Start, theirs.Start)
+	merged.End = value(m, "Accents End", base.End, ours.End, theirs.End)
+	merged.Data = list(m, "Accent", base.Data, ours.Data, theirs.Data, func(a, b config.Accent) bool { return a == b }, func(a config.Accent) string { return fmt.Sprint(a) })
+	return merged
+}
+
+func (m *merger) mergeControls(base, ours, theirs controller.Bindings) controller.Bindings {
+	byControl := func(bindings controller.Bindings) map[controller.Control]controller.Binding {
+		result := make(map[controller.Control]controller.Binding)
+		for _, binding := range bindings {
+			result[binding.Control] = binding
+		}
+		return result
+	}
+	compare := func(a, b controller.Control) int { return strings.Compare(a.String(), b.String()) }
+	keys, merged := keyed(m, func(c controller.Control) string { return "Control " + c.String() },
+		byControl(base), byControl(ours), byControl(theirs), compare,
+		func(a, b controller.Binding) bool { return a == b },
+		func(b controller.Binding) string { return b.Target() })
+
+	var bindings controller.Bindings
+	for _, key := range keys {
+		bindings = append(bindings, merged[key])
+	}
+	return bindings
+}
+
+func (m *merger) mergeGrooves(base, ours, theirs []groove.Groove) []groove.Groove {
+	byName := func(grooves []groove.Groove) map[string]groove.Groove {
+		result := make(map[string]groove.Groove)
+		for _, g := range grooves {
+			result[g.Name] = g
+		}
+		return result
+	}
+	keys, merged := keyed(m, func(name string) string { return "Groove " + name },
+		byName(base), byName(ours), byName(theirs), strings.Compare,
+		func(a, b groove.Groove) bool { return slices.Equal(a.Steps, b.Steps) },
+		func(g groove.Groove) string { return fmt.Sprint(g.Steps) })
+
+	var grooves []groove.Groove
+	for _, key := range keys {
+		grooves = append(grooves, merged[key])
+	}
+	return grooves
+}
+
+func (m *merger) mergeSnapshots(base, ours, theirs []sequence.Snapshot) []sequence.Snapshot {
+	byName := func(snapshots []sequence.Snapshot) map[string]sequence.Snapshot {
+		result := make(map[string]sequence.Snapshot)
+		for _, snapshot := range snapshots {
+			result[snapshot.Name] = snapshot
+		}
+		return result
+	}
+	keys, merged := keyed(m, func(name string) string { return "Snapshot " + name },
+		byName(base), byName(ours), byName(theirs), strings.Compare,
+		sequence.Snapshot.Equal, describeSnapshot)
+
+	var snapshots []sequence.Snapshot
+	for _, key := range keys {
+		snapshots = append(snapshots, merged[key])
+	}
+	return snapshots
+}
+
+func describeSnapshot(snapshot sequence.Snapshot) string {
+	if snapshot.IsWhole() {
+		return "(whole sequence)"
+	}
+	return fmt.Sprintf("(part %d)", snapshot.Part+1)
+}
+
+func partsOf(s sequence.Sequence) []arrangement.Part {
+	if s.Parts == nil {
+		return nil
+	}
+	return *s.Parts
+}
+
+// mergeParts merges the parts by index.  A part added on both sides is merged
+// as if both sides had added it to an empty part.
+func (m *merger) mergeParts(base, ours, theirs []arrangement.Part) []arrangement.Part {
+	var merged []arrangement.Part
+	for i := range max(len(base), len(ours), len(theirs)) {
+		path := fmt.Sprintf("Part %d", i+1)
+		hasBase, hasOurs, hasTheirs := i < len(base), i < len(ours), i < len(theirs)
+		switch {
+		case hasOurs && hasTheirs:
+			basePart := arrangement.InitPart("")
+			if hasBase {
+				basePart = base[i]
+			}
+			merged = append(merged, m.mergePart(path, basePart, ours[i], theirs[i]))
+		case hasOurs:
+			// Removed by them, kept when we changed it
+			if !hasBase {
+				merged = append(merged, ours[i])
+			} else if partChanged(base[i], ours[i]) {
+				m.conflict(path, "(changed)", "(removed)")
+				merged = append(merged, ours[i])
+			}
+		case hasTheirs:
+			if !hasBase {
+				merged = append(merged, theirs[i])
+			} else if partChanged(base[i], theirs[i]) {
+				m.conflict(path, "(removed)", "(changed)")
+			}
+		}
+	}
+	return merged
+}
+
+func (m *merger) mergePart(path string, base, ours, theirs arrangement.Part) arrangement.Part {
+	merged := ours
+	merged.Name = value(m, path+" Name", base.Name, ours.Name, theirs.Name)
+	merged.Beats = value(m, path+" Beats", base.Beats, ours.Beats, theirs.Beats)
+	merged.Meter = value(m, path+" Meter", base.Meter, ours.Meter, theirs.Meter)
+	grooves, _ := optional(m, path+" Grooves", &base.Grooves, &ours.Grooves, &theirs.Grooves, groove.Settings.Equal, func(s groove.Settings) string {
+		return fmt.Sprint(s)
+	})
+	merged.Grooves = grooves
+	merged.Overlays = m.mergeOverlays(path, base.Overlays, ours.Overlays, theirs.Overlays)
+	return merged
+}
+
+func partChanged(base, other arrangement.Part) bool {
+	if base.Name != other.Name || base.Beats != other.Beats || base.Meter != other.Meter || !base.Grooves.Equal(other.Grooves) {
+		return true
+	}
+	baseOverlays, otherOverlays := overlaysByKey(base.Overlays), overlaysByKey(other.Overlays)
+	if !slices.Equal(slices.Sorted(maps.Keys(baseOverlays)), slices.Sorted(maps.Keys(otherOverlays))) {
+		return true
+	}
+	for key, overlay := range baseOverlays {
+		if !overlays.DiffOverlays(overlay, otherOverlays[key]).IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
+
+type overlayKey = overlaykey.OverlayPeriodicity
+
+func overlaysByKey(overlay *overlays.Overlay) map[string]*overlays.Overlay {
+	result := make(map[string]*overlays.Overlay)
+	for current := overlay; current != nil; current = current.Below {
+		result[keyString(current.Key)] = current
+	}
+	return result
+}
+
+func keyString(key overlayKey) string {
+	return fmt.Sprintf("%d/%d/%d/%d", key.Shift, key.Interval, key.Width, key.StartCycle)
+}
+
+// mergeOverlays merges the overlays of a part by key.  Our overlays are copied
+// and the changes they made are kept, then their changes that do not conflict
+// are applied with the diffs of the overlays package.
+func (m *merger) mergeOverlays(path string, base, ours, theirs *overlays.Overlay) *overlays.Overlay {
+	baseOverlays, ourOverlays, theirOverlays := overlaysByKey(base), overlaysByKey(ours), overlaysByKey(theirs)
+
+	keys := make(map[string]overlayKey)
+	for _, byKey := range []map[string]*overlays.Overlay{baseOverlays, ourOverlays, theirOverlays} {
+		for name, overlay := range byKey {
+			keys[name] = overlay.Key
+		}
+	}
+
+	type pending struct {
+		base, ours, theirs *overlays.Overlay
+		merged             *overlays.Overlay
+	}
+	var mergedOverlays []pending
+	for _, name := range slices.Sorted(maps.Keys(keys)) {
+		key := keys[name]
+		overlayPath := fmt.Sprintf("%s Overlay %s", path, name)
+		baseOverlay, ourOverlay, theirOverlay := baseOverlays[name], ourOverlays[name], theirOverlays[name]
+		if baseOverlay == nil {
+			baseOverlay = overlays.InitOverlay(key, nil)
+		}
+		switch {
+		case ourOverlay != nil && theirOverlay != nil:
+			mergedOverlays = append(mergedOverlays, pending{baseOverlay, ourOverlay, theirOverlay, overlays.DeepCopy(ourOverlay)})
+		case ourOverlay != nil:
+			if baseOverlays[name] != nil && !overlays.DiffOverlays(baseOverlay, ourOverlay).IsEmpty() {
+				m.conflict(overlayPath, "(changed)", "(removed)")
+			} else if baseOverlays[name] != nil {
+				continue
+			}
+			mergedOverlays = append(mergedOverlays, pending{merged: overlays.DeepCopy(ourOverlay)})
+		case theirOverlay != nil:
+			if baseOverlays[name] != nil {
+				if !overlays.DiffOverlays(baseOverlay, theirOverlay).IsEmpty() {
+					m.conflict(overlayPath, "(removed)", "(changed)")
+				}
+				continue
+			}
+			mergedOverlays = append(mergedOverlays, pending{merged: overlays.DeepCopy(theirOverlay)})
+		}
+	}
+	if len(mergedOverlays) == 0 {
+		return nil
+	}
+
+	// Overlays are stacked from the most specific key down to the root
+	slices.SortFunc(mergedOverlays, func(a, b pending) int {
+		return overlaykey.Compare(a.merged.Key, b.merged.Key)
+	})
+	for i := range len(mergedOverlays) - 1 {
+		mergedOverlays[i].merged.Below = mergedOverlays[i+1].merged
+	}
+
+	// Notes are applied from the root up as an overlay note depends on
+	// the notes below it
+	for i := len(mergedOverlays) - 1; i >= 0; i-- {
+		p := mergedOverlays[i]
+		if p.theirs != nil {
+			m.mergeOverlay(fmt.Sprintf("%s Overlay %s", path, keyString(p.merged.Key)), p.base, p.ours, p.theirs, p.merged)
+		}
+	}
+	for _, p := range mergedOverlays {
+		m.mergeBlockers(p.merged, ourOverlays[keyString(p.merged.Key)], theirOverlays[keyString(p.merged.Key)], baseOverlays[keyString(p.merged.Key)])
+	}
+
+	return mergedOverlays[0].merged
+}
+
+// mergeOverlay applies their changes to the merged copy of our overlay,
+// leaving out their changes to the notes and chords we changed differently.
+func (m *merger) mergeOverlay(path string, base, ours, theirs, merged *overlays.Overlay) {
+	ourDiff := overlays.DiffOverlays(base, ours)
+	theirDiff := overlays.DiffOverlays(base, theirs)
+
+	ourNotes := make(map[grid.GridKey]bool)
+	for _, keys := range [][]grid.GridKey{slices.Collect(maps.Keys(ourDiff.AddedNotes)), slices.Collect(maps.Keys(ourDiff.RemovedNotes)), slices.Collect(maps.Keys(ourDiff.ModifiedNotes))} {
+		for _, key := range keys {
+			ourNotes[key] = true
+		}
+	}
+	resolveNote := func(key grid.GridKey) bool {
+		if !ourNotes[key] {
+			return true
+		}
+		ourNote, ourExists := ours.Notes[key]
+		theirNote, theirExists := theirs.Notes[key]
+		if ourExists != theirExists || ourNote != theirNote {
+			m.conflict(fmt.Sprintf("%s GridKey(%d,%d)", path, key.Line, key.Beat), describeNote(ourNote, ourExists), describeNote(theirNote, theirExists))
+		}
+		return false
+	}
+	for key := range theirDiff.AddedNotes {
+		if !resolveNote(key) {
+			delete(theirDiff.AddedNotes, key)
+		}
+	}
+	for key := range theirDiff.RemovedNotes {
+		if !resolveNote(key) {
+			delete(theirDiff.RemovedNotes, key)
+		}
+	}
+	for key := range theirDiff.ModifiedNotes {
+		if !resolveNote(key) {
+			delete(theirDiff.ModifiedNotes, key)
+		}
+	}
+
+	ourChords := make(map[grid.GridKey]bool)
+	for _, chord := range slices.Concat(ourDiff.AddedChords, ourDiff.RemovedChords) {
+		ourChords[chord.Root] = true
+	}
+	for chord := range ourDiff.ModifiedChords {
+		ourChords[chord.Root] = true
+	}
+	resolveChord := func(root grid.GridKey) bool {
+		if !ourChords[root] {
+			return true
+		}
+		ourChord, ourExists := chordAt(ours.Chords, root)
+		theirChord, theirExists := chordAt(theirs.Chords, root)
+		if ourExists != theirExists || (ourExists && !chordEqual(ourChord, theirChord)) {
+			m.conflict(fmt.Sprintf("%s Chord(%d,%d)", path, root.Line, root.Beat), describeChord(ourChord, ourExists), describeChord(theirChord, theirExists))
+		}
+		return false
+	}
+	theirDiff.AddedChords = slices.DeleteFunc(theirDiff.AddedChords, func(chord overlays.GridChord) bool {
+		return !resolveChord(chord.Root)
+	})
+	theirDiff.RemovedChords = slices.DeleteFunc(theirDiff.RemovedChords, func(chord overlays.GridChord) bool {
+		return !resolveChord(chord.Root)
+	})
+	for chord := range theirDiff.ModifiedChords {
+		if !resolveChord(chord.Root) {
+			delete(theirDiff.ModifiedChords, chord)
+		}
+	}
+
+	// An option changed on both sides changed to the same value
+	theirDiff.OptionsDiff.PressUpChanged = theirDiff.OptionsDiff.PressUpChanged && !ourDiff.OptionsDiff.PressUpChanged
+	theirDiff.OptionsDiff.PressDownChanged = theirDiff.OptionsDiff.PressDownChanged && !ourDiff.OptionsDiff.PressDownChanged
+
+	theirDiff.Apply(merged)
+}
+
+// mergeBlockers merges the chords an overlay blocks by their roots and points
+// them at the merged chords of the overlays below.
+func (m *merger) mergeBlockers(merged, ours, theirs, base *overlays.Overlay) {
+	roots := func(overlay *overlays.Overlay) []grid.GridKey {
+		var result []grid.GridKey
+		if overlay != nil {
+			for _, blocker := range overlay.Blockers {
+				if blocker != nil {
+					result = append(result, blocker.Root)
+				}
+			}
+		}
+		return result
+	}
+	baseRoots, ourRoots, theirRoots := roots(base), roots(ours), roots(theirs)
+	if ours == nil {
+		ourRoots = theirRoots
+	}
+
+	blocked := slices.Clone(ourRoots)
+	for _, root := range theirRoots {
+		if !slices.Contains(baseRoots, root) && !slices.Contains(blocked, root) {
+			blocked = append(blocked, root)
+		}
+	}
+	if theirs != nil {
+		blocked = slices.DeleteFunc(blocked, func(root grid.GridKey) bool {
+			return slices.Contains(baseRoots, root) && !slices.Contains(theirRoots, root)
+		})
+	}
+
+	merged.Blockers = nil
+	for _, root := range blocked {
+		for below := merged.Below; below != nil; below = below.Below {
+			if chord, exists := chordAt(below.Chords, root); exists {
+				merged.Blockers = append(merged.Blockers, chord)
+				break
+			}
+		}
+	}
+}
+
+// chordAt returns the chord with the root, chords are identified by their root
+func chordAt(chords overlays.Chords, root grid.GridKey) (*overlays.GridChord, bool) {
+	for _, chord := range chords {
+		if chord.Root == root {
+			return chord, true
+		}
+	}
+	return nil, false
+}
+
+func chordEqual(a, b *overlays.GridChord) bool {
+	return a.Arpeggio == b.Arpeggio && a.Chord == b.Chord && slices.Equal(a.Notes, b.Notes)
+}
+
+func describeNote(note grid.Note, exists bool) string {
+	if !exists {
+		return "(removed)"
+	}
+	return fmt.Sprintf("{AccentIndex=%d Ratchets=%d/%d/%d Action=%d GateIndex=%d WaitIndex=%d Probability=%d Condition=%q Locks=%s}",
+		note.AccentIndex, note.Ratchets.Hits, note.Ratchets.Length, note.Ratchets.Span, note.Action, note.GateIndex, note.WaitIndex, note.Probability, note.Condition, note.Locks)
+}
+
+func describeChord(chord *overlays.GridChord, exists bool) string {
+	if !exists {
+		return "(removed)"
+	}
+	return fmt.Sprintf("{Arpeggio=%d Notes=%d BeatNotes=%d}", chord.Arpeggio, chord.Chord.Notes, len(chord.Notes))
+}
+
+// mergeArrangement merges the arrangement node by node when both sides kept
+// the shape of the tree.  A tree reshaped on one side replaces the other when
+// the other side did not change it.
+func (m *merger) mergeArrangement(base, ours, theirs *arrangement.Arrangement) *arrangement.Arrangement {
+	switch {
+	case arrangementEqual(ours, base):
+		return theirs
+	case arrangementEqual(theirs, base), arrangementEqual(ours, theirs):
+		return ours
+	case sameShape(base, ours) && sameShape(base, theirs):
+		m.mergeNode("Arrangement", base, ours, theirs)
+		return ours
+	default:
+		m.conflict("Arrangement", describeArrangement(ours), describeArrangement(theirs))
+		return ours
+	}
+}
+
+func describeArrangement(a *arrangement.Arrangement) string {
+	if a == nil {
+		return "(removed)"
+	}
+	return fmt.Sprintf("(%d sections)", a.CountEndNodes())
+}
+
+func (m *merger) mergeNode(path string, base, ours, theirs *arrangement.Arrangement) {
+	ours.Iterations = value(m, path+" Iterations", base.Iterations, ours.Iterations, theirs.Iterations)
+	if len(ours.Nodes) == 0 {
+		ours.Section.Part = value(m, path+" Part", base.Section.Part, ours.Section.Part, theirs.Section.Part)
+		ours.Section.Cycles = value(m, path+" Cycles", base.Section.Cycles, ours.Section.Cycles, theirs.Section.Cycles)
+		ours.Section.StartBeat = value(m, path+" StartBeat", base.Section.StartBeat, ours.Section.StartBeat, theirs.Section.StartBeat)
+		ours.Section.StartCycles = value(m, path+" StartCycles", base.Section.StartCycles, ours.Section.StartCycles, theirs.Section.StartCycles)
+		ours.Section.KeepCycles = value(m, path+" KeepCycles", base.Section.KeepCycles, ours.Section.KeepCycles, theirs.Section.KeepCycles)
+		ours.Section.Tempo = value(m, path+" Tempo", base.Section.Tempo, ours.Section.Tempo, theirs.Section.Tempo)
+		ours.Section.Subdivisions = value(m, path+" Subdivisions", base.Section.Subdivisions, ours.Section.Subdivisions, theirs.Section.Subdivisions)
+		ours.Section.Ramp = value(m, path+" Ramp", base.Section.Ramp, ours.Section.Ramp, theirs.Section.Ramp)
+	}
+	for i := range ours.Nodes {
+		m.mergeNode(fmt.Sprintf("%s Node %d", path, i+1), base.Nodes[i], ours.Nodes[i], theirs.Nodes[i])
+	}
+}
+
+func arrangementEqual(a, b *arrangement.Arrangement) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Iterations != b.Iterations || len(a.Nodes) != len(b.Nodes) {
+		return false
+	}
+	if len(a.Nodes) == 0 && a.Section != b.Section {
+		return false
+	}
+	for i := range a.Nodes {
+		if !arrangementEqual(a.Nodes[i], b.Nodes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameShape(a, b *arrangement.Arrangement) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if len(a.Nodes) != len(b.Nodes) {
+		return false
+	}
+	for i := range a.Nodes {
+		if !sameShape(a.Nodes[i], b.Nodes[i]) {
+			return false
+		}
+	}
+	return true
+}
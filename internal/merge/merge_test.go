@@ -0,0 +1,225 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+)
+
+var everyOther = overlaykey.OverlayPeriodicity{Shift: 2, Interval: 2, Width: 0, StartCycle: 0}
+
+func testSequence() sequence.Sequence {
+	root := overlays.InitOverlay(overlaykey.ROOT, nil)
+	root.AddNote(grid.GK(0, 0), grid.InitNote())
+	root.Chords = append(root.Chords, &overlays.GridChord{Root: grid.GK(1, 4), Notes: []overlays.BeatNote{{Beat: 0, Note: grid.InitNote()}}})
+	parts := []arrangement.Part{{Name: "Verse", Beats: 8, Overlays: root}}
+	return sequence.Sequence{
+		Parts:       &parts,
+		Arrangement: sequence.InitArrangement(parts),
+		Tempo:       120,
+		Lines: []grid.LineDefinition{
+			{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote, Name: "Kick"},
+			{Channel: 10, Note: 38, MsgType: grid.MessageTypeNote, Name: "Snare"},
+		},
+	}
+}
+
+func rootOverlay(s sequence.Sequence) *overlays.Overlay {
+	return (*s.Parts)[0].Overlays.FindOverlay(overlaykey.ROOT)
+}
+
+func TestSequences(t *testing.T) {
+	t.Run("Notes changed on both sides", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		rootOverlay(ours).AddNote(grid.GK(0, 2), grid.InitNote())
+		rootOverlay(theirs).AddNote(grid.GK(1, 3), grid.InitNote())
+		rootOverlay(theirs).RemoveNote(grid.GK(0, 0))
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		notes := rootOverlay(merged).Notes
+		assert.Len(t, notes, 2)
+		assert.Contains(t, notes, grid.GK(0, 2))
+		assert.Contains(t, notes, grid.GK(1, 3))
+	})
+
+	t.Run("The same change on both sides", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		note := grid.InitNote()
+		note.AccentIndex = 3
+		rootOverlay(ours).AddNote(grid.GK(0, 0), note)
+		rootOverlay(theirs).AddNote(grid.GK(0, 0), note)
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		assert.Equal(t, note, rootOverlay(merged).Notes[grid.GK(0, 0)])
+	})
+
+	t.Run("A note changed differently is a conflict", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		ourNote, theirNote := grid.InitNote(), grid.InitNote()
+		ourNote.AccentIndex = 3
+		theirNote.AccentIndex = 7
+		rootOverlay(ours).AddNote(grid.GK(0, 0), ourNote)
+		rootOverlay(theirs).AddNote(grid.GK(0, 0), theirNote)
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Equal(t, ourNote, rootOverlay(merged).Notes[grid.GK(0, 0)])
+		if assert.Len(t, conflicts, 1) {
+			assert.Equal(t, "Part 1 Overlay 1/1/1/0 GridKey(0,0)", conflicts[0].Path)
+			assert.Contains(t, conflicts[0].Ours, "AccentIndex=3")
+			assert.Contains(t, conflicts[0].Theirs, "AccentIndex=7")
+		}
+	})
+
+	t.Run("A note removed by one side and changed by the other is a conflict", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		rootOverlay(ours).RemoveNote(grid.GK(0, 0))
+		theirNote := grid.InitNote()
+		theirNote.GateIndex = 4
+		rootOverlay(theirs).AddNote(grid.GK(0, 0), theirNote)
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.NotContains(t, rootOverlay(merged).Notes, grid.GK(0, 0))
+		if assert.Len(t, conflicts, 1) {
+			assert.Equal(t, "(removed)", conflicts[0].Ours)
+		}
+	})
+
+	t.Run("Settings changed on one side", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		ours.Lines[1].Name = "Clap"
+		theirs.Tempo = 90
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		assert.Equal(t, 90, merged.Tempo)
+		assert.Equal(t, "Clap", merged.Lines[1].Name)
+	})
+
+	t.Run("Settings changed on both sides", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		ours.Tempo = 100
+		theirs.Tempo = 90
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Equal(t, 100, merged.Tempo)
+		assert.Equal(t, []Conflict{{Path: "Tempo", Ours: "100", Theirs: "90"}}, conflicts)
+	})
+
+	t.Run("Chords are merged by their root", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		rootOverlay(ours).Chords[0].Arpeggio = overlays.ArpUp
+		rootOverlay(theirs).Chords = append(rootOverlay(theirs).Chords, &overlays.GridChord{Root: grid.GK(1, 6)})
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		chords := rootOverlay(merged).Chords
+		if assert.Len(t, chords, 2) {
+			assert.Equal(t, overlays.ArpUp, chords[0].Arpeggio)
+			assert.Equal(t, grid.GK(1, 6), chords[1].Root)
+		}
+	})
+
+	t.Run("An overlay added by them with a blocker", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		rootOverlay(ours).AddNote(grid.GK(1, 1), grid.InitNote())
+		part := &(*theirs.Parts)[0]
+		part.Overlays = part.Overlays.Add(everyOther)
+		added := part.Overlays.FindOverlay(everyOther)
+		added.AddNote(grid.GK(0, 1), grid.InitNote())
+		added.Blockers = append(added.Blockers, rootOverlay(theirs).Chords[0])
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		top := (*merged.Parts)[0].Overlays
+		assert.Equal(t, everyOther, top.Key)
+		assert.Contains(t, top.Notes, grid.GK(0, 1))
+		assert.Equal(t, overlaykey.ROOT, top.Below.Key)
+		assert.Contains(t, top.Below.Notes, grid.GK(1, 1))
+		if assert.Len(t, top.Blockers, 1) {
+			assert.Same(t, top.Below.Chords[0], top.Blockers[0])
+		}
+	})
+
+	t.Run("Parts added on one side", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		parts := append(*theirs.Parts, arrangement.InitPart("Chorus"))
+		theirs.Parts = &parts
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		if assert.Len(t, *merged.Parts, 2) {
+			assert.Equal(t, "Chorus", (*merged.Parts)[1].Name)
+		}
+	})
+
+	t.Run("Arrangement sections", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		ours.Arrangement.Nodes[0].Iterations = 2
+		theirs.Arrangement.Nodes[0].Section.Cycles = 4
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		assert.Equal(t, 2, merged.Arrangement.Nodes[0].Iterations)
+		assert.Equal(t, 4, merged.Arrangement.Nodes[0].Section.Cycles)
+	})
+
+	t.Run("Arrangement reshaped on both sides", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		ours.Arrangement.Nodes = append(ours.Arrangement.Nodes, &arrangement.Arrangement{Iterations: 1})
+		theirs.Arrangement.Nodes = nil
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Len(t, merged.Arrangement.Nodes, 2)
+		if assert.Len(t, conflicts, 1) {
+			assert.Equal(t, "Arrangement", conflicts[0].Path)
+		}
+	})
+
+	t.Run("A snapshot added on their side", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		theirs.SetSnapshot(theirs.Capture("Intro", 0))
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		assert.Empty(t, conflicts)
+		_, exists := merged.FindSnapshot("Intro")
+		assert.True(t, exists)
+	})
+
+	t.Run("A snapshot changed differently is a conflict", func(t *testing.T) {
+		base, ours, theirs := testSequence(), testSequence(), testSequence()
+		for _, s := range []*sequence.Sequence{&base, &ours, &theirs} {
+			s.SetSnapshot(s.Capture("Intro", 0))
+		}
+		rootOverlay(ours).AddNote(grid.GK(0, 2), grid.InitNote())
+		ours.SetSnapshot(ours.Capture("Intro", 0))
+		rootOverlay(theirs).AddNote(grid.GK(1, 3), grid.InitNote())
+		theirs.SetSnapshot(theirs.Capture("Intro", 0))
+
+		merged, conflicts := Sequences(base, ours, theirs)
+
+		snapshot, _ := merged.FindSnapshot("Intro")
+		assert.Contains(t, snapshot.Parts[0].Overlays.Notes, grid.GK(0, 2))
+		if assert.Len(t, conflicts, 1) {
+			assert.Equal(t, "Snapshot Intro", conflicts[0].Path)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+// Package midifile converts sequences to Standard MIDI Files and quantizes
+// Standard MIDI Files back onto the grid.
+// The events come from the renderer, which walks the arrangement with the
+// same play state logic used for live playback, so the file contains exactly
+// what sq would have sent.
+package midifile
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/notereg"
+	"github.com/chriserin/sq/internal/renderer"
+	"github.com/chriserin/sq/internal/sequence"
+	midi "gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Export renders the sequence and writes it to filename as a type 1 SMF.
+func Export(definition sequence.Sequence, filename string, seed int64) error {
+	file, err := Render(definition, seed)
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot render sequence"))
+	}
+	err = file.WriteFile(filename)
+	if err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot write midi file", fmt.Sprintf("Could not write midi file %s", filename)))
+	}
+	return nil
+}
+
+// Render plays the whole arrangement once, from the first section to the
+// last, and returns an SMF with a tempo track followed by one track per MIDI
+// channel used by the sequence lines.  The tempo track follows the tempos and
+// ramps of the sections and the time signatures of their parts.  The chances
+// of the notes are rolled from seed.
+func Render(definition sequence.Sequence, seed int64) (*smf.SMF, error) {
+	events, err := renderer.Render(definition, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	file := smf.NewSMF1()
+	file.TimeFormat = smf.MetricTicks(renderer.PPQN)
+
+	err = file.Add(tempoTrack(definition))
+	if err != nil {
+		return nil, fault.Wrap(err, fmsg.With("cannot add tempo track"))
+	}
+
+	channelEvents := make(map[uint8][]renderer.Event)
+	for _, e := range events {
+		var channel uint8
+		e.Message.GetChannel(&channel)
+		channelEvents[channel] = append(channelEvents[channel], e)
+	}
+
+	for _, channel := range slices.Sorted(maps.Keys(channelEvents)) {
+		track := channelTrack(channel, channelEvents[channel])
+		err = file.Add(track)
+		if err != nil {
+			return nil, fault.Wrap(err, fmsg.With(fmt.Sprintf("cannot add track for channel %d", channel+1)))
+		}
+	}
+
+	return file, nil
+}
+
+// tempoTrack returns the track of the tempo and time signature changes.  A
+// time signature change goes before a tempo change on the same tick, and a
+// change to a part without a meter returns to the 4/4 of an SMF without one.
+func tempoTrack(definition sequence.Sequence) smf.Track {
+	type metaEvent struct {
+		tick    int64
+		message smf.Message
+	}
+	var events []metaEvent
+	for _, change := range renderer.MeterChanges(definition) {
+		meter := change.Meter
+		if !meter.IsSet() {
+			meter = arrangement.Meter{Numerator: 4, Denominator: 4}
+		}
+		events = append(events, metaEvent{change.Tick, smf.MetaMeter(meter.Numerator, meter.Denominator)})
+	}
+	for _, change := range renderer.TempoChanges(definition) {
+		events = append(events, metaEvent{change.Tick, smf.MetaTempo(float64(change.Tempo))})
+	}
+	slices.SortStableFunc(events, func(a, b metaEvent) int {
+		return cmp.Compare(a.tick, b.tick)
+	})
+
+	var track smf.Track
+	var lastTick int64
+	for _, e := range events {
+		track.Add(uint32(e.tick-lastTick), e.message)
+		lastTick = e.tick
+	}
+	track.Close(0)
+	return track
+}
+
+// channelTrack converts the events of a single channel into an SMF track.
+// Note ons that retrigger a sounding note are preceded by a note off and
+// note offs for notes that are no longer sounding are dropped, matching the
+// note registry behaviour of live playback.
+func channelTrack(channel uint8, events []renderer.Event) smf.Track {
+	var track smf.Track
+	track.Add(0, smf.MetaTrackSequenceName(fmt.Sprintf("Channel %d", channel+1)))
+
+	sounding := make(map[notereg.NoteRegKey]bool)
+	var lastTick int64
+	for _, e := range events {
+		key := notereg.GetKey(e.Message)
+		switch e.Message.Type() {
+		case midi.NoteOnMsg:
+			if sounding[key] {
+				track.Add(uint32(e.Tick-lastTick), midi.NoteOff(key.Channel, key.Note))
+				lastTick = e.Tick
+			}
+			sounding[key] = true
+		case midi.NoteOffMsg:
+			if !sounding[key] {
+				continue
+			}
+			delete(sounding, key)
+		}
+		track.Add(uint32(e.Tick-lastTick), e.Message)
+		lastTick = e.Tick
+	}
+	track.Close(0)
+	return track
+}
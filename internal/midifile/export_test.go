@@ -0,0 +1,147 @@
+package midifile
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/renderer"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+	midi "gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestRender(t *testing.T) {
+	t.Run("one track per channel", func(t *testing.T) {
+		definition := sequence.InitSequence("", "")
+		(*definition.Parts)[0].Beats = 4
+		definition.Lines = []grid.LineDefinition{
+			{Channel: 5, Note: 60, MsgType: grid.MessageTypeNote},
+			{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote},
+		}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(1, 1), grid.Note{AccentIndex: 5})
+
+		file, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(1), file.Format())
+		assert.Equal(t, smf.MetricTicks(renderer.PPQN), file.TimeFormat)
+		assert.Len(t, file.Tracks, 3)
+
+		var bpm float64
+		assert.True(t, file.Tracks[0][0].Message.GetMetaTempo(&bpm))
+		assert.Equal(t, float64(120), bpm)
+
+		var channel, key, velocity uint8
+		assert.True(t, file.Tracks[1][1].Message.GetNoteOn(&channel, &key, &velocity))
+		assert.Equal(t, uint8(4), channel)
+		assert.True(t, file.Tracks[2][1].Message.GetNoteOn(&channel, &key, &velocity))
+		assert.Equal(t, uint8(9), channel)
+		assert.Equal(t, uint8(36), key)
+	})
+
+	t.Run("written file can be read back", func(t *testing.T) {
+		definition := sequence.InitSequence("", "")
+		(*definition.Parts)[0].Beats = 4
+		definition.Lines = []grid.LineDefinition{{Channel: 5, Note: 60, MsgType: grid.MessageTypeNote}}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+		filename := filepath.Join(t.TempDir(), "song.mid")
+
+		err := Export(definition, filename, 0)
+		assert.NoError(t, err)
+
+		file, err := smf.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Len(t, file.Tracks, 2)
+
+		var buffer bytes.Buffer
+		_, err = file.WriteTo(&buffer)
+		assert.NoError(t, err)
+	})
+
+	t.Run("tempo track follows section tempos", func(t *testing.T) {
+		definition := sequence.InitSequence("", "")
+		(*definition.Parts)[0].Beats = 4
+		section := definition.Arrangement.Nodes[0]
+		second := &arrangement.Arrangement{Iterations: 1, Section: section.Section}
+		second.Section.Tempo = 90
+		definition.Arrangement.Nodes = append(definition.Arrangement.Nodes, second)
+
+		file, err := Render(definition, 0)
+		assert.NoError(t, err)
+
+		var bpm float64
+		var tempos []float64
+		var ticks []uint32
+		for _, event := range file.Tracks[0] {
+			if event.Message.GetMetaTempo(&bpm) {
+				tempos = append(tempos, bpm)
+				ticks = append(ticks, event.Delta)
+			}
+		}
+		assert.InDeltaSlice(t, []float64{120, 90}, tempos, 0.001)
+		// The second section starts after 4 beats of the first
+		assert.Equal(t, []uint32{0, 4 * renderer.PPQN / 2}, ticks)
+	})
+
+	t.Run("meter of each part", func(t *testing.T) {
+		definition := sequence.InitSequence("", "")
+		(*definition.Parts)[0].Beats = 4
+		(*definition.Parts)[0].Meter = arrangement.Meter{Numerator: 7, Denominator: 8}
+		section := definition.Arrangement.Nodes[0]
+		second := &arrangement.Arrangement{Iterations: 1, Section: section.Section}
+		second.Section.Part = 1
+		definition.Arrangement.Nodes = append(definition.Arrangement.Nodes, second)
+		*definition.Parts = append(*definition.Parts, arrangement.InitPart("Part 2"))
+		(*definition.Parts)[1].Beats = 4
+
+		file, err := Render(definition, 0)
+		assert.NoError(t, err)
+
+		var numerator, denominator uint8
+		var meters [][2]uint8
+		var ticks []uint32
+		for _, event := range file.Tracks[0] {
+			if event.Message.GetMetaMeter(&numerator, &denominator) {
+				meters = append(meters, [2]uint8{numerator, denominator})
+				ticks = append(ticks, event.Delta)
+			}
+		}
+		// The second part has no meter and returns to 4/4
+		assert.Equal(t, [][2]uint8{{7, 8}, {4, 4}}, meters)
+		assert.Equal(t, []uint32{0, 4 * renderer.PPQN / 2}, ticks)
+	})
+
+	t.Run("requires a tempo", func(t *testing.T) {
+		definition := sequence.InitSequence("", "")
+		definition.Tempo = 0
+
+		_, err := Render(definition, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestChannelTrack(t *testing.T) {
+	t.Run("retriggered notes are cut before the next note on", func(t *testing.T) {
+		events := []renderer.Event{
+			{Tick: 0, Message: midi.NoteOn(0, 60, 100)},
+			{Tick: 10, Message: midi.NoteOn(0, 60, 100)},
+			{Tick: 20, Message: midi.NoteOff(0, 60)},
+			{Tick: 30, Message: midi.NoteOff(0, 60)},
+		}
+
+		track := channelTrack(0, events)
+
+		types := make([]midi.Type, 0, len(track))
+		for _, e := range track[1 : len(track)-1] {
+			types = append(types, e.Message.Type())
+		}
+		assert.Equal(t, []midi.Type{midi.NoteOnMsg, midi.NoteOffMsg, midi.NoteOnMsg, midi.NoteOffMsg}, types)
+		assert.Equal(t, uint32(10), track[2].Delta)
+		assert.Equal(t, uint32(0), track[3].Delta)
+		assert.Equal(t, uint32(10), track[4].Delta)
+	})
+}
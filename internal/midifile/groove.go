@@ -0,0 +1,83 @@
+package midifile
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/groove"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// A note this close before a beat is played early rather than late,
+// it counts as on the beat
+const earlyNote = 0.25
+
+// ExtractGroove reads a Standard MIDI File and measures its groove.
+func ExtractGroove(filename string, name string, subdivisions int, steps int) (groove.Groove, error) {
+	file, err := smf.ReadFile(filename)
+	if err != nil {
+		return groove.Groove{}, fault.Wrap(err, fmsg.WithDesc("cannot read midi file", fmt.Sprintf("Could not read midi file %s", filename)))
+	}
+	return ExtractGrooveSMF(file, name, subdivisions, steps)
+}
+
+// ExtractGrooveSMF measures how late and how loud the notes of a file are played
+// at each position of a pattern of steps beats.  The offset of a step is the
+// average delay of its notes and the velocity is the average velocity of its
+// notes relative to the loudest step.  Steps without notes play straight.
+func ExtractGrooveSMF(file *smf.SMF, name string, subdivisions int, steps int) (groove.Groove, error) {
+	resolution, ok := file.TimeFormat.(smf.MetricTicks)
+	if !ok || resolution == 0 {
+		return groove.Groove{}, fault.New("unsupported time format", fmsg.WithDesc("unsupported time format", "Only midi files with metric ticks can be imported"))
+	}
+	if subdivisions <= 0 || steps <= 0 || steps > math.MaxUint8 {
+		return groove.Groove{}, fault.New("invalid groove options", fmsg.WithDesc("invalid groove options", fmt.Sprintf("Subdivisions %d must be greater than 0 and steps %d between 1 and %d", subdivisions, steps, math.MaxUint8)))
+	}
+
+	notes := readNotes(file)
+	if len(notes) == 0 {
+		return groove.Groove{}, fault.New("no notes", fmsg.WithDesc("no notes", "The midi file has no notes to measure a groove from"))
+	}
+
+	stepTicks := float64(resolution.Resolution()) / float64(subdivisions)
+	offsets := make([]float64, steps)
+	velocities := make([]float64, steps)
+	counts := make([]int, steps)
+	for _, note := range notes {
+		position := float64(note.start) / stepTicks
+		beat := math.Floor(position)
+		offset := position - beat
+		if offset >= 1-earlyNote {
+			beat++
+			offset = 0
+		}
+		step := int(beat) % steps
+		offsets[step] += offset
+		velocities[step] += float64(note.velocity)
+		counts[step]++
+	}
+
+	var loudest float64
+	for i := range steps {
+		if counts[i] > 0 {
+			offsets[i] /= float64(counts[i])
+			velocities[i] /= float64(counts[i])
+			loudest = max(loudest, velocities[i])
+		}
+	}
+
+	extracted := groove.Groove{Name: name, Steps: make([]groove.Step, steps)}
+	for i := range steps {
+		extracted.Steps[i] = groove.Step{Offset: 0, Velocity: 100}
+		if counts[i] > 0 && loudest > 0 {
+			extracted.Steps[i] = groove.Step{
+				Offset:   uint8(math.Round(offsets[i] * 100)),
+				Velocity: uint8(math.Round(velocities[i] / loudest * 100)),
+			}
+		}
+	}
+
+	return extracted, nil
+}
@@ -0,0 +1,54 @@
+package midifile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractGrooveSMF(t *testing.T) {
+	t.Run("offsets and velocities of each step", func(t *testing.T) {
+		// Eighth notes with the offbeats 12 ticks late and softer
+		file := NotesSMF(96, 120,
+			testNote{0, 24, 9, 36, 100}, testNote{60, 24, 9, 42, 80},
+			testNote{96, 24, 9, 36, 100}, testNote{156, 24, 9, 42, 60},
+		)
+
+		extracted, err := ExtractGrooveSMF(file, "Late", 2, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, groove.Groove{Name: "Late", Steps: []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 25, Velocity: 70}}}, extracted)
+	})
+
+	t.Run("early notes count as on the beat", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{0, 24, 9, 36, 100}, testNote{94, 24, 9, 36, 100})
+
+		extracted, err := ExtractGrooveSMF(file, "Early", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 0, Velocity: 100}}, extracted.Steps)
+	})
+
+	t.Run("steps without notes play straight", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{0, 24, 9, 36, 50})
+
+		extracted, err := ExtractGrooveSMF(file, "Sparse", 4, 4)
+		assert.NoError(t, err)
+		assert.Equal(t, []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 0, Velocity: 100}, {Offset: 0, Velocity: 100}, {Offset: 0, Velocity: 100}}, extracted.Steps)
+	})
+
+	t.Run("no notes", func(t *testing.T) {
+		_, err := ExtractGrooveSMF(NotesSMF(96, 120), "Empty", 4, 16)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid steps", func(t *testing.T) {
+		_, err := ExtractGrooveSMF(NotesSMF(96, 120, testNote{0, 24, 9, 36, 50}), "Empty", 4, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := ExtractGroove(filepath.Join(t.TempDir(), "missing.mid"), "Missing", 4, 16)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,257 @@
+package midifile
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/sequence"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// ImportOptions control how a MIDI file is quantized onto the grid.
+type ImportOptions struct {
+	// Subdivisions is the number of grid beats per quarter note
+	Subdivisions int
+	// BarsPerPart is the number of bars of the file that make up each part
+	BarsPerPart int
+	Template    string
+	Instrument  string
+}
+
+// DefaultImportOptions quantizes to sixteenth notes with two bars of 4/4 per
+// part, which matches the 32 beats of a new part.
+func DefaultImportOptions() ImportOptions {
+	return ImportOptions{Subdivisions: 4, BarsPerPart: 2}
+}
+
+type importedNote struct {
+	channel  uint8
+	key      uint8
+	velocity uint8
+	start    int64
+	end      int64
+}
+
+type lineKey struct {
+	channel uint8
+	key     uint8
+}
+
+// Import reads a Standard MIDI File and quantizes it into a new sequence.
+func Import(filename string, options ImportOptions) (sequence.Sequence, error) {
+	file, err := smf.ReadFile(filename)
+	if err != nil {
+		return sequence.Sequence{}, fault.Wrap(err, fmsg.WithDesc("cannot read midi file", fmt.Sprintf("Could not read midi file %s", filename)))
+	}
+	return ImportSMF(file, options)
+}
+
+// ImportSMF quantizes the notes of an SMF into a new sequence.  Each distinct
+// channel/note pair becomes a line, each BarsPerPart bars become a part, and
+// the velocity, length and timing of each note are mapped to the nearest
+// accent, gate and wait values.
+func ImportSMF(file *smf.SMF, options ImportOptions) (sequence.Sequence, error) {
+	resolution, ok := file.TimeFormat.(smf.MetricTicks)
+	if !ok || resolution == 0 {
+		return sequence.Sequence{}, fault.New("unsupported time format", fmsg.WithDesc("unsupported time format", "Only midi files with metric ticks can be imported"))
+	}
+	if options.Subdivisions <= 0 || options.BarsPerPart <= 0 {
+		return sequence.Sequence{}, fault.New("invalid import options", fmsg.WithDesc("invalid import options", fmt.Sprintf("Subdivisions %d and bars per part %d must be greater than 0", options.Subdivisions, options.BarsPerPart)))
+	}
+
+	tempo, numerator, denominator := fileTiming(file)
+	notes := readNotes(file)
+
+	stepTicks := float64(resolution.Resolution()) / float64(options.Subdivisions)
+	barSteps := float64(options.Subdivisions*4*int(numerator)) / float64(denominator)
+	partSteps := int(math.Round(barSteps * float64(options.BarsPerPart)))
+	if partSteps < 1 || partSteps > 127 {
+		return sequence.Sequence{}, fault.New("part length out of range", fmsg.WithDesc("part length out of range", fmt.Sprintf("%d bars of %d/%d at %d subdivisions gives %d beats per part, parts must have between 1 and 127 beats", options.BarsPerPart, numerator, denominator, options.Subdivisions, partSteps)))
+	}
+
+	definition := sequence.InitSequence(options.Template, options.Instrument)
+	definition.Tempo = tempo
+	definition.Subdivisions = options.Subdivisions
+
+	lineKeys := make([]lineKey, 0)
+	for _, note := range notes {
+		key := lineKey{note.channel, note.key}
+		if !slices.Contains(lineKeys, key) {
+			lineKeys = append(lineKeys, key)
+		}
+	}
+	if len(lineKeys) > math.MaxUint8 {
+		return sequence.Sequence{}, fault.New("too many lines", fmsg.WithDesc("too many lines", fmt.Sprintf("The midi file has %d distinct notes, only %d lines are supported", len(lineKeys), math.MaxUint8)))
+	}
+	slices.SortFunc(lineKeys, func(a, b lineKey) int {
+		return cmp.Or(cmp.Compare(a.channel, b.channel), cmp.Compare(a.key, b.key))
+	})
+	if len(lineKeys) > 0 {
+		definition.Lines = make([]grid.LineDefinition, 0, len(lineKeys))
+		for _, key := range lineKeys {
+			definition.Lines = append(definition.Lines, grid.LineDefinition{Channel: key.channel + 1, Note: key.key, MsgType: grid.MessageTypeNote})
+		}
+	}
+
+	var lastStep int
+	for _, note := range notes {
+		step, _ := QuantizeStart(float64(note.start) / stepTicks)
+		lastStep = max(lastStep, step)
+	}
+	parts := make([]arrangement.Part, 0, lastStep/partSteps+1)
+	for i := range lastStep/partSteps + 1 {
+		part := arrangement.InitPart(fmt.Sprintf("Part %d", i+1))
+		part.Beats = uint8(partSteps)
+		parts = append(parts, part)
+	}
+
+	beatInterval := time.Minute / time.Duration(tempo*options.Subdivisions)
+	for _, note := range notes {
+		step, waitIndex := QuantizeStart(float64(note.start) / stepTicks)
+		line := uint8(slices.Index(lineKeys, lineKey{note.channel, note.key}))
+		gridKey := grid.GK(line, uint8(step%partSteps))
+		overlay := parts[step/partSteps].Overlays
+		if _, exists := overlay.Notes[gridKey]; exists {
+			continue
+		}
+
+		gridNote := grid.InitNote()
+		gridNote.AccentIndex = AccentIndex(note.velocity, definition.Accents.Data)
+		gridNote.GateIndex = GateIndex(float64(note.end-note.start)/stepTicks, beatInterval)
+		gridNote.WaitIndex = waitIndex
+		overlay.AddNote(gridKey, gridNote)
+	}
+
+	definition.Parts = &parts
+	definition.Arrangement = sequence.InitArrangement(parts)
+
+	return definition, nil
+}
+
+// fileTiming returns the first tempo and time signature found in the file,
+// defaulting to 120bpm in 4/4.
+func fileTiming(file *smf.SMF) (int, uint8, uint8) {
+	tempo, numerator, denominator := 0, uint8(0), uint8(0)
+	for _, track := range file.Tracks {
+		for _, e := range track {
+			var bpm float64
+			var num, denom uint8
+			if tempo == 0 && e.Message.GetMetaTempo(&bpm) {
+				tempo = int(math.Round(bpm))
+			}
+			if numerator == 0 && e.Message.GetMetaMeter(&num, &denom) {
+				numerator, denominator = num, denom
+			}
+		}
+	}
+	if tempo <= 0 {
+		tempo = 120
+	}
+	if numerator == 0 || denominator == 0 {
+		numerator, denominator = 4, 4
+	}
+	return tempo, numerator, denominator
+}
+
+// readNotes pairs the note starts and note ends of every track, returning the
+// notes ordered by start tick.  A note that is never ended has no length.
+func readNotes(file *smf.SMF) []importedNote {
+	notes := make([]importedNote, 0)
+	for _, track := range file.Tracks {
+		sounding := make(map[lineKey][]int)
+		var tick int64
+		for _, e := range track {
+			tick += int64(e.Delta)
+			var channel, key, velocity uint8
+			switch {
+			case e.Message.GetNoteStart(&channel, &key, &velocity):
+				sounding[lineKey{channel, key}] = append(sounding[lineKey{channel, key}], len(notes))
+				notes = append(notes, importedNote{channel: channel, key: key, velocity: velocity, start: tick, end: tick})
+			case e.Message.GetNoteEnd(&channel, &key):
+				open := sounding[lineKey{channel, key}]
+				if len(open) > 0 {
+					notes[open[0]].end = tick
+					sounding[lineKey{channel, key}] = open[1:]
+				}
+			}
+		}
+	}
+	slices.SortStableFunc(notes, func(a, b importedNote) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	return notes
+}
+
+// QuantizeStart converts a position measured in grid beats to a beat and the
+// wait index nearest to the remainder.  Notes closer to the following beat
+// than to the largest wait are moved onto that beat.
+func QuantizeStart(position float64) (int, uint8) {
+	step := int(position)
+	percentage := (position - float64(step)) * 100
+
+	var waitIndex uint8
+	for i, wait := range config.WaitPercentages {
+		if math.Abs(float64(wait)-percentage) < math.Abs(float64(config.WaitPercentages[waitIndex])-percentage) {
+			waitIndex = uint8(i)
+		}
+	}
+	if 100-percentage < math.Abs(float64(config.WaitPercentages[waitIndex])-percentage) {
+		return step + 1, 0
+	}
+	return step, waitIndex
+}
+
+// AccentIndex returns the index of the accent value nearest to velocity.
+// Index 0 is skipped since it is not a playable accent.
+func AccentIndex(velocity uint8, accents []config.Accent) uint8 {
+	index := uint8(1)
+	for i := 1; i < len(accents); i++ {
+		if absDiff(accents[i], velocity) < absDiff(accents[index], velocity) {
+			index = uint8(i)
+		}
+	}
+	return index
+}
+
+func absDiff(accent config.Accent, velocity uint8) int {
+	diff := int(accent) - int(velocity)
+	if diff < 0 {
+		return -diff
+	}
+	return diff
+}
+
+// GateIndex returns the index of the gate nearest to length, measured in grid
+// beats.  The first short gate is a fixed number of milliseconds rather than
+// a fraction of a beat.
+func GateIndex(length float64, beatInterval time.Duration) int16 {
+	longGates := config.LongGates
+	if len(longGates) == 0 {
+		longGates = config.GetGateLengths(32)
+	}
+
+	gateValue := func(i int) float64 {
+		if i == 0 {
+			return float64(time.Duration(config.ShortGates[0].Value)*time.Millisecond) / float64(beatInterval)
+		} else if i < len(config.ShortGates) {
+			return float64(config.ShortGates[i].Value)
+		}
+		return float64(longGates[i-len(config.ShortGates)].Value)
+	}
+
+	var index int
+	for i := 1; i < len(config.ShortGates)+len(longGates); i++ {
+		if math.Abs(gateValue(i)-length) < math.Abs(gateValue(index)-length) {
+			index = i
+		}
+	}
+	return int16(index)
+}
@@ -0,0 +1,162 @@
+package midifile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/renderer"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+	midi "gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+type testNote struct {
+	tick     uint32
+	length   uint32
+	channel  uint8
+	key      uint8
+	velocity uint8
+}
+
+func TestImportSMF(t *testing.T) {
+	t.Run("one line per channel and note", func(t *testing.T) {
+		file := NotesSMF(96, 140, testNote{0, 24, 9, 38, 100}, testNote{24, 24, 9, 36, 100}, testNote{48, 24, 0, 40, 100}, testNote{72, 24, 9, 36, 100})
+
+		definition, err := ImportSMF(file, DefaultImportOptions())
+		assert.NoError(t, err)
+		assert.Equal(t, 140, definition.Tempo)
+		assert.Equal(t, 4, definition.Subdivisions)
+		assert.Equal(t, []grid.LineDefinition{
+			{Channel: 1, Note: 40, MsgType: grid.MessageTypeNote},
+			{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote},
+			{Channel: 10, Note: 38, MsgType: grid.MessageTypeNote},
+		}, definition.Lines)
+
+		notes := (*definition.Parts)[0].Overlays.Notes
+		assert.Len(t, notes, 4)
+		assert.Contains(t, notes, grid.GK(2, 0))
+		assert.Contains(t, notes, grid.GK(1, 1))
+		assert.Contains(t, notes, grid.GK(0, 2))
+		assert.Contains(t, notes, grid.GK(1, 3))
+	})
+
+	t.Run("every n bars becomes a part", func(t *testing.T) {
+		bar := uint32(96 * 4)
+		file := NotesSMF(96, 120, testNote{0, 24, 9, 36, 100}, testNote{bar, 24, 9, 36, 100}, testNote{4*bar + 24, 24, 9, 36, 100})
+
+		definition, err := ImportSMF(file, ImportOptions{Subdivisions: 4, BarsPerPart: 1})
+		assert.NoError(t, err)
+		assert.Len(t, *definition.Parts, 5)
+		assert.Len(t, definition.Arrangement.Nodes, 5)
+		for _, part := range *definition.Parts {
+			assert.Equal(t, uint8(16), part.Beats)
+		}
+		assert.Contains(t, (*definition.Parts)[1].Overlays.Notes, grid.GK(0, 0))
+		assert.Empty(t, (*definition.Parts)[2].Overlays.Notes)
+		assert.Contains(t, (*definition.Parts)[4].Overlays.Notes, grid.GK(0, 1))
+	})
+
+	t.Run("time signature sets the part length", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{0, 24, 9, 36, 100})
+		file.Tracks[0] = append(smf.Track{{Delta: 0, Message: smf.MetaMeter(3, 4)}}, file.Tracks[0]...)
+
+		definition, err := ImportSMF(file, DefaultImportOptions())
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(24), (*definition.Parts)[0].Beats)
+	})
+
+	t.Run("velocity maps to the nearest accent", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{0, 24, 9, 36, 127}, testNote{24, 24, 9, 36, 80}, testNote{48, 24, 9, 36, 1})
+
+		definition, err := ImportSMF(file, DefaultImportOptions())
+		assert.NoError(t, err)
+		notes := (*definition.Parts)[0].Overlays.Notes
+		assert.Equal(t, uint8(1), notes[grid.GK(0, 0)].AccentIndex)
+		assert.Equal(t, uint8(4), notes[grid.GK(0, 1)].AccentIndex)
+		assert.Equal(t, uint8(8), notes[grid.GK(0, 2)].AccentIndex)
+	})
+
+	t.Run("length maps to the nearest gate", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{0, 4, 9, 36, 100}, testNote{24, 12, 9, 36, 100}, testNote{48, 24, 9, 36, 100}, testNote{96, 60, 9, 36, 100})
+
+		definition, err := ImportSMF(file, DefaultImportOptions())
+		assert.NoError(t, err)
+		notes := (*definition.Parts)[0].Overlays.Notes
+		assert.Equal(t, int16(0), notes[grid.GK(0, 0)].GateIndex)
+		assert.Equal(t, int16(4), notes[grid.GK(0, 1)].GateIndex)
+		assert.Equal(t, int16(8), notes[grid.GK(0, 2)].GateIndex)
+		assert.Equal(t, int16(20), notes[grid.GK(0, 4)].GateIndex)
+	})
+
+	t.Run("off grid timing maps to the nearest wait", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{4, 12, 9, 36, 100}, testNote{24 + 13, 12, 9, 36, 100}, testNote{48 + 22, 12, 9, 36, 100})
+
+		definition, err := ImportSMF(file, DefaultImportOptions())
+		assert.NoError(t, err)
+		notes := (*definition.Parts)[0].Overlays.Notes
+		assert.Len(t, notes, 3)
+		assert.Equal(t, uint8(2), notes[grid.GK(0, 0)].WaitIndex)
+		assert.Equal(t, uint8(7), notes[grid.GK(0, 1)].WaitIndex)
+		// Closer to the next beat than to the largest wait
+		assert.Equal(t, uint8(0), notes[grid.GK(0, 3)].WaitIndex)
+	})
+
+	t.Run("parts longer than 127 beats are rejected", func(t *testing.T) {
+		file := NotesSMF(96, 120, testNote{0, 24, 9, 36, 100})
+
+		_, err := ImportSMF(file, ImportOptions{Subdivisions: 4, BarsPerPart: 8})
+		assert.Error(t, err)
+	})
+}
+
+func TestImport(t *testing.T) {
+	t.Run("exported sequence imports to the same notes", func(t *testing.T) {
+		definition := sequence.InitSequence("", "")
+		(*definition.Parts)[0].Beats = 8
+		definition.Lines = []grid.LineDefinition{{Channel: 5, Note: 60, MsgType: grid.MessageTypeNote}}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 1, GateIndex: 4})
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 3), grid.Note{AccentIndex: 1, GateIndex: 4, WaitIndex: 3})
+		filename := filepath.Join(t.TempDir(), "song.mid")
+		assert.NoError(t, Export(definition, filename, 0))
+
+		imported, err := Import(filename, ImportOptions{Subdivisions: 2, BarsPerPart: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []grid.LineDefinition{{Channel: 5, Note: 60, MsgType: grid.MessageTypeNote}}, imported.Lines)
+		notes := (*imported.Parts)[0].Overlays.Notes
+		assert.Len(t, notes, 2)
+		assert.Equal(t, int16(4), notes[grid.GK(0, 0)].GateIndex)
+		assert.Equal(t, uint8(3), notes[grid.GK(0, 3)].WaitIndex)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Import(filepath.Join(t.TempDir(), "missing.mid"), DefaultImportOptions())
+		assert.Error(t, err)
+	})
+}
+
+func NotesSMF(resolution uint16, tempo float64, notes ...testNote) *smf.SMF {
+	events := make([]renderer.Event, 0, len(notes)*2)
+	for _, note := range notes {
+		events = append(events,
+			renderer.Event{Tick: int64(note.tick), Message: midi.NoteOn(note.channel, note.key, note.velocity)},
+			renderer.Event{Tick: int64(note.tick + note.length), Message: midi.NoteOff(note.channel, note.key)},
+		)
+	}
+	renderer.Sort(events)
+
+	var track smf.Track
+	track.Add(0, smf.MetaTempo(tempo))
+	var lastTick int64
+	for _, e := range events {
+		track.Add(uint32(e.Tick-lastTick), e.Message)
+		lastTick = e.Tick
+	}
+	track.Close(0)
+
+	file := smf.NewSMF1()
+	file.TimeFormat = smf.MetricTicks(resolution)
+	file.Tracks = append(file.Tracks, track)
+	return file
+}
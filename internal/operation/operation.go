@@ -45,12 +45,18 @@ const (
 	SelectSetupChannel
 	SelectSetupMessageType
 	SelectSetupValue
+	SelectSetupLength
+	SelectSetupDivision
 	SelectAccentTarget
 	SelectAccentStart
 	SelectAccentEnd
 
 	// Part Change
 	SelectBeats
+	SelectGroove
+	SelectGrooveAmount
+	SelectMeterNumerator
+	SelectMeterDenominator
 
 	// Arrangement Change
 	SelectPart
@@ -65,12 +71,21 @@ const (
 	SelectRatchetSpan
 	SelectSpecificValue
 	SelectEuclideanHits
+	SelectLockControl
+	SelectLockValue
 
 	// Program Level Operation
 	SelectConfirmNew
 	SelectConfirmQuit
 	SelectConfirmReload
+	SelectConfirmImport
 	SelectFileName
+	SelectImportFile
+	SelectRecoverFile
+	SelectUndoTree
+	SelectSnapshotName
+	SelectSnapshot
+	SelectLibrary
 	SelectError
 )
 
@@ -87,6 +102,10 @@ const (
 	PatternNoteGate
 	PatternNoteWait
 	PatternNoteRatchet
+	PatternProbability
+	PatternNoteProbability
+	PatternCondition
+	PatternNoteCondition
 )
 
 type EveryMode uint8
@@ -106,9 +125,14 @@ var NumberSelections = []Selection{
 	SelectTempoSubdivision,
 	SelectSetupChannel,
 	SelectSetupValue,
+	SelectSetupLength,
 	SelectAccentStart,
 	SelectAccentEnd,
 	SelectEuclideanHits,
+	SelectGrooveAmount,
+	SelectMeterNumerator,
+	SelectLockControl,
+	SelectLockValue,
 }
 
 func IsNumberSelection(sel Selection) bool {
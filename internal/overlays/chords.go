@@ -214,6 +214,9 @@ func (gc GridChord) DeepCopy() GridChord {
 				Action:      bn.Note.Action,
 				GateIndex:   bn.Note.GateIndex,
 				WaitIndex:   bn.Note.WaitIndex,
+				Probability: bn.Note.Probability,
+				Condition:   bn.Note.Condition,
+				Locks:       bn.Note.Locks,
 			},
 		}
 	}
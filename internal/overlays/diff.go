@@ -3,6 +3,8 @@ package overlays
 import (
 	"fmt"
 	"maps"
+	"slices"
+	"strings"
 
 	"github.com/chriserin/sq/internal/grid"
 	"github.com/chriserin/sq/internal/theory"
@@ -161,7 +163,10 @@ func noteEqual(a, b grid.Note) bool {
 		a.Ratchets.Span == b.Ratchets.Span &&
 		a.Action == b.Action &&
 		a.GateIndex == b.GateIndex &&
-		a.WaitIndex == b.WaitIndex
+		a.WaitIndex == b.WaitIndex &&
+		a.Probability == b.Probability &&
+		a.Condition == b.Condition &&
+		a.Locks == b.Locks
 }
 
 func chordsMatch(a *GridChord, b GridChord) bool {
@@ -351,3 +356,103 @@ func DeepCopy(ol *Overlay) *Overlay {
 
 	return clone
 }
+
+// Changes returns a readable description of each difference, notes in order
+// of their line and beat followed by chords in order of their root.  Lines and
+// beats are numbered from 1 as they are shown on the grid.
+func (od OverlayDiff) Changes() []string {
+	var changes []string
+
+	for _, gridKey := range sortedKeys(od.RemovedNotes) {
+		changes = append(changes, fmt.Sprintf("removed note %s %s", position(gridKey), describeNote(od.RemovedNotes[gridKey])))
+	}
+	for _, gridKey := range sortedKeys(od.AddedNotes) {
+		changes = append(changes, fmt.Sprintf("added note %s %s", position(gridKey), describeNote(od.AddedNotes[gridKey])))
+	}
+	for _, gridKey := range sortedKeys(od.ModifiedNotes) {
+		noteDiff := od.ModifiedNotes[gridKey]
+		changes = append(changes, fmt.Sprintf("changed note %s %s", position(gridKey), strings.Join(noteChanges(noteDiff.OldNote, noteDiff.NewNote), ", ")))
+	}
+
+	for _, chord := range sortedChords(od.RemovedChords) {
+		changes = append(changes, fmt.Sprintf("removed chord %s %s", chord.Chord.Name(), position(chord.Root)))
+	}
+	for _, chord := range sortedChords(od.AddedChords) {
+		changes = append(changes, fmt.Sprintf("added chord %s %s", chord.Chord.Name(), position(chord.Root)))
+	}
+	modifiedChords := make([]GridChord, 0, len(od.ModifiedChords))
+	for _, chordDiff := range od.ModifiedChords {
+		modifiedChords = append(modifiedChords, chordDiff.OldChord)
+	}
+	for _, chord := range sortedChords(modifiedChords) {
+		changes = append(changes, fmt.Sprintf("changed chord %s %s", chord.Chord.Name(), position(chord.Root)))
+	}
+
+	if od.OptionsDiff.PressUpChanged {
+		changes = append(changes, "changed press up")
+	}
+	if od.OptionsDiff.PressDownChanged {
+		changes = append(changes, "changed press down")
+	}
+
+	return changes
+}
+
+func position(gridKey grid.GridKey) string {
+	return fmt.Sprintf("line %d beat %d", gridKey.Line+1, gridKey.Beat+1)
+}
+
+func comparePosition(a, b grid.GridKey) int {
+	if a.Line == b.Line {
+		return int(a.Beat) - int(b.Beat)
+	}
+	return int(a.Line) - int(b.Line)
+}
+
+func sortedKeys[T any](notes map[grid.GridKey]T) []grid.GridKey {
+	return slices.SortedFunc(maps.Keys(notes), comparePosition)
+}
+
+func sortedChords(chords []GridChord) []GridChord {
+	return slices.SortedFunc(slices.Values(chords), func(a, b GridChord) int {
+		return comparePosition(a.Root, b.Root)
+	})
+}
+
+func describeNote(note grid.Note) string {
+	if note.Action != grid.ActionNothing {
+		return fmt.Sprintf("(action %d)", note.Action)
+	}
+	return fmt.Sprintf("(accent %d)", note.AccentIndex)
+}
+
+func noteChanges(old, new grid.Note) []string {
+	var changes []string
+	if old.AccentIndex != new.AccentIndex {
+		changes = append(changes, fmt.Sprintf("accent %d→%d", old.AccentIndex, new.AccentIndex))
+	}
+	if old.Action != new.Action {
+		changes = append(changes, fmt.Sprintf("action %d→%d", old.Action, new.Action))
+	}
+	if old.GateIndex != new.GateIndex {
+		changes = append(changes, fmt.Sprintf("gate %d→%d", old.GateIndex, new.GateIndex))
+	}
+	if old.WaitIndex != new.WaitIndex {
+		changes = append(changes, fmt.Sprintf("wait %d→%d", old.WaitIndex, new.WaitIndex))
+	}
+	if old.Chance() != new.Chance() {
+		changes = append(changes, fmt.Sprintf("probability %d%%→%d%%", old.Chance(), new.Chance()))
+	}
+	if old.Condition != new.Condition {
+		changes = append(changes, fmt.Sprintf("condition %q→%q", old.Condition, new.Condition))
+	}
+	if old.Locks != new.Locks {
+		changes = append(changes, fmt.Sprintf("locks %s→%s", old.Locks, new.Locks))
+	}
+	if old.Ratchets.Length != new.Ratchets.Length {
+		changes = append(changes, fmt.Sprintf("ratchets %d→%d", old.Ratchets.Length+1, new.Ratchets.Length+1))
+	} else if old.Ratchets != new.Ratchets {
+		changes = append(changes, "ratchet pattern")
+	}
+	return changes
+}
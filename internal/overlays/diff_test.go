@@ -240,6 +240,47 @@ func TestDiffOverlays(t *testing.T) {
 	})
 }
 
+func TestDiffChanges(t *testing.T) {
+	t.Run("Describes notes by line and beat", func(t *testing.T) {
+		key := overlaykey.InitOverlayKey(2, 1)
+		original := InitOverlay(key, nil)
+		original.AddNote(grid.GK(0, 0), grid.InitNote())
+		original.AddNote(grid.GK(1, 4), grid.InitNote())
+
+		modified := InitOverlay(key, nil)
+		changedNote := grid.InitNote()
+		changedNote.AccentIndex = 3
+		changedNote.GateIndex = 4
+		modified.AddNote(grid.GK(1, 4), changedNote)
+		modified.AddNote(grid.GK(3, 8), changedNote)
+
+		diff := DiffOverlays(original, modified)
+
+		assert.Equal(t, []string{
+			"removed note line 1 beat 1 (accent 5)",
+			"added note line 4 beat 9 (accent 3)",
+			"changed note line 2 beat 5 accent 5→3, gate 0→4",
+		}, diff.Changes())
+	})
+
+	t.Run("Describes chords by their root", func(t *testing.T) {
+		key := overlaykey.InitOverlayKey(2, 1)
+		original := InitOverlay(key, nil)
+		modified := InitOverlay(key, nil)
+		modified.CreateChord(grid.GK(2, 1), theory.MajorTriad)
+		modified.PressUp = true
+
+		diff := DiffOverlays(original, modified)
+
+		assert.Equal(t, []string{"added chord I line 3 beat 2", "changed press up"}, diff.Changes())
+	})
+
+	t.Run("No changes", func(t *testing.T) {
+		key := overlaykey.InitOverlayKey(2, 1)
+		assert.Empty(t, DiffOverlays(InitOverlay(key, nil), InitOverlay(key, nil)).Changes())
+	})
+}
+
 func TestDeepCopy(t *testing.T) {
 	// Test with a nil overlay
 	t.Run("Nil overlay", func(t *testing.T) {
@@ -22,6 +22,8 @@ type PlayState struct {
 	Iterations         *Iterations
 	LoopedArrangement  *arrangement.Arrangement
 	BoundedLoop        BoundedLoop
+	// Notes with the fill condition play while fill is on
+	Fill bool
 }
 
 type BoundedLoop struct {
@@ -116,6 +118,8 @@ type LineState struct {
 	GroupPlayState      GroupPlayState
 	Direction           int8
 	ResetDirection      int8
+	// The beats a divided line has waited since its last step
+	ClockCount uint8
 }
 
 func (ls LineState) IsMuted() bool {
@@ -0,0 +1,211 @@
+// Package recorder captures the notes played on a midi input into the grid
+// while sq plays.  Notes are quantized the same way an imported midi file is:
+// velocity becomes the accent, the offset from the beat becomes the wait and
+// the held length becomes the gate.
+package recorder
+
+import (
+	"slices"
+	"time"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/midifile"
+	"github.com/chriserin/sq/internal/overlays"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+type Mode uint8
+
+const (
+	// Overdub adds the recorded notes to the notes already in the grid
+	Overdub Mode = iota
+	// Replace removes the notes the playback cursor passes over
+	Replace
+)
+
+func (mode Mode) String() string {
+	switch mode {
+	case Replace:
+		return "RPL"
+	default:
+		return "OVR"
+	}
+}
+
+// NoteMsg is a note started or ended on the record source.
+type NoteMsg struct {
+	Channel  uint8
+	Key      uint8
+	Velocity uint8
+	On       bool
+	Time     time.Time
+}
+
+// ReadNote returns the note of a message received from the record source.
+func ReadNote(msg []byte, at time.Time) (NoteMsg, bool) {
+	message := midi.Message(msg)
+	var channel, key, velocity uint8
+	switch {
+	case message.GetNoteStart(&channel, &key, &velocity):
+		return NoteMsg{Channel: channel, Key: key, Velocity: velocity, On: true, Time: at}, true
+	case message.GetNoteEnd(&channel, &key):
+		return NoteMsg{Channel: channel, Key: key, Time: at}, true
+	}
+	return NoteMsg{}, false
+}
+
+// FindLine returns the line that plays key on channel, or failing that the
+// first line that plays key on any channel.  Channel is zero based while line
+// channels are one based.
+func FindLine(lines []grid.LineDefinition, channel, key uint8) (uint8, bool) {
+	index := slices.IndexFunc(lines, func(line grid.LineDefinition) bool {
+		return line.MsgType == grid.MessageTypeNote && line.Channel == channel+1 && line.Note == key
+	})
+	if index < 0 {
+		index = slices.IndexFunc(lines, func(line grid.LineDefinition) bool {
+			return line.MsgType == grid.MessageTypeNote && line.Note == key
+		})
+	}
+	if index < 0 {
+		return 0, false
+	}
+	return uint8(index), true
+}
+
+// Beat is the most recent beat that was played and the beat each line was on.
+type Beat struct {
+	Time     time.Time
+	Interval time.Duration
+	Lines    []uint8
+}
+
+// Target is the overlay notes are recorded into.
+type Target struct {
+	Overlay *overlays.Overlay
+	Beats   uint8
+	Cursor  arrangement.ArrCursor
+}
+
+// Change is an overlay changed by a take, along with a copy of the overlay
+// from before the take.
+type Change struct {
+	Target   Target
+	Original *overlays.Overlay
+}
+
+type heldNote struct {
+	overlay *overlays.Overlay
+	key     grid.GridKey
+	start   time.Time
+}
+
+// Take is one recording, from arming the recorder until recording stops.
+type Take struct {
+	Mode     Mode
+	beat     Beat
+	held     map[uint8]heldNote
+	recorded map[*overlays.Overlay]map[grid.GridKey]bool
+	changes  []Change
+}
+
+func NewTake(mode Mode) *Take {
+	return &Take{
+		Mode:     mode,
+		held:     make(map[uint8]heldNote),
+		recorded: make(map[*overlays.Overlay]map[grid.GridKey]bool),
+	}
+}
+
+// Started is true once the first beat of the take has been played.
+func (t Take) Started() bool {
+	return t.beat.Interval > 0
+}
+
+// SetBeat moves the take to the beat that was just played.
+func (t *Take) SetBeat(beat Beat) {
+	t.beat = beat
+}
+
+// Erase removes the notes on the current beat of each line, except for the
+// notes recorded during the take.  Replace mode erases on every beat.
+func (t *Take) Erase(target Target) {
+	if target.Overlay == nil {
+		return
+	}
+	for line, lineBeat := range t.beat.Lines {
+		key := grid.GK(uint8(line), lineBeat)
+		note, exists := target.Overlay.Notes[key]
+		if exists && note.Action == grid.ActionNothing && !t.recorded[target.Overlay][key] {
+			t.remember(target)
+			target.Overlay.RemoveNote(key)
+		}
+	}
+}
+
+// position returns where at falls on line measured in grid beats.
+func (t Take) position(line uint8, at time.Time) float64 {
+	var lineBeat uint8
+	if int(line) < len(t.beat.Lines) {
+		lineBeat = t.beat.Lines[line]
+	}
+	return float64(lineBeat) + float64(at.Sub(t.beat.Time))/float64(t.beat.Interval)
+}
+
+// NoteOn records a note into the target on the nearest beat of line, with the
+// accent nearest to velocity.  Notes played before the first beat of the take
+// are not recorded.
+func (t *Take) NoteOn(target Target, line uint8, velocity uint8, accents []config.Accent, at time.Time) (grid.GridKey, bool) {
+	if !t.Started() || target.Overlay == nil || target.Beats == 0 {
+		return grid.GridKey{}, false
+	}
+	step, waitIndex := midifile.QuantizeStart(max(t.position(line, at), 0))
+	key := grid.GK(line, uint8(step%int(target.Beats)))
+
+	t.remember(target)
+	note := grid.InitNote()
+	note.AccentIndex = midifile.AccentIndex(velocity, accents)
+	note.WaitIndex = waitIndex
+	target.Overlay.AddNote(key, note)
+
+	if t.recorded[target.Overlay] == nil {
+		t.recorded[target.Overlay] = make(map[grid.GridKey]bool)
+	}
+	t.recorded[target.Overlay][key] = true
+	t.held[line] = heldNote{overlay: target.Overlay, key: key, start: at}
+	return key, true
+}
+
+// NoteOff sets the gate of the note held on line to the length it was held.
+func (t *Take) NoteOff(line uint8, at time.Time) {
+	held, exists := t.held[line]
+	if !exists {
+		return
+	}
+	delete(t.held, line)
+	note, exists := held.overlay.Notes[held.key]
+	if !exists {
+		return
+	}
+	length := float64(at.Sub(held.start)) / float64(t.beat.Interval)
+	note.GateIndex = midifile.GateIndex(length, t.beat.Interval)
+	held.overlay.AddNote(held.key, note)
+}
+
+// remember keeps a copy of the target before its first change so the whole
+// take can be undone.
+func (t *Take) remember(target Target) {
+	for _, change := range t.changes {
+		if change.Target.Overlay == target.Overlay {
+			return
+		}
+	}
+	target.Cursor = slices.Clone(target.Cursor)
+	t.changes = append(t.changes, Change{Target: target, Original: overlays.DeepCopy(target.Overlay)})
+}
+
+// Changes returns the overlays changed during the take.
+func (t Take) Changes() []Change {
+	return t.changes
+}
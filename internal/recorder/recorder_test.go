@@ -0,0 +1,144 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/stretchr/testify/assert"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+func testTarget() Target {
+	return Target{Overlay: overlays.InitOverlay(overlaykey.ROOT, nil), Beats: 8, Cursor: arrangement.ArrCursor{}}
+}
+
+func TestReadNote(t *testing.T) {
+	at := time.Now()
+
+	t.Run("Note on", func(t *testing.T) {
+		note, ok := ReadNote(midi.NoteOn(2, 60, 90), at)
+		assert.True(t, ok)
+		assert.Equal(t, NoteMsg{Channel: 2, Key: 60, Velocity: 90, On: true, Time: at}, note)
+	})
+
+	t.Run("Note on without velocity ends the note", func(t *testing.T) {
+		note, ok := ReadNote(midi.NoteOn(2, 60, 0), at)
+		assert.True(t, ok)
+		assert.False(t, note.On)
+	})
+
+	t.Run("Other messages are not notes", func(t *testing.T) {
+		_, ok := ReadNote(midi.ControlChange(2, 7, 100), at)
+		assert.False(t, ok)
+	})
+}
+
+func TestFindLine(t *testing.T) {
+	lines := []grid.LineDefinition{
+		{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote},
+		{Channel: 1, Note: 60, MsgType: grid.MessageTypeCc},
+		{Channel: 1, Note: 60, MsgType: grid.MessageTypeNote},
+		{Channel: 2, Note: 60, MsgType: grid.MessageTypeNote},
+	}
+
+	tests := []struct {
+		name     string
+		channel  uint8
+		key      uint8
+		line     uint8
+		expected bool
+	}{
+		{name: "Channel and note match", channel: 1, key: 60, line: 3, expected: true},
+		{name: "Note matches on another channel", channel: 5, key: 60, line: 2, expected: true},
+		{name: "Note on the drum channel", channel: 9, key: 36, line: 0, expected: true},
+		{name: "No line plays the note", channel: 0, key: 61, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, found := FindLine(lines, tt.channel, tt.key)
+			assert.Equal(t, tt.expected, found)
+			assert.Equal(t, tt.line, line)
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	start := time.Now()
+	beat := Beat{Time: start, Interval: 100 * time.Millisecond, Lines: []uint8{6, 6}}
+
+	t.Run("Notes before the first beat are not recorded", func(t *testing.T) {
+		take := NewTake(Overdub)
+		target := testTarget()
+
+		_, ok := take.NoteOn(target, 0, 100, config.Accents, start)
+		assert.False(t, ok)
+		assert.Empty(t, target.Overlay.Notes)
+	})
+
+	t.Run("Note is quantized with a wait", func(t *testing.T) {
+		take := NewTake(Overdub)
+		take.SetBeat(beat)
+		target := testTarget()
+
+		key, ok := take.NoteOn(target, 1, 100, config.Accents, start.Add(30*time.Millisecond))
+		assert.True(t, ok)
+		assert.Equal(t, grid.GK(1, 6), key)
+		assert.NotZero(t, target.Overlay.Notes[key].WaitIndex)
+	})
+
+	t.Run("Late notes wrap around to the start of the part", func(t *testing.T) {
+		take := NewTake(Overdub)
+		take.SetBeat(beat)
+		target := testTarget()
+
+		key, ok := take.NoteOn(target, 0, 100, config.Accents, start.Add(190*time.Millisecond))
+		assert.True(t, ok)
+		assert.Equal(t, grid.GK(0, 0), key)
+	})
+
+	t.Run("Note off sets the gate", func(t *testing.T) {
+		take := NewTake(Overdub)
+		take.SetBeat(beat)
+		target := testTarget()
+
+		key, _ := take.NoteOn(target, 0, 100, config.Accents, start)
+		short := target.Overlay.Notes[key].GateIndex
+		take.NoteOff(0, start.Add(400*time.Millisecond))
+		assert.Greater(t, target.Overlay.Notes[key].GateIndex, short)
+	})
+
+	t.Run("Erase keeps recorded notes and actions", func(t *testing.T) {
+		take := NewTake(Replace)
+		take.SetBeat(beat)
+		target := testTarget()
+		target.Overlay.AddNote(grid.GK(1, 6), grid.Note{AccentIndex: 3})
+		target.Overlay.AddNote(grid.GK(0, 6), grid.Note{Action: grid.ActionLineReset})
+
+		recorded, _ := take.NoteOn(target, 0, 100, config.Accents, start.Add(-300*time.Millisecond))
+		take.Erase(target)
+
+		assert.NotContains(t, target.Overlay.Notes, grid.GK(1, 6))
+		assert.Contains(t, target.Overlay.Notes, recorded)
+		assert.Equal(t, grid.ActionLineReset, target.Overlay.Notes[grid.GK(0, 6)].Action)
+	})
+
+	t.Run("Changes keep the overlay from before the take", func(t *testing.T) {
+		take := NewTake(Overdub)
+		take.SetBeat(beat)
+		target := testTarget()
+
+		take.NoteOn(target, 0, 100, config.Accents, start)
+		take.NoteOn(target, 1, 100, config.Accents, start)
+
+		changes := take.Changes()
+		assert.Len(t, changes, 1)
+		assert.Empty(t, changes[0].Original.Notes)
+		assert.Len(t, target.Overlay.Notes, 2)
+	})
+}
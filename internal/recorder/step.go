@@ -0,0 +1,45 @@
+package recorder
+
+import "slices"
+
+// Step collects the keys held together on the record source so that they can
+// be entered on a single beat once every key is released.
+type Step struct {
+	held    []uint8
+	pressed []NoteMsg
+}
+
+// Press adds a key to the step.
+func (s *Step) Press(msg NoteMsg) {
+	if !slices.Contains(s.held, msg.Key) {
+		s.held = append(s.held, msg.Key)
+	}
+	s.pressed = append(s.pressed, msg)
+}
+
+// Release removes a key from the held keys and returns every key pressed
+// during the step once the last key is released.
+func (s *Step) Release(msg NoteMsg) ([]NoteMsg, bool) {
+	index := slices.Index(s.held, msg.Key)
+	if index < 0 {
+		return nil, false
+	}
+	s.held = slices.Delete(s.held, index, index+1)
+	if len(s.held) > 0 {
+		return nil, false
+	}
+	pressed := s.pressed
+	s.pressed = nil
+	return pressed, true
+}
+
+// Keys returns the distinct keys of the notes.
+func Keys(notes []NoteMsg) []uint8 {
+	keys := make([]uint8, 0, len(notes))
+	for _, note := range notes {
+		if !slices.Contains(keys, note.Key) {
+			keys = append(keys, note.Key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,40 @@
+package recorder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep(t *testing.T) {
+	t.Run("Keys are returned once every key is released", func(t *testing.T) {
+		var step Step
+		step.Press(NoteMsg{Key: 60, On: true})
+		step.Press(NoteMsg{Key: 64, On: true})
+
+		_, done := step.Release(NoteMsg{Key: 60})
+		assert.False(t, done)
+
+		notes, done := step.Release(NoteMsg{Key: 64})
+		assert.True(t, done)
+		assert.Equal(t, []uint8{60, 64}, Keys(notes))
+	})
+
+	t.Run("Release of a key that was not pressed", func(t *testing.T) {
+		var step Step
+
+		_, done := step.Release(NoteMsg{Key: 60})
+		assert.False(t, done)
+	})
+
+	t.Run("A key pressed twice is one key", func(t *testing.T) {
+		var step Step
+		step.Press(NoteMsg{Key: 60, On: true})
+		step.Press(NoteMsg{Key: 60, On: true})
+
+		notes, done := step.Release(NoteMsg{Key: 60})
+		assert.True(t, done)
+		assert.Len(t, notes, 2)
+		assert.Equal(t, []uint8{60}, Keys(notes))
+	})
+}
@@ -0,0 +1,188 @@
+package renderer
+
+import (
+	"slices"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+func IsDone(playState playstate.PlayState, currentNode *arrangement.Arrangement, currentSection arrangement.SongSection, cursor *arrangement.ArrCursor) bool {
+	return playState.LoopedArrangement != currentNode &&
+		currentSection.Cycles+currentSection.StartCycles <= (*playState.Iterations)[currentNode] &&
+		!(cursor.AllLastSiblings() && playState.Iterations.IsFull(cursor) && playState.PlayMode == playstate.PlayReceiver && playState.LoopMode != playstate.LoopWholeSequence)
+}
+
+// BeatPatterns returns the patterns to be played on the current beat of the
+// play state, split into CC/PC lines and note lines so that meta messages can
+// be sent ahead of the notes they affect.  Steps gives the step of the beat
+// each note plays on, lines with a clock multiplier play several steps in a
+// beat.
+func BeatPatterns(playState playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor) (grid.Pattern, grid.Pattern, map[grid.GridKey]uint8) {
+	currentNode := cursor[len(cursor)-1]
+	currentSection := cursor[len(cursor)-1].Section
+	var partID int
+	var currentCycles int
+	var currentPart arrangement.Part
+	var playingOverlay *overlays.Overlay
+
+	partID = currentSection.Part
+	currentPart = (*definition.Parts)[partID]
+	currentCycles = (*playState.Iterations)[currentNode]
+	playingOverlay = currentPart.Overlays.HighestMatchingOverlay(currentCycles)
+
+	actionPattern := make(grid.Pattern)
+	playingOverlay.CombineActionPattern(&actionPattern, currentCycles)
+
+	steps := make(map[grid.GridKey]uint8)
+	noteLineStates := make([]playstate.LineState, 0, len(playState.LineStates))
+	metaLineStates := make([]playstate.LineState, 0, len(playState.LineStates))
+	for i := range playState.LineStates {
+		for step, ls := range lineSteps(playState.LineStates, i, definition.Lines[i], actionPattern, currentPart.Beats, playState.BoundedLoop, playState.LoopMode) {
+			steps[ls.GridKey()] = uint8(step)
+			if definition.Lines[i].MsgType == grid.MessageTypeNote {
+				noteLineStates = append(noteLineStates, ls)
+			} else {
+				metaLineStates = append(metaLineStates, ls)
+			}
+		}
+	}
+
+	gridKeys := make([]grid.GridKey, 0, len(playState.LineStates))
+	CurrentBeatGridKeys(&gridKeys, metaLineStates, playState.HasSolo)
+
+	metaPattern := make(grid.Pattern)
+	playingOverlay.CurrentBeatOverlayPattern(&metaPattern, currentCycles, gridKeys)
+
+	gridKeys = make([]grid.GridKey, 0, len(playState.LineStates))
+	CurrentBeatGridKeys(&gridKeys, noteLineStates, playState.HasSolo)
+
+	notePattern := make(grid.Pattern)
+	playingOverlay.CurrentBeatOverlayPattern(&notePattern, currentCycles, gridKeys)
+
+	return metaPattern, notePattern, steps
+}
+
+func AdvancePlayState(playState *playstate.PlayState, definition sequence.Sequence, cursor *arrangement.ArrCursor) {
+	currentNode := (*cursor)[len(*cursor)-1]
+	currentSection := (*cursor)[len(*cursor)-1].Section
+	partID := currentSection.Part
+	currentPart := (*definition.Parts)[partID]
+	currentCycles := (*playState.Iterations)[currentNode]
+	playingOverlay := currentPart.Overlays.HighestMatchingOverlay(currentCycles)
+
+	if playState.Playing {
+		// NOTE: Only advance if we've already played the first beat.
+		if playState.AllowAdvance {
+			actionPattern := make(grid.Pattern)
+			playingOverlay.CombineActionPattern(&actionPattern, currentCycles)
+			advanceCurrentBeat(actionPattern, playState.LineStates, definition.Lines, currentPart.Beats, playState.BoundedLoop, playState.LoopMode)
+			keylineStarts := KeylineStarts(playState.LineStates, definition, actionPattern, currentPart.Beats, playState.BoundedLoop, playState.LoopMode)
+			advanceKeyCycle(keylineStarts, playState.LoopMode, currentNode, playState.Iterations)
+			if IsDone(*playState, currentNode, currentSection, cursor) && playState.LoopMode != playstate.LoopOverlay {
+				if PlayMove(cursor, playState.Iterations, playState.LoopedArrangement) || playState.PlayMode == playstate.PlayReceiver {
+					currentSection = (*cursor)[len(*cursor)-1].Section
+					currentNode = (*cursor)[len(*cursor)-1]
+					if !currentSection.KeepCycles {
+						(*playState.Iterations)[currentNode] = currentSection.StartCycles
+					}
+					playState.LineStates = playstate.InitLineStates(len(definition.Lines), playState.LineStates, uint8((*cursor)[len(*cursor)-1].Section.StartBeat))
+				} else {
+					playState.Playing = false
+					return
+				}
+			}
+		}
+	}
+
+}
+
+func CurrentBeatGridKeys(gridKeys *[]grid.GridKey, lineStates []playstate.LineState, hasSolo bool) {
+	for _, linestate := range lineStates {
+		if linestate.IsSolo() || (!linestate.IsMuted() && !hasSolo) {
+			*gridKeys = append(*gridKeys, linestate.GridKey())
+		}
+	}
+}
+
+func advanceCurrentBeat(pattern grid.Pattern, lineStates []playstate.LineState, lines []grid.LineDefinition, partBeats uint8, boundedLoop playstate.BoundedLoop, loopMode playstate.LoopMode) {
+	for i := range lineStates {
+		line := lines[i]
+		// A divided line waits on its clock before it steps
+		if lineStates[i].ClockCount+1 < line.Division.BeatsPerStep() {
+			lineStates[i].ClockCount++
+			continue
+		}
+		lineStates[i].ClockCount = 0
+		for range line.Division.StepsPerBeat() {
+			doContinue := lineStates[i].AdvancePlayState(pattern, i, line.Steps(partBeats), lineStates, boundedLoop, loopMode)
+			if !doContinue {
+				return
+			}
+		}
+	}
+}
+
+// lineSteps returns the state of a line for every step it plays on the
+// current beat.  A divided line waiting on its clock plays none, a multiplied
+// line plays each of its steps in the beat.
+func lineSteps(lineStates []playstate.LineState, index int, line grid.LineDefinition, pattern grid.Pattern, partBeats uint8, boundedLoop playstate.BoundedLoop, loopMode playstate.LoopMode) []playstate.LineState {
+	if lineStates[index].ClockCount > 0 {
+		return nil
+	}
+	steps := []playstate.LineState{lineStates[index]}
+	if line.Division.StepsPerBeat() > 1 {
+		// The steps after the first are looked ahead to, the line states
+		// advance past them on the next beat
+		ahead := slices.Clone(lineStates)
+		for range line.Division.StepsPerBeat() - 1 {
+			ahead[index].AdvancePlayState(pattern, index, line.Steps(partBeats), ahead, boundedLoop, loopMode)
+			steps = append(steps, ahead[index])
+		}
+	}
+	return steps
+}
+
+// KeylineStarts reports whether the keyline plays its first step on the
+// current beat, which starts a key cycle.
+func KeylineStarts(lineStates []playstate.LineState, definition sequence.Sequence, pattern grid.Pattern, partBeats uint8, boundedLoop playstate.BoundedLoop, loopMode playstate.LoopMode) bool {
+	keyline := int(definition.Keyline)
+	if keyline >= len(lineStates) || keyline >= len(definition.Lines) {
+		return false
+	}
+	steps := lineSteps(lineStates, keyline, definition.Lines[keyline], pattern, partBeats, boundedLoop, loopMode)
+	return slices.ContainsFunc(steps, func(ls playstate.LineState) bool { return ls.CurrentBeat == 0 })
+}
+
+func advanceKeyCycle(keylineStarts bool, loopMode playstate.LoopMode, node *arrangement.Arrangement, iterations *playstate.Iterations) {
+	if keylineStarts && loopMode != playstate.LoopOverlay {
+		(*iterations)[node]++
+	}
+}
+
+func PlayMove(cursor *arrangement.ArrCursor, iterations *playstate.Iterations, loopNode *arrangement.Arrangement) bool {
+	if cursor.IsRoot() {
+		cursor.MoveNext()
+		return false
+	} else if cursor.IsLastSibling() {
+		(*iterations)[cursor.GetParentNode()]++
+		hasParentIterations := (*iterations)[cursor.GetParentNode()] < cursor.GetParentNode().Iterations
+		if hasParentIterations || loopNode == cursor.GetParentNode() {
+			cursor.MoveToFirstSibling()
+			if cursor.GetCurrentNode().IsGroup() {
+				cursor.MoveNext()
+			}
+		} else {
+			iterations.ResetIterations(*cursor)
+			cursor.Up()
+			return PlayMove(cursor, iterations, loopNode)
+		}
+	} else {
+		cursor.MoveToSibling()
+		iterations.ResetIterations(*cursor)
+	}
+	return true
+}
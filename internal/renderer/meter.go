@@ -0,0 +1,45 @@
+package renderer
+
+import (
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// MeterChange is a change of time signature on a tick measured at PPQN.
+type MeterChange struct {
+	Tick  int64
+	Meter arrangement.Meter
+}
+
+// MeterChanges returns every change of time signature when the whole
+// arrangement is played once, from the first section whose part has a meter.
+// A change to a part without a meter is a change to the zero Meter.
+func MeterChanges(definition sequence.Sequence) []MeterChange {
+	var changes []MeterChange
+	if definition.Subdivisions <= 0 || definition.Parts == nil {
+		return changes
+	}
+
+	playState, cursor := initialPlayState(definition)
+	var meter arrangement.Meter
+	var tick int64
+	for beat := int64(0); beat < maxBeats; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			break
+		}
+		section := cursor.GetCurrentNode().Section
+		if section.Part < len(*definition.Parts) {
+			partMeter := (*definition.Parts)[section.Part].Meter
+			if partMeter != meter {
+				changes = append(changes, MeterChange{Tick: tick, Meter: partMeter})
+				meter = partMeter
+			}
+		}
+		_, subdivisions := Tempo(playState, definition, cursor)
+		tick += BeatTicks(subdivisions)
+		playState.AllowAdvance = true
+	}
+
+	return changes
+}
@@ -0,0 +1,366 @@
+// Package renderer computes the messages a sequence sends without playing it.
+// Play state is advanced beat by beat with the same logic used for live
+// playback and every message is placed on a tick at PPQN, so the result
+// depends only on the sequence and never on timers.
+package renderer
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/sequence"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+// NOTE: PPQN is Pulses Per Quarter Note
+// NOTE: 840 is the common multiple of 2, 3, 5, 7, 8. The allowable subdivisions.
+const PPQN = 840
+
+// A sequence that never stops (e.g. an arrangement with no sections
+// that finish) would otherwise render forever.
+const maxBeats = 1 << 20
+
+// Ratchet hits are a fixed length rather than a fraction of the beat.
+const ratchetGateLength = 20 * time.Millisecond
+
+// Event is a message placed on a tick measured at PPQN.  The note offs of
+// millisecond gates are placed a Delay after their tick, so that they last the
+// same at every tempo.
+type Event struct {
+	Tick    int64
+	Message midi.Message
+	Delay   time.Duration
+}
+
+// Render plays the whole arrangement once, from the first section to the
+// last, and returns every message the sequence sends ordered by tick.  The
+// chances of the notes are rolled from seed, so a seed always renders the same
+// events.
+func Render(definition sequence.Sequence, seed int64) ([]Event, error) {
+	if definition.Tempo <= 0 || definition.Subdivisions <= 0 {
+		return nil, fault.New("cannot render sequence without tempo", fmsg.WithDesc("invalid tempo", fmt.Sprintf("Tempo %d and subdivisions %d must be greater than 0", definition.Tempo, definition.Subdivisions)))
+	}
+
+	playState, cursor := initialPlayState(definition)
+	trigs := NewTrigs(seed)
+	events := make([]Event, 0)
+	// Sections with their own subdivisions have longer or shorter beats
+	var tick int64
+	for beat := int64(0); ; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			break
+		}
+		if beat >= maxBeats {
+			return nil, fault.New("sequence does not end", fmsg.WithDesc("sequence does not end", fmt.Sprintf("Rendering stopped after %d beats", maxBeats)))
+		}
+
+		tempo, subdivisions := Tempo(playState, definition, cursor)
+		for _, e := range BeatEvents(playState, definition, cursor, trigs) {
+			events = append(events, Event{Tick: tick + e.Tick + durationTicks(e.Delay, tempo), Message: e.Message})
+		}
+		tick += BeatTicks(subdivisions)
+		playState.AllowAdvance = true
+	}
+
+	Sort(events)
+
+	return events, nil
+}
+
+// SongPosition returns the tick at which node is first played when the whole
+// arrangement is played once.  A node that is never reached is at tick 0.
+func SongPosition(definition sequence.Sequence, node *arrangement.Arrangement) int64 {
+	if definition.Subdivisions <= 0 {
+		return 0
+	}
+
+	playState, cursor := initialPlayState(definition)
+	var tick int64
+	for beat := int64(0); beat < maxBeats; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			break
+		}
+		if cursor.GetCurrentNode() == node {
+			return tick
+		}
+		_, subdivisions := Tempo(playState, definition, cursor)
+		tick += BeatTicks(subdivisions)
+		playState.AllowAdvance = true
+	}
+
+	return 0
+}
+
+// SongBeats returns the number of beats the whole arrangement plays when it
+// is played once.
+func SongBeats(definition sequence.Sequence) int64 {
+	playState, cursor := initialPlayState(definition)
+	beat := int64(0)
+	for ; beat < maxBeats; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			break
+		}
+		playState.AllowAdvance = true
+	}
+
+	return beat
+}
+
+// Seek advances the play state to beat without rendering any messages.  The
+// whole arrangement loops, so beats past the end wrap around to the start.
+// The returned play state plays beat next.
+func Seek(definition sequence.Sequence, beat int64) (playstate.PlayState, arrangement.ArrCursor) {
+	playState, cursor := initialPlayState(definition)
+	playState.LoopMode = playstate.LoopWholeSequence
+	playState.LoopedArrangement = definition.Arrangement
+
+	playState.AllowAdvance = true
+	for range min(beat, maxBeats) {
+		AdvancePlayState(&playState, definition, &cursor)
+	}
+	playState.AllowAdvance = false
+
+	return playState, cursor
+}
+
+func initialPlayState(definition sequence.Sequence) (playstate.PlayState, arrangement.ArrCursor) {
+	iterations := make(playstate.Iterations)
+	playstate.BuildIterationsMap(definition.Arrangement, &iterations)
+	cursor := arrangement.ArrCursor{definition.Arrangement}
+	cursor.MoveNext()
+
+	playState := playstate.PlayState{
+		Playing:    true,
+		LoopMode:   playstate.OneTimeWholeSequence,
+		Iterations: &iterations,
+		LineStates: playstate.InitLineStates(len(definition.Lines), []playstate.LineState{}, uint8(cursor.GetCurrentNode().Section.StartBeat)),
+	}
+
+	return playState, cursor
+}
+
+// Sort orders events by tick.  Events on the same tick keep their order except
+// that note offs go first, so that a note ending on a tick does not cut off
+// the note starting on it, and CC/PC messages go before note ons just as they
+// do during playback.
+func Sort(events []Event) {
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return cmp.Or(cmp.Compare(a.Tick, b.Tick), cmp.Compare(priority(a.Message), priority(b.Message)))
+	})
+}
+
+func priority(message midi.Message) int {
+	switch message.Type() {
+	case midi.NoteOffMsg:
+		return 0
+	case midi.NoteOnMsg:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// BeatTicks returns the number of ticks in a single beat of the grid.
+func BeatTicks(subdivisions int) int64 {
+	return int64(PPQN / subdivisions)
+}
+
+// BeatEvents returns the messages for the current beat of the play state with
+// ticks relative to the start of the beat.  CC/PC messages come first, then
+// notes, and each note on is followed by its note off.  Trigs decide which
+// notes with a chance or a condition play, without trigs every note plays.
+// The ticks are measured at the tempo and subdivisions of the beat.
+func BeatEvents(playState playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor, trigs *Trigs) []Event {
+	metaPattern, notePattern, steps := BeatPatterns(playState, definition, cursor)
+	if trigs != nil {
+		keyCycle := CurrentKeyCycle(playState, cursor)
+		trigs.Filter(metaPattern, keyCycle)
+		trigs.Filter(notePattern, keyCycle)
+	}
+
+	part := (*definition.Parts)[cursor[len(cursor)-1].Section.Part]
+	definition.Tempo, definition.Subdivisions = Tempo(playState, definition, cursor)
+
+	events := make([]Event, 0, len(metaPattern)+len(notePattern)*2)
+	events = appendPatternEvents(events, metaPattern, steps, definition, part)
+	events = appendPatternEvents(events, notePattern, steps, definition, part)
+	return events
+}
+
+func appendPatternEvents(events []Event, pattern grid.Pattern, steps map[grid.GridKey]uint8, definition sequence.Sequence, part arrangement.Part) []Event {
+	lines := definition.Lines
+	accents := definition.Accents
+	beatTicks := float64(PPQN) / float64(definition.Subdivisions)
+
+	for _, gridKey := range slices.SortedFunc(maps.Keys(pattern), grid.Compare) {
+		note := pattern[gridKey]
+		if note.Action != grid.ActionNothing {
+			continue
+		}
+		line := lines[gridKey.Line]
+		// Grooves, waits and ratchets divide the step of the line, which
+		// is longer or shorter than a beat for lines with a clock division
+		stepTicks := beatTicks * float64(line.Division.BeatsPerStep()) / float64(line.Division.StepsPerBeat())
+		stepStart := int64(math.Round(float64(steps[gridKey]) * stepTicks))
+		setting, step := grooveStep(definition, part, gridKey)
+		grooveTicks := stepStart + int64(math.Round(setting.Offset(step)/100*stepTicks))
+		// The locks of a note are sent just before its first note on
+		if line.MsgType == grid.MessageTypeNote {
+			lockTick := grooveTicks
+			if note.Ratchets.Length == 0 {
+				lockTick += waitTicks(note.WaitIndex, stepTicks)
+			}
+			for _, message := range LockMessages(line, note, definition.Instrument) {
+				events = append(events, Event{Tick: lockTick, Message: message})
+			}
+		}
+		if note.Ratchets.Length > 0 {
+			ratchetTicks := stepTicks * float64(note.Ratchets.GetSpan()) / float64(note.Ratchets.Length+1)
+			for i := range note.Ratchets.Length + 1 {
+				if note.Ratchets.HitAt(i) {
+					tick := grooveTicks + int64(math.Round(float64(i)*ratchetTicks))
+					onMessage, offMessage := noteMessages(line, uint8(accents.Data[note.AccentIndex]), accents.Target, setting, step)
+					events = append(events, Event{Tick: tick, Message: onMessage}, Event{Tick: tick, Message: offMessage, Delay: ratchetGateLength})
+				}
+			}
+		} else if note != grid.ZeroNote {
+			tick := grooveTicks + waitTicks(note.WaitIndex, stepTicks)
+
+			switch line.MsgType {
+			case grid.MessageTypeNote:
+				onMessage, offMessage := noteMessages(line, uint8(accents.Data[note.AccentIndex]), accents.Target, setting, step)
+				gate, delay := gateLength(note.GateIndex, stepTicks)
+				events = append(events, Event{Tick: tick, Message: onMessage}, Event{Tick: tick + gate, Message: offMessage, Delay: delay})
+			case grid.MessageTypeCc:
+				events = append(events, Event{Tick: tick, Message: CCMessage(line, note, accents.Data, definition.Instrument)})
+			case grid.MessageTypeProgramChange:
+				events = append(events, Event{Tick: tick, Message: PCMessage(line, note, accents.Data)})
+			}
+		}
+	}
+
+	return events
+}
+
+// CurrentKeyCycle returns the key cycle of the section the cursor plays.
+func CurrentKeyCycle(playState playstate.PlayState, cursor arrangement.ArrCursor) KeyCycle {
+	node := cursor[len(cursor)-1]
+	cycle := (*playState.Iterations)[node]
+	return KeyCycle{Cycle: cycle, First: cycle == node.Section.StartCycles, Fill: playState.Fill}
+}
+
+// grooveStep returns the groove setting of the line of a note and the step of
+// the groove at the beat of the note.  A line without a groove, or with a
+// groove that cannot be found, plays straight.
+func grooveStep(definition sequence.Sequence, part arrangement.Part, gridKey grid.GridKey) (groove.Setting, groove.Step) {
+	setting, ok := part.Grooves.Find(gridKey.Line)
+	if !ok {
+		return groove.Setting{}, groove.Groove{}.Step(gridKey.Beat)
+	}
+	g, ok := definition.FindGroove(setting.Name)
+	if !ok {
+		return groove.Setting{}, groove.Groove{}.Step(gridKey.Beat)
+	}
+	return setting, g.Step(gridKey.Beat)
+}
+
+func noteMessages(l grid.LineDefinition, accentValue uint8, accentTarget sequence.AccentTarget, setting groove.Setting, step groove.Step) (midi.Message, midi.Message) {
+	var noteValue uint8
+	var velocityValue uint8
+
+	switch accentTarget {
+	case sequence.AccentTargetNote:
+		noteValue = l.Note + accentValue
+		velocityValue = 96
+	case sequence.AccentTargetVelocity:
+		noteValue = l.Note
+		velocityValue = accentValue
+	}
+	velocityValue = setting.Velocity(velocityValue, step)
+
+	return midi.NoteOn(l.Channel-1, noteValue, velocityValue), midi.NoteOff(l.Channel-1, noteValue)
+}
+
+func CCMessage(l grid.LineDefinition, note grid.Note, accents []config.Accent, instrument string) midi.Message {
+	if note.Action == grid.ActionSpecificValue {
+		return midi.ControlChange(l.Channel-1, l.Note, note.AccentIndex)
+	} else {
+		cc, _ := config.FindCC(l.Note, instrument)
+		var ccValue uint8
+		if cc.UpperLimit == 1 && note.AccentIndex > 4 {
+			ccValue = 0
+		} else if cc.UpperLimit == 1 {
+			ccValue = 1
+		} else {
+			ccValue = uint8((float32((len(accents))-int(note.AccentIndex)) / float32(len(accents)-1)) * float32(cc.UpperLimit))
+		}
+
+		return midi.ControlChange(l.Channel-1, l.Note, ccValue)
+	}
+}
+
+// LockMessages returns the CC messages of the parameter locks of a note, with
+// the values of the named CCs of the instrument kept within their limits.
+func LockMessages(l grid.LineDefinition, note grid.Note, instrument string) []midi.Message {
+	messages := make([]midi.Message, 0, note.Locks.Count)
+	for _, lock := range note.Locks.All() {
+		value := lock.Value
+		if cc, exists := config.FindCC(lock.Control, instrument); exists {
+			value = min(value, cc.UpperLimit)
+		}
+		messages = append(messages, midi.ControlChange(l.Channel-1, lock.Control, value))
+	}
+	return messages
+}
+
+func PCMessage(l grid.LineDefinition, note grid.Note, accents []config.Accent) midi.Message {
+	if note.Action == grid.ActionSpecificValue {
+		return midi.ProgramChange(l.Channel-1, note.AccentIndex)
+	} else {
+		pcValue := uint8((float32((len(accents))-int(note.AccentIndex)) / float32(len(accents)-1)) * float32(127))
+		return midi.ProgramChange(l.Channel-1, pcValue)
+	}
+}
+
+func waitTicks(waitIndex uint8, beatTicks float64) int64 {
+	return int64(math.Round(float64(config.WaitPercentages[waitIndex]) / 100 * beatTicks))
+}
+
+// gateLength returns the length of a gate in ticks, a part of the step of the
+// line, or for the short gates measured in milliseconds as a delay.
+func gateLength(gateIndex int16, stepTicks float64) (int64, time.Duration) {
+	shortGatesLen := int16(len(config.ShortGates))
+	if gateIndex < shortGatesLen {
+		value := config.ShortGates[gateIndex].Value
+		if value > 1 {
+			return 0, time.Duration(value) * time.Millisecond
+		}
+		return int64(math.Round(float64(value) * stepTicks)), 0
+	} else {
+		return int64(math.Round(float64(config.LongGates[gateIndex-shortGatesLen].Value) * stepTicks)), 0
+	}
+}
+
+func durationTicks(duration time.Duration, tempo int) int64 {
+	return int64(math.Round(float64(duration) * float64(tempo*PPQN) / float64(time.Minute)))
+}
+
+// TickDuration converts a number of ticks to a duration given the length of
+// a beat.
+func TickDuration(ticks int64, beatInterval time.Duration, subdivisions int) time.Duration {
+	return time.Duration(ticks) * beatInterval / time.Duration(BeatTicks(subdivisions))
+}
@@ -0,0 +1,719 @@
+package renderer
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRender(t *testing.T) {
+	beatTicks := int64(PPQN / 2)
+
+	t.Run("single note", func(t *testing.T) {
+		definition := SimpleSequence(4, 1)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 1), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{beatTicks}, NoteOnTicks(events, 60))
+		if assert.Len(t, events, 2) {
+			// 20ms gate at 120bpm
+			assert.Equal(t, beatTicks+34, events[1].Tick)
+			assert.True(t, events[1].Message.Is(midi.NoteOffMsg))
+		}
+	})
+
+	t.Run("a seed rolls chances the same way", func(t *testing.T) {
+		definition := SimpleSequence(4, 8)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5, Probability: 50})
+
+		first, err := Render(definition, 42)
+		assert.NoError(t, err)
+		second, err := Render(definition, 42)
+		assert.NoError(t, err)
+		assert.Equal(t, NoteOnTicks(first, 60), NoteOnTicks(second, 60))
+	})
+
+	t.Run("section cycles", func(t *testing.T) {
+		definition := SimpleSequence(4, 3)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, 4 * beatTicks, 8 * beatTicks}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("overlays resolved per key cycle", func(t *testing.T) {
+		definition := SimpleSequence(4, 2)
+		root := (*definition.Parts)[0].Overlays
+		root.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+		everyOther := overlays.InitOverlay(overlaykey.OverlayPeriodicity{Shift: 2, Interval: 2, Width: 1}, root)
+		everyOther.AddNote(grid.GK(0, 2), grid.Note{AccentIndex: 5})
+		(*definition.Parts)[0].Overlays = everyOther
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, 4 * beatTicks, 6 * beatTicks}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("group iterations", func(t *testing.T) {
+		definition := SimpleSequence(2, 1)
+		section := definition.Arrangement.Nodes[0]
+		group := &arrangement.Arrangement{Iterations: 3, Nodes: []*arrangement.Arrangement{section}}
+		definition.Arrangement.Nodes = []*arrangement.Arrangement{group}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, 2 * beatTicks, 4 * beatTicks}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("ratchets", func(t *testing.T) {
+		definition := SimpleSequence(1, 1)
+		ratchets := grid.Ratchet{Length: 1, Hits: 3}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5, Ratchets: ratchets})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, beatTicks / 2}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("ratchet span and missing hits", func(t *testing.T) {
+		definition := SimpleSequence(1, 1)
+		// 4 hits spread over 2 beats with the second hit off
+		ratchets := grid.Ratchet{Length: 3, Hits: 0b1101, Span: 1}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5, Ratchets: ratchets})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, beatTicks, 3 * beatTicks / 2}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("wait and gate", func(t *testing.T) {
+		definition := SimpleSequence(2, 1)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 1), grid.Note{AccentIndex: 5, WaitIndex: 4, GateIndex: 4})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		// 32% wait and a gate of half a beat
+		assert.Equal(t, []int64{beatTicks + 134}, NoteOnTicks(events, 60))
+		assert.Equal(t, beatTicks+134+beatTicks/2, events[1].Tick)
+	})
+
+	t.Run("groove delays and scales offbeats", func(t *testing.T) {
+		definition := SimpleSequence(2, 1)
+		definition.Accents.Data[5] = 100
+		definition.Grooves = []groove.Groove{{Name: "Test", Steps: []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 50, Velocity: 50}}}}
+		(*definition.Parts)[0].Grooves = groove.Settings{{Name: "Test", Amount: 50}}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 1), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, beatTicks + beatTicks/4}, NoteOnTicks(events, 60))
+		assert.Equal(t, []uint8{100, 75}, Velocities(events, 60))
+	})
+
+	t.Run("default grooves", func(t *testing.T) {
+		definition := SimpleSequence(2, 1)
+		(*definition.Parts)[0].Grooves = groove.Settings{{Name: "Swing 58%", Amount: 100}}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 1), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		// 58% swing delays the offbeat by 16% of a beat
+		assert.Equal(t, []int64{beatTicks + 67}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("groove for other lines", func(t *testing.T) {
+		definition := SimpleSequence(2, 1)
+		(*definition.Parts)[0].Grooves = groove.Settings{{Name: "Swing 58%", Amount: 100, Lines: []uint8{1}}}
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 1), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{beatTicks}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("requires a tempo", func(t *testing.T) {
+		definition := SimpleSequence(4, 1)
+		definition.Tempo = 0
+
+		_, err := Render(definition, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestRenderLineActions(t *testing.T) {
+	beatTicks := int64(PPQN / 2)
+
+	// The velocity of each note is the beat it was played from
+	tests := []struct {
+		name       string
+		action     grid.Action
+		velocities []uint8
+	}{
+		{"line reset", grid.ActionLineReset, []uint8{1, 2, 1, 2, 1, 2, 1, 2}},
+		{"line reverse", grid.ActionLineReverse, []uint8{1, 2, 1, 2, 1, 2, 1, 2}},
+		{"line skip beat", grid.ActionLineSkipBeat, []uint8{1, 2, 4, 1, 2, 4, 1, 2}},
+		{"line bounce", grid.ActionLineBounce, []uint8{1, 2, 2, 1, 1, 2, 2, 1}},
+		{"line delay", grid.ActionLineDelay, []uint8{1, 2, 2, 2, 2, 2, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The keyline keeps time while the action plays out on a second line
+			definition := SimpleSequence(4, 2)
+			definition.Lines = append(definition.Lines, grid.LineDefinition{Channel: 5, Note: 62, MsgType: grid.MessageTypeNote})
+			overlay := (*definition.Parts)[0].Overlays
+			overlay.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+			overlay.AddNote(grid.GK(1, 0), grid.Note{AccentIndex: 1})
+			overlay.AddNote(grid.GK(1, 1), grid.Note{AccentIndex: 2})
+			overlay.AddNote(grid.GK(1, 2), grid.InitActionNote(tt.action))
+			overlay.AddNote(grid.GK(1, 3), grid.Note{AccentIndex: 4})
+
+			events, err := Render(definition, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []int64{0, 4 * beatTicks}, NoteOnTicks(events, 60))
+			assert.Equal(t, tt.velocities, Velocities(events, 62))
+		})
+	}
+}
+
+func TestRenderPolymeter(t *testing.T) {
+	beatTicks := int64(PPQN / 2)
+
+	// The keyline keeps time while the second line has its own clock
+	polymeterSequence := func(line grid.LineDefinition, cycles int) sequence.Sequence {
+		definition := SimpleSequence(8, cycles)
+		line.Channel, line.Note, line.MsgType = 5, 62, grid.MessageTypeNote
+		definition.Lines = append(definition.Lines, line)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+		return definition
+	}
+
+	t.Run("line length", func(t *testing.T) {
+		definition := polymeterSequence(grid.LineDefinition{Length: 3}, 2)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(1, 0), grid.Note{AccentIndex: 1})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, 8 * beatTicks}, NoteOnTicks(events, 60))
+		assert.Equal(t, []int64{0, 3 * beatTicks, 6 * beatTicks, 9 * beatTicks, 12 * beatTicks, 15 * beatTicks}, NoteOnTicks(events, 62))
+	})
+
+	t.Run("divided line", func(t *testing.T) {
+		definition := polymeterSequence(grid.LineDefinition{Division: 2}, 1)
+		overlay := (*definition.Parts)[0].Overlays
+		overlay.AddNote(grid.GK(1, 0), grid.Note{AccentIndex: 1})
+		overlay.AddNote(grid.GK(1, 1), grid.Note{AccentIndex: 2})
+		overlay.AddNote(grid.GK(1, 2), grid.Note{AccentIndex: 3})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, 2 * beatTicks, 4 * beatTicks}, NoteOnTicks(events, 62))
+		assert.Equal(t, []uint8{1, 2, 3}, Velocities(events, 62))
+	})
+
+	t.Run("multiplied line", func(t *testing.T) {
+		definition := polymeterSequence(grid.LineDefinition{Division: -2}, 1)
+		overlay := (*definition.Parts)[0].Overlays
+		overlay.AddNote(grid.GK(1, 0), grid.Note{AccentIndex: 1})
+		overlay.AddNote(grid.GK(1, 1), grid.Note{AccentIndex: 2})
+		overlay.AddNote(grid.GK(1, 3), grid.Note{AccentIndex: 4})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		// The 8 steps of the line play twice in the 8 beats of the part
+		assert.Equal(t, []int64{0, beatTicks / 2, 3 * beatTicks / 2, 4 * beatTicks, 9 * beatTicks / 2, 11 * beatTicks / 2}, NoteOnTicks(events, 62))
+	})
+
+	t.Run("multiplied line gates last a part of the step", func(t *testing.T) {
+		definition := polymeterSequence(grid.LineDefinition{Division: -4}, 1)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(1, 1), grid.Note{AccentIndex: 1, GateIndex: 4})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		ons, offs := NoteOnTicks(events, 62), NoteOffTicks(events, 62)
+		if assert.Len(t, offs, len(ons)) && assert.NotEmpty(t, ons) {
+			assert.Equal(t, beatTicks/4, ons[0])
+			// Half of a step that is a quarter of a beat
+			assert.Equal(t, int64(math.Round(float64(beatTicks)/8)), offs[0]-ons[0])
+		}
+	})
+
+	t.Run("divided keyline lengthens key cycles", func(t *testing.T) {
+		definition := SimpleSequence(4, 2)
+		definition.Lines[0].Division = 2
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{0, 8 * beatTicks}, NoteOnTicks(events, 60))
+	})
+}
+
+func TestTempo(t *testing.T) {
+	beatTicks := int64(PPQN / 2)
+
+	t.Run("section subdivisions", func(t *testing.T) {
+		definition := SimpleSequence(4, 1)
+		definition.Arrangement.Nodes[0].Section.Subdivisions = 4
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 2), grid.Note{AccentIndex: 5})
+
+		events, err := Render(definition, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{beatTicks}, NoteOnTicks(events, 60))
+	})
+
+	t.Run("tempo changes of a ramp", func(t *testing.T) {
+		definition := SimpleSequence(2, 2)
+		definition.Arrangement.Nodes[0].Section.Ramp = arrangement.Ramp{Tempo: 160}
+
+		changes := TempoChanges(definition)
+		assert.Equal(t, []TempoChange{
+			{Tick: 0, Tempo: 120},
+			{Tick: beatTicks, Tempo: 130},
+			{Tick: 2 * beatTicks, Tempo: 140},
+			{Tick: 3 * beatTicks, Tempo: 150},
+		}, changes)
+	})
+
+	t.Run("song duration follows the section tempo", func(t *testing.T) {
+		definition := SimpleSequence(4, 1)
+		assert.Equal(t, time.Second, SongDuration(definition))
+		definition.Arrangement.Nodes[0].Section.Tempo = 60
+		assert.Equal(t, 2*time.Second, SongDuration(definition))
+	})
+
+	t.Run("tick beat counts section subdivisions", func(t *testing.T) {
+		definition := SimpleSequence(4, 1)
+		definition.Arrangement.Nodes[0].Section.Subdivisions = 4
+		assert.Equal(t, int64(2), TickBeat(definition, beatTicks))
+		assert.Equal(t, int64(5), TickBeat(definition, 3*beatTicks))
+	})
+
+	t.Run("next beat tick of a position between beats", func(t *testing.T) {
+		definition := SimpleSequence(4, 1)
+		definition.Arrangement.Nodes[0].Section.Subdivisions = 4
+		assert.Equal(t, beatTicks, NextBeatTick(definition, beatTicks-1))
+		assert.Equal(t, 4*beatTicks, NextBeatTick(definition, 3*beatTicks+1))
+	})
+}
+
+func TestRenderSong(t *testing.T) {
+	events, err := Render(SongSequence(), 0)
+	assert.NoError(t, err)
+
+	var builder strings.Builder
+	for _, e := range events {
+		fmt.Fprintf(&builder, "%6d %s\n", e.Tick, e.Message)
+	}
+
+	golden := filepath.Join("testdata", "song.golden")
+	if *update {
+		assert.NoError(t, os.WriteFile(golden, []byte(builder.String()), 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), builder.String())
+}
+
+func TestSongPosition(t *testing.T) {
+	beatTicks := int64(PPQN / 2)
+	definition := SongSequence()
+	group := definition.Arrangement.Nodes[0]
+
+	assert.Equal(t, int64(0), SongPosition(definition, group.Nodes[0]))
+	// The verse plays 2 cycles of 8 beats before the chorus
+	assert.Equal(t, 16*beatTicks, SongPosition(definition, group.Nodes[1]))
+	assert.Equal(t, int64(0), SongPosition(definition, &arrangement.Arrangement{}))
+}
+
+func TestSongBeats(t *testing.T) {
+	assert.Equal(t, int64(16), SongBeats(SimpleSequence(8, 2)))
+}
+
+func TestSeek(t *testing.T) {
+	definition := SongSequence()
+	group := definition.Arrangement.Nodes[0]
+
+	t.Run("seek within the first part", func(t *testing.T) {
+		playState, cursor := Seek(definition, 3)
+		assert.Equal(t, group.Nodes[0], cursor[len(cursor)-1])
+		assert.Equal(t, uint8(3), playState.LineStates[0].CurrentBeat)
+		assert.False(t, playState.AllowAdvance)
+	})
+
+	t.Run("seek to the second part", func(t *testing.T) {
+		_, cursor := Seek(definition, 16)
+		assert.Equal(t, group.Nodes[1], cursor[len(cursor)-1])
+	})
+}
+
+func TestBeatEvents(t *testing.T) {
+	t.Run("meta messages before notes", func(t *testing.T) {
+		definition := SimpleSequence(1, 1)
+		definition.Lines = append(definition.Lines, grid.LineDefinition{Channel: 5, Note: 7, MsgType: grid.MessageTypeCc})
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5})
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(1, 0), grid.Note{AccentIndex: 5})
+
+		iterations := make(playstate.Iterations)
+		playstate.BuildIterationsMap(definition.Arrangement, &iterations)
+		cursor := arrangement.ArrCursor{definition.Arrangement, definition.Arrangement.Nodes[0]}
+		playState := playstate.PlayState{
+			Playing:    true,
+			Iterations: &iterations,
+			LineStates: playstate.InitLineStates(len(definition.Lines), []playstate.LineState{}, 0),
+		}
+
+		events := BeatEvents(playState, definition, cursor, nil)
+		if assert.Len(t, events, 3) {
+			assert.True(t, events[0].Message.Is(midi.ControlChangeMsg))
+			assert.True(t, events[1].Message.Is(midi.NoteOnMsg))
+			assert.True(t, events[2].Message.Is(midi.NoteOffMsg))
+		}
+	})
+
+	t.Run("locks before the note on", func(t *testing.T) {
+		definition := SimpleSequence(1, 1)
+		locks, _ := grid.Locks{}.Set(74, 90)
+		locks, _ = locks.Set(7, 200)
+		(*definition.Parts)[0].Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 5, WaitIndex: 2, Locks: locks})
+
+		iterations := make(playstate.Iterations)
+		playstate.BuildIterationsMap(definition.Arrangement, &iterations)
+		cursor := arrangement.ArrCursor{definition.Arrangement, definition.Arrangement.Nodes[0]}
+		playState := playstate.PlayState{
+			Playing:    true,
+			Iterations: &iterations,
+			LineStates: playstate.InitLineStates(len(definition.Lines), []playstate.LineState{}, 0),
+		}
+
+		events := BeatEvents(playState, definition, cursor, nil)
+		if assert.Len(t, events, 4) {
+			line := definition.Lines[0]
+			assert.Equal(t, midi.ControlChange(line.Channel-1, 74, 90), events[0].Message)
+			// Channel Volume of the standard CCs goes up to 127
+			assert.Equal(t, midi.ControlChange(line.Channel-1, 7, 127), events[1].Message)
+			assert.True(t, events[2].Message.Is(midi.NoteOnMsg))
+			assert.Equal(t, events[2].Tick, events[0].Tick)
+			assert.Equal(t, events[2].Tick, events[1].Tick)
+		}
+	})
+}
+
+func TestTrigs(t *testing.T) {
+	playsIn := func(trigs *Trigs, note grid.Note, keyCycles []KeyCycle) []bool {
+		var plays []bool
+		for _, keyCycle := range keyCycles {
+			plays = append(plays, trigs.Plays(grid.GK(0, 0), note, keyCycle))
+		}
+		return plays
+	}
+	cycles := []KeyCycle{{Cycle: 1, First: true}, {Cycle: 2}, {Cycle: 3}, {Cycle: 4}}
+
+	t.Run("same seed plays the same notes", func(t *testing.T) {
+		note := grid.Note{AccentIndex: 1, Probability: 50}
+		keyCycles := slices.Repeat([]KeyCycle{{Cycle: 1}}, 32)
+		first := playsIn(NewTrigs(7), note, keyCycles)
+		assert.Equal(t, first, playsIn(NewTrigs(7), note, keyCycles))
+		assert.Contains(t, first, true)
+		assert.Contains(t, first, false)
+	})
+
+	t.Run("cycle condition", func(t *testing.T) {
+		note := grid.Note{AccentIndex: 1, Condition: grid.CycleCondition(2, 2)}
+		assert.Equal(t, []bool{false, true, false, true}, playsIn(NewTrigs(0), note, cycles))
+	})
+
+	t.Run("fill and first", func(t *testing.T) {
+		fill := grid.Note{AccentIndex: 1, Condition: grid.ConditionFill}
+		assert.Equal(t, []bool{false, true}, playsIn(NewTrigs(0), fill, []KeyCycle{{Cycle: 1}, {Cycle: 1, Fill: true}}))
+		first := grid.Note{AccentIndex: 1, Condition: grid.ConditionFirst}
+		assert.Equal(t, []bool{true, false, false, false}, playsIn(NewTrigs(0), first, cycles))
+	})
+
+	t.Run("previous follows the last conditional note of the line", func(t *testing.T) {
+		trigs := NewTrigs(0)
+		pattern := grid.Pattern{
+			grid.GK(0, 0): {AccentIndex: 1, Condition: grid.CycleCondition(1, 2)},
+			grid.GK(0, 1): {AccentIndex: 1, Condition: grid.ConditionPrevious},
+			grid.GK(0, 2): {AccentIndex: 1, Condition: grid.ConditionNotPrevious},
+		}
+		for _, keyCycle := range cycles[:2] {
+			beat := maps.Clone(pattern)
+			trigs.Filter(beat, keyCycle)
+			if keyCycle.Cycle == 1 {
+				assert.Equal(t, []grid.GridKey{grid.GK(0, 0), grid.GK(0, 1)}, slices.SortedFunc(maps.Keys(beat), grid.Compare))
+			} else {
+				assert.Equal(t, []grid.GridKey{grid.GK(0, 2)}, slices.SortedFunc(maps.Keys(beat), grid.Compare))
+			}
+		}
+	})
+
+	t.Run("actions always play", func(t *testing.T) {
+		note := grid.Note{Action: grid.ActionLineReset, Condition: grid.ConditionFill}
+		assert.Equal(t, []bool{true}, playsIn(NewTrigs(0), note, cycles[:1]))
+	})
+}
+
+func TestSort(t *testing.T) {
+	events := []Event{
+		{Tick: 10, Message: midi.NoteOn(0, 60, 100)},
+		{Tick: 10, Message: midi.ControlChange(0, 7, 100)},
+		{Tick: 10, Message: midi.NoteOff(0, 60)},
+		{Tick: 0, Message: midi.NoteOn(0, 60, 100)},
+	}
+
+	Sort(events)
+
+	assert.Equal(t, []Event{
+		{Tick: 0, Message: midi.NoteOn(0, 60, 100)},
+		{Tick: 10, Message: midi.NoteOff(0, 60)},
+		{Tick: 10, Message: midi.ControlChange(0, 7, 100)},
+		{Tick: 10, Message: midi.NoteOn(0, 60, 100)},
+	}, events)
+}
+
+func TestTickDuration(t *testing.T) {
+	assert.Equal(t, 125*time.Millisecond, TickDuration(210, 250*time.Millisecond, 2))
+	assert.Equal(t, time.Duration(0), TickDuration(0, 250*time.Millisecond, 2))
+}
+
+func TestCCMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          grid.LineDefinition
+		note          grid.Note
+		accents       []config.Accent
+		instrument    string
+		expectedChan  uint8
+		expectedCtrl  uint8
+		expectedValue uint8
+	}{
+		{
+			name:          "Specific value action",
+			line:          grid.LineDefinition{Channel: 1, Note: 10},
+			note:          grid.Note{Action: grid.ActionSpecificValue, AccentIndex: 42},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			instrument:    "",
+			expectedChan:  0,
+			expectedCtrl:  10,
+			expectedValue: 42,
+		},
+		{
+			name:          "Accent-based value mid-range",
+			line:          grid.LineDefinition{Channel: 3, Note: 7},
+			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 2},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			instrument:    "",
+			expectedChan:  2,
+			expectedCtrl:  7,
+			expectedValue: 95, // (5-2)/4 * 127 = 95.25 -> 95
+		},
+		{
+			name:          "Accent-based value at end",
+			line:          grid.LineDefinition{Channel: 4, Note: 11},
+			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 4},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			instrument:    "",
+			expectedChan:  3,
+			expectedCtrl:  11,
+			expectedValue: 31, // (5-4)/4 * 127 = 31.75 -> 31
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := CCMessage(tt.line, tt.note, tt.accents, tt.instrument)
+
+			var channel, control, value uint8
+			assert.True(t, msg.GetControlChange(&channel, &control, &value), "MIDI message should be control change")
+			assert.Equal(t, tt.expectedChan, channel, "MIDI channel")
+			assert.Equal(t, tt.expectedCtrl, control, "MIDI control")
+			assert.Equal(t, tt.expectedValue, value, "MIDI value")
+		})
+	}
+}
+
+func TestPCMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          grid.LineDefinition
+		note          grid.Note
+		accents       []config.Accent
+		expectedChan  uint8
+		expectedValue uint8
+	}{
+		{
+			name:          "Specific value action",
+			line:          grid.LineDefinition{Channel: 1, Note: 10},
+			note:          grid.Note{Action: grid.ActionSpecificValue, AccentIndex: 64},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			expectedChan:  0,
+			expectedValue: 64,
+		},
+		{
+			name:          "Accent-based value mid-range",
+			line:          grid.LineDefinition{Channel: 3, Note: 30},
+			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 2},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			expectedChan:  2,
+			expectedValue: 95, // (5-2)/4 * 127 = 95.25 -> 95
+		},
+		{
+			name:          "Accent-based value at end",
+			line:          grid.LineDefinition{Channel: 4, Note: 40},
+			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 4},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			expectedChan:  3,
+			expectedValue: 31, // (5-4)/4 * 127 = 31.75 -> 31
+		},
+		{
+			name:          "Highest accent",
+			line:          grid.LineDefinition{Channel: 5, Note: 50},
+			note:          grid.Note{Action: grid.ActionNothing, AccentIndex: 1},
+			accents:       []config.Accent{0, 30, 60, 90, 120},
+			expectedChan:  4,
+			expectedValue: 127, // (5-1)/4 * 127 = 127 exactly
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := PCMessage(tt.line, tt.note, tt.accents)
+
+			var channel, value uint8
+			assert.True(t, msg.GetProgramChange(&channel, &value), "MIDI message should be program change")
+			assert.Equal(t, tt.expectedChan, channel, "MIDI channel")
+			assert.Equal(t, tt.expectedValue, value, "MIDI value")
+		})
+	}
+}
+
+func NoteOnTicks(events []Event, key uint8) []int64 {
+	ticks := make([]int64, 0, len(events))
+	for _, e := range events {
+		var channel, note, velocity uint8
+		if e.Message.GetNoteOn(&channel, &note, &velocity) && note == key {
+			ticks = append(ticks, e.Tick)
+		}
+	}
+	return ticks
+}
+
+func NoteOffTicks(events []Event, key uint8) []int64 {
+	ticks := make([]int64, 0, len(events))
+	for _, e := range events {
+		var channel, note, velocity uint8
+		if e.Message.GetNoteOff(&channel, &note, &velocity) && note == key {
+			ticks = append(ticks, e.Tick)
+		}
+	}
+	return ticks
+}
+
+func Velocities(events []Event, key uint8) []uint8 {
+	velocities := make([]uint8, 0, len(events))
+	for _, e := range events {
+		var channel, note, velocity uint8
+		if e.Message.GetNoteOn(&channel, &note, &velocity) && note == key {
+			velocities = append(velocities, velocity)
+		}
+	}
+	return velocities
+}
+
+func SimpleSequence(beats uint8, cycles int) sequence.Sequence {
+	parts := sequence.InitParts()
+	parts[0].Beats = beats
+
+	section := &arrangement.Arrangement{
+		Section:    arrangement.SongSection{Part: 0, Cycles: cycles, StartBeat: 0, StartCycles: 1},
+		Iterations: 1,
+	}
+
+	root := &arrangement.Arrangement{
+		Iterations: 1,
+		Nodes:      []*arrangement.Arrangement{section},
+	}
+
+	return sequence.Sequence{
+		Arrangement:  root,
+		Parts:        &parts,
+		Tempo:        120,
+		Subdivisions: 2,
+		Keyline:      0,
+		Lines:        []grid.LineDefinition{{Channel: 5, Note: 60, MsgType: grid.MessageTypeNote, Name: "Line 1"}},
+		Accents: sequence.PatternAccents{
+			Start:  0,
+			End:    8,
+			Data:   []config.Accent{0, 1, 2, 3, 4, 5, 6, 7},
+			Target: sequence.AccentTargetVelocity,
+		},
+	}
+}
+
+// SongSequence is a two part song played through a repeating group that
+// exercises overlays, line actions, ratchets, waits and CC lines together.
+func SongSequence() sequence.Sequence {
+	definition := SimpleSequence(8, 2)
+	definition.Lines = append(definition.Lines,
+		grid.LineDefinition{Channel: 5, Note: 62, MsgType: grid.MessageTypeNote, Name: "Line 2"},
+		grid.LineDefinition{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote, Name: "Line 3"},
+		grid.LineDefinition{Channel: 10, Note: 7, MsgType: grid.MessageTypeCc, Name: "Line 4"},
+	)
+
+	verse := (*definition.Parts)[0].Overlays
+	verse.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 1})
+	verse.AddNote(grid.GK(0, 4), grid.Note{AccentIndex: 3, GateIndex: 4})
+	verse.AddNote(grid.GK(1, 1), grid.Note{AccentIndex: 2, WaitIndex: 3})
+	verse.AddNote(grid.GK(1, 2), grid.Note{AccentIndex: 4})
+	verse.AddNote(grid.GK(1, 5), grid.InitActionNote(grid.ActionLineBounce))
+	verse.AddNote(grid.GK(2, 3), grid.Note{AccentIndex: 1, Ratchets: grid.Ratchet{Length: 2, Hits: 0b101}})
+	verse.AddNote(grid.GK(2, 6), grid.InitActionNote(grid.ActionLineReset))
+	verse.AddNote(grid.GK(3, 0), grid.Note{AccentIndex: 2})
+	fills := overlays.InitOverlay(overlaykey.OverlayPeriodicity{Shift: 2, Interval: 2, Width: 1}, verse)
+	fills.AddNote(grid.GK(2, 7), grid.Note{AccentIndex: 1})
+	fills.AddNote(grid.GK(1, 7), grid.InitActionNote(grid.ActionLineSkipBeat))
+	(*definition.Parts)[0].Overlays = fills
+
+	chorus := arrangement.InitPart("Chorus")
+	chorus.Beats = 4
+	chorus.Overlays.AddNote(grid.GK(0, 0), grid.Note{AccentIndex: 1})
+	chorus.Overlays.AddNote(grid.GK(1, 1), grid.InitActionNote(grid.ActionLineDelay))
+	chorus.Overlays.AddNote(grid.GK(1, 2), grid.Note{AccentIndex: 2})
+	chorus.Overlays.AddNote(grid.GK(2, 3), grid.Note{AccentIndex: 1, GateIndex: 2})
+	*definition.Parts = append(*definition.Parts, chorus)
+
+	verseSection := definition.Arrangement.Nodes[0]
+	chorusSection := &arrangement.Arrangement{
+		Section:    arrangement.SongSection{Part: 1, Cycles: 1, StartBeat: 0, StartCycles: 1},
+		Iterations: 1,
+	}
+	group := &arrangement.Arrangement{Iterations: 2, Nodes: []*arrangement.Arrangement{verseSection, chorusSection}}
+	definition.Arrangement.Nodes = []*arrangement.Arrangement{group}
+
+	return definition
+}
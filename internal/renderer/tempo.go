@@ -0,0 +1,131 @@
+package renderer
+
+import (
+	"time"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// TempoChange is a change of tempo on a tick measured at PPQN.
+type TempoChange struct {
+	Tick  int64
+	Tempo int
+}
+
+// Tempo returns the tempo and subdivisions of the beat the play state is on,
+// those of the section the cursor plays or else those of the sequence.  A
+// section ramps its tempo over the cycles of its keyline, beat by beat.
+func Tempo(playState playstate.PlayState, definition sequence.Sequence, cursor arrangement.ArrCursor) (int, int) {
+	node := cursor[len(cursor)-1]
+	section := node.Section
+	subdivisions := section.SubdivisionsOr(definition.Subdivisions)
+	if section.Ramp.Tempo == 0 {
+		return section.TempoAt(definition.Tempo, 0), subdivisions
+	}
+
+	cycles := 0.0
+	if playState.Iterations != nil {
+		cycles = float64((*playState.Iterations)[node] - section.StartCycles)
+	}
+	keyline := int(definition.Keyline)
+	if definition.Parts != nil && section.Part < len(*definition.Parts) && keyline < len(playState.LineStates) && keyline < len(definition.Lines) {
+		line := definition.Lines[keyline]
+		steps := line.Steps((*definition.Parts)[section.Part].Beats)
+		if steps > 0 {
+			cycles += float64(playState.LineStates[keyline].CurrentBeat) / float64(steps)
+		}
+	}
+	return section.TempoAt(definition.Tempo, cycles), subdivisions
+}
+
+// BeatInterval returns how long a beat lasts at a tempo and subdivisions.
+func BeatInterval(tempo int, subdivisions int) time.Duration {
+	return time.Minute / time.Duration(tempo*subdivisions)
+}
+
+// TempoChanges returns the tempo of the first beat and every change of tempo
+// after it when the whole arrangement is played once.
+func TempoChanges(definition sequence.Sequence) []TempoChange {
+	var changes []TempoChange
+	if definition.Tempo <= 0 || definition.Subdivisions <= 0 {
+		return changes
+	}
+
+	playState, cursor := initialPlayState(definition)
+	var tick int64
+	for beat := int64(0); beat < maxBeats; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			break
+		}
+		tempo, subdivisions := Tempo(playState, definition, cursor)
+		if len(changes) == 0 || changes[len(changes)-1].Tempo != tempo {
+			changes = append(changes, TempoChange{Tick: tick, Tempo: tempo})
+		}
+		tick += BeatTicks(subdivisions)
+		playState.AllowAdvance = true
+	}
+
+	return changes
+}
+
+// SongDuration returns how long the whole arrangement plays when it is played
+// once, at the tempo of each of its beats.
+func SongDuration(definition sequence.Sequence) time.Duration {
+	var duration time.Duration
+	if definition.Tempo <= 0 || definition.Subdivisions <= 0 {
+		return duration
+	}
+
+	playState, cursor := initialPlayState(definition)
+	for beat := int64(0); beat < maxBeats; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			break
+		}
+		duration += BeatInterval(Tempo(playState, definition, cursor))
+		playState.AllowAdvance = true
+	}
+
+	return duration
+}
+
+// TickBeat returns the first beat at or after tick when the whole arrangement
+// is played once.  Ticks past the end count beats at the subdivisions of the
+// sequence.
+func TickBeat(definition sequence.Sequence, tick int64) int64 {
+	beat, _ := seekTick(definition, tick)
+	return beat
+}
+
+// NextBeatTick returns the tick of the beat TickBeat finds, which is where a
+// song position that falls between beats picks up.
+func NextBeatTick(definition sequence.Sequence, tick int64) int64 {
+	_, beatTick := seekTick(definition, tick)
+	return beatTick
+}
+
+func seekTick(definition sequence.Sequence, tick int64) (int64, int64) {
+	if definition.Subdivisions <= 0 {
+		return 0, 0
+	}
+
+	playState, cursor := initialPlayState(definition)
+	var beatTick int64
+	beat := int64(0)
+	for ; beat < maxBeats && beatTick < tick; beat++ {
+		AdvancePlayState(&playState, definition, &cursor)
+		if !playState.Playing {
+			beatTicks := BeatTicks(definition.Subdivisions)
+			remaining := (tick - beatTick + beatTicks - 1) / beatTicks
+			return beat + remaining, beatTick + remaining*beatTicks
+		}
+		_, subdivisions := Tempo(playState, definition, cursor)
+		beatTick += BeatTicks(subdivisions)
+		playState.AllowAdvance = true
+	}
+
+	return beat, beatTick
+}
@@ -0,0 +1,62 @@
+package renderer
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+
+	"github.com/chriserin/sq/internal/grid"
+)
+
+// Trigs decides whether the notes with a chance or a condition play.  The
+// chances are rolled by a seeded random number generator, so that the same
+// seed plays a sequence the same way again.
+type Trigs struct {
+	rng *rand.Rand
+	// Whether the last note with a chance or a condition of each line
+	// played, the previous conditions follow it
+	previous map[uint8]bool
+}
+
+func NewTrigs(seed int64) *Trigs {
+	return &Trigs{rng: rand.New(rand.NewSource(seed)), previous: make(map[uint8]bool)}
+}
+
+// KeyCycle is the key cycle that a beat is played in.
+type KeyCycle struct {
+	// Cycles count from 1, as overlay keys do
+	Cycle int
+	First bool
+	Fill  bool
+}
+
+// Filter removes the notes of the pattern that do not play in the key cycle.
+// Notes are decided in the order they are played, so that the chances are
+// rolled in the same order every time.
+func (t *Trigs) Filter(pattern grid.Pattern, keyCycle KeyCycle) {
+	for _, gridKey := range slices.SortedFunc(maps.Keys(pattern), grid.Compare) {
+		if !t.Plays(gridKey, pattern[gridKey], keyCycle) {
+			delete(pattern, gridKey)
+		}
+	}
+}
+
+// Plays reports whether a note plays in the key cycle.
+func (t *Trigs) Plays(gridKey grid.GridKey, note grid.Note, keyCycle KeyCycle) bool {
+	if note.Action != grid.ActionNothing {
+		return true
+	}
+
+	plays := note.Condition.Holds(keyCycle.Cycle, keyCycle.First, keyCycle.Fill, t.previous[gridKey.Line])
+	// A note that cannot play leaves the chance unrolled
+	if plays && note.Chance() < 100 {
+		plays = t.rng.Intn(100) < int(note.Chance())
+	}
+	// Notes that follow the previous note leave it for the next to follow
+	switch {
+	case note.Condition == grid.ConditionPrevious || note.Condition == grid.ConditionNotPrevious:
+	case note.Condition != grid.ConditionNone || note.Chance() < 100:
+		t.previous[gridKey.Line] = plays
+	}
+	return plays
+}
@@ -20,11 +20,15 @@ type OutDeviceInfo struct {
 }
 
 type InDeviceInfo struct {
-	IsOpen        bool
-	IsTransmitter bool
-	In            drivers.In
-	Name          string
-	Type          string
+	IsOpen         bool
+	IsTransmitter  bool
+	IsClockSource  bool
+	IsRecordSource bool
+	// The controller that commands and parameters are bound to
+	IsControlSource bool
+	In              drivers.In
+	Name            string
+	Type            string
 }
 
 func (di OutDeviceInfo) Matches(name string) bool {
@@ -68,7 +72,7 @@ func (mc *MidiConnection) ListenToTransmitter(recFunc ReceiverFunc) error {
 					mc.StopFn()
 					mc.StopFn = nil
 				}
-				stopFn, err := device.In.Listen(recFunc, drivers.ListenConfig{TimeCode: true, ActiveSense: true})
+				stopFn, err := device.In.Listen(recFunc, drivers.ListenConfig{TimeCode: true, ActiveSense: true, SysEx: true})
 				if err != nil {
 					return fault.Wrap(err, fmsg.With("cannot listen to transmitter"))
 				}
@@ -79,6 +83,143 @@ func (mc *MidiConnection) ListenToTransmitter(recFunc ReceiverFunc) error {
 	return nil
 }
 
+// ListenToClock listens to the device chosen as the midi clock source.
+func (mc *MidiConnection) ListenToClock(recFunc ReceiverFunc) error {
+	mc.ClockReceiverFunc = recFunc
+	for _, device := range mc.inDevices {
+		if device.IsClockSource && device.In != nil && device.In.IsOpen() {
+			mc.StopReceivingFromClock()
+			stopFn, err := device.In.Listen(recFunc, drivers.ListenConfig{TimeCode: true})
+			if err != nil {
+				return fault.Wrap(err, fmsg.With("cannot listen to clock source"))
+			}
+			mc.ClockStopFn = stopFn
+		}
+	}
+	return nil
+}
+
+// recordSource returns the input notes are recorded from, the input matching
+// the name sq was given or else the first input that is not another sq.
+func (mc *MidiConnection) recordSource() *InDeviceInfo {
+	for _, device := range mc.inDevices {
+		if device.IsTransmitter || device.IsClockSource || device.IsControlSource || device.Matches(ClockName) {
+			continue
+		}
+		if mc.recordInName == "" || device.Matches(mc.recordInName) {
+			return device
+		}
+	}
+	return nil
+}
+
+// ListenToRecordSource passes the notes played on the record source to the
+// RecordReceiverFunc until StopRecording is called.
+func (mc *MidiConnection) ListenToRecordSource() error {
+	if mc.Test {
+		mc.recording = true
+		return nil
+	}
+	device := mc.recordSource()
+	if device == nil {
+		return fault.New("cannot find record source", fmsg.WithDesc("cannot find record source", "No MIDI input was found to record from. Connect a keyboard or choose an input with --recordin"))
+	}
+	device.Open()
+	if !device.IsOpen {
+		return fault.New("cannot open record source", fmsg.WithDesc("cannot open record source", fmt.Sprintf("Could not open MIDI input %s", device.Name)))
+	}
+	if mc.RecordStopFn != nil {
+		mc.RecordStopFn()
+		mc.RecordStopFn = nil
+	}
+	stopFn, err := device.In.Listen(func(msg []byte, milliseconds int32) {
+		if mc.RecordReceiverFunc != nil {
+			mc.RecordReceiverFunc(msg, milliseconds)
+		}
+	}, drivers.ListenConfig{})
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot listen to record source"))
+	}
+	device.IsRecordSource = true
+	mc.RecordStopFn = stopFn
+	mc.recording = true
+	return nil
+}
+
+// listenToRecordSourceAgain listens again when the record source was reopened
+// during a recording.  An unplugged record source is waited for.
+func (mc *MidiConnection) listenToRecordSourceAgain() error {
+	if mc.recording && mc.RecordStopFn == nil && mc.recordSource() != nil {
+		err := mc.ListenToRecordSource()
+		if err != nil {
+			return fault.Wrap(err, fmsg.With("cannot listen to record source"))
+		}
+	}
+	return nil
+}
+
+// markClockSource opens the device when it matches the name of the clock
+// source sq was asked to follow.
+func (mc *MidiConnection) markClockSource(device *InDeviceInfo) {
+	if mc.clockInName != "" && device.Matches(mc.clockInName) && !device.IsTransmitter {
+		device.IsClockSource = true
+		device.Open()
+	}
+}
+
+// listenToNewClockSource starts listening when the clock source appears after
+// sq has started.
+func (mc *MidiConnection) listenToNewClockSource() error {
+	if mc.ClockReceiverFunc != nil && mc.ClockStopFn == nil && !mc.DoNotListen {
+		err := mc.ListenToClock(mc.ClockReceiverFunc)
+		if err != nil {
+			return fault.Wrap(err, fmsg.With("cannot listen to clock source"))
+		}
+	}
+	return nil
+}
+
+// markControlSource opens the device when it matches the name of the
+// controller sq was given.
+func (mc *MidiConnection) markControlSource(device *InDeviceInfo) {
+	if mc.controlInName != "" && device.Matches(mc.controlInName) && !device.IsTransmitter {
+		device.IsControlSource = true
+		device.Open()
+	}
+}
+
+// ListenToControlSource passes the notes and control changes of the
+// controller to the ControlReceiverFunc.
+func (mc *MidiConnection) ListenToControlSource() error {
+	for _, device := range mc.inDevices {
+		if device.IsControlSource && device.In != nil && device.In.IsOpen() {
+			mc.StopReceivingFromControl()
+			stopFn, err := device.In.Listen(func(msg []byte, milliseconds int32) {
+				if mc.ControlReceiverFunc != nil {
+					mc.ControlReceiverFunc(msg, milliseconds)
+				}
+			}, drivers.ListenConfig{})
+			if err != nil {
+				return fault.Wrap(err, fmsg.With("cannot listen to control source"))
+			}
+			mc.ControlStopFn = stopFn
+		}
+	}
+	return nil
+}
+
+// listenToNewControlSource starts listening when the controller appears
+// after sq has started.
+func (mc *MidiConnection) listenToNewControlSource() error {
+	if mc.ControlReceiverFunc != nil && mc.ControlStopFn == nil && !mc.DoNotListen {
+		err := mc.ListenToControlSource()
+		if err != nil {
+			return fault.Wrap(err, fmsg.With("cannot listen to control source"))
+		}
+	}
+	return nil
+}
+
 func (mc *MidiConnection) UpdateOutDeviceList(driver drivers.Driver) error {
 	var newDevices []*OutDeviceInfo
 
@@ -141,3 +282,13 @@ func (mc *MidiConnection) TransmitterOut() (drivers.Out, error) {
 	mc.IsTransmitter = true
 	return out, nil
 }
+
+// ClockOut opens the virtual out that sends a standard 24 PPQN midi clock for
+// external hardware and software.
+func (mc *MidiConnection) ClockOut() (drivers.Out, error) {
+	out, err := OpenVirtualOut(ClockName)
+	if err != nil {
+		return nil, fault.Wrap(err, fmsg.With("cannot open virtual clock out"))
+	}
+	return out, nil
+}
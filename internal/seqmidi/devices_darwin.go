@@ -71,6 +71,18 @@ func (mc *MidiConnection) UpdateInDeviceList(driver drivers.Driver) error {
 				if foundDevice.IsTransmitter {
 					foundDevice.Open()
 				}
+				if foundDevice.IsClockSource {
+					// The port was reopened, the previous listener is gone
+					foundDevice.Open()
+					mc.ClockStopFn = nil
+				}
+				if foundDevice.IsRecordSource {
+					mc.RecordStopFn = nil
+				}
+				if foundDevice.IsControlSource {
+					foundDevice.Open()
+					mc.ControlStopFn = nil
+				}
 				break
 			}
 		}
@@ -86,6 +98,8 @@ func (mc *MidiConnection) UpdateInDeviceList(driver drivers.Driver) error {
 					newDevice.IsTransmitter = true
 					newDevice.Open()
 				}
+				mc.markClockSource(newDevice)
+				mc.markControlSource(newDevice)
 				newDevices = append(newDevices, newDevice)
 			}
 		} else {
@@ -95,6 +109,21 @@ func (mc *MidiConnection) UpdateInDeviceList(driver drivers.Driver) error {
 
 	mc.inDevices = newDevices
 
+	err = mc.listenToNewClockSource()
+	if err != nil {
+		return err
+	}
+
+	err = mc.listenToNewControlSource()
+	if err != nil {
+		return err
+	}
+
+	err = mc.listenToRecordSourceAgain()
+	if err != nil {
+		return err
+	}
+
 	if mc.ReceiverFunc != nil && !mc.DoNotListen {
 		err := mc.ListenToTransmitter(mc.ReceiverFunc)
 		if err != nil {
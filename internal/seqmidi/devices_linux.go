@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -112,6 +113,8 @@ func (mc *MidiConnection) UpdateInDeviceList(driver drivers.Driver) error {
 					newDevice.IsTransmitter = true
 					newDevice.Open()
 				}
+				mc.markClockSource(newDevice)
+				mc.markControlSource(newDevice)
 				newDevices = append(newDevices, newDevice)
 			}
 		} else {
@@ -119,8 +122,30 @@ func (mc *MidiConnection) UpdateInDeviceList(driver drivers.Driver) error {
 		}
 	}
 
+	for _, device := range mc.inDevices {
+		if device.IsRecordSource && !slices.Contains(newDevices, device) {
+			// The record port is reopened as a new device when it comes back
+			mc.RecordStopFn = nil
+		}
+	}
+
 	mc.inDevices = newDevices
 
+	err = mc.listenToNewClockSource()
+	if err != nil {
+		return err
+	}
+
+	err = mc.listenToNewControlSource()
+	if err != nil {
+		return err
+	}
+
+	err = mc.listenToRecordSourceAgain()
+	if err != nil {
+		return err
+	}
+
 	if mc.ReceiverFunc != nil && !mc.DoNotListen {
 		if !mc.HasTransmitter() {
 			err := mc.ListenToTransmitter(mc.ReceiverFunc)
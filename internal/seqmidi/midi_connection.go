@@ -20,20 +20,35 @@ import (
 	"gitlab.com/gomidi/midi/v2/drivers"
 )
 
+// The transmitter port links sq instances at full resolution while the
+// clock port carries a standard midi clock for everything else.
 const TransmitterName string = "sq-transmitter"
+const ClockName string = "sq-clock"
 
 type MidiConnection struct {
-	IsTransmitter bool
-	DoNotListen   bool
-	outportName   string
-	seqOutport    drivers.Out
-	midiChannel   chan Message
-	outDevices    []*OutDeviceInfo
-	inDevices     []*InDeviceInfo
-	TestQueue     *[]Message
-	Test          bool
-	StopFn        func()
-	ReceiverFunc  ReceiverFunc
+	IsTransmitter     bool
+	DoNotListen       bool
+	outportName       string
+	clockInName       string
+	recordInName      string
+	controlInName     string
+	seqOutport        drivers.Out
+	midiChannel       chan Message
+	outDevices        []*OutDeviceInfo
+	inDevices         []*InDeviceInfo
+	TestQueue         *[]Message
+	Test              bool
+	StopFn            func()
+	ReceiverFunc      ReceiverFunc
+	ClockStopFn       func()
+	ClockReceiverFunc ReceiverFunc
+	RecordStopFn      func()
+	// Set once at startup, notes are only passed on while recording
+	RecordReceiverFunc ReceiverFunc
+	recording          bool
+	ControlStopFn      func()
+	// Set once at startup, every control of the controller is passed on
+	ControlReceiverFunc ReceiverFunc
 }
 
 func (mc *MidiConnection) HasTransmitter() bool {
@@ -52,6 +67,46 @@ func (mc *MidiConnection) StopReceivingFromTransmitter() {
 	}
 }
 
+// FollowsClock is true when sq was asked to follow the midi clock of another
+// device.
+func (mc *MidiConnection) FollowsClock() bool {
+	return mc.clockInName != ""
+}
+
+func (mc *MidiConnection) HasClockSource() bool {
+	for _, device := range mc.inDevices {
+		if device.IsClockSource {
+			return true
+		}
+	}
+	return false
+}
+
+func (mc *MidiConnection) StopReceivingFromClock() {
+	if mc.ClockStopFn != nil {
+		mc.ClockStopFn()
+		mc.ClockStopFn = nil
+	}
+}
+
+func (mc *MidiConnection) StopReceivingFromControl() {
+	if mc.ControlStopFn != nil {
+		mc.ControlStopFn()
+		mc.ControlStopFn = nil
+	}
+}
+
+func (mc *MidiConnection) StopRecording() {
+	mc.recording = false
+	if mc.RecordStopFn != nil {
+		mc.RecordStopFn()
+		mc.RecordStopFn = nil
+	}
+	for _, device := range mc.inDevices {
+		device.IsRecordSource = false
+	}
+}
+
 func (mc *MidiConnection) HasOutport() bool {
 	return mc.seqOutport != nil
 }
@@ -81,16 +136,17 @@ func (mc *MidiConnection) HasConnection() bool {
 }
 
 type Message struct {
-	Delay time.Duration
-	Msg   midi.Message
+	// When the message is due; the zero time sends it at once
+	At  time.Time
+	Msg midi.Message
 }
 
-func InitMidiConnection(createOut bool, outportName string, ctx context.Context) *MidiConnection {
+func InitMidiConnection(createOut bool, outportName string, clockInName string, recordInName string, controlInName string, ctx context.Context) *MidiConnection {
 	var midiConn MidiConnection
 	if createOut {
-		midiConn = MidiConnection{midiChannel: make(chan Message)}
+		midiConn = MidiConnection{clockInName: clockInName, recordInName: recordInName, controlInName: controlInName, midiChannel: make(chan Message)}
 	} else {
-		midiConn = MidiConnection{outportName: outportName, midiChannel: make(chan Message)}
+		midiConn = MidiConnection{outportName: outportName, clockInName: clockInName, recordInName: recordInName, controlInName: controlInName, midiChannel: make(chan Message)}
 	}
 
 	return &midiConn
@@ -111,7 +167,7 @@ func (mc *MidiConnection) LoopMidi(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case msg := <-mc.midiChannel:
-				if msg.Delay == 0 {
+				if msg.At.IsZero() {
 					key := notereg.GetKey(msg.Msg)
 					if msg.Msg.Type().Is(midi.NoteOnMsg) && !notereg.HasKey(key) {
 						notereg.AddKey(key)
@@ -124,7 +180,7 @@ func (mc *MidiConnection) LoopMidi(ctx context.Context) {
 					}
 				} else {
 					key := notereg.GetKey(msg.Msg)
-					timer := time.AfterFunc(msg.Delay, func() {
+					timer := time.AfterFunc(time.Until(msg.At), func() {
 						if msg.Msg.Type().Is(midi.NoteOffMsg) && !notereg.HasKey(key) {
 							return
 						}
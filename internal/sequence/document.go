@@ -0,0 +1,773 @@
+package sequence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/controller"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/theory"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the serialization of a sequence file, chosen by the extension of
+// the file.
+type Format uint8
+
+const (
+	FormatText Format = iota
+	FormatJSON
+	FormatYAML
+)
+
+// Extensions are the extensions of the files listed as sequences, the text
+// format's own and those of the json and yaml formats.
+var Extensions = []string{".sq", ".json", ".yaml", ".yml"}
+
+// FormatOf returns the format of a file by its extension.  Files without a
+// json or yaml extension use the text format.
+func FormatOf(filename string) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	default:
+		return FormatText
+	}
+}
+
+// document is the JSON and YAML form of a sequence.  Overlays are listed from
+// the top of the overlay tree to the root and blockers refer to chords by the
+// same IDs as the text format.
+type document struct {
+	FormatVersion   int                `json:"formatVersion" yaml:"formatVersion"`
+	Conflicts       []string           `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
+	Tempo           int                `json:"tempo" yaml:"tempo"`
+	Subdivisions    int                `json:"subdivisions" yaml:"subdivisions"`
+	Keyline         uint8              `json:"keyline" yaml:"keyline"`
+	Instrument      string             `json:"instrument" yaml:"instrument"`
+	Template        string             `json:"template" yaml:"template"`
+	TemplateUIStyle string             `json:"templateUIStyle" yaml:"templateUIStyle"`
+	SequencerType   uint8              `json:"sequencerType" yaml:"sequencerType"`
+	Lines           []lineDocument     `json:"lines" yaml:"lines"`
+	Accents         accentsDocument    `json:"accents" yaml:"accents"`
+	Controls        []controlDocument  `json:"controls,omitempty" yaml:"controls,omitempty"`
+	Grooves         []grooveDocument   `json:"grooves,omitempty" yaml:"grooves,omitempty"`
+	Parts           []partDocument     `json:"parts" yaml:"parts"`
+	Arrangement     *nodeDocument      `json:"arrangement,omitempty" yaml:"arrangement,omitempty"`
+	Snapshots       []snapshotDocument `json:"snapshots,omitempty" yaml:"snapshots,omitempty"`
+}
+
+// snapshotDocument is a snapshot, the part is left out of a snapshot of the
+// whole sequence and only a whole snapshot has lines, tempo and accents.
+type snapshotDocument struct {
+	Name         string           `json:"name" yaml:"name"`
+	Part         *int             `json:"part,omitempty" yaml:"part,omitempty"`
+	Tempo        int              `json:"tempo,omitempty" yaml:"tempo,omitempty"`
+	Subdivisions int              `json:"subdivisions,omitempty" yaml:"subdivisions,omitempty"`
+	Lines        []lineDocument   `json:"lines,omitempty" yaml:"lines,omitempty"`
+	Accents      *accentsDocument `json:"accents,omitempty" yaml:"accents,omitempty"`
+	Parts        []partDocument   `json:"parts" yaml:"parts"`
+}
+
+type lineDocument struct {
+	Channel     uint8  `json:"channel" yaml:"channel"`
+	Note        uint8  `json:"note" yaml:"note"`
+	MessageType uint8  `json:"messageType" yaml:"messageType"`
+	Name        string `json:"name" yaml:"name"`
+	Length      uint8  `json:"length,omitempty" yaml:"length,omitempty"`
+	Division    string `json:"division,omitempty" yaml:"division,omitempty"`
+}
+
+type accentsDocument struct {
+	Target string  `json:"target" yaml:"target"`
+	Start  uint8   `json:"start" yaml:"start"`
+	End    uint8   `json:"end" yaml:"end"`
+	Data   []uint8 `json:"data" yaml:"data,flow"`
+}
+
+type controlDocument struct {
+	Type      string `json:"type" yaml:"type"`
+	Channel   uint8  `json:"channel" yaml:"channel"`
+	Number    uint8  `json:"number" yaml:"number"`
+	Command   string `json:"command,omitempty" yaml:"command,omitempty"`
+	Parameter string `json:"parameter,omitempty" yaml:"parameter,omitempty"`
+}
+
+type grooveDocument struct {
+	Name  string         `json:"name" yaml:"name"`
+	Steps []stepDocument `json:"steps" yaml:"steps"`
+}
+
+type stepDocument struct {
+	Offset   uint8 `json:"offset" yaml:"offset"`
+	Velocity uint8 `json:"velocity" yaml:"velocity"`
+}
+
+type partDocument struct {
+	Name     string                  `json:"name" yaml:"name"`
+	Beats    uint8                   `json:"beats" yaml:"beats"`
+	Meter    string                  `json:"meter,omitempty" yaml:"meter,omitempty"`
+	Grooves  []grooveSettingDocument `json:"grooves,omitempty" yaml:"grooves,omitempty"`
+	Overlays []overlayDocument       `json:"overlays" yaml:"overlays"`
+}
+
+type grooveSettingDocument struct {
+	Name   string  `json:"name" yaml:"name"`
+	Amount uint8   `json:"amount" yaml:"amount"`
+	Lines  []uint8 `json:"lines,omitempty" yaml:"lines,omitempty,flow"`
+}
+
+type overlayDocument struct {
+	Shift      uint8           `json:"shift" yaml:"shift"`
+	Interval   uint8           `json:"interval" yaml:"interval"`
+	Width      uint8           `json:"width" yaml:"width"`
+	StartCycle uint8           `json:"startCycle" yaml:"startCycle"`
+	PressUp    bool            `json:"pressUp" yaml:"pressUp"`
+	PressDown  bool            `json:"pressDown" yaml:"pressDown"`
+	Chords     []chordDocument `json:"chords,omitempty" yaml:"chords,omitempty"`
+	Blockers   []string        `json:"blockers,omitempty" yaml:"blockers,omitempty"`
+	Notes      []noteDocument  `json:"notes" yaml:"notes"`
+}
+
+type chordDocument struct {
+	ID        string             `json:"id" yaml:"id"`
+	Line      uint8              `json:"line" yaml:"line"`
+	Beat      uint8              `json:"beat" yaml:"beat"`
+	Arpeggio  int                `json:"arpeggio" yaml:"arpeggio"`
+	Notes     uint32             `json:"notes" yaml:"notes"`
+	BeatNotes []beatNoteDocument `json:"beatNotes" yaml:"beatNotes"`
+}
+
+type beatNoteDocument struct {
+	Beat          int `json:"beat" yaml:"beat"`
+	valueDocument `yaml:",inline"`
+}
+
+type noteDocument struct {
+	Line          uint8 `json:"line" yaml:"line"`
+	Beat          uint8 `json:"beat" yaml:"beat"`
+	valueDocument `yaml:",inline"`
+}
+
+type valueDocument struct {
+	AccentIndex uint8            `json:"accentIndex" yaml:"accentIndex"`
+	Ratchets    ratchetsDocument `json:"ratchets" yaml:"ratchets,flow"`
+	Action      uint8            `json:"action" yaml:"action"`
+	GateIndex   int16            `json:"gateIndex" yaml:"gateIndex"`
+	WaitIndex   uint8            `json:"waitIndex" yaml:"waitIndex"`
+	Probability uint8            `json:"probability,omitempty" yaml:"probability,omitempty"`
+	Condition   string           `json:"condition,omitempty" yaml:"condition,omitempty"`
+	Locks       []lockDocument   `json:"locks,omitempty" yaml:"locks,omitempty"`
+}
+
+type lockDocument struct {
+	Control uint8 `json:"control" yaml:"control"`
+	Value   uint8 `json:"value" yaml:"value"`
+}
+
+type ratchetsDocument struct {
+	Hits   uint8 `json:"hits" yaml:"hits"`
+	Length uint8 `json:"length" yaml:"length"`
+	Span   uint8 `json:"span" yaml:"span"`
+}
+
+type nodeDocument struct {
+	Iterations int              `json:"iterations" yaml:"iterations"`
+	Section    *sectionDocument `json:"section,omitempty" yaml:"section,omitempty"`
+	Nodes      []nodeDocument   `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+}
+
+type sectionDocument struct {
+	Part        int  `json:"part" yaml:"part"`
+	Cycles      int  `json:"cycles" yaml:"cycles"`
+	StartBeat   int  `json:"startBeat" yaml:"startBeat"`
+	StartCycles int  `json:"startCycles" yaml:"startCycles"`
+	KeepCycles  bool `json:"keepCycles" yaml:"keepCycles"`
+	// Sections without a tempo of their own leave these out
+	Tempo        int           `json:"tempo,omitempty" yaml:"tempo,omitempty"`
+	Subdivisions int           `json:"subdivisions,omitempty" yaml:"subdivisions,omitempty"`
+	Ramp         *rampDocument `json:"ramp,omitempty" yaml:"ramp,omitempty"`
+}
+
+type rampDocument struct {
+	Tempo  int    `json:"tempo" yaml:"tempo"`
+	Cycles int    `json:"cycles" yaml:"cycles"`
+	Curve  string `json:"curve" yaml:"curve"`
+}
+
+// Marshal encodes a sequence in the JSON or YAML format.
+func Marshal(sequence Sequence, format Format) ([]byte, error) {
+	doc := toDocument(sequence)
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, fault.Wrap(err, fmsg.WithDesc("cannot encode json", "Could not encode the sequence as json"))
+		}
+		return append(data, '\n'), nil
+	case FormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fault.Wrap(err, fmsg.WithDesc("cannot encode yaml", "Could not encode the sequence as yaml"))
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fault.New("unsupported format", fmsg.WithDesc("unsupported format", "Only json and yaml can be marshaled"))
+	}
+}
+
+// Unmarshal decodes a sequence from the JSON or YAML format.
+func Unmarshal(data []byte, format Format) (Sequence, error) {
+	var doc document
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&doc); err != nil {
+			return Sequence{}, fault.Wrap(err, fmsg.WithDesc("cannot decode json", fmt.Sprintf("Could not read the sequence json: %s", err)))
+		}
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&doc); err != nil {
+			return Sequence{}, fault.Wrap(err, fmsg.WithDesc("cannot decode yaml", fmt.Sprintf("Could not read the sequence yaml: %s", err)))
+		}
+	default:
+		return Sequence{}, fault.New("unsupported format", fmsg.WithDesc("unsupported format", "Only json and yaml can be unmarshaled"))
+	}
+
+	if err := checkVersion(doc.FormatVersion); err != nil {
+		return Sequence{}, err
+	}
+	return fromDocument(doc)
+}
+
+func writeDocument(sequence Sequence, filename string, format Format) error {
+	data, err := Marshal(sequence, format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot write file", fmt.Sprintf("Could not write %s", filename)))
+	}
+	return nil
+}
+
+func readDocument(filename string, format Format) (Sequence, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Sequence{}, err
+	}
+	return Unmarshal(data, format)
+}
+
+func toDocument(sequence Sequence) document {
+	doc := document{
+		FormatVersion:   FormatVersion,
+		Conflicts:       sequence.Conflicts,
+		Tempo:           sequence.Tempo,
+		Subdivisions:    sequence.Subdivisions,
+		Keyline:         sequence.Keyline,
+		Instrument:      sequence.Instrument,
+		Template:        sequence.Template,
+		TemplateUIStyle: sequence.TemplateUIStyle,
+		SequencerType:   uint8(sequence.TemplateSequencerType),
+		Lines:           toLineDocuments(sequence.Lines),
+		Accents:         toAccentsDocument(sequence.Accents),
+	}
+
+	for _, binding := range sequence.Controls {
+		control := controlDocument{Type: binding.Control.Kind.String(), Channel: binding.Control.Channel, Number: binding.Control.Number}
+		if binding.Parameter != controller.ParameterNone {
+			control.Parameter = binding.Parameter.String()
+		} else {
+			control.Command = binding.Command.String()
+		}
+		doc.Controls = append(doc.Controls, control)
+	}
+
+	for _, g := range usedGrooves(&sequence) {
+		steps := make([]stepDocument, len(g.Steps))
+		for i, step := range g.Steps {
+			steps[i] = stepDocument{Offset: step.Offset, Velocity: step.Velocity}
+		}
+		doc.Grooves = append(doc.Grooves, grooveDocument{Name: g.Name, Steps: steps})
+	}
+
+	if sequence.Parts != nil {
+		for _, part := range *sequence.Parts {
+			doc.Parts = append(doc.Parts, toPartDocument(part))
+		}
+	}
+
+	if sequence.Arrangement != nil {
+		node := toNodeDocument(sequence.Arrangement)
+		doc.Arrangement = &node
+	}
+
+	for _, snapshot := range sequence.Snapshots {
+		doc.Snapshots = append(doc.Snapshots, toSnapshotDocument(snapshot))
+	}
+
+	return doc
+}
+
+func toLineDocuments(lines []grid.LineDefinition) []lineDocument {
+	docs := make([]lineDocument, len(lines))
+	for i, line := range lines {
+		docs[i] = lineDocument{Channel: line.Channel, Note: line.Note, MessageType: uint8(line.MsgType), Name: line.Name, Length: line.Length, Division: line.Division.String()}
+	}
+	return docs
+}
+
+func toAccentsDocument(accents PatternAccents) accentsDocument {
+	doc := accentsDocument{
+		Target: "NOTE",
+		Start:  accents.Start,
+		End:    accents.End,
+		Data:   make([]uint8, len(accents.Data)),
+	}
+	if accents.Target == AccentTargetVelocity {
+		doc.Target = "VELOCITY"
+	}
+	for i, accent := range accents.Data {
+		doc.Data[i] = uint8(accent)
+	}
+	return doc
+}
+
+func toSnapshotDocument(snapshot Snapshot) snapshotDocument {
+	doc := snapshotDocument{Name: snapshot.Name, Parts: make([]partDocument, len(snapshot.Parts))}
+	if snapshot.IsWhole() {
+		accents := toAccentsDocument(snapshot.Accents)
+		doc.Tempo = snapshot.Tempo
+		doc.Subdivisions = snapshot.Subdivisions
+		doc.Lines = toLineDocuments(snapshot.Lines)
+		doc.Accents = &accents
+	} else {
+		part := snapshot.Part
+		doc.Part = &part
+	}
+	for i, part := range snapshot.Parts {
+		doc.Parts[i] = toPartDocument(part)
+	}
+	return doc
+}
+
+func toPartDocument(part arrangement.Part) partDocument {
+	doc := partDocument{Name: part.Name, Beats: part.Beats}
+	if part.Meter.IsSet() {
+		doc.Meter = part.Meter.String()
+	}
+	for _, setting := range part.Grooves {
+		doc.Grooves = append(doc.Grooves, grooveSettingDocument{Name: setting.Name, Amount: setting.Amount, Lines: setting.Lines})
+	}
+
+	ids := chordIDs(part.Overlays)
+	for overlay := part.Overlays; overlay != nil; overlay = overlay.Below {
+		overlayDoc := overlayDocument{
+			Shift:      overlay.Key.Shift,
+			Interval:   overlay.Key.Interval,
+			Width:      overlay.Key.Width,
+			StartCycle: overlay.Key.StartCycle,
+			PressUp:    overlay.PressUp,
+			PressDown:  overlay.PressDown,
+			Notes:      []noteDocument{},
+		}
+
+		chords := slices.Clone(overlay.Chords)
+		slices.SortStableFunc(chords, func(a, b *overlays.GridChord) int {
+			return grid.Compare(a.Root, b.Root)
+		})
+		for _, gridChord := range chords {
+			chordDoc := chordDocument{
+				ID:        ids[gridChord],
+				Line:      gridChord.Root.Line,
+				Beat:      gridChord.Root.Beat,
+				Arpeggio:  int(gridChord.Arpeggio),
+				Notes:     gridChord.Chord.Notes,
+				BeatNotes: make([]beatNoteDocument, len(gridChord.Notes)),
+			}
+			for i, beatNote := range gridChord.Notes {
+				chordDoc.BeatNotes[i] = beatNoteDocument{Beat: beatNote.Beat, valueDocument: toValueDocument(beatNote.Note)}
+			}
+			overlayDoc.Chords = append(overlayDoc.Chords, chordDoc)
+		}
+
+		// A blocker of a chord that is no longer in any overlay blocks nothing
+		for _, blocker := range overlay.Blockers {
+			if id, exists := ids[blocker]; exists {
+				overlayDoc.Blockers = append(overlayDoc.Blockers, id)
+			}
+		}
+		slices.Sort(overlayDoc.Blockers)
+
+		gridKeys := make([]grid.GridKey, 0, len(overlay.Notes))
+		for gridKey := range overlay.Notes {
+			gridKeys = append(gridKeys, gridKey)
+		}
+		slices.SortFunc(gridKeys, func(a, b grid.GridKey) int {
+			if a.Line != b.Line {
+				return int(a.Line) - int(b.Line)
+			}
+			return int(a.Beat) - int(b.Beat)
+		})
+		for _, gridKey := range gridKeys {
+			overlayDoc.Notes = append(overlayDoc.Notes, noteDocument{Line: gridKey.Line, Beat: gridKey.Beat, valueDocument: toValueDocument(overlay.Notes[gridKey])})
+		}
+
+		doc.Overlays = append(doc.Overlays, overlayDoc)
+	}
+	return doc
+}
+
+func toValueDocument(note grid.Note) valueDocument {
+	var locks []lockDocument
+	for _, lock := range note.Locks.All() {
+		locks = append(locks, lockDocument{Control: lock.Control, Value: lock.Value})
+	}
+	return valueDocument{
+		AccentIndex: note.AccentIndex,
+		Ratchets:    ratchetsDocument{Hits: note.Ratchets.Hits, Length: note.Ratchets.Length, Span: note.Ratchets.Span},
+		Action:      uint8(note.Action),
+		GateIndex:   note.GateIndex,
+		WaitIndex:   note.WaitIndex,
+		Probability: note.Probability,
+		Condition:   note.Condition.String(),
+		Locks:       locks,
+	}
+}
+
+func toNodeDocument(node *arrangement.Arrangement) nodeDocument {
+	doc := nodeDocument{Iterations: node.Iterations}
+	if node.IsEndNode() {
+		doc.Section = &sectionDocument{
+			Part:         node.Section.Part,
+			Cycles:       node.Section.Cycles,
+			StartBeat:    node.Section.StartBeat,
+			StartCycles:  node.Section.StartCycles,
+			KeepCycles:   node.Section.KeepCycles,
+			Tempo:        node.Section.Tempo,
+			Subdivisions: node.Section.Subdivisions,
+		}
+		if node.Section.Ramp.Tempo > 0 {
+			ramp := node.Section.Ramp
+			doc.Section.Ramp = &rampDocument{Tempo: ramp.Tempo, Cycles: ramp.Cycles, Curve: ramp.Curve.String()}
+		}
+	}
+	for _, child := range node.Nodes {
+		doc.Nodes = append(doc.Nodes, toNodeDocument(child))
+	}
+	return doc
+}
+
+func fromDocument(doc document) (Sequence, error) {
+	sequence := Sequence{
+		Conflicts:             doc.Conflicts,
+		Tempo:                 doc.Tempo,
+		Subdivisions:          doc.Subdivisions,
+		Keyline:               doc.Keyline,
+		Instrument:            doc.Instrument,
+		Template:              doc.Template,
+		TemplateUIStyle:       doc.TemplateUIStyle,
+		TemplateSequencerType: operation.SequencerMode(doc.SequencerType),
+	}
+
+	lines, err := fromLineDocuments(doc.Lines)
+	if err != nil {
+		return Sequence{}, err
+	}
+	sequence.Lines = lines
+
+	accents, err := fromAccentsDocument(doc.Accents)
+	if err != nil {
+		return Sequence{}, err
+	}
+	sequence.Accents = accents
+
+	for _, control := range doc.Controls {
+		binding, ok := controller.NewBinding(control.Type, control.Channel, control.Number, control.Command, control.Parameter)
+		if !ok {
+			return Sequence{}, invalidDocument("control %s %d/%d has no known command or parameter", control.Type, control.Channel, control.Number)
+		}
+		sequence.Controls = append(sequence.Controls, binding)
+	}
+
+	for _, g := range doc.Grooves {
+		steps := make([]groove.Step, len(g.Steps))
+		for i, step := range g.Steps {
+			steps[i] = groove.Step{Offset: step.Offset, Velocity: step.Velocity}
+		}
+		sequence.Grooves = append(sequence.Grooves, groove.Groove{Name: g.Name, Steps: steps})
+	}
+
+	parts := make([]arrangement.Part, len(doc.Parts))
+	for i, partDoc := range doc.Parts {
+		part, err := fromPartDocument(partDoc)
+		if err != nil {
+			return Sequence{}, err
+		}
+		parts[i] = part
+	}
+	sequence.Parts = &parts
+
+	if doc.Arrangement != nil {
+		root, err := fromNodeDocument(*doc.Arrangement, len(parts))
+		if err != nil {
+			return Sequence{}, err
+		}
+		sequence.Arrangement = root
+	}
+
+	for _, snapshotDoc := range doc.Snapshots {
+		snapshot, err := fromSnapshotDocument(snapshotDoc)
+		if err != nil {
+			return Sequence{}, err
+		}
+		if snapshot.Part >= len(parts) {
+			return Sequence{}, invalidDocument("snapshot %q captures part %d, the sequence has %d parts", snapshot.Name, snapshot.Part, len(parts))
+		}
+		sequence.Snapshots = append(sequence.Snapshots, snapshot)
+	}
+
+	return sequence, nil
+}
+
+// MarshalJSON encodes a snapshot like the snapshots of a JSON sequence file.
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toSnapshotDocument(s))
+}
+
+// UnmarshalJSON decodes a snapshot of a JSON sequence file.
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var doc snapshotDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	snapshot, err := fromSnapshotDocument(doc)
+	if err != nil {
+		return err
+	}
+	*s = snapshot
+	return nil
+}
+
+func fromLineDocuments(docs []lineDocument) ([]grid.LineDefinition, error) {
+	lines := make([]grid.LineDefinition, len(docs))
+	for i, line := range docs {
+		division, ok := grid.ParseDivision(line.Division)
+		if !ok {
+			return nil, invalidDocument("line %d division %q is not a division", i, line.Division)
+		}
+		lines[i] = grid.LineDefinition{Channel: line.Channel, Note: line.Note, MsgType: grid.MessageType(line.MessageType), Name: line.Name, Length: line.Length, Division: division}
+	}
+	return lines, nil
+}
+
+func fromAccentsDocument(doc accentsDocument) (PatternAccents, error) {
+	accents := PatternAccents{
+		Target: AccentTargetNote,
+		Start:  doc.Start,
+		End:    doc.End,
+		Data:   make([]config.Accent, len(doc.Data)),
+	}
+	switch doc.Target {
+	case "NOTE":
+	case "VELOCITY":
+		accents.Target = AccentTargetVelocity
+	default:
+		return PatternAccents{}, invalidDocument("accent target %q is not NOTE or VELOCITY", doc.Target)
+	}
+	for i, accent := range doc.Data {
+		accents.Data[i] = config.Accent(accent)
+	}
+	return accents, nil
+}
+
+func fromSnapshotDocument(doc snapshotDocument) (Snapshot, error) {
+	snapshot := Snapshot{Name: doc.Name, Part: WholeSequence}
+	if doc.Part != nil {
+		if *doc.Part < 0 {
+			return Snapshot{}, invalidDocument("snapshot %q captures part %d", doc.Name, *doc.Part)
+		}
+		if len(doc.Parts) != 1 {
+			return Snapshot{}, invalidDocument("snapshot %q of a part has %d parts", doc.Name, len(doc.Parts))
+		}
+		snapshot.Part = *doc.Part
+	} else {
+		snapshot.Tempo = doc.Tempo
+		snapshot.Subdivisions = doc.Subdivisions
+		lines, err := fromLineDocuments(doc.Lines)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Lines = lines
+		if doc.Accents != nil {
+			accents, err := fromAccentsDocument(*doc.Accents)
+			if err != nil {
+				return Snapshot{}, err
+			}
+			snapshot.Accents = accents
+		}
+	}
+	for _, partDoc := range doc.Parts {
+		part, err := fromPartDocument(partDoc)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Parts = append(snapshot.Parts, part)
+	}
+	return snapshot, nil
+}
+
+func fromPartDocument(doc partDocument) (arrangement.Part, error) {
+	part := arrangement.Part{Name: doc.Name, Beats: doc.Beats}
+	if doc.Meter != "" {
+		meter, ok := arrangement.ParseMeter(doc.Meter)
+		if !ok {
+			return arrangement.Part{}, invalidDocument("part %q meter %q is not a time signature", doc.Name, doc.Meter)
+		}
+		part.Meter = meter
+	}
+	for _, setting := range doc.Grooves {
+		part.Grooves = append(part.Grooves, groove.Setting{Name: setting.Name, Amount: setting.Amount, Lines: setting.Lines})
+	}
+
+	chords := make(map[string]*overlays.GridChord)
+	var overlayList []*overlays.Overlay
+	for _, overlayDoc := range doc.Overlays {
+		key := overlays.Key{Shift: overlayDoc.Shift, Interval: overlayDoc.Interval, Width: overlayDoc.Width, StartCycle: overlayDoc.StartCycle}
+		overlay := overlays.InitOverlay(key, nil)
+		overlay.PressUp = overlayDoc.PressUp
+		overlay.PressDown = overlayDoc.PressDown
+		for _, noteDoc := range overlayDoc.Notes {
+			note, err := fromValueDocument(noteDoc.valueDocument)
+			if err != nil {
+				return arrangement.Part{}, err
+			}
+			overlay.Notes[grid.GK(noteDoc.Line, noteDoc.Beat)] = note
+		}
+		for _, chordDoc := range overlayDoc.Chords {
+			gridChord := &overlays.GridChord{
+				Chord:    theory.Chord{Notes: chordDoc.Notes},
+				Root:     grid.GK(chordDoc.Line, chordDoc.Beat),
+				Arpeggio: overlays.Arp(chordDoc.Arpeggio),
+				Notes:    make([]overlays.BeatNote, len(chordDoc.BeatNotes)),
+			}
+			for i, beatNote := range chordDoc.BeatNotes {
+				note, err := fromValueDocument(beatNote.valueDocument)
+				if err != nil {
+					return arrangement.Part{}, err
+				}
+				gridChord.Notes[i] = overlays.BeatNote{Beat: beatNote.Beat, Note: note}
+			}
+			if _, exists := chords[chordDoc.ID]; exists {
+				return arrangement.Part{}, invalidDocument("part %q defines chord ID %q twice", doc.Name, chordDoc.ID)
+			}
+			chords[chordDoc.ID] = gridChord
+			overlay.Chords = append(overlay.Chords, gridChord)
+		}
+		overlayList = append(overlayList, overlay)
+	}
+
+	for i, overlayDoc := range doc.Overlays {
+		for _, id := range overlayDoc.Blockers {
+			gridChord, exists := chords[id]
+			if !exists {
+				return arrangement.Part{}, invalidDocument("part %q blocks chord ID %q that is not defined", doc.Name, id)
+			}
+			overlayList[i].Blockers = append(overlayList[i].Blockers, gridChord)
+		}
+		if i > 0 {
+			overlayList[i-1].Below = overlayList[i]
+		}
+	}
+
+	if len(overlayList) > 0 {
+		part.Overlays = overlayList[0]
+	}
+	return part, nil
+}
+
+func fromValueDocument(doc valueDocument) (grid.Note, error) {
+	condition, ok := grid.ParseCondition(doc.Condition)
+	if !ok {
+		return grid.Note{}, invalidDocument("note condition %q is not a condition", doc.Condition)
+	}
+	if doc.Probability > 100 {
+		return grid.Note{}, invalidDocument("note probability %d is out of range, it is a percentage", doc.Probability)
+	}
+	var locks grid.Locks
+	for _, lock := range doc.Locks {
+		if lock.Control > 127 || lock.Value > 127 {
+			return grid.Note{}, invalidDocument("note lock %d:%d is out of range, controls and values go up to 127", lock.Control, lock.Value)
+		}
+		if locks, ok = locks.Set(lock.Control, lock.Value); !ok {
+			return grid.Note{}, invalidDocument("note has more than %d locks", grid.MaxLocks)
+		}
+	}
+	return grid.Note{
+		AccentIndex: doc.AccentIndex,
+		Ratchets:    grid.Ratchet{Hits: doc.Ratchets.Hits, Length: doc.Ratchets.Length, Span: doc.Ratchets.Span},
+		Action:      grid.Action(doc.Action),
+		GateIndex:   doc.GateIndex,
+		WaitIndex:   doc.WaitIndex,
+		Probability: doc.Probability,
+		Condition:   condition,
+		Locks:       locks,
+	}, nil
+}
+
+func fromNodeDocument(doc nodeDocument, partCount int) (*arrangement.Arrangement, error) {
+	node := &arrangement.Arrangement{Iterations: doc.Iterations, Nodes: []*arrangement.Arrangement{}}
+	if doc.Section != nil {
+		if doc.Section.Part < 0 || doc.Section.Part >= partCount {
+			return nil, invalidDocument("arrangement section plays part %d, the sequence has %d parts", doc.Section.Part, partCount)
+		}
+		node.Section = arrangement.SongSection{
+			Part:         doc.Section.Part,
+			Cycles:       doc.Section.Cycles,
+			StartBeat:    doc.Section.StartBeat,
+			StartCycles:  doc.Section.StartCycles,
+			KeepCycles:   doc.Section.KeepCycles,
+			Tempo:        doc.Section.Tempo,
+			Subdivisions: doc.Section.Subdivisions,
+		}
+		if doc.Section.Ramp != nil {
+			curve, ok := arrangement.ParseCurve(doc.Section.Ramp.Curve)
+			if !ok {
+				return nil, invalidDocument("section ramp curve %q is not linear or exponential", doc.Section.Ramp.Curve)
+			}
+			node.Section.Ramp = arrangement.Ramp{Tempo: doc.Section.Ramp.Tempo, Cycles: doc.Section.Ramp.Cycles, Curve: curve}
+		}
+	}
+	for _, childDoc := range doc.Nodes {
+		child, err := fromNodeDocument(childDoc, partCount)
+		if err != nil {
+			return nil, err
+		}
+		node.Nodes = append(node.Nodes, child)
+	}
+	return node, nil
+}
+
+func invalidDocument(format string, a ...any) error {
+	message := fmt.Sprintf(format, a...)
+	return fault.New(message, fmsg.WithDesc("invalid sequence", message))
+}
@@ -0,0 +1,207 @@
+package sequence
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/controller"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, FormatJSON, FormatOf("song.json"))
+	assert.Equal(t, FormatYAML, FormatOf("song.yaml"))
+	assert.Equal(t, FormatYAML, FormatOf("SONG.YML"))
+	assert.Equal(t, FormatText, FormatOf("song.sq"))
+	assert.Equal(t, FormatText, FormatOf("song"))
+}
+
+func TestDocument(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// The text format covers every value of a sequence, a conversion
+	// is lossless when the text written before and after it is the same
+	textOf := func(t *testing.T, sequence Sequence) string {
+		filename := filepath.Join(tempDir, "text.sq")
+		assert.NoError(t, Write(sequence, filename))
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		return string(content)
+	}
+
+	for _, testFile := range []string{"testdata/checkchord.sq", "testdata/checkblockers.sq"} {
+		for _, extension := range []string{"json", "yaml"} {
+			t.Run(fmt.Sprintf("%s to %s and back", testFile, extension), func(t *testing.T) {
+				original, err := Read(testFile)
+				assert.NoError(t, err)
+
+				filename := filepath.Join(tempDir, "converted."+extension)
+				assert.NoError(t, Write(original, filename))
+				converted, err := Read(filename)
+				assert.NoError(t, err)
+
+				assert.Equal(t, textOf(t, original), textOf(t, converted))
+			})
+		}
+	}
+
+	t.Run("Blockers refer to the chords below", func(t *testing.T) {
+		original, err := Read("testdata/checkblockers.sq")
+		assert.NoError(t, err)
+		data, err := Marshal(original, FormatJSON)
+		assert.NoError(t, err)
+
+		converted, err := Unmarshal(data, FormatJSON)
+		assert.NoError(t, err)
+		top := (*converted.Parts)[0].Overlays
+		if assert.Len(t, top.Blockers, 1) {
+			assert.Same(t, top.Below.Chords[0], top.Blockers[0])
+		}
+	})
+
+	t.Run("Settings, controls, grooves and conflicts", func(t *testing.T) {
+		parts := []arrangement.Part{arrangement.InitPart("Verse")}
+		parts[0].Grooves = groove.Settings{{Name: "Pushed", Amount: 80, Lines: []uint8{1}}}
+		original := validSequence()
+		original.Parts = &parts
+		original.Arrangement = InitArrangement(parts)
+		original.Arrangement.Nodes[0].Section.KeepCycles = true
+		original.Controls = controller.Bindings{
+			{Control: controller.Control{Kind: controller.KindNote, Channel: 1, Number: 36}, Command: mappings.PlayStop},
+			{Control: controller.Control{Kind: controller.KindCC, Channel: 1, Number: 7}, Parameter: controller.ParameterTempo},
+		}
+		original.Grooves = []groove.Groove{{Name: "Pushed", Steps: []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 10, Velocity: 80}}}}
+		original.Conflicts = []string{"Tempo: Ours=120, Theirs=90"}
+
+		for _, format := range []Format{FormatJSON, FormatYAML} {
+			data, err := Marshal(original, format)
+			assert.NoError(t, err)
+			converted, err := Unmarshal(data, format)
+			assert.NoError(t, err)
+
+			assert.Equal(t, original.Controls, converted.Controls)
+			assert.Equal(t, original.Grooves, converted.Grooves)
+			assert.Equal(t, original.Conflicts, converted.Conflicts)
+			assert.Equal(t, parts[0].Grooves, (*converted.Parts)[0].Grooves)
+			assert.Equal(t, original.Arrangement.Nodes[0].Section, converted.Arrangement.Nodes[0].Section)
+			assert.Equal(t, textOf(t, original), textOf(t, converted))
+		}
+	})
+
+	t.Run("Chances, trig conditions and locks", func(t *testing.T) {
+		original := validSequence()
+		overlay := (*original.Parts)[0].Overlays
+		overlay.AddNote(grid.GridKey{Line: 0, Beat: 0}, grid.Note{AccentIndex: 1, Probability: 40})
+		overlay.AddNote(grid.GridKey{Line: 0, Beat: 4}, grid.Note{AccentIndex: 1, Condition: grid.CycleCondition(3, 4)})
+		overlay.AddNote(grid.GridKey{Line: 1, Beat: 4}, grid.Note{AccentIndex: 1, Probability: 70, Condition: grid.ConditionNotPrevious})
+		locks, _ := grid.Locks{}.Set(74, 90)
+		locks, _ = locks.Set(71, 30)
+		overlay.AddNote(grid.GridKey{Line: 1, Beat: 6}, grid.Note{AccentIndex: 1, Locks: locks})
+
+		filename := filepath.Join(tempDir, "trigs.sq")
+		assert.NoError(t, Write(original, filename))
+		read, err := Read(filename)
+		assert.NoError(t, err)
+		readOverlay := (*read.Parts)[0].Overlays
+		assert.Equal(t, overlay.Notes, readOverlay.Notes)
+
+		for _, format := range []Format{FormatJSON, FormatYAML} {
+			data, err := Marshal(original, format)
+			assert.NoError(t, err)
+			converted, err := Unmarshal(data, format)
+			assert.NoError(t, err)
+			assert.Equal(t, textOf(t, original), textOf(t, converted))
+		}
+	})
+
+	t.Run("Line lengths and divisions", func(t *testing.T) {
+		original := validSequence()
+		original.Lines[0].Length = 5
+		original.Lines[0].Division = 3
+		original.Lines[1].Division = -2
+
+		filename := filepath.Join(tempDir, "polymeter.sq")
+		assert.NoError(t, Write(original, filename))
+		read, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, original.Lines, read.Lines)
+
+		for _, format := range []Format{FormatJSON, FormatYAML} {
+			data, err := Marshal(original, format)
+			assert.NoError(t, err)
+			converted, err := Unmarshal(data, format)
+			assert.NoError(t, err)
+			assert.Equal(t, original.Lines, converted.Lines)
+		}
+	})
+
+	t.Run("Section tempos and ramps", func(t *testing.T) {
+		original := validSequence()
+		section := &original.Arrangement.Nodes[0].Section
+		section.Tempo = 90
+		section.Subdivisions = 3
+		section.Ramp = arrangement.Ramp{Tempo: 140, Cycles: 4, Curve: arrangement.CurveExponential}
+
+		filename := filepath.Join(tempDir, "tempo.sq")
+		assert.NoError(t, Write(original, filename))
+		read, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, *section, read.Arrangement.Nodes[0].Section)
+
+		for _, format := range []Format{FormatJSON, FormatYAML} {
+			data, err := Marshal(original, format)
+			assert.NoError(t, err)
+			converted, err := Unmarshal(data, format)
+			assert.NoError(t, err)
+			assert.Equal(t, *section, converted.Arrangement.Nodes[0].Section)
+		}
+	})
+
+	t.Run("Part meters", func(t *testing.T) {
+		original := validSequence()
+		(*original.Parts)[0].Meter = arrangement.Meter{Numerator: 7, Denominator: 8}
+
+		filename := filepath.Join(tempDir, "meter.sq")
+		assert.NoError(t, Write(original, filename))
+		read, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, (*original.Parts)[0].Meter, (*read.Parts)[0].Meter)
+
+		for _, format := range []Format{FormatJSON, FormatYAML} {
+			data, err := Marshal(original, format)
+			assert.NoError(t, err)
+			converted, err := Unmarshal(data, format)
+			assert.NoError(t, err)
+			assert.Equal(t, (*original.Parts)[0].Meter, (*converted.Parts)[0].Meter)
+		}
+	})
+
+	t.Run("Invalid documents", func(t *testing.T) {
+		tests := []struct {
+			name string
+			data string
+		}{
+			{"unknown field", `{"formatVersion": 2, "tempos": 120}`},
+			{"newer version", fmt.Sprintf(`{"formatVersion": %d}`, FormatVersion+1)},
+			{"undefined blocker", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "parts": [{"overlays": [{"shift": 1, "interval": 1, "blockers": ["1/1/1/0:0,0"]}]}]}`},
+			{"too many locks", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "parts": [{"overlays": [{"shift": 1, "interval": 1, "notes": [{"line": 0, "beat": 0, "accentIndex": 1, "locks": [{"control": 1, "value": 1}, {"control": 2, "value": 1}, {"control": 3, "value": 1}, {"control": 4, "value": 1}, {"control": 5, "value": 1}]}]}]}]}`},
+			{"unknown division", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "lines": [{"channel": 1, "note": 60, "messageType": 0, "name": "", "division": "/9"}]}`},
+			{"unknown meter", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "parts": [{"name": "", "beats": 8, "meter": "4/3", "overlays": []}]}`},
+			{"unknown ramp curve", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "arrangement": {"iterations": 1, "nodes": [{"iterations": 1, "section": {"part": 0, "cycles": 1, "startBeat": 0, "startCycles": 1, "keepCycles": false, "ramp": {"tempo": 140, "cycles": 0, "curve": "sine"}}}]}}`},
+			{"unknown condition", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "parts": [{"overlays": [{"shift": 1, "interval": 1, "notes": [{"line": 0, "beat": 0, "accentIndex": 1, "condition": "sometimes"}]}]}]}`},
+			{"missing part", `{"formatVersion": 2, "accents": {"target": "NOTE"}, "arrangement": {"iterations": 1, "nodes": [{"iterations": 1, "section": {"part": 1}}]}}`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := Unmarshal([]byte(tt.data), FormatJSON)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
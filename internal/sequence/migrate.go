@@ -0,0 +1,61 @@
+package sequence
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/overlays"
+)
+
+// FormatVersion is the version of the file format written by Write.  Files
+// without a version line are version 1.
+const FormatVersion = 3
+
+// The migration at index n upgrades a sequence read from a version n+1
+// file to version n+2
+var migrations = []func(*Sequence){
+	migrateChordIDs,
+	migrateAdditions,
+}
+
+// Migrate upgrades a sequence read from a file of an older format version.
+func Migrate(sequence *Sequence, version int) error {
+	if err := checkVersion(version); err != nil {
+		return err
+	}
+	for v := max(version, 1); v < FormatVersion; v++ {
+		migrations[v-1](sequence)
+	}
+	return nil
+}
+
+// checkVersion fails for files written by a newer version of sq.
+func checkVersion(version int) error {
+	if version > FormatVersion {
+		return fault.New("newer file format", fmsg.WithDesc("newer file format", fmt.Sprintf("The file has format version %d, this version of sq reads up to version %d", version, FormatVersion)))
+	}
+	return nil
+}
+
+// migrateChordIDs removes the blockers that version 1 files referenced by
+// memory address although the blocked chord was not saved with the file.
+func migrateChordIDs(sequence *Sequence) {
+	if sequence.Parts == nil {
+		return
+	}
+	for _, part := range *sequence.Parts {
+		for overlay := part.Overlays; overlay != nil; overlay = overlay.Below {
+			overlay.Blockers = slices.DeleteFunc(overlay.Blockers, func(gridChord *overlays.GridChord) bool {
+				return gridChord == nil
+			})
+		}
+	}
+}
+
+// migrateAdditions upgrades a version 2 file, which has no snapshots, chances,
+// conditions, locks, line lengths, divisions, section tempos or meters.  Their
+// zero values play the way a version 2 file played, so nothing is changed.
+// The version keeps older builds from reading and then dropping them.
+func migrateAdditions(sequence *Sequence) {}
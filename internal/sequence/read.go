@@ -3,16 +3,16 @@ package sequence
 import (
 	"bufio"
 	"fmt"
-	"maps"
 	"os"
-	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/chriserin/sq/internal/arrangement"
 	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/controller"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/chriserin/sq/internal/overlaykey"
 	"github.com/chriserin/sq/internal/overlays"
 )
@@ -20,10 +20,33 @@ import (
 // Read loads the model's sequence struct from a file
 // The file format should match the format created by the Write function
 func Read(filename string) (Sequence, error) {
+	sequence, _, err := read(filename)
+	return sequence, err
+}
+
+// ReadStrict loads a sequence like Read but fails with the Diagnostics of
+// every value of the file that could not be read or is out of range
+func ReadStrict(filename string) (Sequence, error) {
+	sequence, diagnostics, err := read(filename)
+	if err != nil {
+		return Sequence{}, err
+	}
+	if len(diagnostics) > 0 {
+		return Sequence{}, diagnostics
+	}
+	return sequence, nil
+}
+
+func read(filename string) (Sequence, Diagnostics, error) {
+	if format := FormatOf(filename); format != FormatText {
+		sequence, err := readDocument(filename, format)
+		return sequence, nil, err
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
 		log.Error("Failed to open file", "filename", filename, "error", err)
-		return Sequence{}, err
+		return Sequence{}, nil, err
 	}
 	defer file.Close()
 
@@ -46,26 +69,39 @@ func Read(filename string) (Sequence, error) {
 		},
 	}
 
-	sequence = Scan(scanner, sequence)
+	sequence, version, diagnostics := Scan(scanner, sequence)
 	// Check if we got a scanner error
 	if err := scanner.Err(); err != nil {
 		log.Error("Error reading file", "filename", filename, "error", err)
-		return Sequence{}, err
+		return Sequence{}, nil, err
 	}
 
-	return sequence, nil
+	// Upgrade files written by older versions of sq
+	if err := Migrate(&sequence, version); err != nil {
+		return Sequence{}, nil, err
+	}
+
+	return sequence, diagnostics, nil
 }
 
-func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
+// Scan reads the sections of a file into the sequence and returns the format
+// version of the file and the problems found on its lines
+func Scan(bufScanner *bufio.Scanner, sequence Sequence) (Sequence, int, Diagnostics) {
+	scanner := &LineScanner{Scanner: bufScanner}
 	var currentSection string
+	var version = 1
 	var currentPart *arrangement.Part
 	var currentOverlay *overlays.Overlay
 	var currentChord *overlays.GridChord
 
-	var blockersList = make(map[overlaykey.OverlayPeriodicity][]string)
+	var blockersList = make(map[overlaykey.OverlayPeriodicity][]blockerRef)
 	var chordsList = make(map[string]*overlays.GridChord)
 
-	for scanner.Scan() {
+	// A section that reads up to the header of the next section leaves
+	// the header to be read again
+	var reread bool
+	for reread || scanner.Scan() {
+		reread = false
 		line := scanner.Text()
 
 		// Skip empty lines
@@ -80,6 +116,19 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 
 			// Handle other section markers
 			switch {
+			case strings.HasPrefix(sectionLine, "SNAPSHOT "):
+				// Snapshots are written last, the sections of the file are done
+				if currentPart != nil {
+					finalizePreviousPart(currentPart, blockersList, chordsList, version, scanner)
+					currentPart = nil
+				}
+				name := strings.TrimSpace(strings.TrimPrefix(sectionLine, "SNAPSHOT "))
+				var snapshot Snapshot
+				snapshot, reread = scanSnapshot(scanner, name, sequence, version)
+				sequence.Snapshots = append(sequence.Snapshots, snapshot)
+				continue
+			case strings.Contains(sectionLine, "CONFLICTS"):
+				currentSection = "CONFLICTS"
 			case strings.Contains(sectionLine, "GLOBAL SETTINGS"):
 				currentSection = "GLOBAL_SETTINGS"
 			case strings.Contains(sectionLine, "LINES"):
@@ -88,15 +137,19 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 				currentSection = "ACCENTS"
 			case strings.Contains(sectionLine, "ACCENT DATA"):
 				currentSection = "ACCENT_DATA"
+			case strings.Contains(sectionLine, "CONTROLS"):
+				currentSection = "CONTROLS"
+			case strings.Contains(sectionLine, "GROOVES"):
+				currentSection = "GROOVES"
 			case strings.Contains(sectionLine, "PARTS"):
 				currentSection = "PARTS"
 			case strings.Contains(sectionLine, "PART "):
 				currentSection = "PART"
 
 				if currentPart != nil {
-					finalizePreviousPart(currentPart, blockersList, chordsList)
+					finalizePreviousPart(currentPart, blockersList, chordsList, version, scanner)
 
-					blockersList = make(map[overlaykey.OverlayPeriodicity][]string)
+					blockersList = make(map[overlaykey.OverlayPeriodicity][]blockerRef)
 					chordsList = make(map[string]*overlays.GridChord)
 				}
 
@@ -113,6 +166,11 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 
 			case strings.Contains(sectionLine, "OVERLAY"):
 				currentSection = "OVERLAY"
+				if currentPart == nil {
+					scanner.report("overlay outside of a part")
+					currentSection = ""
+					continue
+				}
 
 				// Create a default overlay if none exists yet
 				if currentPart.Overlays == nil {
@@ -152,6 +210,11 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 
 			case strings.Contains(sectionLine, "CHORD"):
 				currentSection = "CHORD"
+				if currentOverlay == nil {
+					scanner.report("chord outside of an overlay")
+					currentSection = ""
+					continue
+				}
 				currentChord = &overlays.GridChord{}
 				currentOverlay.Chords = append(currentOverlay.Chords, currentChord)
 
@@ -167,7 +230,7 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 					Iterations: 1,
 					Nodes:      []*arrangement.Arrangement{},
 				}
-				ScanArrangement(scanner, sequence.Arrangement, 0)
+				reread = ScanArrangement(scanner, sequence.Arrangement, 0, len(*sequence.Parts))
 
 			default:
 				// Unknown section
@@ -178,6 +241,25 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 
 		// Process line based on current section
 		switch currentSection {
+		case "":
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) != "Format Version" {
+				continue
+			}
+			if v, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+				version = v
+			} else {
+				scanner.invalid("format version", strings.TrimSpace(parts[1]))
+			}
+
+		case "CONFLICTS":
+			// Conflicts are resolved by editing the sequence and removing them
+			if conflict, found := strings.CutPrefix(line, "Conflict "); found {
+				if _, description, found := strings.Cut(conflict, ": "); found {
+					scanner.report("unresolved merge conflict %s", description)
+				}
+			}
+
 		case "GLOBAL_SETTINGS":
 			parts := strings.SplitN(line, ":", 2)
 			if len(parts) != 2 {
@@ -191,14 +273,20 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 			case "Tempo":
 				if tempo, err := strconv.Atoi(value); err == nil {
 					sequence.Tempo = tempo
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "Subdivisions":
 				if subdiv, err := strconv.Atoi(value); err == nil {
 					sequence.Subdivisions = subdiv
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "Keyline":
 				if keyline, err := strconv.ParseUint(value, 10, 8); err == nil {
 					sequence.Keyline = uint8(keyline)
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "Instrument":
 				sequence.Instrument = value
@@ -236,16 +324,34 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 
 				switch key {
 				case "Channel":
-					if channel, err := strconv.ParseUint(value, 10, 8); err == nil {
+					if channel, err := strconv.ParseUint(value, 10, 8); err == nil && channel >= 1 && channel <= 16 {
 						lineDef.Channel = uint8(channel)
+					} else {
+						scanner.invalid(key, value)
 					}
 				case "Note":
-					if note, err := strconv.ParseUint(value, 10, 8); err == nil {
+					if note, err := strconv.ParseUint(value, 10, 8); err == nil && note <= 127 {
 						lineDef.Note = uint8(note)
+					} else {
+						scanner.invalid(key, value)
 					}
 				case "MessageType":
 					if msgType, err := strconv.ParseUint(value, 10, 8); err == nil {
 						lineDef.MsgType = grid.MessageType(msgType)
+					} else {
+						scanner.invalid(key, value)
+					}
+				case "Length":
+					if length, err := strconv.ParseUint(value, 10, 8); err == nil {
+						lineDef.Length = uint8(length)
+					} else {
+						scanner.invalid(key, value)
+					}
+				case "Division":
+					if division, ok := grid.ParseDivision(value); ok {
+						lineDef.Division = division
+					} else {
+						scanner.invalid(key, value)
 					}
 				case "Name":
 					lineDef.Name = value
@@ -267,10 +373,14 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 			case "End":
 				if end, err := strconv.ParseUint(value, 10, 8); err == nil {
 					sequence.Accents.End = uint8(end)
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "Start":
 				if start, err := strconv.ParseUint(value, 10, 8); err == nil {
 					sequence.Accents.Start = uint8(start)
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "Target":
 				switch value {
@@ -278,6 +388,8 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 					sequence.Accents.Target = AccentTargetNote
 				case "VELOCITY":
 					sequence.Accents.Target = AccentTargetVelocity
+				default:
+					scanner.invalid(key, value)
 				}
 			}
 
@@ -309,14 +421,98 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 
 				switch key {
 				case "Value":
-					if val, err := strconv.Atoi(value); err == nil {
+					if val, err := strconv.Atoi(value); err == nil && val >= 0 && val <= 127 {
 						accent = config.Accent(val)
+					} else {
+						scanner.invalid("accent value", value)
 					}
 				}
 			}
 
 			sequence.Accents.Data = append(sequence.Accents.Data, accent)
 
+		case "CONTROLS":
+			if !strings.HasPrefix(line, "Control ") {
+				continue
+			}
+
+			// Parse controller binding
+			// Format: Control X: Type=CC, Channel=Y, Number=Z, Parameter=W
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			paramStr := strings.TrimSpace(parts[1])
+			params := strings.Split(paramStr, ", ")
+
+			var kind, command, parameter string
+			var channel, number uint8
+
+			for _, param := range params {
+				keyVal := strings.SplitN(param, "=", 2)
+				if len(keyVal) != 2 {
+					continue
+				}
+
+				key := strings.TrimSpace(keyVal[0])
+				value := strings.TrimSpace(keyVal[1])
+
+				switch key {
+				case "Type":
+					kind = value
+				case "Channel":
+					if val, err := strconv.ParseUint(value, 10, 8); err == nil {
+						channel = uint8(val)
+					}
+				case "Number":
+					if val, err := strconv.ParseUint(value, 10, 8); err == nil {
+						number = uint8(val)
+					}
+				case "Command":
+					command = value
+				case "Parameter":
+					parameter = value
+				}
+			}
+
+			if binding, ok := controller.NewBinding(kind, channel, number, command, parameter); ok {
+				sequence.Controls = append(sequence.Controls, binding)
+			} else {
+				scanner.report("control binding %q does not parse", paramStr)
+			}
+
+		case "GROOVES":
+			if !strings.HasPrefix(line, "Groove ") {
+				continue
+			}
+
+			// Parse groove
+			// Format: Groove X: Name=Y, Steps=0/100 20/80
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			var newGroove groove.Groove
+			for key, value := range parseParams(parts[1]) {
+				switch key {
+				case "Name":
+					newGroove.Name = value
+				case "Steps":
+					for _, stepStr := range strings.Fields(value) {
+						var offset, velocity uint8
+						if _, err := fmt.Sscanf(stepStr, "%d/%d", &offset, &velocity); err == nil {
+							newGroove.Steps = append(newGroove.Steps, groove.Step{Offset: offset, Velocity: velocity})
+						} else {
+							scanner.invalid("groove step", stepStr)
+						}
+					}
+				}
+			}
+
+			sequence.Grooves = append(sequence.Grooves, newGroove)
+
 		case "PART":
 			if currentPart == nil {
 				continue
@@ -336,7 +532,39 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 			case "Beats":
 				if beats, err := strconv.ParseUint(value, 10, 8); err == nil {
 					currentPart.Beats = uint8(beats)
+				} else {
+					scanner.invalid(key, value)
 				}
+			case "Meter":
+				if meter, ok := arrangement.ParseMeter(value); ok {
+					currentPart.Meter = meter
+				} else {
+					scanner.invalid(key, value)
+				}
+			case "Groove":
+				// Format: Groove: Name=X, Amount=Y, Lines=1 2 3
+				var setting groove.Setting
+				for key, value := range parseParams(value) {
+					switch key {
+					case "Name":
+						setting.Name = value
+					case "Amount":
+						if amount, err := strconv.ParseUint(value, 10, 8); err == nil && amount <= groove.MaxAmount {
+							setting.Amount = uint8(amount)
+						} else {
+							scanner.invalid("groove amount", value)
+						}
+					case "Lines":
+						for _, lineStr := range strings.Fields(value) {
+							if lineIndex, err := strconv.ParseUint(lineStr, 10, 8); err == nil {
+								setting.Lines = append(setting.Lines, uint8(lineIndex))
+							} else {
+								scanner.invalid("groove line", lineStr)
+							}
+						}
+					}
+				}
+				currentPart.Grooves = append(currentPart.Grooves, setting)
 			}
 
 		case "OVERLAY":
@@ -356,26 +584,38 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 			case "Shift":
 				if shift, err := strconv.ParseUint(value, 10, 8); err == nil {
 					currentOverlay.Key.Shift = uint8(shift)
+				} else {
+					scanner.report("overlay key %s %q does not parse", key, value)
 				}
 			case "Interval":
 				if interval, err := strconv.ParseUint(value, 10, 8); err == nil {
 					currentOverlay.Key.Interval = uint8(interval)
+				} else {
+					scanner.report("overlay key %s %q does not parse", key, value)
 				}
 			case "Width":
 				if width, err := strconv.ParseUint(value, 10, 8); err == nil {
 					currentOverlay.Key.Width = uint8(width)
+				} else {
+					scanner.report("overlay key %s %q does not parse", key, value)
 				}
 			case "StartCycle":
 				if startCycle, err := strconv.ParseUint(value, 10, 8); err == nil {
 					currentOverlay.Key.StartCycle = uint8(startCycle)
+				} else {
+					scanner.report("overlay key %s %q does not parse", key, value)
 				}
 			case "PressUp":
 				if pressUp, err := strconv.ParseBool(value); err == nil {
 					currentOverlay.PressUp = pressUp
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "PressDown":
 				if pressDown, err := strconv.ParseBool(value); err == nil {
 					currentOverlay.PressDown = pressDown
+				} else {
+					scanner.invalid(key, value)
 				}
 			}
 
@@ -385,11 +625,16 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 			}
 
 			beat, coordEnd := GetBeat(line)
+			if coordEnd < 0 || currentChord == nil {
+				scanner.report("chord note %q does not parse", line)
+				continue
+			}
 
 			// Parse note properties
 			propStr := line[coordEnd+2:] // "AccentIndex=Z, ..."
 
-			note := noteprops(propStr)
+			note := noteprops(propStr, scanner)
+			scanner.checkNote(note, sequence)
 
 			currentChord.Notes = append(currentChord.Notes, overlays.BeatNote{Beat: beat, Note: note})
 
@@ -399,11 +644,20 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 			}
 
 			gridKey, coordEnd := GetGridKey(line)
+			if coordEnd < 0 {
+				scanner.report("note %q does not parse", line)
+				continue
+			}
 
 			// Parse note properties
 			propStr := line[coordEnd+2:] // "AccentIndex=Z, ..."
 
-			note := noteprops(propStr)
+			note := noteprops(propStr, scanner)
+			scanner.checkNote(note, sequence)
+			// A note outside the grid is reported and left out of the overlay
+			if !scanner.checkGridKey(gridKey, sequence) {
+				continue
+			}
 
 			currentOverlay.AddNote(gridKey, note)
 
@@ -414,7 +668,7 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 				continue
 			} else {
 				id := GetID(line)
-				blockersList[currentOverlay.Key] = append(blockersList[currentOverlay.Key], id)
+				blockersList[currentOverlay.Key] = append(blockersList[currentOverlay.Key], blockerRef{id: id, line: scanner.line})
 			}
 		case "CHORD":
 			id := GetID(line)
@@ -424,6 +678,11 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 				line = scanner.Text()
 			}
 			gridKey, coordEnd := GetGridKey(line)
+			if coordEnd < 0 {
+				scanner.report("chord %q does not parse", line)
+				continue
+			}
+			scanner.checkGridKey(gridKey, sequence)
 			currentChord.Root = gridKey
 			propStr := line[coordEnd+2:] // "AccentIndex=Z, ..."
 			props := strings.Split(propStr, ", ")
@@ -441,10 +700,14 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 				case "Arpeggio":
 					if arppegio, err := strconv.ParseInt(value, 10, 8); err == nil {
 						currentChord.Arpeggio = overlays.Arp(arppegio)
+					} else {
+						scanner.invalid(key, value)
 					}
 				case "Notes":
 					if notes, err := strconv.ParseUint(value, 10, 32); err == nil {
 						currentChord.Chord.Notes = uint32(notes)
+					} else {
+						scanner.invalid(key, value)
 					}
 				}
 			}
@@ -455,20 +718,95 @@ func Scan(scanner *bufio.Scanner, sequence Sequence) Sequence {
 	}
 
 	if currentPart != nil {
-		finalizePreviousPart(currentPart, blockersList, chordsList)
+		finalizePreviousPart(currentPart, blockersList, chordsList, version, scanner)
 	}
 
-	return sequence
+	return sequence, version, scanner.diagnostics
 }
 
-func finalizePreviousPart(currentPart *arrangement.Part, blockersList map[overlaykey.OverlayPeriodicity][]string, chordsList map[string]*overlays.GridChord) {
+// scanSnapshot reads the sections of a snapshot up to the next snapshot.  The
+// sections are scanned like a file of their own, the lines they are reported
+// on are lines of the file.  It reports whether it stopped at the header of the
+// next snapshot.
+func scanSnapshot(scanner *LineScanner, name string, sequence Sequence, version int) (Snapshot, bool) {
+	snapshot := Snapshot{Name: name, Part: WholeSequence}
+	start := scanner.line
+	var text strings.Builder
+	fmt.Fprintf(&text, "Format Version: %d\n", version)
+
+	var reread, inSections bool
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isSnapshotHeader(line) {
+			reread = true
+			break
+		}
+		if strings.Contains(line, "----------------------") {
+			inSections = true
+		}
+		if key, value, found := strings.Cut(line, ":"); !inSections && found && strings.TrimSpace(key) == "Part" {
+			part, err := strconv.Atoi(strings.TrimSpace(value))
+			switch {
+			case err != nil || part < 0:
+				scanner.invalid("snapshot part", strings.TrimSpace(value))
+			case part >= len(*sequence.Parts):
+				scanner.report("snapshot %q captures part %d, the sequence has %d parts", name, part, len(*sequence.Parts))
+				snapshot.Part = part
+			default:
+				snapshot.Part = part
+			}
+		}
+		text.WriteString(line)
+		text.WriteString("\n")
+	}
+
+	// The notes of a part are checked against the lines and accents of
+	// the sequence, a whole snapshot has its own
+	captured := Sequence{Parts: &[]arrangement.Part{}, Lines: sequence.Lines, Accents: sequence.Accents}
+	if snapshot.IsWhole() {
+		captured = Sequence{Parts: &[]arrangement.Part{}, Lines: []grid.LineDefinition{}, Accents: PatternAccents{Data: []config.Accent{}}}
+	}
+	captured, _, diagnostics := Scan(bufio.NewScanner(strings.NewReader(text.String())), captured)
+	for _, diagnostic := range diagnostics {
+		// The first line scanned is the format version
+		diagnostic.Line += start - 1
+		scanner.diagnostics = append(scanner.diagnostics, diagnostic)
+	}
+
+	snapshot.Parts = *captured.Parts
+	if snapshot.IsWhole() {
+		snapshot.Tempo = captured.Tempo
+		snapshot.Subdivisions = captured.Subdivisions
+		snapshot.Lines = captured.Lines
+		snapshot.Accents = captured.Accents
+	} else if len(snapshot.Parts) != 1 {
+		scanner.report("snapshot %q of a part has %d parts", name, len(snapshot.Parts))
+		snapshot.Parts = nil
+	}
+	return snapshot, reread
+}
+
+func isSnapshotHeader(line string) bool {
+	return strings.Contains(line, "----------------------") && strings.HasPrefix(strings.Trim(line, "- "), "SNAPSHOT ")
+}
+
+// blockerRef is the chord ID of a blocker and the line it was read from
+type blockerRef struct {
+	id   string
+	line int
+}
+
+func finalizePreviousPart(currentPart *arrangement.Part, blockersList map[overlaykey.OverlayPeriodicity][]blockerRef, chordsList map[string]*overlays.GridChord, version int, scanner *LineScanner) {
 	currentOverlay := currentPart.Overlays
 	for currentOverlay != nil {
-		if ids, exists := blockersList[currentOverlay.Key]; exists {
-			for _, id := range ids {
-				blocker := chordsList[id]
-				fmt.Fprintln(os.Stderr, "KEYS", slices.Collect(maps.Keys(chordsList)))
-				fmt.Fprintln(os.Stderr, "Adding blocker", id, "to overlay", currentOverlay.Key, "blocker:", blocker)
+		if refs, exists := blockersList[currentOverlay.Key]; exists {
+			for _, ref := range refs {
+				blocker, defined := chordsList[ref.id]
+				// Version 1 blockers of chords that were not saved are removed by migration
+				if !defined && version > 1 {
+					scanner.diagnostics = append(scanner.diagnostics, Diagnostic{Line: ref.line, Message: fmt.Sprintf("chord ID %q is not defined", ref.id)})
+					continue
+				}
 				currentOverlay.Blockers = append(currentOverlay.Blockers, blocker)
 			}
 		}
@@ -476,6 +814,19 @@ func finalizePreviousPart(currentPart *arrangement.Part, blockersList map[overla
 	}
 }
 
+// parseParams splits comma separated key=value pairs
+func parseParams(paramStr string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimSpace(paramStr), ", ") {
+		keyVal := strings.SplitN(param, "=", 2)
+		if len(keyVal) != 2 {
+			continue
+		}
+		params[strings.TrimSpace(keyVal[0])] = strings.TrimSpace(keyVal[1])
+	}
+	return params
+}
+
 func GetID(line string) string {
 	parts := strings.SplitN(line, ":", 2)
 	if len(parts) != 2 {
@@ -492,7 +843,7 @@ func GetID(line string) string {
 	return value
 }
 
-func ScanArrangement(scanner *bufio.Scanner, currentArrangement *arrangement.Arrangement, indentLevel int) bool {
+func ScanArrangement(scanner *LineScanner, currentArrangement *arrangement.Arrangement, indentLevel int, partCount int) bool {
 	for scanner.Scan() {
 	REREAD:
 		line := scanner.Text()
@@ -514,7 +865,7 @@ func ScanArrangement(scanner *bufio.Scanner, currentArrangement *arrangement.Arr
 			case strings.Contains(sectionLine, "SECTION"):
 				newArrangement := &arrangement.Arrangement{}
 				currentArrangement.Nodes = append(currentArrangement.Nodes, newArrangement)
-				reread := ScanArrangement(scanner, newArrangement, sectionLineIndentLevel)
+				reread := ScanArrangement(scanner, newArrangement, sectionLineIndentLevel, partCount)
 				if reread {
 					goto REREAD
 				} else {
@@ -538,30 +889,83 @@ func ScanArrangement(scanner *bufio.Scanner, currentArrangement *arrangement.Arr
 				if currentArrangement != nil {
 					currentArrangement.Iterations = iterations
 				}
+			} else {
+				scanner.invalid(key, value)
 			}
 		case "Part":
 			if part, err := strconv.Atoi(value); err == nil {
+				if part < 0 || part >= partCount {
+					scanner.report("arrangement section plays part %d, the sequence has %d parts", part, partCount)
+				}
 				if currentArrangement != nil {
 					currentArrangement.Section.Part = part
 				}
+			} else {
+				scanner.invalid(key, value)
 			}
 		case "Cycles":
 			if cycles, err := strconv.Atoi(value); err == nil {
 				if currentArrangement != nil {
 					currentArrangement.Section.Cycles = cycles
 				}
+			} else {
+				scanner.invalid(key, value)
 			}
 		case "StartBeat":
 			if startBeat, err := strconv.Atoi(value); err == nil {
 				if currentArrangement != nil {
 					currentArrangement.Section.StartBeat = startBeat
 				}
+			} else {
+				scanner.invalid(key, value)
 			}
 		case "StartCycles":
 			if startCycles, err := strconv.Atoi(value); err == nil {
 				if currentArrangement != nil {
 					currentArrangement.Section.StartCycles = startCycles
 				}
+			} else {
+				scanner.invalid(key, value)
+			}
+		case "Tempo":
+			if tempo, err := strconv.Atoi(value); err == nil {
+				if currentArrangement != nil {
+					currentArrangement.Section.Tempo = tempo
+				}
+			} else {
+				scanner.invalid(key, value)
+			}
+		case "Subdivisions":
+			if subdivisions, err := strconv.Atoi(value); err == nil {
+				if currentArrangement != nil {
+					currentArrangement.Section.Subdivisions = subdivisions
+				}
+			} else {
+				scanner.invalid(key, value)
+			}
+		case "RampTempo":
+			if rampTempo, err := strconv.Atoi(value); err == nil {
+				if currentArrangement != nil {
+					currentArrangement.Section.Ramp.Tempo = rampTempo
+				}
+			} else {
+				scanner.invalid(key, value)
+			}
+		case "RampCycles":
+			if rampCycles, err := strconv.Atoi(value); err == nil {
+				if currentArrangement != nil {
+					currentArrangement.Section.Ramp.Cycles = rampCycles
+				}
+			} else {
+				scanner.invalid(key, value)
+			}
+		case "RampCurve":
+			if curve, ok := arrangement.ParseCurve(value); ok {
+				if currentArrangement != nil {
+					currentArrangement.Section.Ramp.Curve = curve
+				}
+			} else {
+				scanner.invalid(key, value)
 			}
 		case "KeepCycles":
 			if keepCycles, err := strconv.ParseBool(value); err == nil {
@@ -641,7 +1045,7 @@ func GetGridKey(line string) (grid.GridKey, int) {
 	return gridKey, coordEnd
 }
 
-func noteprops(propStr string) grid.Note {
+func noteprops(propStr string, scanner *LineScanner) grid.Note {
 
 	props := strings.Split(propStr, ", ")
 	note := grid.InitNote()
@@ -665,14 +1069,20 @@ func noteprops(propStr string) grid.Note {
 				case "Hits":
 					if hits, err := strconv.ParseUint(rValue, 10, 8); err == nil {
 						note.Ratchets.Hits = uint8(hits)
+					} else {
+						scanner.invalid("ratchet "+rKey, rValue)
 					}
 				case "Length":
 					if length, err := strconv.ParseUint(rValue, 10, 8); err == nil {
 						note.Ratchets.Length = uint8(length)
+					} else {
+						scanner.invalid("ratchet "+rKey, rValue)
 					}
 				case "Span":
 					if span, err := strconv.ParseUint(rValue, 10, 8); err == nil {
 						note.Ratchets.Span = uint8(span)
+					} else {
+						scanner.invalid("ratchet "+rKey, rValue)
 					}
 				}
 			}
@@ -689,18 +1099,44 @@ func noteprops(propStr string) grid.Note {
 			case "AccentIndex":
 				if accentIdx, err := strconv.ParseUint(value, 10, 8); err == nil {
 					note.AccentIndex = uint8(accentIdx)
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "Action":
 				if action, err := strconv.ParseUint(value, 10, 8); err == nil {
 					note.Action = grid.Action(action)
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "GateIndex":
-				if gateIdx, err := strconv.ParseUint(value, 10, 8); err == nil {
+				if gateIdx, err := strconv.ParseInt(value, 10, 16); err == nil {
 					note.GateIndex = int16(gateIdx)
+				} else {
+					scanner.invalid(key, value)
 				}
 			case "WaitIndex":
 				if waitIdx, err := strconv.ParseUint(value, 10, 8); err == nil {
 					note.WaitIndex = uint8(waitIdx)
+				} else {
+					scanner.invalid(key, value)
+				}
+			case "Probability":
+				if probability, err := strconv.ParseUint(value, 10, 8); err == nil {
+					note.Probability = uint8(probability)
+				} else {
+					scanner.invalid(key, value)
+				}
+			case "Condition":
+				if condition, ok := grid.ParseCondition(value); ok {
+					note.Condition = condition
+				} else {
+					scanner.invalid(key, value)
+				}
+			case "Locks":
+				if locks, ok := grid.ParseLocks(value); ok {
+					note.Locks = locks
+				} else {
+					scanner.invalid(key, value)
 				}
 			}
 		}
@@ -1,13 +1,18 @@
 package sequence
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/chriserin/sq/internal/arrangement"
 	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/controller"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/mappings"
 	"github.com/chriserin/sq/internal/overlaykey"
 	"github.com/chriserin/sq/internal/overlays"
 	"github.com/stretchr/testify/assert"
@@ -276,6 +281,65 @@ func TestReadWrite(t *testing.T) {
 		assert.Equal(t, 2, readDef.Arrangement.Section.Cycles)
 		assert.Equal(t, 1, readDef.Arrangement.Section.StartBeat)
 	})
+
+	t.Run("Controller bindings", func(t *testing.T) {
+		sequence := Sequence{
+			Parts: &[]arrangement.Part{
+				{
+					Name:  "TestPart",
+					Beats: 16,
+				},
+			},
+			Controls: controller.Bindings{
+				{Control: controller.Control{Kind: controller.KindNote, Channel: 10, Number: 36}, Command: mappings.PlayStop},
+				{Control: controller.Control{Kind: controller.KindCC, Channel: 1, Number: 20}, Parameter: controller.ParameterTempo},
+			},
+		}
+
+		filename := filepath.Join(tempDir, "controls.txt")
+		err := Write(sequence, filename)
+		assert.NoError(t, err)
+
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "Control 0: Type=NOTE, Channel=10, Number=36, Command=PlayStop")
+
+		readDef, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, sequence.Controls, readDef.Controls)
+		assert.Len(t, *readDef.Parts, 1)
+	})
+
+	t.Run("Part grooves", func(t *testing.T) {
+		sequence := Sequence{
+			Parts: &[]arrangement.Part{
+				{
+					Name:  "TestPart",
+					Beats: 16,
+					Grooves: groove.Settings{
+						{Name: "Swing 58%", Amount: 100},
+						{Name: "Shuffle", Amount: 40, Lines: []uint8{1, 2}},
+					},
+				},
+			},
+			Grooves: []groove.Groove{{Name: "Shuffle", Steps: []groove.Step{{Offset: 0, Velocity: 100}, {Offset: 20, Velocity: 80}}}},
+		}
+
+		filename := filepath.Join(tempDir, "grooves.txt")
+		err := Write(sequence, filename)
+		assert.NoError(t, err)
+
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "Groove 1: Name=Shuffle, Steps=0/100 20/80")
+		assert.Contains(t, string(content), "Groove: Name=Shuffle, Amount=40, Lines=1 2")
+
+		readDef, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, (*sequence.Parts)[0].Grooves, (*readDef.Parts)[0].Grooves)
+		// Default grooves are saved with the sequence that uses them
+		assert.Equal(t, []groove.Groove{groove.Swing(58), sequence.Grooves[0]}, readDef.Grooves)
+	})
 }
 
 func TestReadFileWithChords(t *testing.T) {
@@ -375,3 +439,89 @@ func TestReadFileWithBlockers(t *testing.T) {
 
 	assert.Equal(t, blockedChord, blocker)
 }
+
+func TestFormatVersion(t *testing.T) {
+	tempDir := t.TempDir()
+
+	blockedSequence := func() Sequence {
+		root := overlays.InitOverlay(overlaykey.ROOT, nil)
+		chord := &overlays.GridChord{Root: grid.GridKey{Line: 2, Beat: 0}, Notes: []overlays.BeatNote{{Beat: 0, Note: grid.InitNote()}}}
+		root.Chords = append(root.Chords, chord)
+		top := overlays.InitOverlay(overlaykey.OverlayPeriodicity{Shift: 2, Interval: 1}, root)
+		top.Blockers = append(top.Blockers, chord, &overlays.GridChord{Root: grid.GridKey{Line: 3, Beat: 1}})
+		return Sequence{
+			Parts: &[]arrangement.Part{{Name: "TestPart", Beats: 8, Overlays: top}},
+		}
+	}
+
+	t.Run("Files start with the format version", func(t *testing.T) {
+		filename := filepath.Join(tempDir, "version.sq")
+		err := Write(blockedSequence(), filename)
+		assert.NoError(t, err)
+
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), fmt.Sprintf("Format Version: %d\n", FormatVersion)))
+	})
+
+	t.Run("Chord IDs are the same for every save", func(t *testing.T) {
+		first := filepath.Join(tempDir, "first.sq")
+		second := filepath.Join(tempDir, "second.sq")
+		assert.NoError(t, Write(blockedSequence(), first))
+		assert.NoError(t, Write(blockedSequence(), second))
+
+		firstContent, err := os.ReadFile(first)
+		assert.NoError(t, err)
+		secondContent, err := os.ReadFile(second)
+		assert.NoError(t, err)
+		assert.Equal(t, string(firstContent), string(secondContent))
+		assert.Contains(t, string(firstContent), "ID: 1/1/1/0:2,0")
+	})
+
+	t.Run("Blockers are read back", func(t *testing.T) {
+		filename := filepath.Join(tempDir, "blockers.sq")
+		assert.NoError(t, Write(blockedSequence(), filename))
+
+		readDef, err := Read(filename)
+		assert.NoError(t, err)
+		top := (*readDef.Parts)[0].Overlays
+		// The blocker of a chord that was not saved is left out
+		if assert.Len(t, top.Blockers, 1) {
+			assert.Same(t, top.Below.Chords[0], top.Blockers[0])
+		}
+	})
+
+	t.Run("Version 1 blockers of unsaved chords are removed", func(t *testing.T) {
+		content, err := os.ReadFile("testdata/checkblockers.sq")
+		assert.NoError(t, err)
+		filename := filepath.Join(tempDir, "version1.sq")
+		unsaved := strings.Replace(string(content), "BLOCKERS --------------------------\nID: 0x1400042eb40", "BLOCKERS --------------------------\nID: 0x1400042eb40\nID: 0x14000000000", 1)
+		assert.NoError(t, os.WriteFile(filename, []byte(unsaved), 0644))
+
+		readDef, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Len(t, (*readDef.Parts)[0].Overlays.Blockers, 1)
+	})
+
+	t.Run("Version 2 files are read", func(t *testing.T) {
+		filename := filepath.Join(tempDir, "version2.sq")
+		assert.NoError(t, Write(blockedSequence(), filename))
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		version2 := strings.Replace(string(content), fmt.Sprintf("Format Version: %d\n", FormatVersion), "Format Version: 2\n", 1)
+		assert.NoError(t, os.WriteFile(filename, []byte(version2), 0644))
+
+		readDef, err := Read(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, "TestPart", (*readDef.Parts)[0].Name)
+		assert.Len(t, migrations, FormatVersion-1)
+	})
+
+	t.Run("Newer versions are not read", func(t *testing.T) {
+		filename := filepath.Join(tempDir, "newer.sq")
+		assert.NoError(t, os.WriteFile(filename, []byte(fmt.Sprintf("Format Version: %d\n", FormatVersion+1)), 0644))
+
+		_, err := Read(filename)
+		assert.Error(t, err)
+	})
+}
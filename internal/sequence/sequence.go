@@ -6,7 +6,9 @@ import (
 
 	"github.com/chriserin/sq/internal/arrangement"
 	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/controller"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/chriserin/sq/internal/operation"
 )
 
@@ -22,6 +24,23 @@ type Sequence struct {
 	Template              string
 	TemplateUIStyle       string
 	TemplateSequencerType operation.SequencerMode
+	Controls              controller.Bindings
+	// Grooves read from the file, used before the grooves of the config
+	Grooves []groove.Groove
+	// Conflicts of a merge are written for the user to resolve, reading
+	// them back reports them as diagnostics
+	Conflicts []string
+	// Snapshots are recalled in place of the parts they captured
+	Snapshots []Snapshot
+}
+
+// FindGroove returns the groove with the given name, from the sequence or else
+// from the config.
+func (s Sequence) FindGroove(name string) (groove.Groove, bool) {
+	if g, ok := groove.Find(s.Grooves, name); ok {
+		return g, true
+	}
+	return config.GetGroove(name)
 }
 
 type PatternAccents struct {
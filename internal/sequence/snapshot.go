@@ -0,0 +1,149 @@
+package sequence
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlays"
+)
+
+// WholeSequence is the part of a snapshot that captured every part.
+const WholeSequence = -1
+
+// Snapshot is a named capture of the sequence, of every part along with the
+// lines, tempo and accents, or of a single part.
+type Snapshot struct {
+	Name string
+	// The index of the captured part, or WholeSequence
+	Part         int
+	Tempo        int
+	Subdivisions int
+	Accents      PatternAccents
+	Lines        []grid.LineDefinition
+	Parts        []arrangement.Part
+}
+
+// IsWhole reports whether the snapshot captured every part.
+func (s Snapshot) IsWhole() bool {
+	return s.Part == WholeSequence
+}
+
+// Equal reports whether two snapshots captured the same state, comparing them
+// as they are written to a file.
+func (s Snapshot) Equal(other Snapshot) bool {
+	var ours, theirs strings.Builder
+	if writeSnapshots(&ours, []Snapshot{s}) != nil || writeSnapshots(&theirs, []Snapshot{other}) != nil {
+		return false
+	}
+	return ours.String() == theirs.String()
+}
+
+// Capture returns a snapshot of the sequence, of every part when part is
+// WholeSequence.
+func (s Sequence) Capture(name string, part int) Snapshot {
+	snapshot := Snapshot{Name: name, Part: part}
+	if part != WholeSequence {
+		snapshot.Parts = []arrangement.Part{CopyPart((*s.Parts)[part])}
+		return snapshot
+	}
+
+	snapshot.Tempo = s.Tempo
+	snapshot.Subdivisions = s.Subdivisions
+	snapshot.Accents = s.Accents
+	snapshot.Accents.Data = slices.Clone(s.Accents.Data)
+	snapshot.Lines = slices.Clone(s.Lines)
+	for _, p := range *s.Parts {
+		snapshot.Parts = append(snapshot.Parts, CopyPart(p))
+	}
+	return snapshot
+}
+
+// Recall puts the captured state of a snapshot in place of the current
+// state.  The parts are changed in place, parts the snapshot does not know of
+// are kept.
+func (s *Sequence) Recall(snapshot Snapshot) {
+	if !snapshot.IsWhole() {
+		if snapshot.Part < len(*s.Parts) && len(snapshot.Parts) > 0 {
+			(*s.Parts)[snapshot.Part] = CopyPart(snapshot.Parts[0])
+		}
+		return
+	}
+
+	s.Tempo = snapshot.Tempo
+	s.Subdivisions = snapshot.Subdivisions
+	s.Accents = snapshot.Accents
+	s.Accents.Data = slices.Clone(snapshot.Accents.Data)
+	s.Lines = slices.Clone(snapshot.Lines)
+	if int(s.Keyline) >= len(s.Lines) {
+		s.Keyline = 0
+	}
+	for i, p := range snapshot.Parts {
+		if i < len(*s.Parts) {
+			(*s.Parts)[i] = CopyPart(p)
+		} else {
+			*s.Parts = append(*s.Parts, CopyPart(p))
+		}
+	}
+}
+
+// FindSnapshot returns the snapshot with the given name.
+func (s Sequence) FindSnapshot(name string) (Snapshot, bool) {
+	i := slices.IndexFunc(s.Snapshots, func(snapshot Snapshot) bool { return snapshot.Name == name })
+	if i < 0 {
+		return Snapshot{}, false
+	}
+	return s.Snapshots[i], true
+}
+
+// SetSnapshot stores a snapshot, in place of a snapshot of the same name.
+func (s *Sequence) SetSnapshot(snapshot Snapshot) {
+	i := slices.IndexFunc(s.Snapshots, func(existing Snapshot) bool { return existing.Name == snapshot.Name })
+	if i < 0 {
+		s.Snapshots = append(s.Snapshots, snapshot)
+	} else {
+		s.Snapshots = slices.Clone(s.Snapshots)
+		s.Snapshots[i] = snapshot
+	}
+}
+
+// RemoveSnapshot removes the snapshot with the given name.
+func (s *Sequence) RemoveSnapshot(name string) {
+	s.Snapshots = slices.DeleteFunc(slices.Clone(s.Snapshots), func(snapshot Snapshot) bool { return snapshot.Name == name })
+}
+
+// CopyPart returns a copy of a part that shares none of its overlays, the
+// blockers of the copy block the copied chords.
+func CopyPart(part arrangement.Part) arrangement.Part {
+	copied := part
+	copied.Grooves = slices.Clone(part.Grooves)
+	copied.Overlays = nil
+
+	chords := make(map[*overlays.GridChord]*overlays.GridChord)
+	var originals, copies []*overlays.Overlay
+	for overlay := part.Overlays; overlay != nil; overlay = overlay.Below {
+		overlayCopy := overlays.DeepCopy(overlay)
+		for i, chord := range overlay.Chords {
+			chords[chord] = overlayCopy.Chords[i]
+		}
+		if len(copies) > 0 {
+			copies[len(copies)-1].Below = overlayCopy
+		}
+		originals = append(originals, overlay)
+		copies = append(copies, overlayCopy)
+	}
+
+	for i, overlay := range originals {
+		for j, blocker := range overlay.Blockers {
+			if chord, exists := chords[blocker]; exists {
+				copies[i].Blockers[j] = chord
+			}
+		}
+	}
+
+	if len(copies) > 0 {
+		copied.Overlays = copies[0]
+	}
+	return copied
+}
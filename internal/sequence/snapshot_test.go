@@ -0,0 +1,133 @@
+package sequence
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("Recall puts back the captured state", func(t *testing.T) {
+		s := validSequence()
+		snapshot := s.Capture("verse A", WholeSequence)
+
+		s.Tempo = 90
+		s.Lines = s.Lines[:1]
+		(*s.Parts)[0].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 0}, grid.InitNote())
+		assert.Len(t, snapshot.Parts[0].Overlays.Notes, 1)
+
+		s.Recall(snapshot)
+		assert.Equal(t, 120, s.Tempo)
+		assert.Len(t, s.Lines, 2)
+		assert.NotContains(t, (*s.Parts)[0].Overlays.Notes, grid.GridKey{Line: 0, Beat: 0})
+
+		// Edits after a recall leave the snapshot as it was
+		(*s.Parts)[0].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 1}, grid.InitNote())
+		assert.Len(t, snapshot.Parts[0].Overlays.Notes, 1)
+	})
+
+	t.Run("A part snapshot recalls only its part", func(t *testing.T) {
+		s := validSequence()
+		*s.Parts = append(*s.Parts, arrangement.InitPart("Part 2"))
+		snapshot := s.Capture("dense", 1)
+		(*s.Parts)[1].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 0}, grid.InitNote())
+		(*s.Parts)[0].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 0}, grid.InitNote())
+		s.Tempo = 90
+
+		s.Recall(snapshot)
+		assert.Empty(t, (*s.Parts)[1].Overlays.Notes)
+		assert.Contains(t, (*s.Parts)[0].Overlays.Notes, grid.GridKey{Line: 0, Beat: 0})
+		assert.Equal(t, 90, s.Tempo)
+	})
+
+	t.Run("Snapshots are stored by name", func(t *testing.T) {
+		s := validSequence()
+		s.SetSnapshot(s.Capture("verse A", WholeSequence))
+		s.Tempo = 90
+		s.SetSnapshot(s.Capture("verse A", WholeSequence))
+		s.SetSnapshot(s.Capture("verse B", WholeSequence))
+
+		assert.Len(t, s.Snapshots, 2)
+		snapshot, exists := s.FindSnapshot("verse A")
+		assert.True(t, exists)
+		assert.Equal(t, 90, snapshot.Tempo)
+
+		s.RemoveSnapshot("verse A")
+		_, exists = s.FindSnapshot("verse A")
+		assert.False(t, exists)
+	})
+
+	t.Run("Copied blockers block the copied chords", func(t *testing.T) {
+		root := overlays.InitOverlay(overlaykey.ROOT, nil)
+		chord := &overlays.GridChord{Root: grid.GridKey{Line: 1, Beat: 0}}
+		root.Chords = append(root.Chords, chord)
+		top := overlays.InitOverlay(overlaykey.OverlayPeriodicity{Shift: 2, Interval: 1}, root)
+		top.Blockers = append(top.Blockers, chord)
+
+		copied := CopyPart(arrangement.Part{Overlays: top})
+		assert.NotSame(t, chord, copied.Overlays.Below.Chords[0])
+		assert.Same(t, copied.Overlays.Below.Chords[0], copied.Overlays.Blockers[0])
+	})
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	snapshotted := func() Sequence {
+		s := validSequence()
+		s.SetSnapshot(s.Capture("verse A", WholeSequence))
+		(*s.Parts)[0].Overlays.AddNote(grid.GridKey{Line: 0, Beat: 3}, grid.InitNote())
+		s.SetSnapshot(s.Capture("verse B dense", 0))
+		return s
+	}
+
+	for _, extension := range []string{"sq", "json", "yaml"} {
+		t.Run("Snapshots are read back from "+extension, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "snapshots."+extension)
+			assert.NoError(t, Write(snapshotted(), filename))
+
+			readDef, err := ReadStrict(filename)
+			assert.NoError(t, err)
+			assert.NotNil(t, readDef.Arrangement)
+			assert.Len(t, *readDef.Parts, 1)
+			if assert.Len(t, readDef.Snapshots, 2) {
+				verseA, verseB := readDef.Snapshots[0], readDef.Snapshots[1]
+				assert.Equal(t, "verse A", verseA.Name)
+				assert.True(t, verseA.IsWhole())
+				assert.Equal(t, 120, verseA.Tempo)
+				assert.Len(t, verseA.Lines, 2)
+				assert.Len(t, verseA.Parts[0].Overlays.Notes, 1)
+				assert.Equal(t, "verse B dense", verseB.Name)
+				assert.Equal(t, 0, verseB.Part)
+				assert.Len(t, verseB.Parts[0].Overlays.Notes, 2)
+			}
+		})
+	}
+
+	t.Run("Problems of a snapshot are reported on the lines of the file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "snapshots.sq")
+		assert.NoError(t, Write(snapshotted(), filename))
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+
+		lines := strings.Split(string(content), "\n")
+		// The last note of the file is in the part snapshot
+		number := 0
+		for i := len(lines) - 1; i >= 0 && number == 0; i-- {
+			if strings.HasPrefix(lines[i], "GridKey(0,3)") {
+				lines[i] = "GridKey(5,3): AccentIndex=1"
+				number = i + 1
+			}
+		}
+		assert.NoError(t, os.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644))
+
+		diagnostics, err := Validate(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, Diagnostics{{Line: number, Message: "line 5 is out of range, the sequence has 2 lines"}}, diagnostics)
+	})
+}
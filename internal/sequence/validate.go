@@ -0,0 +1,98 @@
+package sequence
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+)
+
+// Diagnostic is a problem found on a line of a sequence file.
+type Diagnostic struct {
+	Line    int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d: %s", d.Line, d.Message)
+}
+
+// Diagnostics are the problems of a sequence file, in the order of the lines.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	messages := make([]string, len(ds))
+	for i, d := range ds {
+		messages[i] = d.String()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// LineScanner counts the lines of a file while scanning so that values that
+// cannot be read are reported with the line they are on.
+type LineScanner struct {
+	*bufio.Scanner
+	line        int
+	diagnostics Diagnostics
+}
+
+func (s *LineScanner) Scan() bool {
+	if s.Scanner.Scan() {
+		s.line++
+		return true
+	}
+	return false
+}
+
+func (s *LineScanner) report(format string, a ...any) {
+	s.diagnostics = append(s.diagnostics, Diagnostic{Line: s.line, Message: fmt.Sprintf(format, a...)})
+}
+
+func (s *LineScanner) invalid(key, value string) {
+	s.report("invalid %s %q", key, value)
+}
+
+// checkNote reports the values of a note that are outside the range of the
+// sequence.
+func (s *LineScanner) checkNote(note grid.Note, sequence Sequence) {
+	if len(sequence.Accents.Data) > 0 && int(note.AccentIndex) >= len(sequence.Accents.Data) {
+		s.report("accent index %d is out of range, the sequence has %d accents", note.AccentIndex, len(sequence.Accents.Data))
+	}
+	// Sequences are played with gates up to 32 beats long
+	gates := len(config.ShortGates) + len(config.GetGateLengths(32))
+	if note.GateIndex < 0 || int(note.GateIndex) >= gates {
+		s.report("gate index %d is out of range, there are %d gates", note.GateIndex, gates)
+	}
+	if int(note.WaitIndex) >= len(config.WaitPercentages) {
+		s.report("wait index %d is out of range, there are %d waits", note.WaitIndex, len(config.WaitPercentages))
+	}
+	if note.Probability > 100 {
+		s.report("probability %d is out of range, it is a percentage", note.Probability)
+	}
+}
+
+// checkGridKey reports a grid key on a line the sequence does not have or on
+// a beat no part reaches, and whether the key is in range.  Like the accents,
+// the lines are only a range when the file has them.
+func (s *LineScanner) checkGridKey(gridKey grid.GridKey, sequence Sequence) bool {
+	inRange := true
+	if len(sequence.Lines) > 0 && int(gridKey.Line) >= len(sequence.Lines) {
+		s.report("line %d is out of range, the sequence has %d lines", gridKey.Line, len(sequence.Lines))
+		inRange = false
+	}
+	// Parts are at most 127 beats long
+	if gridKey.Beat >= 127 {
+		s.report("beat %d is out of range, parts have up to 127 beats", gridKey.Beat)
+		inRange = false
+	}
+	return inRange
+}
+
+// Validate returns the problems of a sequence file.  A file that cannot be
+// opened is an error, not a diagnostic.
+func Validate(filename string) (Diagnostics, error) {
+	_, diagnostics, err := read(filename)
+	return diagnostics, err
+}
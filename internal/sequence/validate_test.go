@@ -0,0 +1,154 @@
+package sequence
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlaykey"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/stretchr/testify/assert"
+)
+
+func validSequence() Sequence {
+	overlay := overlays.InitOverlay(overlaykey.ROOT, nil)
+	overlay.AddNote(grid.GridKey{Line: 1, Beat: 2}, grid.InitNote())
+	parts := []arrangement.Part{{Name: "Part 1", Beats: 8, Overlays: overlay}}
+	return Sequence{
+		Parts:       &parts,
+		Arrangement: InitArrangement(parts),
+		Tempo:       120,
+		Lines: []grid.LineDefinition{
+			{Channel: 10, Note: 36, MsgType: grid.MessageTypeNote, Name: "Kick"},
+			{Channel: 10, Note: 38, MsgType: grid.MessageTypeNote, Name: "Snare"},
+		},
+		Accents: PatternAccents{Data: []config.Accent{0, 120, 100, 80, 60, 40, 20, 10, 5}, Start: 120, End: 5, Target: AccentTargetVelocity},
+	}
+}
+
+// writeBroken writes a valid sequence with a line of it replaced and returns
+// the file and the line number of the replacement
+func writeBroken(t *testing.T, old, new string) (string, int) {
+	filename := filepath.Join(t.TempDir(), "broken.sq")
+	assert.NoError(t, Write(validSequence(), filename))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+
+	lines := strings.Split(string(content), "\n")
+	number := 0
+	for i, line := range lines {
+		if line == old {
+			lines[i] = new
+			number = i + 1
+			break
+		}
+	}
+	assert.NotZero(t, number, "line %q not found", old)
+	assert.NoError(t, os.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644))
+	return filename, number
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("A written sequence is valid", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "valid.sq")
+		assert.NoError(t, Write(validSequence(), filename))
+
+		diagnostics, err := Validate(filename)
+		assert.NoError(t, err)
+		assert.Empty(t, diagnostics)
+	})
+
+	t.Run("Test files are valid", func(t *testing.T) {
+		for _, filename := range []string{"testdata/checkchord.sq", "testdata/checkblockers.sq"} {
+			diagnostics, err := Validate(filename)
+			assert.NoError(t, err)
+			assert.Empty(t, diagnostics, filename)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		message string
+	}{
+		{"Tempo", "Tempo: 120", "Tempo: fast", `invalid Tempo "fast"`},
+		{"Accent value", "Accent 1: Value=120", "Accent 1: Value=200", `invalid accent value "200"`},
+		{"Overlay key", "Shift: 1", "Shift: one", `overlay key Shift "one" does not parse`},
+		{"Note line", "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "GridKey(2,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "line 2 is out of range, the sequence has 2 lines"},
+		{"Note beat", "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "GridKey(1,200): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "beat 200 is out of range, parts have up to 127 beats"},
+		{"Accent index", "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "GridKey(1,2): AccentIndex=9, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "accent index 9 is out of range, the sequence has 9 accents"},
+		{"Gate index", "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=300, WaitIndex=0", "gate index 300 is out of range, there are 256 gates"},
+		{"Grid key", "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", "GridKey(1): AccentIndex=5", `note "GridKey(1): AccentIndex=5" does not parse`},
+		{"Missing part", "  Part: 0", "  Part: 3", "arrangement section plays part 3, the sequence has 1 parts"},
+		{"Undefined chord", "------------------------ BLOCKERS --------------------------", "------------------------ BLOCKERS --------------------------\nID: 1/1/1/0:0,0", `chord ID "1/1/1/0:0,0" is not defined`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename, number := writeBroken(t, tt.old, tt.new)
+			if strings.HasPrefix(tt.new, tt.old+"\n") {
+				number++
+			}
+
+			diagnostics, err := Validate(filename)
+			assert.NoError(t, err)
+			assert.Equal(t, Diagnostics{{Line: number, Message: tt.message}}, diagnostics)
+		})
+	}
+
+	t.Run("Notes outside the grid are left out", func(t *testing.T) {
+		for _, broken := range []string{
+			"GridKey(2,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0",
+			"GridKey(1,200): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0",
+		} {
+			filename, _ := writeBroken(t, "GridKey(1,2): AccentIndex=5, Ratchets={Hits:1,Length:0,Span:0}, Action=0, GateIndex=0, WaitIndex=0", broken)
+
+			readDef, err := Read(filename)
+			assert.NoError(t, err)
+			assert.Empty(t, (*readDef.Parts)[0].Overlays.Notes, broken)
+		}
+	})
+
+	t.Run("Strict reading fails with the diagnostics", func(t *testing.T) {
+		filename, number := writeBroken(t, "Tempo: 120", "Tempo: fast")
+
+		_, err := ReadStrict(filename)
+		var diagnostics Diagnostics
+		assert.ErrorAs(t, err, &diagnostics)
+		assert.Len(t, diagnostics, 1)
+		assert.Equal(t, fmt.Sprintf(`line %d: invalid Tempo "fast"`, number), err.Error())
+
+		_, err = Read(filename)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := Validate(filepath.Join(t.TempDir(), "missing.sq"))
+		assert.Error(t, err)
+	})
+}
+
+func TestConflicts(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "conflicts.sq")
+	conflicted := validSequence()
+	conflicted.Conflicts = []string{"Tempo: Ours=100, Theirs=90"}
+	assert.NoError(t, Write(conflicted, filename))
+
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "Conflict 0: Tempo: Ours=100, Theirs=90")
+
+	diagnostics, err := Validate(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, Diagnostics{{Line: 4, Message: "unresolved merge conflict Tempo: Ours=100, Theirs=90"}}, diagnostics)
+
+	readDef, err := Read(filename)
+	assert.NoError(t, err)
+	assert.Empty(t, readDef.Conflicts)
+}
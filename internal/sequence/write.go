@@ -6,11 +6,15 @@ import (
 	"os"
 	"slices"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/log"
 
 	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/controller"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/chriserin/sq/internal/overlays"
 )
 
@@ -20,6 +24,10 @@ func Write(sequence Sequence, filename string) error {
 
 	log.SetLevel(log.FatalLevel)
 
+	if format := FormatOf(filename); format != FormatText {
+		return writeDocument(sequence, filename, format)
+	}
+
 	f, err := os.Create(filename)
 	if err != nil {
 		log.Error("Failed to create file", "filename", filename, "error", err)
@@ -32,6 +40,14 @@ func Write(sequence Sequence, filename string) error {
 		return nil
 	}
 
+	// Write the version of the file format
+	fmt.Fprintf(f, "Format Version: %d\n\n", FormatVersion)
+
+	// Write the conflicts of a merge
+	if err := writeConflicts(f, sequence.Conflicts); err != nil {
+		return err
+	}
+
 	// Write global sequencer settings
 	if err := writeSettings(f, &sequence); err != nil {
 		return err
@@ -47,6 +63,16 @@ func Write(sequence Sequence, filename string) error {
 		return err
 	}
 
+	// Write controller bindings
+	if err := writeControls(f, sequence.Controls); err != nil {
+		return err
+	}
+
+	// Write the grooves the parts use
+	if err := writeGrooves(f, &sequence); err != nil {
+		return err
+	}
+
 	// Write parts
 	if err := writeParts(f, *sequence.Parts); err != nil {
 		return err
@@ -59,6 +85,11 @@ func Write(sequence Sequence, filename string) error {
 		}
 	}
 
+	// Write snapshots
+	if err := writeSnapshots(f, sequence.Snapshots); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -76,6 +107,21 @@ func writeSettings(w io.Writer, def *Sequence) error {
 	return nil
 }
 
+// writeConflicts writes the conflicts of a merge at the top of the file
+func writeConflicts(w io.Writer, conflicts []string) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "------------------------ CONFLICTS ------------------------")
+	for i, conflict := range conflicts {
+		fmt.Fprintf(w, "Conflict %d: %s\n", i, conflict)
+	}
+	fmt.Fprintln(w, "")
+
+	return nil
+}
+
 // writeLineSequences writes all line sequences
 func writeLineSequences(w io.Writer, lines []grid.LineDefinition) error {
 	if len(lines) == 0 {
@@ -84,8 +130,17 @@ func writeLineSequences(w io.Writer, lines []grid.LineDefinition) error {
 
 	fmt.Fprintln(w, "------------------------- LINES -------------------------")
 	for i, line := range lines {
-		fmt.Fprintf(w, "Line %d: Channel=%d, Note=%d, MessageType=%d, Name=%s\n",
-			i, line.Channel, line.Note, line.MsgType, line.Name)
+		// The length and division are written only when they are set, and
+		// before the name which is the rest of the line
+		var clock strings.Builder
+		if line.Length > 0 {
+			fmt.Fprintf(&clock, "Length=%d, ", line.Length)
+		}
+		if line.Division != 0 {
+			fmt.Fprintf(&clock, "Division=%s, ", line.Division)
+		}
+		fmt.Fprintf(w, "Line %d: Channel=%d, Note=%d, MessageType=%d, %sName=%s\n",
+			i, line.Channel, line.Note, line.MsgType, clock.String(), line.Name)
 	}
 	fmt.Fprintln(w, "")
 
@@ -122,6 +177,75 @@ func writeAccents(w io.Writer, accents PatternAccents) error {
 	return nil
 }
 
+// writeControls writes the bindings of a midi controller
+func writeControls(w io.Writer, bindings controller.Bindings) error {
+	if len(bindings) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "------------------------ CONTROLS ------------------------")
+	for i, binding := range bindings {
+		control := binding.Control
+		if binding.Parameter != controller.ParameterNone {
+			fmt.Fprintf(w, "Control %d: Type=%s, Channel=%d, Number=%d, Parameter=%s\n",
+				i, control.Kind, control.Channel, control.Number, binding.Parameter)
+		} else {
+			fmt.Fprintf(w, "Control %d: Type=%s, Channel=%d, Number=%d, Command=%s\n",
+				i, control.Kind, control.Channel, control.Number, binding.Command)
+		}
+	}
+	fmt.Fprintln(w, "")
+
+	return nil
+}
+
+// writeGrooves writes the grooves used by the parts so that a sequence plays
+// the same without the config it was made with
+func writeGrooves(w io.Writer, def *Sequence) error {
+	grooves := usedGrooves(def)
+	if len(grooves) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "------------------------ GROOVES ------------------------")
+	for i, g := range grooves {
+		steps := make([]string, len(g.Steps))
+		for j, step := range g.Steps {
+			steps[j] = fmt.Sprintf("%d/%d", step.Offset, step.Velocity)
+		}
+		fmt.Fprintf(w, "Groove %d: Name=%s, Steps=%s\n", i, g.Name, strings.Join(steps, " "))
+	}
+	fmt.Fprintln(w, "")
+
+	return nil
+}
+
+// usedGrooves returns the grooves the parts and the parts of the snapshots
+// use, from the sequence or else from the config
+func usedGrooves(def *Sequence) []groove.Groove {
+	parts := slices.Clone(*def.Parts)
+	for _, snapshot := range def.Snapshots {
+		parts = append(parts, snapshot.Parts...)
+	}
+
+	var names []string
+	for _, part := range parts {
+		for _, name := range part.Grooves.Names() {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var grooves []groove.Groove
+	for _, name := range names {
+		if g, ok := def.FindGroove(name); ok {
+			grooves = append(grooves, g)
+		}
+	}
+	return grooves
+}
+
 // writeParts writes all parts to the provided writer
 func writeParts(w io.Writer, parts []arrangement.Part) error {
 	fmt.Fprintln(w, "--------------------------- PARTS ---------------------------")
@@ -136,8 +260,22 @@ func writeParts(w io.Writer, parts []arrangement.Part) error {
 		fmt.Fprintln(w, separator)
 		fmt.Fprintf(w, "Name: %s\n", part.Name)
 		fmt.Fprintf(w, "Beats: %d\n", part.Beats)
+		if part.Meter.IsSet() {
+			fmt.Fprintf(w, "Meter: %s\n", part.Meter)
+		}
+		for _, setting := range part.Grooves {
+			fmt.Fprintf(w, "Groove: Name=%s, Amount=%d", setting.Name, setting.Amount)
+			if len(setting.Lines) > 0 {
+				lines := make([]string, len(setting.Lines))
+				for j, line := range setting.Lines {
+					lines[j] = strconv.Itoa(int(line))
+				}
+				fmt.Fprintf(w, ", Lines=%s", strings.Join(lines, " "))
+			}
+			fmt.Fprintln(w, "")
+		}
 
-		if err := writeOverlays(w, part.Overlays); err != nil {
+		if err := writeOverlays(w, part.Overlays, chordIDs(part.Overlays)); err != nil {
 			return err
 		}
 
@@ -196,6 +334,19 @@ func writeArrangementNode(w io.Writer, node *arrangement.Arrangement, depth int,
 		fmt.Fprintf(w, "%sCycles: %d\n", indent, node.Section.Cycles)
 		fmt.Fprintf(w, "%sStartBeat: %d\n", indent, node.Section.StartBeat)
 		fmt.Fprintf(w, "%sStartCycles: %d\n", indent, node.Section.StartCycles)
+		// The tempo of a section is written only when it is set, and before
+		// KeepCycles which ends the section
+		if node.Section.Tempo > 0 {
+			fmt.Fprintf(w, "%sTempo: %d\n", indent, node.Section.Tempo)
+		}
+		if node.Section.Subdivisions > 0 {
+			fmt.Fprintf(w, "%sSubdivisions: %d\n", indent, node.Section.Subdivisions)
+		}
+		if node.Section.Ramp.Tempo > 0 {
+			fmt.Fprintf(w, "%sRampTempo: %d\n", indent, node.Section.Ramp.Tempo)
+			fmt.Fprintf(w, "%sRampCycles: %d\n", indent, node.Section.Ramp.Cycles)
+			fmt.Fprintf(w, "%sRampCurve: %s\n", indent, node.Section.Ramp.Curve)
+		}
 		fmt.Fprintf(w, "%sKeepCycles: %t\n", indent, node.Section.KeepCycles)
 	}
 
@@ -220,8 +371,64 @@ func writeArrangementNode(w io.Writer, node *arrangement.Arrangement, depth int,
 	return nil
 }
 
+// writeSnapshots writes each snapshot with the sections of the state it
+// captured, after the sections of the sequence
+func writeSnapshots(w io.Writer, snapshots []Snapshot) error {
+	for _, snapshot := range snapshots {
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "------------------------ SNAPSHOT %s ------------------------\n", snapshot.Name)
+		if !snapshot.IsWhole() {
+			fmt.Fprintf(w, "Part: %d\n", snapshot.Part)
+			fmt.Fprintln(w, "")
+		} else {
+			fmt.Fprintln(w, "------------------------ GLOBAL SETTINGS ------------------------")
+			fmt.Fprintf(w, "Tempo: %d\n", snapshot.Tempo)
+			fmt.Fprintf(w, "Subdivisions: %d\n", snapshot.Subdivisions)
+			fmt.Fprintln(w, "")
+
+			if err := writeLineSequences(w, snapshot.Lines); err != nil {
+				return err
+			}
+			if err := writeAccents(w, snapshot.Accents); err != nil {
+				return err
+			}
+		}
+
+		if err := writeParts(w, snapshot.Parts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chordIDs names every chord of an overlay tree by its overlay key and root so
+// that the IDs only change when the chord moves.  Blockers refer to chords of
+// the overlays below by these IDs.
+func chordIDs(overlay *overlays.Overlay) map[*overlays.GridChord]string {
+	ids := make(map[*overlays.GridChord]string)
+	taken := make(map[string]bool)
+	for current := overlay; current != nil; current = current.Below {
+		chords := slices.Clone(current.Chords)
+		slices.SortStableFunc(chords, func(a, b *overlays.GridChord) int {
+			return grid.Compare(a.Root, b.Root)
+		})
+		for _, gridChord := range chords {
+			key := current.Key
+			id := fmt.Sprintf("%d/%d/%d/%d:%d,%d", key.Shift, key.Interval, key.Width, key.StartCycle, gridChord.Root.Line, gridChord.Root.Beat)
+			// Chords sharing a root are numbered in the order of the overlay
+			for n := 2; taken[id]; n++ {
+				id = fmt.Sprintf("%d/%d/%d/%d:%d,%d#%d", key.Shift, key.Interval, key.Width, key.StartCycle, gridChord.Root.Line, gridChord.Root.Beat, n)
+			}
+			taken[id] = true
+			ids[gridChord] = id
+		}
+	}
+	return ids
+}
+
 // writeOverlays writes the overlay tree structure recursively
-func writeOverlays(w io.Writer, overlay *overlays.Overlay) error {
+func writeOverlays(w io.Writer, overlay *overlays.Overlay, ids map[*overlays.GridChord]string) error {
 	if overlay == nil {
 		return nil
 	}
@@ -239,22 +446,22 @@ func writeOverlays(w io.Writer, overlay *overlays.Overlay) error {
 
 	fmt.Fprintln(w, "------------------------ CHORDS --------------------------")
 	if len(overlay.Chords) > 0 {
-		slices.SortFunc(overlay.Chords, func(a, b *overlays.GridChord) int {
+		slices.SortStableFunc(overlay.Chords, func(a, b *overlays.GridChord) int {
 			return grid.Compare(a.Root, b.Root)
 		})
 
 		for _, gridChord := range overlay.Chords {
 			fmt.Fprintln(w, "------------------------ CHORD --------------------------")
-			fmt.Fprintln(w, "ID:", fmt.Sprintf("%p", gridChord))
+			fmt.Fprintln(w, "ID:", ids[gridChord])
 			fmt.Fprintf(w, "GridKey(%d,%d): Arpeggio=%d, Notes=%d\n", gridChord.Root.Line, gridChord.Root.Beat, gridChord.Arpeggio, gridChord.Chord.Notes)
 
 			fmt.Fprintln(w, "------------------------ BEATNOTES --------------------------")
 			for _, beatNote := range gridChord.Notes {
 				note := beatNote.Note
-				fmt.Fprintf(w, "Beat(%d): AccentIndex=%d, Ratchets={Hits:%d,Length:%d,Span:%d}, Action=%d, GateIndex=%d, WaitIndex=%d\n",
+				fmt.Fprintf(w, "Beat(%d): AccentIndex=%d, Ratchets={Hits:%d,Length:%d,Span:%d}, Action=%d, GateIndex=%d, WaitIndex=%d%s\n",
 					beatNote.Beat,
 					note.AccentIndex, note.Ratchets.Hits, note.Ratchets.Length, note.Ratchets.Span,
-					note.Action, note.GateIndex, note.WaitIndex)
+					note.Action, note.GateIndex, note.WaitIndex, trigProps(note))
 			}
 		}
 	} else {
@@ -262,13 +469,18 @@ func writeOverlays(w io.Writer, overlay *overlays.Overlay) error {
 	}
 
 	fmt.Fprintln(w, "------------------------ BLOCKERS --------------------------")
-	if len(overlay.Blockers) > 0 {
-		slices.SortFunc(overlay.Chords, func(a, b *overlays.GridChord) int {
-			return grid.Compare(a.Root, b.Root)
+	// A blocker of a chord that is no longer in any overlay blocks nothing
+	blockers := slices.DeleteFunc(slices.Clone(overlay.Blockers), func(gridChord *overlays.GridChord) bool {
+		_, exists := ids[gridChord]
+		return !exists
+	})
+	if len(blockers) > 0 {
+		slices.SortFunc(blockers, func(a, b *overlays.GridChord) int {
+			return strings.Compare(ids[a], ids[b])
 		})
 
-		for _, gridChord := range overlay.Blockers {
-			fmt.Fprintln(w, "ID:", fmt.Sprintf("%p", gridChord))
+		for _, gridChord := range blockers {
+			fmt.Fprintln(w, "ID:", ids[gridChord])
 		}
 	} else {
 		fmt.Fprintln(w, "(empty)")
@@ -293,10 +505,10 @@ func writeOverlays(w io.Writer, overlay *overlays.Overlay) error {
 
 		for _, k := range gridKeys {
 			note := overlay.Notes[k]
-			fmt.Fprintf(w, "GridKey(%d,%d): AccentIndex=%d, Ratchets={Hits:%d,Length:%d,Span:%d}, Action=%d, GateIndex=%d, WaitIndex=%d\n",
+			fmt.Fprintf(w, "GridKey(%d,%d): AccentIndex=%d, Ratchets={Hits:%d,Length:%d,Span:%d}, Action=%d, GateIndex=%d, WaitIndex=%d%s\n",
 				k.Line, k.Beat,
 				note.AccentIndex, note.Ratchets.Hits, note.Ratchets.Length, note.Ratchets.Span,
-				note.Action, note.GateIndex, note.WaitIndex)
+				note.Action, note.GateIndex, note.WaitIndex, trigProps(note))
 		}
 	} else {
 		fmt.Fprintln(w, "(empty)")
@@ -304,8 +516,25 @@ func writeOverlays(w io.Writer, overlay *overlays.Overlay) error {
 
 	// Recursively process the overlay below this one
 	if overlay.Below != nil {
-		writeOverlays(w, overlay.Below)
+		writeOverlays(w, overlay.Below, ids)
 	}
 
 	return nil
 }
+
+// trigProps returns the chance, the condition and the parameter locks of a
+// note, written only when they are set so that the notes of files without them
+// are unchanged.
+func trigProps(note grid.Note) string {
+	var props strings.Builder
+	if note.Probability != 0 {
+		fmt.Fprintf(&props, ", Probability=%d", note.Probability)
+	}
+	if note.Condition != grid.ConditionNone {
+		fmt.Fprintf(&props, ", Condition=%s", note.Condition)
+	}
+	if note.Locks.Count > 0 {
+		fmt.Fprintf(&props, ", Locks=%s", note.Locks)
+	}
+	return props.String()
+}
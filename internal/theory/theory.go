@@ -5,6 +5,8 @@
 // and harmonic analysis for polyphonic sequencing.
 package theory
 
+import "slices"
+
 func InitChord(alteration uint32) Chord {
 	return Chord{Notes: alteration}
 }
@@ -532,3 +534,45 @@ func (c Chord) Name() string {
 
 	return baseName
 }
+
+// DetectRoot finds the root of notes played together.  The root is the pitch
+// class that forms a triad with the other notes, placed at or below the lowest
+// note, so an inverted triad keeps its root.  Without a triad the lowest note
+// is the root.
+func DetectRoot(notes []uint8) uint8 {
+	if len(notes) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(notes)
+	slices.Sort(sorted)
+	lowest := sorted[0]
+	for _, note := range sorted {
+		below := (lowest%12 + 12 - note%12) % 12
+		if below > lowest {
+			continue
+		}
+		root := lowest - below
+		pitchClasses := uint32(0)
+		for _, note := range sorted {
+			pitchClasses |= 1 << ((note - root) % 12)
+		}
+		for _, triad := range triads {
+			if ContainsBits(pitchClasses, triad) {
+				return root
+			}
+		}
+	}
+	return lowest
+}
+
+// Alteration returns the chord the notes form above root.  Notes below the
+// root or more than 31 semitones above it are left out.
+func Alteration(root uint8, notes []uint8) uint32 {
+	alteration := uint32(0)
+	for _, note := range notes {
+		if note >= root && note-root < 32 {
+			alteration |= 1 << (note - root)
+		}
+	}
+	return alteration
+}
@@ -524,3 +524,41 @@ func TestChordName(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		notes    []uint8
+		expected uint8
+	}{
+		{name: "Root position major triad", notes: []uint8{60, 64, 67}, expected: 60},
+		{name: "First inversion keeps the root below", notes: []uint8{64, 67, 72}, expected: 60},
+		{name: "Second inversion keeps the root below", notes: []uint8{67, 72, 76}, expected: 60},
+		{name: "Minor seventh in any order", notes: []uint8{67, 57, 60, 64}, expected: 57},
+		{name: "Interval without a triad", notes: []uint8{62, 69}, expected: 62},
+		{name: "Single note", notes: []uint8{40}, expected: 40},
+		{name: "Root would be below the lowest midi note", notes: []uint8{2, 5, 10}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DetectRoot(tt.notes))
+		})
+	}
+}
+
+func TestAlteration(t *testing.T) {
+	t.Run("Root position", func(t *testing.T) {
+		assert.Equal(t, MajorTriad, Alteration(60, []uint8{60, 64, 67}))
+	})
+
+	t.Run("Inversion above the root", func(t *testing.T) {
+		chord := InitChord(MajorTriad)
+		chord.NextInversion()
+		assert.Equal(t, chord.Notes, Alteration(60, []uint8{64, 67, 72}))
+	})
+
+	t.Run("Notes out of range are left out", func(t *testing.T) {
+		assert.Equal(t, Root|Perfect5, Alteration(60, []uint8{59, 60, 67, 100}))
+	})
+}
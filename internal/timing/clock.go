@@ -0,0 +1,95 @@
+package timing
+
+import (
+	"bytes"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/playstate"
+	midi "gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/drivers"
+)
+
+// A standard midi clock sends 24 pulses per quarter note and counts the
+// song position in midi beats, which are sixteenth notes of 6 pulses.
+const ClockPPQN = 24
+const pulsesPerMidiBeat = 6
+
+// The largest value a 14 bit song position pointer can hold
+const maxSongPosition = 1<<14 - 1
+
+// Clock sends a standard midi clock that drum machines, clock modules and
+// DAWs can follow.
+type Clock struct {
+	out drivers.Out
+}
+
+// Start begins playback on the receiving device.  Playback from the top of
+// the song is a Start, anywhere else is a Song Position Pointer followed by
+// a Continue.
+func (clock Clock) Start(songPosition uint16) error {
+	if songPosition == 0 {
+		err := clock.out.Send(midi.Start())
+		if err != nil {
+			return fault.Wrap(err, fmsg.With("cannot send midi clock start"))
+		}
+		return nil
+	}
+
+	err := clock.out.Send(midi.SPP(songPosition))
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot send midi clock spp"))
+	}
+	err = clock.out.Send(midi.Continue())
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot send midi clock continue"))
+	}
+	return nil
+}
+
+func (clock Clock) Stop() error {
+	err := clock.out.Send(midi.Stop())
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot send midi clock stop"))
+	}
+	return nil
+}
+
+func (clock Clock) Pulse() error {
+	err := clock.out.Send(midi.TimingClock())
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot send midi clock pulse"))
+	}
+	return nil
+}
+
+// SongPosition converts a tick measured at PPQN to a song position in midi
+// beats, rounding down to the previous sixteenth note.
+func SongPosition(tick int64) uint16 {
+	position := tick * ClockPPQN / (int64(PPQN) * pulsesPerMidiBeat)
+	return uint16(max(min(position, maxSongPosition), 0))
+}
+
+// 0x7D is the sysex manufacturer id reserved for non-commercial use,
+// followed by "sq" so that other non-commercial messages are ignored.
+var loopModeHeader = []byte{0x7D, 's', 'q'}
+
+// LoopModeMessage is sent from one sq instance to another ahead of a Start so
+// that the receiver plays with the same loop mode.
+func LoopModeMessage(loopMode playstate.LoopMode) midi.Message {
+	return midi.SysEx(append(bytes.Clone(loopModeHeader), byte(loopMode)))
+}
+
+// GetLoopMode returns true if the message is a loop mode message, extracting
+// the loop mode.
+func GetLoopMode(message midi.Message, loopMode *playstate.LoopMode) bool {
+	var data []byte
+	if !message.GetSysEx(&data) {
+		return false
+	}
+	if len(data) != len(loopModeHeader)+1 || !bytes.HasPrefix(data, loopModeHeader) {
+		return false
+	}
+	*loopMode = playstate.LoopMode(data[len(loopModeHeader)])
+	return true
+}
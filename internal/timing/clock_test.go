@@ -0,0 +1,75 @@
+package timing
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/stretchr/testify/assert"
+	midi "gitlab.com/gomidi/midi/v2"
+)
+
+type testOut struct {
+	messages []midi.Message
+}
+
+func (out *testOut) Open() error     { return nil }
+func (out *testOut) Close() error    { return nil }
+func (out *testOut) IsOpen() bool    { return true }
+func (out *testOut) Number() int     { return 0 }
+func (out *testOut) String() string  { return "test" }
+func (out *testOut) Underlying() any { return nil }
+func (out *testOut) Send(b []byte) error {
+	out.messages = append(out.messages, midi.Message(b))
+	return nil
+}
+
+func TestClockStart(t *testing.T) {
+	t.Run("top of the song starts", func(t *testing.T) {
+		out := &testOut{}
+		err := Clock{out}.Start(0)
+		assert.NoError(t, err)
+		assert.Equal(t, []midi.Message{midi.Start()}, out.messages)
+	})
+
+	t.Run("anywhere else continues from the song position", func(t *testing.T) {
+		out := &testOut{}
+		err := Clock{out}.Start(16)
+		assert.NoError(t, err)
+		assert.Equal(t, []midi.Message{midi.SPP(16), midi.Continue()}, out.messages)
+	})
+}
+
+func TestSongPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		tick     int64
+		expected uint16
+	}{
+		{"start", 0, 0},
+		{"one sixteenth", int64(PPQN / 4), 1},
+		{"one bar", int64(PPQN * 4), 16},
+		{"rounds down between sixteenths", int64(PPQN/4) - 1, 0},
+		{"clamped to 14 bits", int64(PPQN) * 10000, maxSongPosition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SongPosition(tt.tick))
+		})
+	}
+}
+
+func TestLoopModeMessage(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		var loopMode playstate.LoopMode
+		assert.True(t, GetLoopMode(LoopModeMessage(playstate.LoopPart), &loopMode))
+		assert.Equal(t, playstate.LoopPart, loopMode)
+	})
+
+	t.Run("other messages are ignored", func(t *testing.T) {
+		var loopMode playstate.LoopMode
+		assert.False(t, GetLoopMode(midi.SysEx([]byte{0x7D, 0x01, 0x02, 0x03}), &loopMode))
+		assert.False(t, GetLoopMode(midi.SPP(2), &loopMode))
+		assert.Equal(t, playstate.OneTimeWholeSequence, loopMode)
+	})
+}
@@ -0,0 +1,102 @@
+package timing
+
+import (
+	"time"
+)
+
+// Average the intervals of one quarter note of pulses so that jitter in
+// the incoming clock does not reach the gate and ratchet timing.
+const smoothingPulses = ClockPPQN
+
+// An interval this long means the clock was stopped rather than slowed.
+const maxPulseInterval = time.Second
+
+// The tempo assumed until enough pulses have arrived to measure it.
+const defaultFollowTempo = 120
+
+// ClockFollower places the pulses of a standard 24 PPQN midi clock on the
+// grid.  It keeps the song position in ticks at PPQN, which the pulses of the
+// clock advance by PPQN/ClockPPQN at a time, and the smoothed interval between
+// pulses, which is used to place beats that fall between pulses.  Each beat is
+// placed one beat length after the beat before it, so that sections with their
+// own subdivisions keep their place on the grid.
+type ClockFollower struct {
+	tick      int64
+	nextBeat  int64
+	lastPulse time.Time
+	intervals []time.Duration
+}
+
+// Start moves the song position to the top of the song.
+func (cf *ClockFollower) Start() {
+	cf.tick = 0
+	cf.nextBeat = 0
+}
+
+// SetSongPosition moves the song position to a Song Position Pointer given in
+// midi beats.  The next beat is at the song position until SetNextBeat places
+// it on the grid of the sequence.
+func (cf *ClockFollower) SetSongPosition(midiBeats uint16) {
+	cf.tick = int64(midiBeats) * int64(PPQN) * pulsesPerMidiBeat / ClockPPQN
+	cf.nextBeat = cf.tick
+}
+
+// SetNextBeat places the next beat on a tick at or after the song position.
+func (cf *ClockFollower) SetNextBeat(tick int64) {
+	cf.nextBeat = max(tick, cf.tick)
+}
+
+// SongPosition returns the song position in ticks at PPQN.
+func (cf ClockFollower) SongPosition() int64 {
+	return cf.tick
+}
+
+// Measure records the time a pulse was received.  The clock is measured even
+// while stopped so that the tempo is known when playback starts.
+func (cf *ClockFollower) Measure(now time.Time) {
+	if !cf.lastPulse.IsZero() {
+		interval := now.Sub(cf.lastPulse)
+		if interval > maxPulseInterval {
+			cf.intervals = cf.intervals[:0]
+		} else {
+			cf.intervals = append(cf.intervals, interval)
+			if len(cf.intervals) > smoothingPulses {
+				cf.intervals = cf.intervals[1:]
+			}
+		}
+	}
+	cf.lastPulse = now
+}
+
+// Advance moves the song position forward by one pulse and returns the
+// delays, from the pulse, of the beats that fall before the next pulse.  The
+// subdivisions are those of the beat being played.
+func (cf *ClockFollower) Advance(subdivisions int) []time.Duration {
+	pulseTicks := int64(PPQN / ClockPPQN)
+	beatTicks := int64(PPQN / subdivisions)
+	interval := cf.PulseInterval()
+
+	delays := make([]time.Duration, 0, 1)
+	for ; cf.nextBeat < cf.tick+pulseTicks; cf.nextBeat += beatTicks {
+		delays = append(delays, time.Duration(cf.nextBeat-cf.tick)*interval/time.Duration(pulseTicks))
+	}
+	cf.tick += pulseTicks
+	return delays
+}
+
+// PulseInterval returns the average interval between the most recent pulses.
+func (cf ClockFollower) PulseInterval() time.Duration {
+	if len(cf.intervals) == 0 {
+		return time.Minute / (defaultFollowTempo * ClockPPQN)
+	}
+	var total time.Duration
+	for _, interval := range cf.intervals {
+		total += interval
+	}
+	return total / time.Duration(len(cf.intervals))
+}
+
+// BeatInterval returns the length of a beat of the grid at the measured tempo.
+func (cf ClockFollower) BeatInterval(subdivisions int) time.Duration {
+	return cf.PulseInterval() * ClockPPQN / time.Duration(subdivisions)
+}
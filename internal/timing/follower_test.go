@@ -0,0 +1,121 @@
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockFollowerAdvance(t *testing.T) {
+	pulse := time.Minute / (defaultFollowTempo * ClockPPQN)
+
+	t.Run("two subdivisions place a beat every 12 pulses", func(t *testing.T) {
+		follower := ClockFollower{}
+		var beats int
+		for range ClockPPQN {
+			delays := follower.Advance(2)
+			for _, delay := range delays {
+				assert.Equal(t, time.Duration(0), delay)
+			}
+			beats += len(delays)
+		}
+		assert.Equal(t, 2, beats)
+		assert.Equal(t, int64(PPQN), follower.SongPosition())
+	})
+
+	t.Run("five subdivisions place beats between pulses", func(t *testing.T) {
+		follower := ClockFollower{}
+		var delays []time.Duration
+		for range 4 {
+			delays = append(delays, follower.Advance(5)...)
+		}
+		// The second beat is 168 ticks in, 28 ticks past the fifth pulse at 140
+		assert.Equal(t, []time.Duration{0}, delays)
+		delays = follower.Advance(5)
+		assert.Equal(t, []time.Duration{28 * pulse / 35}, delays)
+	})
+
+	t.Run("three subdivisions land on every eighth pulse", func(t *testing.T) {
+		follower := ClockFollower{}
+		var beats int
+		for range ClockPPQN {
+			beats += len(follower.Advance(3))
+		}
+		assert.Equal(t, 3, beats)
+	})
+
+	t.Run("a change of subdivisions steps from the last beat", func(t *testing.T) {
+		follower := ClockFollower{}
+		var pulses []int
+		for pulse := range ClockPPQN {
+			subdivisions := 3
+			if pulse >= 8 {
+				subdivisions = 2
+			}
+			if len(follower.Advance(subdivisions)) > 0 {
+				pulses = append(pulses, pulse)
+			}
+		}
+		assert.Equal(t, []int{0, 8, 20}, pulses)
+	})
+
+	t.Run("song position between beats waits for the next beat", func(t *testing.T) {
+		follower := ClockFollower{}
+		follower.SetSongPosition(1)
+		follower.SetNextBeat(int64(PPQN / 2))
+		var pulses []int
+		for pulse := range 12 {
+			if len(follower.Advance(2)) > 0 {
+				pulses = append(pulses, pulse)
+			}
+		}
+		assert.Equal(t, []int{6}, pulses)
+	})
+}
+
+func TestClockFollowerSongPosition(t *testing.T) {
+	follower := ClockFollower{}
+	follower.SetSongPosition(4)
+	// 4 midi beats are one quarter note
+	assert.Equal(t, int64(PPQN), follower.SongPosition())
+	follower.Start()
+	assert.Equal(t, int64(0), follower.SongPosition())
+}
+
+func TestClockFollowerMeasure(t *testing.T) {
+	t.Run("default tempo before pulses arrive", func(t *testing.T) {
+		follower := ClockFollower{}
+		assert.Equal(t, ClockPPQN*follower.PulseInterval(), follower.BeatInterval(1))
+		assert.InDelta(t, 500*time.Millisecond, follower.BeatInterval(1), float64(time.Microsecond))
+	})
+
+	t.Run("averages pulse intervals", func(t *testing.T) {
+		follower := ClockFollower{}
+		now := time.Now()
+		follower.Measure(now)
+		follower.Measure(now.Add(10 * time.Millisecond))
+		follower.Measure(now.Add(30 * time.Millisecond))
+		assert.Equal(t, 15*time.Millisecond, follower.PulseInterval())
+	})
+
+	t.Run("keeps one quarter note of intervals", func(t *testing.T) {
+		follower := ClockFollower{}
+		now := time.Now()
+		for i := range 2 * smoothingPulses {
+			now = now.Add(time.Duration(i) * time.Millisecond)
+			follower.Measure(now)
+		}
+		assert.Len(t, follower.intervals, smoothingPulses)
+	})
+
+	t.Run("a gap in the clock starts measuring again", func(t *testing.T) {
+		follower := ClockFollower{}
+		now := time.Now()
+		follower.Measure(now)
+		follower.Measure(now.Add(10 * time.Millisecond))
+		follower.Measure(now.Add(2 * time.Second))
+		follower.Measure(now.Add(2*time.Second + 20*time.Millisecond))
+		assert.Equal(t, 20*time.Millisecond, follower.PulseInterval())
+	})
+}
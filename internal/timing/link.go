@@ -0,0 +1,225 @@
+package timing
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chriserin/sq/internal/beats"
+	"github.com/chriserin/sq/internal/link"
+	"github.com/chriserin/sq/internal/playstate"
+)
+
+// LinkLoop shares tempo, beat phase and start/stop with the other sq instances
+// on the local network.  Playing starts every peer on the next bar of the
+// shared timeline, stopping stops every peer and a tempo change on any peer
+// changes the tempo of every peer.
+func (t *Timing) LinkLoop(sendFn func(tea.Msg)) error {
+	conn, err := link.Listen()
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot join link session"))
+	}
+	messageChannel := make(chan link.Message)
+	go conn.Receive(t.ctx, messageChannel)
+
+	session := link.NewSession(link.NewNodeID(), defaultFollowTempo, link.MonotonicClock())
+	var follower LinkFollower
+
+	send := func(messages ...link.Message) {
+		for _, message := range messages {
+			err := conn.Send(message)
+			if err != nil {
+				sendFn(ErrorMsg{err})
+			}
+		}
+	}
+
+	var beatTimer *time.Timer
+	// A beat timer stopped too late to keep it from firing is ignored
+	// by its generation
+	var generation int
+	tickChannel := make(chan int)
+	schedule := func() {
+		if beatTimer != nil {
+			beatTimer.Stop()
+		}
+		generation++
+		if !follower.playing || t.subdivisions == 0 {
+			return
+		}
+		current := generation
+		beatTimer = time.AfterFunc(max(session.Until(follower.NextBeat(t.subdivisions)), 0), func() {
+			tickChannel <- current
+		})
+	}
+
+	peers := 0
+	update := func(changed bool, local bool) {
+		if session.Peers() != peers {
+			peers = session.Peers()
+			sendFn(LinkPeersMsg{Peers: peers})
+		}
+		if !changed {
+			return
+		}
+		state := session.State()
+		if state.Timeline.Tempo != t.tempo && !local {
+			t.tempo = state.Timeline.Tempo
+			sendFn(UITempoMsg{Tempo: t.tempo})
+		}
+		if state.Playing && !follower.playing {
+			follower.Start(state.StartBeat, session.BeatNow(), t.subdivisions)
+			if !local {
+				sendFn(UIStartMsg{LoopMode: playstate.LoopWholeSequence, SongPosition: follower.SongPosition(t.subdivisions)})
+			}
+		} else if !state.Playing && follower.playing {
+			follower.playing = false
+			if !local {
+				sendFn(UIStopMsg{})
+			}
+		}
+		schedule()
+	}
+
+	setSubdivisions := func(subdivisions int) bool {
+		if t.subdivisions == subdivisions {
+			return false
+		}
+		t.subdivisions = subdivisions
+		if follower.playing {
+			follower.Resume(session.BeatNow(), t.subdivisions)
+		}
+		return true
+	}
+
+	// Only a tempo set by hand is shared.  Section tempos and ramps stay
+	// local, otherwise every peer would push its own sections onto the others.
+	sequenceTempo := 0
+	shareTempo := func(tempo int) bool {
+		if tempo == sequenceTempo {
+			return false
+		}
+		sequenceTempo = tempo
+		t.tempo = tempo
+		message, ok := session.SetTempo(tempo)
+		if ok {
+			send(message)
+		}
+		return ok
+	}
+
+	heartbeat := time.NewTicker(link.HeartbeatInterval)
+	send(session.Heartbeat()...)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(os.Stderr, "Recovered in timing link loop from panic: %v\n", r)
+				debug.PrintStack()
+			}
+		}()
+		var command TimingMsg
+		for {
+			select {
+			case <-t.ctx.Done():
+				heartbeat.Stop()
+				conn.Close()
+				return
+			case <-heartbeat.C:
+				send(session.Heartbeat()...)
+				update(false, false)
+			case message := <-messageChannel:
+				replies, changed := session.Receive(message)
+				send(replies...)
+				update(changed, false)
+			case tickGeneration := <-tickChannel:
+				if tickGeneration != generation || !follower.playing {
+					continue
+				}
+				if t.preRollBeats == 0 {
+					tempo := session.State().Timeline.Tempo
+					t.beatsLooper.BeatChannel <- beats.BeatMsg{Interval: time.Minute / time.Duration(tempo*t.subdivisions)}
+				} else {
+					t.preRollBeats--
+				}
+				follower.Advance()
+				schedule()
+			case tempo := <-t.beatsLooper.TempoChannel:
+				update(setSubdivisions(tempo.Subdivisions), true)
+			case command = <-timingChannel:
+				switch command := command.(type) {
+				case StartMsg:
+					t.subdivisions = command.Subdivisions
+					t.preRollBeats = command.Prerollbeats
+					shareTempo(command.SequenceTempo)
+					message, ok := session.Start()
+					if ok {
+						send(message)
+					}
+					update(ok, true)
+				case StopMsg:
+					message, ok := session.Stop()
+					if ok {
+						send(message)
+					}
+					update(ok, true)
+				case TempoMsg:
+					subdivisionsChanged := setSubdivisions(command.Subdivisions)
+					tempoChanged := shareTempo(command.SequenceTempo)
+					update(subdivisionsChanged || tempoChanged, true)
+				case QuitMsg:
+					heartbeat.Stop()
+					send(session.Leave())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// LinkFollower places the beats of the grid on the timeline of a link
+// session, counting them from the beat the session started on.
+type LinkFollower struct {
+	playing   bool
+	startBeat float64
+	beat      int64
+}
+
+// Start begins counting grid beats from startBeat.  A peer that joins a
+// session that is already playing picks up from the next grid beat.
+func (lf *LinkFollower) Start(startBeat float64, now float64, subdivisions int) {
+	lf.playing = true
+	lf.startBeat = startBeat
+	lf.beat = 0
+	lf.Resume(now, subdivisions)
+}
+
+// Resume moves to the first grid beat at or after now, which is needed when
+// joining late or when the subdivisions change.
+func (lf *LinkFollower) Resume(now float64, subdivisions int) {
+	if subdivisions > 0 && now > lf.startBeat {
+		lf.beat = int64(math.Ceil((now - lf.startBeat) * float64(subdivisions)))
+	}
+}
+
+func (lf *LinkFollower) Advance() {
+	lf.beat++
+}
+
+// NextBeat returns the beat of the timeline the next grid beat is on.
+func (lf LinkFollower) NextBeat(subdivisions int) float64 {
+	return lf.startBeat + float64(lf.beat)/float64(subdivisions)
+}
+
+// SongPosition returns the position of the next grid beat in ticks at PPQN.
+func (lf LinkFollower) SongPosition(subdivisions int) int64 {
+	if subdivisions == 0 {
+		return 0
+	}
+	return lf.beat * int64(PPQN/subdivisions)
+}
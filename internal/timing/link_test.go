@@ -0,0 +1,32 @@
+package timing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkFollower(t *testing.T) {
+	t.Run("counts grid beats from the start beat", func(t *testing.T) {
+		follower := LinkFollower{}
+		follower.Start(8, 7.5, 2)
+		assert.Equal(t, float64(8), follower.NextBeat(2))
+		follower.Advance()
+		assert.Equal(t, 8.5, follower.NextBeat(2))
+		assert.Equal(t, int64(0), LinkFollower{}.SongPosition(2))
+	})
+
+	t.Run("joining late picks up from the next grid beat", func(t *testing.T) {
+		follower := LinkFollower{}
+		follower.Start(8, 9.6, 4)
+		assert.Equal(t, float64(9.75), follower.NextBeat(4))
+		assert.Equal(t, 7*int64(PPQN/4), follower.SongPosition(4))
+	})
+
+	t.Run("subdivision change keeps the phase", func(t *testing.T) {
+		follower := LinkFollower{}
+		follower.Start(8, 8, 4)
+		follower.Resume(9.1, 3)
+		assert.InDelta(t, 9+1.0/3, follower.NextBeat(3), 1e-9)
+	})
+}
@@ -13,14 +13,14 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/chriserin/sq/internal/beats"
 	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/renderer"
 	"github.com/chriserin/sq/internal/seqmidi"
 	midi "gitlab.com/gomidi/midi/v2"
 	"gitlab.com/gomidi/midi/v2/drivers"
 )
 
-// NOTE: PPQN is Pulses Per Quarter Note
-// NOTE: 840 is the common multiple of 2, 3, 5, 7, 8. The allowable subdivisions.
-var PPQN = 840
+// PPQN is the resolution the renderer places events on and pulses count at
+var PPQN = renderer.PPQN
 
 func (t *Timing) BeatInterval() time.Duration {
 	tickInterval := t.TickInterval()
@@ -38,6 +38,24 @@ func (t Tick) ReceiverBeatInterval(subdivisions int) time.Duration {
 	return time.Minute / time.Duration(t.tempo*subdivisions)
 }
 
+// FollowTempo changes to the tempo and subdivisions of the section about to
+// play.
+func (t *Timing) FollowTempo(tempo beats.TempoMsg) {
+	t.tempo = tempo.Tempo
+	t.subdivisions = tempo.Subdivisions
+}
+
+// BeatDue reports whether a beat starts on the current pulse.  Beats are
+// counted from the last beat rather than from the start, so that a change of
+// subdivisions takes effect with the next beat.
+func (t *Timing) BeatDue() bool {
+	if t.pulseCount == 0 || t.pulseCount-t.beatPulse >= PPQN/t.subdivisions {
+		t.beatPulse = t.pulseCount
+		return true
+	}
+	return false
+}
+
 func (t Timing) PulseInterval() time.Duration {
 	return time.Minute / time.Duration(t.tempo*PPQN)
 }
@@ -54,7 +72,9 @@ type Timing struct {
 	started      bool
 	pulseCount   int
 	pulseLimit   int
+	beatPulse    int
 	preRollBeats uint8
+	songPosition uint16
 	transmitting bool
 	beatsLooper  beats.BeatsLooper
 	ctx          context.Context
@@ -66,6 +86,8 @@ const (
 	MlmStandAlone MidiLoopMode = iota
 	MlmTransmitter
 	MlmReceiver
+	MlmClockReceiver
+	MlmLink
 )
 
 var timingChannel chan TimingMsg
@@ -105,6 +127,30 @@ func Loop(mode MidiLoopMode, lockReceiverChannel, unlockReceiverChannel chan boo
 			}()
 			return fault.Wrap(err, fmsg.With("cannot start receiver loop"))
 		}
+	case MlmClockReceiver:
+		timing.StandAloneLoop(sendFn)
+		err := timing.ClockReceiverLoop(lockReceiverChannel, unlockReceiverChannel, sendFn, midiConnection)
+		if err != nil {
+			// NOTE: In case the clock receiver loop was not setup correctly, swallow the lock/unlock messages
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-lockReceiverChannel:
+					case <-unlockReceiverChannel:
+					}
+				}
+			}()
+			return fault.Wrap(err, fmsg.With("cannot start clock receiver loop"))
+		}
+	case MlmLink:
+		err := timing.LinkLoop(sendFn)
+		if err != nil {
+			// Without a network, play alone
+			timing.StandAloneLoop(sendFn)
+			return fault.Wrap(err, fmsg.With("cannot start link loop"))
+		}
 	}
 	return nil
 }
@@ -114,10 +160,10 @@ type Transmitter struct {
 }
 
 func (tmtr Transmitter) Start(loopMode playstate.LoopMode) error {
-	message := midi.SPP(uint16(loopMode))
+	message := LoopModeMessage(loopMode)
 	err := tmtr.out.Send(message)
 	if err != nil {
-		return fault.Wrap(err, fmsg.With("cannot send midi spp pre-start"))
+		return fault.Wrap(err, fmsg.With("cannot send loop mode pre-start"))
 	}
 	message = midi.Start()
 	err = tmtr.out.Send(message)
@@ -163,6 +209,11 @@ func (t *Timing) TransmitterLoop(sendFn func(tea.Msg), midiConnection *seqmidi.M
 	if err != nil {
 		return fault.Wrap(err)
 	}
+	clockOut, err := midiConnection.ClockOut()
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot open clock out"))
+	}
+	clock := Clock{clockOut}
 
 	tickChannel := make(chan Tick)
 	activeSenseChannel := make(chan bool)
@@ -203,6 +254,7 @@ func (t *Timing) TransmitterLoop(sendFn func(tea.Msg), midiConnection *seqmidi.M
 					t.pulseCount = 0
 					t.pulseLimit = 0
 					t.preRollBeats = command.Prerollbeats
+					t.songPosition = command.SongPosition
 					t.transmitting = command.Transmitting
 					pulse(0)
 					err := transmitter.Start(command.LoopMode)
@@ -216,6 +268,10 @@ func (t *Timing) TransmitterLoop(sendFn func(tea.Msg), midiConnection *seqmidi.M
 					if err != nil {
 						sendFn(ErrorMsg{err})
 					}
+					err = clock.Stop()
+					if err != nil {
+						sendFn(ErrorMsg{err})
+					}
 					// m.playing should be false now.
 				case AnticipatoryStopMsg:
 					//NOTE: A receiver must not receive a Pulse message and a Stop message in immediate succession.
@@ -228,10 +284,26 @@ func (t *Timing) TransmitterLoop(sendFn func(tea.Msg), midiConnection *seqmidi.M
 					t.tempo = command.Tempo
 					t.subdivisions = command.Subdivisions
 				}
+			case tempo := <-t.beatsLooper.TempoChannel:
+				// The clock pulses follow the tempo, which passes it on to
+				// receivers and clock followers
+				t.FollowTempo(tempo)
 			case <-tickChannel:
 				if t.started {
-					if t.pulseCount%(PPQN/24) == 0 {
+					// The clock runs through the pre-roll so that the receiving device
+					// can lock to the tempo, playback starts on the first beat.
+					if t.preRollBeats == 0 && t.pulseCount == 0 {
+						err := clock.Start(t.songPosition)
+						if err != nil {
+							sendFn(ErrorMsg{err})
+						}
+					}
+					if t.pulseCount%(PPQN/ClockPPQN) == 0 {
 						clockChannel <- beats.ClockMsg{}
+						err := clock.Pulse()
+						if err != nil {
+							sendFn(ErrorMsg{err})
+						}
 					}
 					if t.preRollBeats == 0 {
 						if t.pulseLimit == 0 || t.pulseCount < t.pulseLimit {
@@ -243,7 +315,7 @@ func (t *Timing) TransmitterLoop(sendFn func(tea.Msg), midiConnection *seqmidi.M
 								}
 							}
 						}
-						if t.pulseCount%(PPQN/t.subdivisions) == 0 {
+						if t.BeatDue() {
 							tickInterval := t.TickInterval()
 							beatChannel <- beats.BeatMsg{Interval: tickInterval}
 						}
@@ -300,10 +372,8 @@ func (t *Timing) ReceiverLoop(lockReceiverChannel, unlockReceiverChannel chan bo
 			var ReceiverFunc seqmidi.ReceiverFunc = func(msg []byte, milliseconds int32) {
 				midiMessage := midi.Message(msg)
 				switch midiMessage.Type() {
-				case midi.SPPMsg:
-					var ref uint16
-					midiMessage.GetSPP(&ref)
-					loopMode = playstate.LoopMode(ref)
+				case midi.SysExMsg:
+					GetLoopMode(midiMessage, &loopMode)
 				case midi.StartMsg:
 					timingClockTime = time.Time{}
 					receiverChannel <- StartMsg{LoopMode: loopMode}
@@ -380,10 +450,12 @@ func (t *Timing) ReceiverLoop(lockReceiverChannel, unlockReceiverChannel chan bo
 						activeSenseTimer.Stop()
 						midiConnection.StopFn()
 					}
+				case tempo := <-t.beatsLooper.TempoChannel:
+					t.FollowTempo(tempo)
 				case pulseTiming := <-tickChannel:
 					activeSenseTimer.Reset(330 * time.Millisecond)
 					if t.started {
-						if t.pulseCount%(PPQN/t.subdivisions) == 0 {
+						if t.BeatDue() {
 							beatChannel <- beats.BeatMsg{Interval: pulseTiming.ReceiverBeatInterval(t.subdivisions)}
 						}
 						t.pulseCount++
@@ -402,6 +474,122 @@ func (t *Timing) ReceiverLoop(lockReceiverChannel, unlockReceiverChannel chan bo
 	return nil
 }
 
+// ClockReceiverLoop follows a standard 24 PPQN midi clock from any device,
+// such as a DAW, a drum machine or a hardware master clock.  Start plays from
+// the top of the song while Continue plays from the last Song Position
+// Pointer, or from where the clock stopped.
+func (t *Timing) ClockReceiverLoop(lockReceiverChannel, unlockReceiverChannel chan bool, sendFn func(tea.Msg), midiConnection *seqmidi.MidiConnection) error {
+	var beatChannel = t.beatsLooper.BeatChannel
+	messageChannel := make(chan midi.Message)
+	pulseChannel := make(chan time.Time)
+	var receiverFunc seqmidi.ReceiverFunc = func(msg []byte, milliseconds int32) {
+		midiMessage := midi.Message(msg)
+		if midiMessage.Is(midi.TimingClockMsg) {
+			pulseChannel <- time.Now()
+		} else {
+			messageChannel <- midiMessage
+		}
+	}
+	err := midiConnection.ListenToClock(receiverFunc)
+	if err != nil {
+		return fault.Wrap(err, fmsg.With("cannot listen to clock source"))
+	}
+
+	connected := false
+	disconnectedChannel := make(chan bool)
+	connectedTimer := time.AfterFunc(maxPulseInterval, func() {
+		disconnectedChannel <- true
+	})
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(os.Stderr, "Recovered in timing clock receiver loop from panic: %v\n", r)
+				debug.PrintStack()
+			}
+		}()
+		var follower ClockFollower
+		var following bool
+		var command TimingMsg
+		for {
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-lockReceiverChannel:
+				// Playing alone, ignore the clock until stopped
+				midiConnection.StopReceivingFromClock()
+				midiConnection.DoNotListen = true
+				<-unlockReceiverChannel
+				midiConnection.DoNotListen = false
+				err := midiConnection.ListenToClock(receiverFunc)
+				if err != nil {
+					sendFn(ErrorMsg{err})
+				}
+			case midiMessage := <-messageChannel:
+				switch midiMessage.Type() {
+				case midi.StartMsg:
+					follower.Start()
+					following = true
+					sendFn(UIStartMsg{LoopMode: playstate.LoopWholeSequence})
+				case midi.ContinueMsg:
+					following = true
+					sendFn(UIStartMsg{LoopMode: playstate.LoopWholeSequence, SongPosition: follower.SongPosition()})
+				case midi.StopMsg:
+					following = false
+					sendFn(UIStopMsg{})
+				case midi.SPPMsg:
+					// Song position may only be moved while stopped
+					var pointer uint16
+					if !following && midiMessage.GetSPP(&pointer) {
+						follower.SetSongPosition(pointer)
+						sendFn(UISongPositionMsg{SongPosition: follower.SongPosition()})
+					}
+				}
+			case now := <-pulseChannel:
+				follower.Measure(now)
+				connectedTimer.Reset(maxPulseInterval)
+				if !connected {
+					connected = true
+					sendFn(TransmitterConnectedMsg{})
+				}
+				if following && t.subdivisions > 0 {
+					beatMsg := beats.BeatMsg{Interval: follower.BeatInterval(t.subdivisions)}
+					for _, delay := range follower.Advance(t.subdivisions) {
+						if delay == 0 {
+							beatChannel <- beatMsg
+						} else {
+							time.AfterFunc(delay, func() {
+								beatChannel <- beatMsg
+							})
+						}
+					}
+				}
+			case <-disconnectedChannel:
+				connected = false
+				sendFn(TransmitterNotConnectedMsg{})
+			case tempo := <-t.beatsLooper.TempoChannel:
+				// The tempo comes from the clock, the subdivisions of the
+				// sections are followed
+				t.FollowTempo(tempo)
+			case command = <-timingChannel:
+				switch command := command.(type) {
+				case TempoMsg:
+					t.tempo = command.Tempo
+					t.subdivisions = command.Subdivisions
+				case NextBeatMsg:
+					if !following {
+						follower.SetNextBeat(command.Tick)
+					}
+				case QuitMsg:
+					connectedTimer.Stop()
+					midiConnection.StopReceivingFromClock()
+				}
+			}
+		}
+	}()
+	return nil
+}
+
 func (t *Timing) StandAloneLoop(sendFn func(tea.Msg)) {
 	var beatChannel = t.beatsLooper.BeatChannel
 	tickChannel := make(chan Tick)
@@ -445,6 +633,8 @@ func (t *Timing) StandAloneLoop(sendFn func(tea.Msg)) {
 					t.tempo = command.Tempo
 					t.subdivisions = command.Subdivisions
 				}
+			case tempo := <-t.beatsLooper.TempoChannel:
+				t.FollowTempo(tempo)
 			case <-tickChannel:
 				if t.started {
 					adjustedInterval := t.BeatInterval()
@@ -465,8 +655,11 @@ type StartMsg struct {
 	Transmitting bool
 	LoopMode     playstate.LoopMode
 	Prerollbeats uint8
+	SongPosition uint16
 	Tempo        int
 	Subdivisions int
+	// SequenceTempo is the tempo set by hand, without section tempos and ramps
+	SequenceTempo int
 }
 
 type StopMsg struct{}
@@ -474,8 +667,15 @@ type AnticipatoryStopMsg struct{}
 type QuitMsg struct{}
 
 type TempoMsg struct {
-	Tempo        int
-	Subdivisions int
+	Tempo         int
+	Subdivisions  int
+	SequenceTempo int
+}
+
+// NextBeatMsg carries the tick of the first beat of the sequence at or after
+// a song position.
+type NextBeatMsg struct {
+	Tick int64
 }
 
 type ErrorMsg struct {
@@ -483,7 +683,26 @@ type ErrorMsg struct {
 }
 
 type UIStopMsg struct{}
-type UIStartMsg struct{ LoopMode playstate.LoopMode }
+type UIStartMsg struct {
+	LoopMode playstate.LoopMode
+	// Song position in ticks at PPQN to start from
+	SongPosition int64
+}
+
+// UISongPositionMsg asks for the first beat at or after a song position in
+// ticks at PPQN, received from the clock while stopped.
+type UISongPositionMsg struct {
+	SongPosition int64
+}
+
+// UITempoMsg carries the tempo of a link session after a peer changed it.
+type UITempoMsg struct {
+	Tempo int
+}
+
+type LinkPeersMsg struct {
+	Peers int
+}
 
 type TransmitterConnectedMsg struct{}
 type TransmitterNotConnectedMsg struct{}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/autosave"
+	"github.com/chriserin/sq/internal/library"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// LibraryDirectory returns the project directory sq was started on, or else
+// the directory of the current sequence file.
+func (m model) LibraryDirectory() string {
+	if m.directory != "" {
+		return m.directory
+	}
+	return filepath.Dir(m.filename)
+}
+
+// ScanLibrary lists the sequence files of the library directory, choosing
+// the current sequence file.
+func (m *model) ScanLibrary() {
+	entries, err := library.Scan(m.LibraryDirectory())
+	if err != nil {
+		m.SetCurrentError(err)
+	}
+	m.libraryEntries = entries
+	index := slices.IndexFunc(entries, func(entry library.Entry) bool { return entry.Filename == m.filename })
+	if index >= 0 {
+		m.libraryIndex = index
+	}
+	m.ClampLibraryIndex()
+}
+
+func (m *model) ClampLibraryIndex() {
+	m.libraryIndex = max(min(m.libraryIndex, len(m.libraryEntries)-1), 0)
+}
+
+func (m *model) IncreaseLibraryIndex() {
+	m.libraryIndex++
+	m.ClampLibraryIndex()
+}
+
+func (m *model) DecreaseLibraryIndex() {
+	m.libraryIndex--
+	m.ClampLibraryIndex()
+}
+
+// OpenLibraryEntry opens the sequence file chosen in the library.
+func (m model) OpenLibraryEntry() model {
+	if m.libraryIndex >= len(m.libraryEntries) {
+		return m
+	}
+	entry := m.libraryEntries[m.libraryIndex]
+	if entry.Err != nil {
+		m.SetCurrentError(fault.Wrap(entry.Err, fmsg.WithDesc("could not open file", fmt.Sprintf("Could not open file %s", entry.Filename))))
+		return m
+	}
+	return m.OpenFile(entry.Filename)
+}
+
+// SwitchDefinition stops playback and replaces the sequence with another one.
+// The unsaved changes of the current sequence are kept in its recovery file.
+func (m model) SwitchDefinition(definition sequence.Sequence) model {
+	if m.playState.Playing {
+		m.SafeStop()
+	}
+	m.Autosave()
+
+	newModel := m.ReplaceDefinition(definition)
+	newModel.queuedSnapshot = nil
+	newModel.snapshotIndex = 0
+	newModel.playState.LineStates = playstate.InitLineStates(len(definition.Lines), []playstate.LineState{}, 0)
+	return newModel
+}
+
+// OpenFile replaces the sequence with the sequence of another file.  The
+// unsaved changes of the current sequence are kept in its recovery file, and
+// are offered back when it is opened again.
+func (m model) OpenFile(filename string) model {
+	definition, err := LoadFile(filename, m.definition.Template, m.definition.Instrument)
+	if err != nil {
+		m.SetCurrentError(fault.Wrap(err, fmsg.WithDesc("could not open file", fmt.Sprintf("Could not open file %s", filename))))
+		return m
+	}
+
+	newModel := m.SwitchDefinition(definition)
+	newModel.filename = filename
+	if err := newModel.LoadUndoHistory(); err != nil {
+		newModel.SetCurrentError(err)
+	}
+	if autosave.HasRecovery(filename) {
+		newModel.selectionIndicator = operation.SelectRecoverFile
+	}
+	return newModel
+}
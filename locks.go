@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/overlays"
+)
+
+// ChooseLockControl chooses the first control locked on the current note, or
+// else the first named CC of the instrument.
+func (m *model) ChooseLockControl() {
+	currentNote, _ := m.CurrentNote()
+	if locks := currentNote.Locks.All(); len(locks) > 0 {
+		m.lockControl = locks[0].Control
+		return
+	}
+	for i := range uint8(128) {
+		if _, exists := config.FindCC(i, m.definition.Instrument); exists {
+			m.lockControl = i
+			return
+		}
+	}
+}
+
+func (m *model) IncrementLockControl() {
+	for i := m.lockControl + 1; i <= 127; i++ {
+		if _, exists := config.FindCC(i, m.definition.Instrument); exists {
+			m.lockControl = i
+			return
+		}
+	}
+}
+
+func (m *model) DecrementLockControl() {
+	for i := m.lockControl - 1; i != 255; i-- {
+		if _, exists := config.FindCC(i, m.definition.Instrument); exists {
+			m.lockControl = i
+			return
+		}
+	}
+}
+
+func (m *model) SetLockControl(number int) {
+	m.lockControl = uint8(m.clamp(m.UnshiftDigit(int(m.lockControl), number), 0, 127))
+}
+
+// LockUpperLimit returns the highest value of the chosen control, the limit of
+// a named CC or else 127.
+func (m model) LockUpperLimit() uint8 {
+	if cc, exists := config.FindCC(m.lockControl, m.definition.Instrument); exists {
+		return cc.UpperLimit
+	}
+	return 127
+}
+
+// LockValue returns the value the chosen control is locked to on the current
+// note.
+func (m model) LockValue() (uint8, bool) {
+	currentNote, _ := m.CurrentNote()
+	return currentNote.Locks.Value(m.lockControl)
+}
+
+// IncreaseLockValue changes the value of the chosen control on the current
+// note, locking the control when it is not locked yet.
+func (m *model) IncreaseLockValue(modifier int) {
+	value, _ := m.LockValue()
+	m.ChangeLocks(func(note grid.Note) (grid.Note, bool) {
+		return m.setLockValue(note, max(min(int(value)+modifier, int(m.LockUpperLimit())), 0))
+	})
+}
+
+func (m *model) SetLockValue(number int) {
+	currentNote, exists := m.LockableNote()
+	if !exists {
+		return
+	}
+	value, _ := m.LockValue()
+	if note, ok := m.setLockValue(currentNote, m.clamp(m.UnshiftDigit(int(value), number), 0, int(m.LockUpperLimit()))); ok {
+		m.currentOverlay.SetNote(m.gridCursor, note)
+	}
+}
+
+func (m *model) RemoveLock() {
+	currentNote, exists := m.LockableNote()
+	if exists {
+		currentNote.Locks = currentNote.Locks.Remove(m.lockControl)
+		m.currentOverlay.SetNote(m.gridCursor, currentNote)
+	}
+}
+
+func (m *model) setLockValue(note grid.Note, value int) (grid.Note, bool) {
+	locks, ok := note.Locks.Set(m.lockControl, uint8(value))
+	if !ok {
+		m.SetCurrentError(fault.New("too many locks", fmsg.WithDesc("too many locks", fmt.Sprintf("A note can lock at most %d CCs", grid.MaxLocks))))
+		return note, false
+	}
+	note.Locks = locks
+	return note, true
+}
+
+// LockableNote returns the current note when it is a note that can have locks
+// rather than an action.
+func (m model) LockableNote() (grid.Note, bool) {
+	currentNote, exists := m.CurrentNote()
+	return currentNote, exists && currentNote != zeronote && currentNote.Action == grid.ActionNothing
+}
+
+// ChangeLocks changes the locks of the current note as an edit that can be
+// undone.
+func (m *model) ChangeLocks(change func(grid.Note) (grid.Note, bool)) {
+	currentNote, exists := m.LockableNote()
+	if !exists {
+		return
+	}
+	deepCopy := overlays.DeepCopy(m.currentOverlay)
+	note, changed := change(currentNote)
+	if !changed {
+		return
+	}
+	m.currentOverlay.SetNote(m.gridCursor, note)
+	undoable := m.UndoableOverlay(m.currentOverlay, deepCopy)
+	redoable := m.UndoableOverlay(deepCopy, m.currentOverlay)
+	if !undoable.overlayDiff.IsEmpty() {
+		m.PushUndoables(undoable, redoable)
+		m.ResetRedo()
+	}
+}
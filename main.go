@@ -3,10 +3,17 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/chriserin/sq/internal/config"
 	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/merge"
+	"github.com/chriserin/sq/internal/midifile"
 	"github.com/chriserin/sq/internal/seqmidi"
+	"github.com/chriserin/sq/internal/sequence"
 	"github.com/chriserin/sq/internal/themes"
 	"github.com/spf13/cobra"
 )
@@ -19,13 +26,21 @@ type ProgramOptions struct {
 	outport      bool
 	theme        string
 	midiout      string
+	clockin      string
+	recordin     string
+	controlin    string
+	link         bool
+	autosave     time.Duration
+	backups      int
+	undoHistory  int
+	seed         int64
 }
 
 var cliOptions ProgramOptions
 
 func main() {
 	rootCmd := &cobra.Command{
-		Use:   "sq",
+		Use:   "sq [file.sq | directory]",
 		Short: "A sequencer for your cli",
 		Long:  "A sequencer for your cli",
 		Args:  cobra.MaximumNArgs(1),
@@ -46,9 +61,18 @@ func main() {
 				filename = args[0]
 			}
 			p, err := RunProgram(filename, cliOptions)
-			_, err = p.Run()
 			if err != nil {
 				log.Fatalf("Program Failure: %v\n", err)
+			}
+			finalModel, err := p.Run()
+			if err != nil {
+				// Keep the unsaved changes of a program that failed
+				if m, ok := finalModel.(model); ok {
+					m.Autosave()
+				} else {
+					fmt.Fprintln(os.Stderr, "No recovery file was written, the program ended without its sequence")
+				}
+				log.Fatalf("Program Failure: %v\n", err)
 			} else {
 				return
 			}
@@ -85,14 +109,235 @@ func main() {
 		},
 	}
 
+	var exportOutput string
+	var exportSeed int64
+	cmdExport := &cobra.Command{
+		Use:   "export [file.sq]",
+		Short: "Export a sequence to a standard midi file",
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"sq"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			if _, err := os.Stat(filename); err != nil {
+				return fmt.Errorf("cannot export %s: %w", filename, err)
+			}
+			config.Init()
+			definition, err := LoadFile(filename, cliOptions.gridTemplate, cliOptions.instrument)
+			if err != nil {
+				return fmt.Errorf("cannot read %s: %w", filename, err)
+			}
+
+			output := exportOutput
+			if output == "" {
+				output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mid"
+			}
+			err = midifile.Export(definition, output, exportSeed)
+			if err != nil {
+				return fmt.Errorf("cannot export %s: %w", filename, err)
+			}
+			fmt.Printf("Exported %s to %s\n", filename, output)
+			return nil
+		},
+	}
+	cmdExport.Flags().StringVarP(&exportOutput, "output", "o", "", "Midi file to write (default: the sequence filename with a .mid extension)")
+	cmdExport.Flags().Int64Var(&exportSeed, "seed", 0, "Seed the chances of notes, the same seed always writes the same file")
+
+	var importOutput string
+	importOptions := midifile.DefaultImportOptions()
+	cmdImport := &cobra.Command{
+		Use:   "import [file.mid]",
+		Short: "Import a standard midi file into a new sequence",
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"mid", "midi"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			config.Init()
+			importOptions.Template = cliOptions.gridTemplate
+			importOptions.Instrument = cliOptions.instrument
+			definition, err := midifile.Import(filename, importOptions)
+			if err != nil {
+				return fmt.Errorf("cannot import %s: %w", filename, err)
+			}
+
+			output := importOutput
+			if output == "" {
+				output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".sq"
+			}
+			if _, err := os.Stat(output); err == nil {
+				return fmt.Errorf("cannot import %s: %s already exists", filename, output)
+			}
+			err = sequence.Write(definition, output)
+			if err != nil {
+				return fmt.Errorf("cannot write %s: %w", output, err)
+			}
+			fmt.Printf("Imported %s to %s\n", filename, output)
+			return nil
+		},
+	}
+	cmdImport.Flags().StringVarP(&importOutput, "output", "o", "", "Sequence file to write (default: the midi filename with a .sq extension)")
+	cmdImport.Flags().IntVar(&importOptions.BarsPerPart, "bars", importOptions.BarsPerPart, "Number of bars of the midi file in each part")
+	cmdImport.Flags().IntVar(&importOptions.Subdivisions, "subdivisions", importOptions.Subdivisions, "Number of grid beats per quarter note")
+	cmdImport.Flags().StringVar(&cliOptions.gridTemplate, "template", "Drums", "Choose a template (default: Drums)")
+	cmdImport.Flags().StringVar(&cliOptions.instrument, "instrument", "Standard", "Choose an instrument for CC integration (default: Standard)")
+
+	grooveName := "Imported"
+	grooveSteps := 16
+	grooveSubdivisions := midifile.DefaultImportOptions().Subdivisions
+	cmdGroove := &cobra.Command{
+		Use:   "groove [file.mid]",
+		Short: "Measure the groove of a standard midi file and print it as lua for the config",
+		Args:  cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"mid", "midi"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			extracted, err := midifile.ExtractGroove(filename, grooveName, grooveSubdivisions, grooveSteps)
+			if err != nil {
+				return fmt.Errorf("cannot measure groove of %s: %w", filename, err)
+			}
+			fmt.Print(config.GrooveLua(extracted))
+			return nil
+		},
+	}
+	cmdGroove.Flags().StringVar(&grooveName, "name", grooveName, "Name of the groove")
+	cmdGroove.Flags().IntVar(&grooveSteps, "steps", grooveSteps, "Number of grid beats before the groove repeats")
+	cmdGroove.Flags().IntVar(&grooveSubdivisions, "subdivisions", grooveSubdivisions, "Number of grid beats per quarter note")
+
+	cmdValidate := &cobra.Command{
+		Use:   "validate [file.sq...]",
+		Short: "Check sequence files for values that cannot be read",
+		Args:  cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"sq"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var problems int
+			for _, filename := range args {
+				diagnostics, err := sequence.Validate(filename)
+				if err != nil {
+					return fmt.Errorf("cannot validate %s: %w", filename, err)
+				}
+				for _, diagnostic := range diagnostics {
+					fmt.Printf("%s:%d: %s\n", filename, diagnostic.Line, diagnostic.Message)
+				}
+				problems += len(diagnostics)
+			}
+			if problems > 0 {
+				return fmt.Errorf("found %d problems in %d files", problems, len(args))
+			}
+			return nil
+		},
+	}
+
+	cmdMergeDriver := &cobra.Command{
+		Use:   "merge-driver [base] [ours] [theirs]",
+		Short: "Merge two versions of a sequence file, for use as a git merge driver",
+		Long: `Merge two versions of a sequence file, for use as a git merge driver.
+The merged sequence is written to the ours file.  Conflicts keep our value and
+are listed at the top of the merged file until they are resolved.
+
+  git config merge.sq.driver "sq merge-driver %O %A %B"
+  echo "*.sq merge=sq" >> .gitattributes`,
+		Args:         cobra.ExactArgs(3),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			versions := make([]sequence.Sequence, len(args))
+			for i, filename := range args {
+				definition, err := sequence.Read(filename)
+				if err != nil {
+					return fmt.Errorf("cannot read %s: %w", filename, err)
+				}
+				versions[i] = definition
+			}
+
+			merged, conflicts := merge.Sequences(versions[0], versions[1], versions[2])
+			for _, conflict := range conflicts {
+				merged.Conflicts = append(merged.Conflicts, conflict.String())
+			}
+			if err := sequence.Write(merged, args[1]); err != nil {
+				return fmt.Errorf("cannot write %s: %w", args[1], err)
+			}
+			if len(conflicts) > 0 {
+				return fmt.Errorf("%d conflicts merging %s", len(conflicts), args[1])
+			}
+			return nil
+		},
+	}
+
+	cmdDiff := &cobra.Command{
+		Use:   "diff [old.sq] [new.sq]",
+		Short: "Describe the musical changes between two sequence files",
+		Args:  cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"sq"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			versions := make([]sequence.Sequence, len(args))
+			for i, filename := range args {
+				definition, err := sequence.Read(filename)
+				if err != nil {
+					return fmt.Errorf("cannot read %s: %w", filename, err)
+				}
+				versions[i] = definition
+			}
+
+			for _, change := range DiffSequences(versions[0], versions[1]) {
+				fmt.Println(change)
+			}
+			return nil
+		},
+	}
+
+	cmdConvert := &cobra.Command{
+		Use:   "convert [input] [output]",
+		Short: "Convert a sequence file between the sq, json and yaml formats",
+		Long: `Convert a sequence file between the sq, json and yaml formats.
+The format of each file is chosen by its extension, .json for json, .yaml or
+.yml for yaml and the sq text format for any other extension.`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			definition, err := sequence.ReadStrict(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot read %s: %w", args[0], err)
+			}
+			if err := sequence.Write(definition, args[1]); err != nil {
+				return fmt.Errorf("cannot write %s: %w", args[1], err)
+			}
+			return nil
+		},
+	}
+
 	rootCmd.AddCommand(cmdListOutports)
 	rootCmd.AddCommand(cmdVersion)
 	rootCmd.AddCommand(cmdMappings)
+	rootCmd.AddCommand(cmdExport)
+	rootCmd.AddCommand(cmdImport)
+	rootCmd.AddCommand(cmdGroove)
+	rootCmd.AddCommand(cmdValidate)
+	rootCmd.AddCommand(cmdMergeDriver)
+	rootCmd.AddCommand(cmdDiff)
+	rootCmd.AddCommand(cmdConvert)
 	rootCmd.Flags().StringVar(&cliOptions.gridTemplate, "template", "Drums", "Choose a template (default: Drums)")
 	rootCmd.Flags().StringVar(&cliOptions.instrument, "instrument", "Standard", "Choose an instrument for CC integration (default: Standard)")
 	rootCmd.Flags().BoolVar(&cliOptions.outport, "outport", false, "sq will create an outport to send midi")
 	rootCmd.Flags().StringVar(&cliOptions.theme, "theme", "miles", "Choose an theme for the sequencer visual representation")
 	rootCmd.Flags().StringVar(&cliOptions.midiout, "midiout", "", "Choose a midi out port")
+	rootCmd.Flags().DurationVar(&cliOptions.autosave, "autosave", 30*time.Second, "Write unsaved changes to a recovery file this often, 0 to turn off")
+	rootCmd.Flags().IntVar(&cliOptions.backups, "backups", 3, "Number of backups of the previous versions of a file kept on save")
+	rootCmd.Flags().IntVar(&cliOptions.undoHistory, "undo-history", 500, "Number of undos, redos and edits of other branches kept with a file on save, 0 to turn off")
+	rootCmd.Flags().Int64Var(&cliOptions.seed, "seed", 0, "Seed the chances of notes to play them the same way every time, 0 for a different seed on every run")
+	rootCmd.Flags().StringVar(&cliOptions.clockin, "clockin", "", "Follow the midi clock of a midi in port")
+	rootCmd.Flags().StringVar(&cliOptions.recordin, "recordin", "", "Choose the midi in port notes are recorded from")
+	rootCmd.Flags().StringVar(&cliOptions.controlin, "controlin", "", "Choose the midi in port of a controller for midi learn")
+	rootCmd.Flags().BoolVar(&cliOptions.link, "link", false, "Share tempo, beat phase and start/stop with sq instances on the local network")
 
 	// Register completion function for template flag
 	err := rootCmd.RegisterFlagCompletionFunc("template", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -133,8 +378,36 @@ func main() {
 		log.Fatal("Failed to register midiout completion")
 	}
 
+	// Register completion function for clockin flag
+	err = rootCmd.RegisterFlagCompletionFunc("clockin", inPortCompletion)
+	if err != nil {
+		log.Fatal("Failed to register clockin completion")
+	}
+
+	// Register completion function for recordin flag
+	err = rootCmd.RegisterFlagCompletionFunc("recordin", inPortCompletion)
+	if err != nil {
+		log.Fatal("Failed to register recordin completion")
+	}
+
+	// Register completion function for controlin flag
+	err = rootCmd.RegisterFlagCompletionFunc("controlin", inPortCompletion)
+	if err != nil {
+		log.Fatal("Failed to register controlin completion")
+	}
+
 	err = rootCmd.Execute()
 	if err != nil {
 		log.Fatal("Program failed")
 	}
 }
+
+// inPortCompletion completes the names of the midi input ports.
+func inPortCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	inports, _ := seqmidi.GetIns()
+	inportNames := make([]string, len(inports))
+	for i, inport := range inports {
+		inportNames[i] = inport.String()
+	}
+	return inportNames, cobra.ShellCompDirectiveNoFileComp
+}
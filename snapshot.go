@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// CaptureSnapshot saves a snapshot of the sequence, or of the current part
+// when a part snapshot was asked for.
+func (m *model) CaptureSnapshot(name string) {
+	undoable := UndoSnapshots{m.definition.Snapshots}
+	m.definition.SetSnapshot(m.definition.Capture(name, m.snapshotPart))
+	m.PushUndoables(undoable, UndoSnapshots{m.definition.Snapshots})
+	m.ResetRedo()
+}
+
+// RemoveSnapshot removes the snapshot chosen to recall.
+func (m *model) RemoveSnapshot() {
+	if m.snapshotIndex >= len(m.definition.Snapshots) {
+		return
+	}
+	undoable := UndoSnapshots{m.definition.Snapshots}
+	m.definition.RemoveSnapshot(m.definition.Snapshots[m.snapshotIndex].Name)
+	m.PushUndoables(undoable, UndoSnapshots{m.definition.Snapshots})
+	m.ResetRedo()
+	m.ClampSnapshotIndex()
+}
+
+func (m *model) ClampSnapshotIndex() {
+	m.snapshotIndex = max(min(m.snapshotIndex, len(m.definition.Snapshots)-1), 0)
+}
+
+func (m *model) IncreaseSnapshotIndex() {
+	m.snapshotIndex++
+	m.ClampSnapshotIndex()
+}
+
+func (m *model) DecreaseSnapshotIndex() {
+	m.snapshotIndex--
+	m.ClampSnapshotIndex()
+}
+
+// RecallSnapshot recalls the snapshot chosen to recall.  During playback the
+// snapshot is queued to be recalled with the next cycle of the keyline.
+func (m *model) RecallSnapshot() {
+	if m.snapshotIndex >= len(m.definition.Snapshots) {
+		return
+	}
+	snapshot := m.definition.Snapshots[m.snapshotIndex]
+	if !snapshot.IsWhole() && snapshot.Part >= len(*m.definition.Parts) {
+		m.SetCurrentError(fault.New("snapshot part missing", fmsg.WithDesc("snapshot part missing", fmt.Sprintf("Snapshot %s captured part %d, the sequence has %d parts", snapshot.Name, snapshot.Part+1, len(*m.definition.Parts)))))
+		return
+	}
+
+	if m.playState.Playing {
+		m.queuedSnapshot = &snapshot
+	} else {
+		m.PlaySnapshot(snapshot)
+	}
+}
+
+// PlayQueuedSnapshot recalls the queued snapshot, the beat loop plays it
+// already.
+func (m *model) PlayQueuedSnapshot() {
+	if m.queuedSnapshot == nil {
+		return
+	}
+	snapshot := *m.queuedSnapshot
+	m.queuedSnapshot = nil
+	m.PlaySnapshot(snapshot)
+}
+
+// PlaySnapshot recalls a snapshot as an edit that can be undone.
+func (m *model) PlaySnapshot(snapshot sequence.Snapshot) {
+	undoable := UndoRecall{m.definition.Capture(snapshot.Name, snapshot.Part)}
+	m.ApplySnapshot(snapshot)
+	m.PushUndoables(undoable, UndoRecall{snapshot})
+	m.ResetRedo()
+}
+
+// ApplySnapshot puts the captured state of a snapshot in place of the
+// current state, keeping the cursors within the recalled lines and beats.
+func (m *model) ApplySnapshot(snapshot sequence.Snapshot) {
+	tempo, subdivisions := m.definition.Tempo, m.definition.Subdivisions
+	m.definition.Recall(snapshot)
+	if tempo != m.definition.Tempo || subdivisions != m.definition.Subdivisions {
+		m.SyncTempo()
+	}
+
+	if len(m.playState.LineStates) != len(m.definition.Lines) {
+		m.playState.LineStates = playstate.InitLineStates(len(m.definition.Lines), m.playState.LineStates, 0)
+	}
+	line := min(int(m.gridCursor.Line), max(len(m.definition.Lines)-1, 0))
+	beat := min(int(m.gridCursor.Beat), max(int(m.CurrentPart().Beats)-1, 0))
+	m.gridCursor = GK(uint8(line), uint8(beat))
+
+	// The overlays of the recalled parts are copies, the current overlay
+	// is found again by its key
+	overlay := m.CurrentPart().Overlays.FindOverlay(m.currentOverlay.Key)
+	if overlay == nil {
+		overlay = m.CurrentPart().Overlays
+	}
+	m.currentOverlay = overlay
+	m.overlayKeyEdit.SetOverlayKey(overlay.Key)
+}
+
+// QueuedSequence returns the sequence with the queued snapshot recalled,
+// for the beat loop to play from the next cycle of the keyline.
+func (m model) QueuedSequence() *sequence.Sequence {
+	if m.queuedSnapshot == nil {
+		return nil
+	}
+	queued := m.definition
+	parts := slices.Clone(*m.definition.Parts)
+	queued.Parts = &parts
+	queued.Recall(*m.queuedSnapshot)
+	return &queued
+}
@@ -7,9 +7,11 @@ import (
 	"maps"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Southclaws/fault"
@@ -22,15 +24,22 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/autosave"
 	"github.com/chriserin/sq/internal/beats"
 	"github.com/chriserin/sq/internal/config"
+	"github.com/chriserin/sq/internal/controller"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/library"
 	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/midifile"
 	"github.com/chriserin/sq/internal/notereg"
 	"github.com/chriserin/sq/internal/operation"
 	"github.com/chriserin/sq/internal/overlaykey"
 	"github.com/chriserin/sq/internal/overlays"
 	"github.com/chriserin/sq/internal/playstate"
+	"github.com/chriserin/sq/internal/recorder"
+	"github.com/chriserin/sq/internal/renderer"
 	"github.com/chriserin/sq/internal/seqmidi"
 	"github.com/chriserin/sq/internal/sequence"
 	themes "github.com/chriserin/sq/internal/themes"
@@ -85,6 +94,8 @@ type model struct {
 	firstDigitApplied     bool
 	hasUIFocus            bool
 	transmitterConnected  bool
+	linkPeers             int
+	linkTempo             int
 	logFileAvailable      bool
 	playEditing           bool
 	showArrangementView   bool
@@ -92,23 +103,41 @@ type model struct {
 	modifyKey             bool
 	transmitting          bool
 	clockPreRoll          bool
+	recordIntoOverlay     bool
+	recordMode            recorder.Mode
+	startTick             int64
 	euclideanHits         uint8
+	grooveLines           []uint8
 	ratchetCursor         uint8
+	lockControl           uint8
 	temporaryNoteValue    uint8
 	focus                 operation.Focus
 	sectionSideIndicator  SectionSide
 	selectionIndicator    operation.Selection
 	patternMode           operation.PatternMode
+	patternBars           bool
 	midiLoopMode          timing.MidiLoopMode
 	gridCursor            gridKey
 	visualSelection       VisualSelection
 	partSelectorIndex     int
+	snapshotPart          int
+	importFilename        string
+	snapshotIndex         int
+	queuedSnapshot        *sequence.Snapshot
+	directory             string
+	libraryEntries        []library.Entry
+	libraryIndex          int
 	needsWrite            int
+	autosaved             int
+	autosaveInterval      time.Duration
+	backups               int
+	undoHistory           int
 	cancel                context.CancelFunc
 	currentOverlay        *overlays.Overlay
 	logFile               *os.File
 	undoStack             UndoStack
 	redoStack             UndoStack
+	undoTree              UndoTree
 	yankBuffer            Buffer
 	lockReceiverChannel   chan bool
 	unlockReceiverChannel chan bool
@@ -125,6 +154,9 @@ type model struct {
 	midiConnection        *seqmidi.MidiConnection
 	activeChord           overlays.OverlayChord
 	temporaryState        temporaryState
+	take                  *recorder.Take
+	step                  *recorder.Step
+	learn                 controller.Learn
 	// play state
 	playState playstate.PlayState
 	// save everything below here
@@ -225,6 +257,7 @@ func (m *model) PushArrUndo(arrundo arrangement.Undo) {
 }
 
 func (m *model) PushUndoables(undo Undoable, redo Undoable) {
+	parent := m.undoStack.id
 	if m.undoStack == EmptyStack {
 		m.undoStack = UndoStack{
 			undo: undo,
@@ -242,6 +275,7 @@ func (m *model) PushUndoables(undo Undoable, redo Undoable) {
 		}
 		m.undoStack = lastin
 	}
+	m.undoTree.Add(UndoNode{id: m.undoStack.id, parent: parent, undo: undo, redo: redo, time: time.Now()})
 }
 
 func (m *model) PushUndo(undo UndoStack) {
@@ -331,6 +365,8 @@ type temporaryState struct {
 	subdivisions int
 	accents      sequence.PatternAccents
 	beats        uint8
+	grooves      groove.Settings
+	meter        arrangement.Meter
 	active       bool
 }
 
@@ -353,6 +389,25 @@ func (s StateDiff) Changed() bool {
 	return s.LinesChanged || s.TempoChanged || s.SubdivisionsChanged || s.AccentsChanged
 }
 
+// Changes returns a readable description of each difference, lines
+// numbered from 1 as they are shown on the grid.
+func (s StateDiff) Changes() []string {
+	var changes []string
+	if s.TempoChanged {
+		changes = append(changes, fmt.Sprintf("Tempo %d→%d", s.OldTempo, s.NewTempo))
+	}
+	if s.SubdivisionsChanged {
+		changes = append(changes, fmt.Sprintf("Subdivisions %d→%d", s.OldSubdivisions, s.NewSubdivisions))
+	}
+	if s.LinesChanged {
+		changes = append(changes, lineChanges(s.OldLines, s.NewLines)...)
+	}
+	if s.AccentsChanged {
+		changes = append(changes, accentChanges(s.OldAccents, s.NewAccents)...)
+	}
+	return changes
+}
+
 func (s StateDiff) Reverse() StateDiff {
 	return StateDiff{
 		LinesChanged:        s.LinesChanged,
@@ -424,14 +479,23 @@ type viewPanicMsg struct {
 }
 
 func (m model) SyncTempo() {
+	tempo, subdivisions := m.PlayTempo()
+	sequenceTempo := m.definition.Tempo
 	go func() {
 		timingChannel <- timing.TempoMsg{
-			Tempo:        m.definition.Tempo,
-			Subdivisions: m.definition.Subdivisions,
+			Tempo:         tempo,
+			Subdivisions:  subdivisions,
+			SequenceTempo: sequenceTempo,
 		}
 	}()
 }
 
+// PlayTempo returns the tempo and subdivisions of the beat being played, those
+// of the current section when it has its own.
+func (m model) PlayTempo() (int, int) {
+	return renderer.Tempo(m.playState, m.definition, m.arrangement.Cursor)
+}
+
 func (m *model) EnsureOverlay() {
 	m.EnsureOverlayWithKey(m.overlayKeyEdit.GetKey())
 }
@@ -678,6 +742,79 @@ func (m *model) DecreaseBeats() {
 	}
 }
 
+// GrooveTargetLines returns the lines of the visual selection, or no lines to
+// change the groove of the whole part.
+func (m model) GrooveTargetLines() []uint8 {
+	if m.visualSelection.visualMode == operation.VisualNone {
+		return nil
+	}
+	bounds := m.visualSelection.Bounds(m.CurrentPart().Beats)
+	lines := make([]uint8, 0, bounds.Bottom-bounds.Top+1)
+	for line := bounds.Top; line <= bounds.Bottom; line++ {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// GrooveSetting returns the groove of the lines changed by the groove inputs.
+func (m model) GrooveSetting() groove.Setting {
+	setting := groove.Setting{Amount: groove.MaxAmount}
+	grooves := m.CurrentPart().Grooves
+	if len(m.grooveLines) > 0 {
+		if found, ok := grooves.Find(m.grooveLines[0]); ok {
+			setting = found
+		}
+	} else if len(grooves) > 0 && len(grooves[0].Lines) == 0 {
+		setting = grooves[0]
+	}
+	setting.Lines = m.grooveLines
+	return setting
+}
+
+// GrooveNames returns the grooves of the sequence and of the config.
+func (m model) GrooveNames() []string {
+	var names []string
+	for _, g := range m.definition.Grooves {
+		names = append(names, g.Name)
+	}
+	for _, name := range config.GetGrooveNames() {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (m *model) SetGroove(setting groove.Setting) {
+	partID := m.CurrentPartID()
+	(*m.definition.Parts)[partID].Grooves = m.CurrentPart().Grooves.Apply(setting)
+}
+
+// ChangeGroove moves through the grooves, starting from no groove.
+func (m *model) ChangeGroove(direction int) {
+	names := append([]string{""}, m.GrooveNames()...)
+	setting := m.GrooveSetting()
+	index := max(slices.Index(names, setting.Name), 0)
+	setting.Name = names[(index+direction+len(names))%len(names)]
+	m.SetGroove(setting)
+}
+
+func (m *model) IncreaseGrooveAmount(amount int) {
+	setting := m.GrooveSetting()
+	if setting.Name != "" {
+		setting.Amount = uint8(m.clamp(int(setting.Amount)+amount, 0, groove.MaxAmount))
+		m.SetGroove(setting)
+	}
+}
+
+func (m *model) SetGrooveAmount(number int) {
+	setting := m.GrooveSetting()
+	if setting.Name != "" {
+		setting.Amount = uint8(m.clamp(m.UnshiftDigit(int(setting.Amount), number), 0, groove.MaxAmount))
+		m.SetGroove(setting)
+	}
+}
+
 func (m *model) IncreaseStartBeats() {
 	currentNode := m.arrangement.Cursor.GetCurrentNode()
 	partBeats := m.CurrentPart().Beats
@@ -767,6 +904,13 @@ func InitModel(filename string, midiConnection *seqmidi.MidiConnection, options
 	unlockReceiverChannel := make(chan bool)
 	errorChannel := make(chan error)
 
+	// Started on a directory, the sequence files of the directory are
+	// offered to open
+	var directory string
+	if library.IsDir(filename) {
+		directory, filename = filename, ""
+	}
+
 	logFile, logFileErr := tea.LogToFile("debug.log", "debug")
 	definition, err := LoadFile(filename, options.gridTemplate, options.instrument)
 	if err == nil {
@@ -774,12 +918,21 @@ func InitModel(filename string, midiConnection *seqmidi.MidiConnection, options
 		err = logFileErr
 	}
 
-	return model{
+	selection := operation.SelectGrid
+	if directory != "" {
+		selection = operation.SelectLibrary
+	}
+	if autosave.HasRecovery(filename) {
+		selection = operation.SelectRecoverFile
+	}
+
+	m := model{
 		cancel:                cancel,
 		transmitting:          true,
 		currentError:          err,
 		theme:                 options.theme,
 		filename:              filename,
+		directory:             directory,
 		textInput:             InitTextInput(),
 		partSelectorIndex:     -1,
 		lockReceiverChannel:   lockReceiverChannel,
@@ -789,10 +942,13 @@ func InitModel(filename string, midiConnection *seqmidi.MidiConnection, options
 		cursor:                newCursor,
 		midiConnection:        midiConnection,
 		logFile:               logFile,
-		selectionIndicator:    operation.SelectGrid,
+		selectionIndicator:    selection,
 		focus:                 operation.FocusGrid,
 		patternMode:           operation.PatternFill,
 		logFileAvailable:      logFileErr == nil,
+		autosaveInterval:      options.autosave,
+		backups:               options.backups,
+		undoHistory:           options.undoHistory,
 		gridCursor:            GK(0, 0),
 		currentOverlay:        (*definition.Parts)[0].Overlays,
 		overlayKeyEdit:        overlaykey.InitModel(),
@@ -802,6 +958,13 @@ func InitModel(filename string, midiConnection *seqmidi.MidiConnection, options
 			LineStates: playstate.InitLineStates(len(definition.Lines), []playstate.LineState{}, 0),
 		},
 	}
+	if historyErr := m.LoadUndoHistory(); historyErr != nil && m.currentError == nil {
+		m.currentError = historyErr
+	}
+	if directory != "" {
+		m.ScanLibrary()
+	}
+	return m
 }
 
 func InitTextInput() textinput.Model {
@@ -866,23 +1029,50 @@ func RunProgram(filename string, options ProgramOptions) (*tea.Program, error) {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 
-	midiConnection := seqmidi.InitMidiConnection(options.outport, options.midiout, ctx)
+	midiConnection := seqmidi.InitMidiConnection(options.outport, options.midiout, options.clockin, options.recordin, options.controlin, ctx)
 	config.Init()
 	themes.ChooseTheme(options.theme)
 	model := InitModel(filename, midiConnection, options, cancel)
 	model.ResetIterations()
 	beatsLooper = beats.InitBeatsLooper()
+	if options.seed != 0 {
+		beatsLooper.Trigs = renderer.NewTrigs(options.seed)
+	}
 	updateChannel = beatsLooper.UpdateChannel
 	midiConnection.DeviceLoop(ctx)
 
-	if model.midiConnection.HasTransmitter() {
+	if model.midiConnection.FollowsClock() {
+		model.midiLoopMode = timing.MlmClockReceiver
+	} else if options.link {
+		model.midiLoopMode = timing.MlmLink
+	} else if model.midiConnection.HasTransmitter() {
 		model.midiLoopMode = timing.MlmReceiver
 	} else {
 		model.midiLoopMode = timing.MlmTransmitter
 	}
 
 	program := tea.NewProgram(model, tea.WithAltScreen(), tea.WithReportFocus())
+	midiConnection.RecordReceiverFunc = func(msg []byte, milliseconds int32) {
+		if note, ok := recorder.ReadNote(msg, time.Now()); ok {
+			program.Send(note)
+		}
+	}
+	midiConnection.ControlReceiverFunc = func(msg []byte, milliseconds int32) {
+		if control, ok := controller.ReadControl(msg); ok {
+			program.Send(control)
+		}
+	}
+	err := midiConnection.ListenToControlSource()
+	if err != nil {
+		go func() {
+			program.Send(errorMsg{fault.Wrap(err, fmsg.With("could not listen to control source"))})
+		}()
+	}
 	SetupTimingLoop(model, beatsLooper, program.Send, ctx)
+	if model.midiLoopMode == timing.MlmLink {
+		// The tempo a new link session is founded with
+		model.SyncTempo()
+	}
 	beatsLooper.Loop(program.Send, midiConnection, ctx)
 	midiConnection.LoopMidi(ctx)
 	if options.outport {
@@ -926,7 +1116,19 @@ func ErrorLoop(sendFn func(tea.Msg), errChan chan error) {
 }
 
 func (m model) Init() tea.Cmd {
-	return func() tea.Msg { return tea.FocusMsg{} }
+	return tea.Batch(func() tea.Msg { return tea.FocusMsg{} }, m.AutosaveTick())
+}
+
+type autosaveMsg struct{}
+
+// AutosaveTick waits for the autosave interval before the next autosave.
+func (m model) AutosaveTick() tea.Cmd {
+	if m.autosaveInterval <= 0 {
+		return nil
+	}
+	return tea.Tick(m.autosaveInterval, func(time.Time) tea.Msg {
+		return autosaveMsg{}
+	})
 }
 
 func Is(msg tea.KeyMsg, k ...key.Binding) bool {
@@ -945,6 +1147,8 @@ type panicMsg struct {
 func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 	defer func() {
 		if r := recover(); r != nil {
+			// The program may not live to handle the panic message
+			m.AutosaveAfterPanic()
 			rModel = m
 			stackTrace := make([]byte, 4096)
 			n := runtime.Stack(stackTrace, false)
@@ -964,9 +1168,21 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 		m.SetCurrentError(errors.New(msg.message))
 		m.LogString(fmt.Sprintf(" ------ Panic Message ------- \n%s\n", msg.message))
 		m.LogString(fmt.Sprintf(" ------ Stacktrace ---------- \n%s\n", msg.stacktrace))
-	case tea.KeyMsg:
+	case tea.KeyMsg, mappings.Mapping:
+
+		var mapping mappings.Mapping
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			mapping = mappings.ProcessKey(msg, m.focus, m.selectionIndicator, m.definition.TemplateSequencerType, m.patternMode)
+		case mappings.Mapping:
+			// A command bound to a control of a midi controller
+			mapping = msg
+		}
 
-		mapping := mappings.ProcessKey(msg, m.focus, m.selectionIndicator, m.definition.TemplateSequencerType, m.patternMode)
+		if m.learn.Active && (!m.learn.HasTarget || mapping.Command == mappings.Escape) {
+			m.LearnTarget(mapping.Command)
+			return m, nil
+		}
 
 		// NOTE: Finally process the mapping
 		switch mapping.Command {
@@ -1098,7 +1314,15 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 			return m, cmd
 		case mappings.ConfirmFileName:
 			if m.textInput.Value() != "" {
-				m.filename = fmt.Sprintf("%s.sq", m.textInput.Value())
+				m.filename = m.textInput.Value()
+				// Names ending in .json or .yaml are saved in that format
+				if sequence.FormatOf(m.filename) == sequence.FormatText {
+					m.filename = fmt.Sprintf("%s.sq", m.filename)
+				}
+				// New sequences of a project directory are saved in it
+				if m.directory != "" && !filepath.IsAbs(m.filename) {
+					m.filename = filepath.Join(m.directory, m.filename)
+				}
 				m.textInput.Reset()
 				m.selectionIndicator = operation.SelectGrid
 				m.Save()
@@ -1107,11 +1331,55 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.selectionIndicator = operation.SelectGrid
 				return m, nil
 			}
+		case mappings.ConfirmLibrary:
+			newModel := m.OpenLibraryEntry()
+			var cmd tea.Cmd
+			cursor, cmd := m.cursor.Update(tea.FocusMsg{})
+			newModel.cursor = cursor
+			newModel.SyncTempo()
+			newModel.SyncBeatLoop()
+			return newModel, cmd
+		case mappings.ConfirmRecoverFile:
+			newModel := m.RecoverFile()
+			var cmd tea.Cmd
+			cursor, cmd := m.cursor.Update(tea.FocusMsg{})
+			newModel.cursor = cursor
+			newModel.SyncTempo()
+			newModel.SyncBeatLoop()
+			return newModel, cmd
+		case mappings.ConfirmImportFile:
+			if m.textInput.Value() != "" {
+				filename := m.textInput.Value()
+				if filepath.Ext(filename) == "" {
+					filename = fmt.Sprintf("%s.mid", filename)
+				}
+				m.textInput.Reset()
+				if m.NeedsWrite() {
+					m.importFilename = filename
+					m.selectionIndicator = operation.SelectConfirmImport
+					return m, nil
+				}
+				return m.ImportFile(filename)
+			} else {
+				m.selectionIndicator = operation.SelectGrid
+				return m, nil
+			}
+		case mappings.ConfirmConfirmImport:
+			filename := m.importFilename
+			m.importFilename = ""
+			return m.ImportFile(filename)
 		case mappings.ConfirmRenamePart:
 			m.RenamePart(m.textInput.Value())
 			m.textInput.Reset()
 			m.selectionIndicator = operation.SelectGrid
 			return m, nil
+		case mappings.ConfirmSnapshotName:
+			if name := strings.TrimSpace(m.textInput.Value()); name != "" {
+				m.CaptureSnapshot(name)
+			}
+			m.textInput.Reset()
+			m.selectionIndicator = operation.SelectGrid
+			return m, nil
 		case mappings.ConfirmOverlayKey:
 			currentKey := m.currentOverlay.Key
 			m.focus = operation.FocusGrid
@@ -1151,9 +1419,11 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				return m.Quit()
 			}
 		case mappings.ArrKeyMessage:
+			m.arrangement.SetTempo(m.definition.Tempo, m.definition.Subdivisions)
 			arrangmementModel, cmd := m.arrangement.Update(msg)
 			m.arrangement = arrangmementModel
 			m.ResetCurrentOverlay()
+			m.SyncTempo()
 			return m, cmd
 		case mappings.TextInputMessage:
 			tiModel, cmd := m.textInput.Update(msg)
@@ -1168,13 +1438,13 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 		case mappings.HoldingKeys:
 			return m, nil
 		case mappings.CursorDown:
-			if slices.Contains([]operation.Selection{operation.SelectGrid, operation.SelectSetupChannel, operation.SelectSetupMessageType, operation.SelectSetupValue, operation.SelectSpecificValue}, m.selectionIndicator) {
+			if slices.Contains([]operation.Selection{operation.SelectGrid, operation.SelectSetupChannel, operation.SelectSetupMessageType, operation.SelectSetupValue, operation.SelectSetupLength, operation.SelectSetupDivision, operation.SelectSpecificValue}, m.selectionIndicator) {
 				m.CursorDown()
 				m.UnsetActiveChord()
 				m.SetVisualArea()
 			}
 		case mappings.CursorUp:
-			if slices.Contains([]operation.Selection{operation.SelectGrid, operation.SelectSetupChannel, operation.SelectSetupMessageType, operation.SelectSetupValue, operation.SelectSpecificValue}, m.selectionIndicator) {
+			if slices.Contains([]operation.Selection{operation.SelectGrid, operation.SelectSetupChannel, operation.SelectSetupMessageType, operation.SelectSetupValue, operation.SelectSetupLength, operation.SelectSetupDivision, operation.SelectSpecificValue}, m.selectionIndicator) {
 				m.CursorUp()
 				m.UnsetActiveChord()
 				m.SetVisualArea()
@@ -1312,6 +1582,63 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 			} else {
 				m.StartStop(0)
 			}
+		case mappings.RecordNotes:
+			if m.take != nil {
+				m.EndTake()
+				return m, nil
+			}
+			err := m.midiConnection.ListenToRecordSource()
+			if err != nil {
+				m.SetCurrentError(err)
+				return m, nil
+			}
+			m.take = recorder.NewTake(m.recordMode)
+			if !m.playState.Playing {
+				m.playState.LoopMode = playstate.LoopPart
+				m.StartStop(0)
+			}
+		case mappings.ToggleRecordMode:
+			if m.recordMode == recorder.Overdub {
+				m.recordMode = recorder.Replace
+			} else {
+				m.recordMode = recorder.Overdub
+			}
+			if m.take != nil {
+				m.take.Mode = m.recordMode
+			}
+		case mappings.ToggleRecordOverlay:
+			m.recordIntoOverlay = !m.recordIntoOverlay
+		case mappings.ToggleStepInput:
+			if m.step != nil {
+				m.step = nil
+				if m.take == nil {
+					m.midiConnection.StopRecording()
+				}
+				return m, nil
+			}
+			err := m.midiConnection.ListenToRecordSource()
+			if err != nil {
+				m.SetCurrentError(err)
+				return m, nil
+			}
+			m.step = &recorder.Step{}
+		case mappings.LearnControl:
+			m.learn = controller.Learn{Active: true}
+			switch m.selectionIndicator {
+			case operation.SelectTempo:
+				m.learn.HasTarget = true
+				m.learn.Parameter = controller.ParameterTempo
+			case operation.SelectAccentStart:
+				m.learn.HasTarget = true
+				m.learn.Parameter = controller.ParameterAccentStart
+			case operation.SelectAccentEnd:
+				m.learn.HasTarget = true
+				m.learn.Parameter = controller.ParameterAccentEnd
+			}
+		case mappings.IncreaseTempo:
+			m.IncreaseTempo(1)
+		case mappings.DecreaseTempo:
+			m.DecreaseTempo(1)
 		case mappings.ModifyKeyInputSwitch:
 			m.SetSelectionIndicator(operation.SelectGrid)
 			m.focus = operation.FocusOverlayKey
@@ -1354,6 +1681,26 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.PushUndoableDefinitionState()
 			}
 			m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
+		case mappings.LineClockInputSwitch:
+			states := []operation.Selection{operation.SelectGrid, operation.SelectSetupLength, operation.SelectSetupDivision}
+			if m.selectionIndicator == states[0] {
+				m.CaptureTemporaryState()
+			}
+			if m.selectionIndicator == states[len(states)-1] {
+				m.PushUndoableDefinitionState()
+			}
+			m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
+		case mappings.MeterInputSwitch:
+			states := []operation.Selection{operation.SelectGrid, operation.SelectMeterNumerator, operation.SelectMeterDenominator}
+			if m.selectionIndicator == states[0] {
+				m.CaptureTemporaryState()
+			}
+			if m.selectionIndicator == states[len(states)-1] {
+				m.PushUndoableDefinitionState()
+			}
+			m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
+		case mappings.ToggleBarPattern:
+			m.patternBars = !m.patternBars
 		case mappings.AccentInputSwitch:
 			states := []operation.Selection{operation.SelectGrid, operation.SelectAccentTarget, operation.SelectAccentStart, operation.SelectAccentEnd}
 			if m.selectionIndicator == states[0] {
@@ -1378,6 +1725,14 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
 				m.ratchetCursor = 0
 			}
+		case mappings.LockInputSwitch:
+			if _, lockable := m.LockableNote(); lockable {
+				states := []operation.Selection{operation.SelectGrid, operation.SelectLockControl, operation.SelectLockValue}
+				if m.selectionIndicator == states[0] {
+					m.ChooseLockControl()
+				}
+				m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
+			}
 		case mappings.BeatInputSwitch:
 			states := []operation.Selection{operation.SelectGrid, operation.SelectBeats, operation.SelectStartBeats}
 			if m.selectionIndicator == states[0] {
@@ -1387,6 +1742,18 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.PushUndoableDefinitionState()
 			}
 			m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
+		case mappings.GrooveInputSwitch:
+			states := []operation.Selection{operation.SelectGrid, operation.SelectGroove, operation.SelectGrooveAmount}
+			if !slices.Contains(states[1:], m.selectionIndicator) {
+				m.grooveLines = m.GrooveTargetLines()
+			}
+			if m.selectionIndicator == states[0] {
+				m.CaptureTemporaryState()
+			}
+			if m.selectionIndicator == states[len(states)-1] {
+				m.PushUndoableDefinitionState()
+			}
+			m.SetSelectionIndicator(AdvanceSelectionState(states, m.selectionIndicator))
 		case mappings.CyclesInputSwitch:
 			states := []operation.Selection{operation.SelectGrid, operation.SelectCycles, operation.SelectStartCycles}
 			if m.selectionIndicator == states[0] {
@@ -1425,8 +1792,20 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				case grid.MessageTypeCc:
 					m.IncrementCC()
 				}
+			case operation.SelectSetupLength:
+				m.IncreaseSetupLength(1)
+			case operation.SelectSetupDivision:
+				m.definition.Lines[m.gridCursor.Line].IncrementDivision(-1)
+			case operation.SelectMeterNumerator:
+				m.CurrentPartMeter().IncreaseNumerator(1)
+			case operation.SelectMeterDenominator:
+				m.CurrentPartMeter().IncreaseDenominator(1)
 			case operation.SelectRatchetSpan:
 				m.IncreaseSpan()
+			case operation.SelectLockControl:
+				m.IncrementLockControl()
+			case operation.SelectLockValue:
+				m.IncreaseLockValue(1)
 			case operation.SelectAccentEnd:
 				m.IncreaseAccentEnd()
 			case operation.SelectAccentTarget:
@@ -1437,6 +1816,10 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.IncreaseAccentStart()
 			case operation.SelectBeats:
 				m.IncreaseBeats()
+			case operation.SelectGroove:
+				m.ChangeGroove(1)
+			case operation.SelectGrooveAmount:
+				m.IncreaseGrooveAmount(5)
 			case operation.SelectCycles:
 				m.IncreaseCycles()
 			case operation.SelectStartBeats:
@@ -1447,6 +1830,10 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.IncreasePartSelector()
 			case operation.SelectChangePart:
 				m.IncreasePartSelector()
+			case operation.SelectSnapshot:
+				m.IncreaseSnapshotIndex()
+			case operation.SelectLibrary:
+				m.IncreaseLibraryIndex()
 			case operation.SelectSpecificValue:
 				note, _ := m.CurrentNote()
 				m.IncrementSpecificValue(note)
@@ -1474,8 +1861,20 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				case grid.MessageTypeCc:
 					m.DecrementCC()
 				}
+			case operation.SelectSetupLength:
+				m.IncreaseSetupLength(-1)
+			case operation.SelectSetupDivision:
+				m.definition.Lines[m.gridCursor.Line].IncrementDivision(1)
+			case operation.SelectMeterNumerator:
+				m.CurrentPartMeter().IncreaseNumerator(-1)
+			case operation.SelectMeterDenominator:
+				m.CurrentPartMeter().IncreaseDenominator(-1)
 			case operation.SelectRatchetSpan:
 				m.DecreaseSpan()
+			case operation.SelectLockControl:
+				m.DecrementLockControl()
+			case operation.SelectLockValue:
+				m.IncreaseLockValue(-1)
 			case operation.SelectAccentEnd:
 				m.DecreaseAccentEnd()
 			case operation.SelectAccentTarget:
@@ -1484,6 +1883,10 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.DecreaseAccentStart()
 			case operation.SelectBeats:
 				m.DecreaseBeats()
+			case operation.SelectGroove:
+				m.ChangeGroove(-1)
+			case operation.SelectGrooveAmount:
+				m.IncreaseGrooveAmount(-5)
 			case operation.SelectCycles:
 				m.DecreaseCycles()
 			case operation.SelectStartBeats:
@@ -1494,6 +1897,10 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 				m.DecreasePartSelector()
 			case operation.SelectChangePart:
 				m.DecreasePartSelector()
+			case operation.SelectSnapshot:
+				m.DecreaseSnapshotIndex()
+			case operation.SelectLibrary:
+				m.DecreaseLibraryIndex()
 			case operation.SelectSpecificValue:
 				note, _ := m.CurrentNote()
 				m.DecrementSpecificValue(note)
@@ -1517,6 +1924,17 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 			m.SetPatternMode(operation.PatternRatchet)
 		case mappings.ToggleRatchetNoteMode:
 			m.SetPatternMode(operation.PatternNoteRatchet)
+		case mappings.ToggleChanceMode:
+			m.SetPatternMode(operation.PatternProbability)
+		case mappings.ToggleChanceNoteMode:
+			m.SetPatternMode(operation.PatternNoteProbability)
+		case mappings.ToggleTrigMode:
+			m.SetPatternMode(operation.PatternCondition)
+		case mappings.ToggleTrigNoteMode:
+			m.SetPatternMode(operation.PatternNoteCondition)
+		case mappings.ToggleFill:
+			m.playState.Fill = !m.playState.Fill
+			m.SyncBeatLoop()
 		case mappings.ToggleChordMode:
 			if m.definition.TemplateSequencerType == operation.SeqModeChord {
 				m.definition.TemplateSequencerType = operation.SeqModeLine
@@ -1547,24 +1965,61 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 			m.selectionIndicator = operation.SelectFileName
 		case mappings.Undo:
 			tempo, subdiv := m.definition.Tempo, m.definition.Subdivisions
-			undoStack := m.Undo()
-			if undoStack != EmptyStack {
-				m.PushRedo(undoStack)
-			}
+			m.StepUndo()
 			if tempo != m.definition.Tempo || subdiv != m.definition.Subdivisions {
 				m.SyncTempo()
 			}
 		case mappings.Redo:
 			tempo, subdiv := m.definition.Tempo, m.definition.Subdivisions
-			undoStack := m.Redo()
-			if undoStack != EmptyStack {
-				m.PushUndo(undoStack)
+			m.StepRedo()
+			if tempo != m.definition.Tempo || subdiv != m.definition.Subdivisions {
+				m.SyncTempo()
+			}
+		case mappings.UndoEarlier:
+			tempo, subdiv := m.definition.Tempo, m.definition.Subdivisions
+			if id, exists := m.undoTree.Earlier(m.undoStack.id); exists {
+				m.TravelTo(id)
+			}
+			if tempo != m.definition.Tempo || subdiv != m.definition.Subdivisions {
+				m.SyncTempo()
+			}
+		case mappings.UndoLater:
+			tempo, subdiv := m.definition.Tempo, m.definition.Subdivisions
+			if id, exists := m.undoTree.Later(m.undoStack.id); exists {
+				m.TravelTo(id)
 			}
 			if tempo != m.definition.Tempo || subdiv != m.definition.Subdivisions {
 				m.SyncTempo()
 			}
+		case mappings.ShowUndoTree:
+			m.Escape()
+			m.selectionIndicator = operation.SelectUndoTree
+		case mappings.CaptureSnapshot:
+			m.Escape()
+			m.snapshotPart = sequence.WholeSequence
+			m.selectionIndicator = operation.SelectSnapshotName
+		case mappings.CapturePartSnapshot:
+			m.Escape()
+			m.snapshotPart = m.CurrentPartID()
+			m.selectionIndicator = operation.SelectSnapshotName
+		case mappings.ShowSnapshots:
+			m.Escape()
+			m.ClampSnapshotIndex()
+			m.selectionIndicator = operation.SelectSnapshot
+		case mappings.ConfirmSnapshot:
+			m.RecallSnapshot()
+			m.SyncBeatLoop()
+		case mappings.RemoveSnapshot:
+			m.RemoveSnapshot()
+		case mappings.ShowLibrary:
+			m.Escape()
+			m.ScanLibrary()
+			m.selectionIndicator = operation.SelectLibrary
 		case mappings.New:
 			m.selectionIndicator = operation.SelectConfirmNew
+		case mappings.ImportMidi:
+			m.Escape()
+			m.selectionIndicator = operation.SelectImportFile
 		case mappings.ToggleVisualMode:
 			switch m.visualSelection.visualMode {
 			case operation.VisualBlock:
@@ -1675,11 +2130,21 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 		} else {
 			m.SetCurrentError(errors.New("cannot start when already started"))
 		}
+		m.startTick = msg.SongPosition
 		m.Start(0)
 	case timing.UIStopMsg:
 		m.playState.Playing = false
 		m.playState.PlayMode = playstate.PlayStandard
 		m.Stop()
+	case timing.UISongPositionMsg:
+		tick := renderer.NextBeatTick(m.definition, msg.SongPosition)
+		go func() {
+			timingChannel <- timing.NextBeatMsg{Tick: tick}
+		}()
+	case timing.UITempoMsg:
+		m.linkTempo = msg.Tempo
+	case timing.LinkPeersMsg:
+		m.linkPeers = msg.Peers
 	case timing.TransmitterConnectedMsg:
 		m.transmitterConnected = true
 	case timing.TransmitterNotConnectedMsg:
@@ -1696,6 +2161,9 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 			m.playState = msg.PlayState
 			m.arrangement.Cursor = msg.Cursor
 		}
+		if msg.PlayedQueued {
+			m.PlayQueuedSnapshot()
+		}
 		if msg.PerformStop {
 			m.playState.LineStates = playstate.InitLineStates(len(m.definition.Lines), m.playState.LineStates, 0)
 			m.ResetIterations()
@@ -1703,9 +2171,27 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 			m.SyncBeatLoop()
 		} else {
 			m.ResetCurrentOverlay()
+			if m.take != nil {
+				m.take.SetBeat(m.RecordBeat(msg))
+				if m.take.Mode == recorder.Replace {
+					m.take.Erase(m.RecordTarget())
+				}
+			}
 		}
 		m.arrangement.ResetDepth()
 		return m, nil
+	case recorder.NoteMsg:
+		if m.take != nil {
+			m.RecordNote(msg)
+		} else if m.step != nil {
+			m.StepNote(msg)
+		}
+		return m, nil
+	case controller.ControlMsg:
+		return m.UpdateControl(msg)
+	case autosaveMsg:
+		m.Autosave()
+		return m, m.AutosaveTick()
 	case beats.AnticipatoryStop:
 		if m.midiLoopMode == timing.MlmTransmitter {
 			timingChannel <- timing.AnticipatoryStopMsg{}
@@ -1718,9 +2204,84 @@ func (m model) Update(msg tea.Msg) (rModel tea.Model, rCmd tea.Cmd) {
 	return m, cmd
 }
 
+// Controls returns the bindings of the lua config with the bindings saved in
+// the sequence on top of them.
+func (m model) Controls() controller.Bindings {
+	return controller.FromConfig(config.GetControls()).Merge(m.definition.Controls)
+}
+
+// LearnTarget chooses the command a control is bound to during midi learn.
+// Escape or learn again cancels.
+func (m *model) LearnTarget(command mappings.Command) {
+	switch command {
+	case mappings.HoldingKeys:
+	case mappings.Escape, mappings.LearnControl:
+		m.learn = controller.Learn{}
+	default:
+		// Only commands with a name can be saved
+		if _, ok := mappings.CommandDescriptions[command]; ok {
+			m.learn.Command = command
+			m.learn.HasTarget = true
+		}
+	}
+}
+
+// UpdateControl binds the control during midi learn, otherwise it sets the
+// bound parameter or runs the bound command.
+func (m model) UpdateControl(msg controller.ControlMsg) (tea.Model, tea.Cmd) {
+	if m.learn.Active {
+		if binding, ok := m.learn.Bind(msg.Control); ok {
+			m.BindControl(binding)
+			m.learn = controller.Learn{}
+		}
+		return m, nil
+	}
+
+	binding, ok := m.Controls().Find(msg.Control)
+	if !ok {
+		return m, nil
+	}
+	if binding.Parameter != controller.ParameterNone {
+		if msg.Control.Kind == controller.KindCC {
+			m.SetParameter(binding.Parameter, msg.Value)
+		}
+		return m, nil
+	}
+	if msg.Pressed() {
+		return m.Update(mappings.Mapping{Command: binding.Command})
+	}
+	return m, nil
+}
+
+func (m *model) BindControl(binding controller.Binding) {
+	controls := m.definition.Controls.Bind(binding)
+	m.PushUndoables(UndoControls{m.definition.Controls}, UndoControls{controls})
+	m.ResetRedo()
+	m.definition.Controls = controls
+}
+
+// SetParameter follows a continuous control.  The accent start stays above
+// the accent end.
+func (m *model) SetParameter(parameter controller.Parameter, value uint8) {
+	switch parameter {
+	case controller.ParameterTempo:
+		m.definition.Tempo = parameter.Scale(value)
+		m.SyncTempo()
+	case controller.ParameterAccentStart:
+		m.definition.Accents.Start = uint8(min(max(int(value), int(m.definition.Accents.End)+1), 127))
+		m.definition.Accents.ReCalc()
+	case controller.ParameterAccentEnd:
+		if m.definition.Accents.Start > 0 {
+			m.definition.Accents.End = min(value, m.definition.Accents.Start-1)
+			m.definition.Accents.ReCalc()
+		}
+	}
+}
+
 func (m *model) SyncBeatLoop() {
+	queued := m.QueuedSequence()
 	go func() {
-		updateChannel <- beats.ModelMsg{Sequence: m.definition, PlayState: m.playState, Cursor: m.arrangement.Cursor}
+		updateChannel <- beats.ModelMsg{Sequence: m.definition, PlayState: m.playState, Cursor: m.arrangement.Cursor, Queued: queued}
 	}()
 }
 
@@ -1834,6 +2395,12 @@ func (m *model) PushUndoableDefinitionState() {
 		if m.CurrentPart().Beats != m.temporaryState.beats {
 			m.PushUndoables(UndoBeats{m.temporaryState.beats, m.arrangement.Cursor}, UndoBeats{m.CurrentPart().Beats, m.arrangement.Cursor})
 		}
+		if !m.CurrentPart().Grooves.Equal(m.temporaryState.grooves) {
+			m.PushUndoables(UndoGrooves{m.temporaryState.grooves, m.arrangement.Cursor}, UndoGrooves{m.CurrentPart().Grooves, m.arrangement.Cursor})
+		}
+		if m.CurrentPart().Meter != m.temporaryState.meter {
+			m.PushUndoables(UndoMeter{m.temporaryState.meter, m.arrangement.Cursor}, UndoMeter{m.CurrentPart().Meter, m.arrangement.Cursor})
+		}
 		m.temporaryState = temporaryState{}
 	}
 }
@@ -1842,10 +2409,12 @@ func (m *model) CaptureTemporaryState() {
 	linesCopy := make([]grid.LineDefinition, len(m.definition.Lines))
 	for i, defLine := range m.definition.Lines {
 		newLine := grid.LineDefinition{
-			Channel: defLine.Channel,
-			Note:    defLine.Note,
-			MsgType: defLine.MsgType,
-			Name:    defLine.Name,
+			Channel:  defLine.Channel,
+			Note:     defLine.Note,
+			MsgType:  defLine.MsgType,
+			Name:     defLine.Name,
+			Length:   defLine.Length,
+			Division: defLine.Division,
 		}
 		linesCopy[i] = newLine
 	}
@@ -1862,8 +2431,10 @@ func (m *model) CaptureTemporaryState() {
 			Target: m.definition.Accents.Target,
 			Data:   accentDataCopy,
 		},
-		beats:  m.CurrentPart().Beats,
-		active: true,
+		beats:   m.CurrentPart().Beats,
+		grooves: m.CurrentPart().Grooves,
+		meter:   m.CurrentPart().Meter,
+		active:  true,
 	}
 }
 
@@ -1907,15 +2478,48 @@ func (m *model) ClampAndSyncTempo() {
 }
 
 func (m model) NewSequence() model {
+	definition, _ := LoadFile("", m.definition.Template, m.definition.Instrument)
+	return m.ReplaceDefinition(definition)
+}
+
+// ImportFile replaces the sequence with one imported from a midi file.  The
+// imported sequence is named like the midi file, as sq import names it, so
+// that it never shares the recovery file of an untitled sequence.
+func (m model) ImportFile(filename string) (tea.Model, tea.Cmd) {
+	m.selectionIndicator = operation.SelectGrid
+	sequenceFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".sq"
+	if _, err := os.Stat(sequenceFilename); err == nil {
+		m.SetCurrentError(fault.New("sequence file exists", fmsg.WithDesc("sequence file exists", fmt.Sprintf("Cannot import %s, %s already exists", filename, sequenceFilename))))
+		return m, nil
+	}
+	options := midifile.DefaultImportOptions()
+	options.Template = m.definition.Template
+	options.Instrument = m.definition.Instrument
+	definition, err := midifile.Import(filename, options)
+	if err != nil {
+		m.SetCurrentError(err)
+		return m, nil
+	}
+	newModel := m.SwitchDefinition(definition)
+	newModel.filename = sequenceFilename
+	newModel.needsWrite = -1
+	cursor, cmd := m.cursor.Update(tea.FocusMsg{})
+	newModel.cursor = cursor
+	newModel.SyncTempo()
+	newModel.SyncBeatLoop()
+	return newModel, cmd
+}
+
+func (m model) ReplaceDefinition(definition sequence.Sequence) model {
 	newModel := m
 
-	definition, _ := LoadFile("", m.definition.Template, m.definition.Instrument)
 	newModel.definition = definition
 	newModel.arrangement = arrangement.InitModel(definition.Arrangement, definition.Parts)
 	newModel.ResetIterations()
 	newModel.SetGridCursor(GK(0, 0))
 	newModel.undoStack = UndoStack{}
 	newModel.redoStack = UndoStack{}
+	newModel.undoTree = UndoTree{}
 	newModel.activeChord = overlays.OverlayChord{}
 	newModel.currentOverlay = newModel.CurrentPart().Overlays
 	newModel.focus = operation.FocusGrid
@@ -2015,30 +2619,197 @@ func (m *model) Start(delay time.Duration) {
 		}
 	}
 
+	if m.startTick > 0 {
+		m.SeekSongPosition(m.startTick)
+		m.startTick = 0
+	}
+
 	if m.playState.Playing {
+		songPosition := m.SongPosition()
+		tempo, subdivisions := m.PlayTempo()
+		sequenceTempo := m.definition.Tempo
 		time.AfterFunc(delay, func() {
 			// NOTE: Order matters here, modelMsg must be sent before startMsg
 			updateChannel <- beats.ModelMsg{Sequence: m.definition, PlayState: m.playState, Cursor: m.arrangement.Cursor}
 			if m.playState.PlayMode != playstate.PlayReceiver {
-				timingChannel <- timing.StartMsg{LoopMode: m.playState.LoopMode, Tempo: m.definition.Tempo, Subdivisions: m.definition.Subdivisions, Prerollbeats: m.playState.RecordPreRollBeats, Transmitting: m.transmitting}
+				timingChannel <- timing.StartMsg{LoopMode: m.playState.LoopMode, Tempo: tempo, Subdivisions: subdivisions, SequenceTempo: sequenceTempo, Prerollbeats: m.playState.RecordPreRollBeats, SongPosition: songPosition, Transmitting: m.transmitting}
 			}
 		})
 	}
 }
 
 func (m *model) Stop() {
+	if m.take != nil {
+		m.EndTake()
+	}
 	m.playState.AllowAdvance = false
 	m.playState.RecordPreRollBeats = 0
 	m.playState.LoopMode = playstate.OneTimeWholeSequence
 	m.playState.LoopedArrangement = nil
 	m.arrangement.ResetDepth()
+	// A snapshot queued for the next key cycle is recalled on stopping
+	m.PlayQueuedSnapshot()
 	m.ResetCurrentOverlay()
 	m.SyncBeatLoop()
 
 	notes := notereg.Clear()
 	for _, n := range notes {
-		beatsLooper.PlayMessage(time.Duration(0), n)
+		beatsLooper.PlayMessage(time.Time{}, n)
+	}
+}
+
+// RecordBeat returns the beat each line was on when the beat was played.
+func (m model) RecordBeat(msg beats.ModelPlayedMsg) recorder.Beat {
+	lines := make([]uint8, len(msg.PlayState.LineStates))
+	for i, lineState := range msg.PlayState.LineStates {
+		lines[i] = lineState.CurrentBeat
+	}
+	return recorder.Beat{Time: msg.Time, Interval: msg.Interval, Lines: lines}
+}
+
+// RecordTarget returns the overlay notes are recorded into, the root overlay
+// of the current part unless recording into the current overlay.
+func (m *model) RecordTarget() recorder.Target {
+	overlay := m.CurrentPart().Overlays.FindOverlay(overlaykey.ROOT)
+	if m.recordIntoOverlay {
+		m.EnsureOverlay()
+		overlay = m.currentOverlay
 	}
+	return recorder.Target{Overlay: overlay, Beats: m.CurrentPart().Beats, Cursor: m.arrangement.Cursor}
+}
+
+func (m *model) RecordNote(msg recorder.NoteMsg) {
+	line, exists := recorder.FindLine(m.definition.Lines, msg.Channel, msg.Key)
+	if !exists {
+		return
+	}
+	if msg.On {
+		m.take.NoteOn(m.RecordTarget(), line, msg.Velocity, m.definition.Accents.Data, msg.Time)
+	} else {
+		m.take.NoteOff(line, msg.Time)
+	}
+}
+
+// EndTake stops recording and makes each overlay changed by the take undoable.
+func (m *model) EndTake() {
+	if m.step == nil {
+		m.midiConnection.StopRecording()
+	}
+	changes := m.take.Changes()
+	m.take = nil
+	for _, change := range changes {
+		overlay := change.Target.Overlay
+		undoable := UndoOverlayDiff{overlay.Key, m.gridCursor, change.Target.Cursor, overlays.DiffOverlays(overlay, change.Original)}
+		redoable := UndoOverlayDiff{overlay.Key, m.gridCursor, change.Target.Cursor, overlays.DiffOverlays(change.Original, overlay)}
+		if !undoable.overlayDiff.IsEmpty() {
+			m.PushUndoables(undoable, redoable)
+		}
+	}
+	if len(changes) > 0 {
+		m.ResetRedo()
+	}
+}
+
+func (m *model) StepNote(msg recorder.NoteMsg) {
+	if msg.On {
+		m.step.Press(msg)
+		return
+	}
+	notes, done := m.step.Release(msg)
+	if !done {
+		return
+	}
+	m.EnsureOverlay()
+	deepCopy := overlays.DeepCopy(m.currentOverlay)
+	m.EnterStep(notes)
+	undoable := m.UndoableOverlay(m.currentOverlay, deepCopy)
+	redoable := m.UndoableOverlay(deepCopy, m.currentOverlay)
+	if !undoable.overlayDiff.IsEmpty() {
+		m.PushUndoables(undoable, redoable)
+		m.ResetRedo()
+	}
+}
+
+// EnterStep puts the notes played together on the cursor beat and moves the
+// cursor to the next beat.  In polyphony mode several notes become a chord and
+// in mono mode the last note played replaces the notes on the beat.
+func (m *model) EnterStep(notes []recorder.NoteMsg) {
+	beat := m.gridCursor.Beat
+	var line uint8
+	var entered bool
+	switch m.definition.TemplateSequencerType {
+	case operation.SeqModeChord:
+		line, entered = m.EnterStepChord(notes, beat)
+	case operation.SeqModeMono:
+		line, entered = m.EnterStepNote(notes[len(notes)-1], beat)
+		if entered {
+			pattern := m.CombinedEditPattern(m.currentOverlay)
+			for i := range uint8(len(m.definition.Lines)) {
+				currentNote, hasNote := pattern[GK(i, beat)]
+				if i != line && hasNote && currentNote != zeronote {
+					m.currentOverlay.SetNote(GK(i, beat), zeronote)
+				}
+			}
+		}
+	default:
+		for _, note := range notes {
+			if noteLine, ok := m.EnterStepNote(note, beat); ok {
+				line, entered = noteLine, true
+			}
+		}
+	}
+	if entered {
+		m.SetGridCursor(GK(line, (beat+1)%m.CurrentPart().Beats))
+	}
+}
+
+func (m *model) EnterStepNote(msg recorder.NoteMsg, beat uint8) (uint8, bool) {
+	line, exists := recorder.FindLine(m.definition.Lines, msg.Channel, msg.Key)
+	if !exists {
+		return 0, false
+	}
+	note := grid.InitNote()
+	note.AccentIndex = midifile.AccentIndex(msg.Velocity, m.definition.Accents.Data)
+	m.currentOverlay.SetNote(GK(line, beat), note)
+	return line, true
+}
+
+// EnterStepChord creates a chord from the root detected in the notes, replacing
+// a chord already rooted on the same line and beat.
+func (m *model) EnterStepChord(notes []recorder.NoteMsg, beat uint8) (uint8, bool) {
+	keys := recorder.Keys(notes)
+	if len(keys) == 1 {
+		return m.EnterStepNote(notes[0], beat)
+	}
+	channel := notes[0].Channel
+	root := theory.DetectRoot(keys)
+	rootLine, exists := recorder.FindLine(m.definition.Lines, channel, root)
+	if !exists {
+		root = slices.Min(keys)
+		rootLine, exists = recorder.FindLine(m.definition.Lines, channel, root)
+		if !exists {
+			return 0, false
+		}
+	}
+
+	rootKey := GK(rootLine, beat)
+	for _, gridChord := range m.currentOverlay.Chords {
+		if gridChord.Root == rootKey {
+			m.currentOverlay.Chords = m.currentOverlay.Chords.Remove(gridChord)
+			break
+		}
+	}
+	m.currentOverlay.CreateChord(rootKey, theory.Alteration(root, keys))
+
+	velocity := 0
+	for _, note := range notes {
+		velocity += int(note.Velocity)
+	}
+	gridChord := m.currentOverlay.Chords[len(m.currentOverlay.Chords)-1]
+	for i := range gridChord.Notes {
+		gridChord.Notes[i].Note.AccentIndex = midifile.AccentIndex(uint8(velocity/len(notes)), m.definition.Accents.Data)
+	}
+	return rootLine, true
 }
 
 func (m *model) StartStop(delay time.Duration) {
@@ -2053,7 +2824,7 @@ func (m *model) StartStop(delay time.Duration) {
 func (m *model) SafeStart(delay time.Duration) {
 	m.playState.Playing = true
 	m.playState.PlayMode = playstate.PlayStandard
-	if m.midiLoopMode == timing.MlmReceiver {
+	if m.midiLoopMode == timing.MlmReceiver || m.midiLoopMode == timing.MlmClockReceiver {
 		// NOTE: When instance is receiver, allow it to play alone and lock out transmitter messages
 		m.lockReceiverChannel <- true
 	}
@@ -2065,7 +2836,7 @@ func (m *model) SafeStop() {
 	if m.playState.PlayMode == playstate.PlayStandard {
 		go func() {
 			timingChannel <- timing.StopMsg{}
-			if m.midiLoopMode == timing.MlmReceiver {
+			if m.midiLoopMode == timing.MlmReceiver || m.midiLoopMode == timing.MlmClockReceiver {
 				// NOTE: Unlock to allow transmitter messages
 				m.unlockReceiverChannel <- true
 			}
@@ -2117,12 +2888,22 @@ func (m model) UpdateDefinitionKeys(mapping mappings.Mapping) model {
 				m.SetSetupChannel(number)
 			case operation.SelectSetupValue:
 				m.SetSetupValue(number)
+			case operation.SelectSetupLength:
+				m.SetSetupLength(number)
+			case operation.SelectMeterNumerator:
+				m.SetMeterNumerator(number)
 			case operation.SelectAccentStart:
 				m.SetAccentStart(number)
 			case operation.SelectAccentEnd:
 				m.SetAccentEnd(number)
 			case operation.SelectEuclideanHits:
 				m.SetEuclideanHits(number)
+			case operation.SelectGrooveAmount:
+				m.SetGrooveAmount(number)
+			case operation.SelectLockControl:
+				m.SetLockControl(number)
+			case operation.SelectLockValue:
+				m.SetLockValue(number)
 			}
 		}
 		return m
@@ -2132,6 +2913,8 @@ func (m model) UpdateDefinitionKeys(mapping mappings.Mapping) model {
 	case mappings.ConfirmEuclidenHits:
 		m.ApplyEuclidean(m.euclideanHits)
 		m.SetSelectionIndicator(operation.SelectGrid)
+	case mappings.RemoveLock:
+		m.RemoveLock()
 	case mappings.NoteAdd:
 		m.AddNote()
 	case mappings.NoteRemove:
@@ -2351,23 +3134,31 @@ func (m model) UpdateDefinitionKeys(mapping mappings.Mapping) model {
 		beatInterval, _ := strconv.ParseInt(mapping.LastValue, 0, 8)
 		switch m.patternMode {
 		case operation.PatternFill:
-			m.fill(uint8(beatInterval))
+			m.fill(m.PatternInterval(uint8(beatInterval)))
 		case operation.PatternAccent:
-			m.incrementAccent(uint8(beatInterval), -1, operation.EveryBeat)
+			m.incrementAccent(m.PatternInterval(uint8(beatInterval)), -1, operation.EveryBeat)
 		case operation.PatternNoteAccent:
 			m.incrementAccent(uint8(beatInterval), -1, operation.EveryNote)
 		case operation.PatternGate:
-			m.incrementGate(uint8(beatInterval), -1, operation.EveryBeat)
+			m.incrementGate(m.PatternInterval(uint8(beatInterval)), -1, operation.EveryBeat)
 		case operation.PatternNoteGate:
 			m.incrementGate(uint8(beatInterval), -1, operation.EveryNote)
 		case operation.PatternRatchet:
-			m.incrementRatchet(uint8(beatInterval), -1, operation.EveryBeat)
+			m.incrementRatchet(m.PatternInterval(uint8(beatInterval)), -1, operation.EveryBeat)
 		case operation.PatternNoteRatchet:
 			m.incrementRatchet(uint8(beatInterval), -1, operation.EveryNote)
 		case operation.PatternWait:
-			m.incrementWait(uint8(beatInterval), -1, operation.EveryBeat)
+			m.incrementWait(m.PatternInterval(uint8(beatInterval)), -1, operation.EveryBeat)
 		case operation.PatternNoteWait:
 			m.incrementWait(uint8(beatInterval), -1, operation.EveryNote)
+		case operation.PatternProbability:
+			m.incrementProbability(m.PatternInterval(uint8(beatInterval)), -1, operation.EveryBeat)
+		case operation.PatternNoteProbability:
+			m.incrementProbability(uint8(beatInterval), -1, operation.EveryNote)
+		case operation.PatternCondition:
+			m.incrementCondition(m.PatternInterval(uint8(beatInterval)), -1, operation.EveryBeat)
+		case operation.PatternNoteCondition:
+			m.incrementCondition(uint8(beatInterval), -1, operation.EveryNote)
 		}
 	}
 
@@ -2375,23 +3166,31 @@ func (m model) UpdateDefinitionKeys(mapping mappings.Mapping) model {
 		beatInterval := convertSymbolToInt(mapping.LastValue)
 		switch m.patternMode {
 		case operation.PatternFill:
-			m.fillSpaces(uint8(beatInterval))
+			m.fillSpaces(m.PatternInterval(uint8(beatInterval)))
 		case operation.PatternAccent:
-			m.incrementAccent(uint8(beatInterval), 1, operation.EveryBeat)
+			m.incrementAccent(m.PatternInterval(uint8(beatInterval)), 1, operation.EveryBeat)
 		case operation.PatternNoteAccent:
 			m.incrementAccent(uint8(beatInterval), 1, operation.EveryNote)
 		case operation.PatternGate:
-			m.incrementGate(uint8(beatInterval), 1, operation.EveryBeat)
+			m.incrementGate(m.PatternInterval(uint8(beatInterval)), 1, operation.EveryBeat)
 		case operation.PatternNoteGate:
 			m.incrementGate(uint8(beatInterval), 1, operation.EveryNote)
 		case operation.PatternRatchet:
-			m.incrementRatchet(uint8(beatInterval), 1, operation.EveryBeat)
+			m.incrementRatchet(m.PatternInterval(uint8(beatInterval)), 1, operation.EveryBeat)
 		case operation.PatternNoteRatchet:
 			m.incrementRatchet(uint8(beatInterval), 1, operation.EveryNote)
 		case operation.PatternWait:
-			m.incrementWait(uint8(beatInterval), 1, operation.EveryBeat)
+			m.incrementWait(m.PatternInterval(uint8(beatInterval)), 1, operation.EveryBeat)
 		case operation.PatternNoteWait:
 			m.incrementWait(uint8(beatInterval), 1, operation.EveryNote)
+		case operation.PatternProbability:
+			m.incrementProbability(m.PatternInterval(uint8(beatInterval)), 1, operation.EveryBeat)
+		case operation.PatternNoteProbability:
+			m.incrementProbability(uint8(beatInterval), 1, operation.EveryNote)
+		case operation.PatternCondition:
+			m.incrementCondition(m.PatternInterval(uint8(beatInterval)), 1, operation.EveryBeat)
+		case operation.PatternNoteCondition:
+			m.incrementCondition(uint8(beatInterval), 1, operation.EveryNote)
 		}
 	}
 
@@ -2435,6 +3234,58 @@ func (m *model) SetSetupChannel(number int) {
 		uint8(m.clamp(m.UnshiftDigit(int(m.definition.Lines[m.gridCursor.Line].Channel), number), 1, 16))
 }
 
+// IncreaseSetupLength changes the length of the current line, a line as long
+// as the part is left without a length so that it follows the part.
+func (m *model) IncreaseSetupLength(modifier int) {
+	m.setSetupLength(int(m.definition.Lines[m.gridCursor.Line].Steps(m.CurrentPart().Beats)) + modifier)
+}
+
+func (m *model) SetSetupLength(number int) {
+	m.setSetupLength(m.UnshiftDigit(int(m.definition.Lines[m.gridCursor.Line].Steps(m.CurrentPart().Beats)), number))
+}
+
+func (m *model) setSetupLength(length int) {
+	beats := int(m.CurrentPart().Beats)
+	length = m.clamp(length, 1, beats)
+	if length == beats {
+		length = 0
+	}
+	m.definition.Lines[m.gridCursor.Line].Length = uint8(length)
+}
+
+// CurrentPartMeter returns the time signature of the current part to change.
+func (m *model) CurrentPartMeter() *arrangement.Meter {
+	return &(*m.definition.Parts)[m.CurrentPartID()].Meter
+}
+
+func (m *model) SetMeterNumerator(number int) {
+	meter := m.CurrentPartMeter()
+	if !meter.IsSet() {
+		meter.IncreaseNumerator(1)
+	}
+	meter.Numerator = uint8(m.clamp(m.UnshiftDigit(int(meter.Numerator), number), 1, 32))
+}
+
+// MeterSubdivisions returns the subdivisions that the time signature of the
+// current part divides, those of the current section when it has its own.
+func (m model) MeterSubdivisions() int {
+	return m.CurrentSongSection().SubdivisionsOr(m.definition.Subdivisions)
+}
+
+// PatternInterval returns the beats between the notes of a pattern of every
+// number beats, or of every number bars when counting in bars of the time
+// signature of the current part.
+func (m model) PatternInterval(number uint8) uint8 {
+	if !m.patternBars {
+		return number
+	}
+	barSteps := m.CurrentPart().Meter.BarSteps(m.MeterSubdivisions())
+	if barSteps == 0 {
+		return number
+	}
+	return uint8(min(int(number)*barSteps, 255))
+}
+
 func (m *model) SetTempoSubdivision(number int) {
 	m.definition.Subdivisions = m.clamp(number, 1, 8)
 }
@@ -2661,11 +3512,60 @@ func (m model) UndoableOverlay(overlayA, overlayB *overlays.Overlay) UndoOverlay
 }
 
 func (m *model) Save() {
+	if err := autosave.RotateBackups(m.filename, m.backups); err != nil {
+		m.SetCurrentError(err)
+	}
 	err := sequence.Write(m.definition, m.filename)
 	if err != nil {
 		m.SetCurrentError(fault.Wrap(err, fmsg.With("cannot write file")))
+		return
 	}
 	m.needsWrite = m.undoStack.id
+	m.autosaved = m.undoStack.id
+	if err := autosave.RemoveRecovery(m.filename); err != nil {
+		m.SetCurrentError(err)
+	}
+	if err := m.WriteUndoHistory(); err != nil {
+		m.SetCurrentError(err)
+	}
+}
+
+// Autosave writes the changes made since the last save, or the last
+// autosave, to the recovery file of the sequence.
+func (m *model) Autosave() {
+	if !m.NeedsWrite() || m.autosaved == m.undoStack.id {
+		return
+	}
+	err := sequence.Write(m.definition, autosave.RecoveryFile(m.filename))
+	if err != nil {
+		m.SetCurrentError(fault.Wrap(err, fmsg.With("cannot write recovery file")))
+		return
+	}
+	m.autosaved = m.undoStack.id
+}
+
+// AutosaveAfterPanic writes the recovery file of a model that panicked.  A
+// model too broken to write is logged rather than panicking again.
+func (m *model) AutosaveAfterPanic() {
+	defer func() {
+		if r := recover(); r != nil {
+			m.LogString(fmt.Sprintf("Could not write the recovery file after a panic: %v\n", r))
+		}
+	}()
+	m.Autosave()
+}
+
+// RecoverFile replaces the sequence with the changes of its recovery file.
+// The changes stay unsaved until the sequence is saved.
+func (m model) RecoverFile() model {
+	definition, err := LoadFile(autosave.RecoveryFile(m.filename), m.definition.Template, m.definition.Instrument)
+	if err != nil {
+		m.SetCurrentError(fault.Wrap(err, fmsg.WithDesc("could not recover file", fmt.Sprintf("Could not read the recovery file %s", autosave.RecoveryFile(m.filename)))))
+		return m
+	}
+	newModel := m.ReplaceDefinition(definition)
+	newModel.needsWrite = -1
+	return newModel
 }
 
 func (m model) CurrentPart() arrangement.Part {
@@ -2694,6 +3594,31 @@ func (m model) CurrentSongSection() arrangement.SongSection {
 	panic("Cursor should always be at end node")
 }
 
+// SeekSongPosition moves the cursor and line states to the first beat at or
+// after tick so that playback picks up mid-song.
+func (m *model) SeekSongPosition(tick int64) {
+	playState, cursor := renderer.Seek(m.definition, renderer.TickBeat(m.definition, tick))
+	m.playState.LineStates = playState.LineStates
+	m.playState.Iterations = playState.Iterations
+	m.arrangement.Cursor = cursor
+	m.arrangement.ResetDepth()
+}
+
+// SongPosition returns the position playback starts from in midi beats, for
+// devices following the midi clock.
+func (m model) SongPosition() uint16 {
+	if m.playState.LoopMode == playstate.OneTimeWholeSequence || m.playState.LoopMode == playstate.LoopWholeSequence {
+		return 0
+	}
+
+	tick := renderer.SongPosition(m.definition, m.arrangement.Cursor.GetCurrentNode())
+	section := m.CurrentSongSection()
+	if m.playState.LoopMode == playstate.LoopOverlay && m.playState.BoundedLoop.Active && int(m.playState.BoundedLoop.LeftBound) > section.StartBeat {
+		tick += int64(int(m.playState.BoundedLoop.LeftBound)-section.StartBeat) * renderer.BeatTicks(section.SubdivisionsOr(m.definition.Subdivisions))
+	}
+	return timing.SongPosition(tick)
+}
+
 func (m model) CurrentNote() (note, bool) {
 	note, exists := m.currentOverlay.GetNote(m.gridCursor)
 	return note, exists
@@ -3771,6 +4696,46 @@ func (m *model) incrementWait(every uint8, modifier int8, everyMode operation.Ev
 	}
 }
 
+func (m *model) incrementProbability(every uint8, modifier int8, everyMode operation.EveryMode) {
+	combinedOverlay := m.CombinedEditPattern(m.currentOverlay)
+
+	everyFn := func(gridKey gridKey) {
+		currentNote, hasNote := combinedOverlay[gridKey]
+		hasNote = hasNote && currentNote != zeronote
+
+		if hasNote {
+			m.currentOverlay.SetNote(gridKey, currentNote.IncrementProbability(modifier))
+		}
+	}
+
+	switch everyMode {
+	case operation.EveryBeat:
+		m.Every(every, everyFn)
+	case operation.EveryNote:
+		m.EveryNote(every, everyFn, combinedOverlay)
+	}
+}
+
+func (m *model) incrementCondition(every uint8, modifier int8, everyMode operation.EveryMode) {
+	combinedOverlay := m.CombinedEditPattern(m.currentOverlay)
+
+	everyFn := func(gridKey gridKey) {
+		currentNote, hasNote := combinedOverlay[gridKey]
+		hasNote = hasNote && currentNote != zeronote
+
+		if hasNote {
+			m.currentOverlay.SetNote(gridKey, currentNote.IncrementCondition(modifier))
+		}
+	}
+
+	switch everyMode {
+	case operation.EveryBeat:
+		m.Every(every, everyFn)
+	case operation.EveryNote:
+		m.EveryNote(every, everyFn, combinedOverlay)
+	}
+}
+
 func (m *model) IncrementSpecificValue(note grid.Note) {
 	note.AccentIndex = note.AccentIndex + 1
 	if note.AccentIndex < 128 {
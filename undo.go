@@ -2,9 +2,12 @@ package main
 
 import (
 	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/controller"
 	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
 	"github.com/chriserin/sq/internal/operation"
 	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/sequence"
 )
 
 type Undoable interface {
@@ -38,6 +41,30 @@ func (ub UndoBeats) ApplyUndo(m *model) Location {
 	return Location{ApplyLocation: false}
 }
 
+type UndoGrooves struct {
+	grooves   groove.Settings
+	ArrCursor arrangement.ArrCursor
+}
+
+func (ug UndoGrooves) ApplyUndo(m *model) Location {
+	m.arrangement.Cursor = ug.ArrCursor
+	partID := m.CurrentPartID()
+	(*m.definition.Parts)[partID].Grooves = ug.grooves
+	return Location{ApplyLocation: false}
+}
+
+type UndoMeter struct {
+	meter     arrangement.Meter
+	ArrCursor arrangement.ArrCursor
+}
+
+func (um UndoMeter) ApplyUndo(m *model) Location {
+	m.arrangement.Cursor = um.ArrCursor
+	partID := m.CurrentPartID()
+	(*m.definition.Parts)[partID].Meter = um.meter
+	return Location{ApplyLocation: false}
+}
+
 type UndoSpecificValue struct {
 	overlayKey     overlayKey
 	cursorPosition gridKey
@@ -122,6 +149,15 @@ func (uod UndoOverlayDiff) ApplyUndo(m *model) Location {
 	return Location{uod.overlayKey, uod.cursorPosition, true}
 }
 
+type UndoControls struct {
+	controls controller.Bindings
+}
+
+func (uc UndoControls) ApplyUndo(m *model) Location {
+	m.definition.Controls = uc.controls
+	return Location{ApplyLocation: false}
+}
+
 type UndoStateDiff struct {
 	stateDiff StateDiff
 }
@@ -143,3 +179,22 @@ func (usd UndoStateDiff) ApplyUndo(m *model) Location {
 	}
 	return Location{ApplyLocation: false}
 }
+
+type UndoSnapshots struct {
+	snapshots []sequence.Snapshot
+}
+
+func (us UndoSnapshots) ApplyUndo(m *model) Location {
+	m.definition.Snapshots = us.snapshots
+	m.ClampSnapshotIndex()
+	return Location{ApplyLocation: false}
+}
+
+type UndoRecall struct {
+	snapshot sequence.Snapshot
+}
+
+func (ur UndoRecall) ApplyUndo(m *model) Location {
+	m.ApplySnapshot(ur.snapshot)
+	return Location{ApplyLocation: false}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Southclaws/fault"
+	"github.com/Southclaws/fault/fmsg"
+	"github.com/chriserin/sq/internal/arrangement"
+	"github.com/chriserin/sq/internal/controller"
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/overlays"
+	"github.com/chriserin/sq/internal/sequence"
+)
+
+// A history of another version is ignored rather than migrated
+const undoHistoryVersion = 2
+
+// undoHistory is the serializable form of the undo tree, written next to the
+// sequence file when it is saved.  The edits are in the order they were made,
+// current is the edit of the saved state and redo lists the edits that redo
+// steps through, the next one first.  The fingerprint of the saved file keeps
+// a history from being applied to a file changed elsewhere.
+type undoHistory struct {
+	Version     int                      `json:"version"`
+	Fingerprint string                   `json:"fingerprint"`
+	Root        int                      `json:"root"`
+	Nodes       []arrangement.NodeRecord `json:"nodes"`
+	Current     int                      `json:"current"`
+	Redo        []int                    `json:"redo"`
+	Edits       []undoEntry              `json:"edits"`
+}
+
+type undoEntry struct {
+	ID     int        `json:"id"`
+	Parent int        `json:"parent"`
+	Time   time.Time  `json:"time"`
+	Undo   undoRecord `json:"undo"`
+	Redo   undoRecord `json:"redo"`
+}
+
+// undoRecord is the serializable form of an Undoable, the fields in use
+// depend on its type.
+type undoRecord struct {
+	Type        string                  `json:"type"`
+	Cursor      []int                   `json:"cursor,omitempty"`
+	Location    *locationRecord         `json:"location,omitempty"`
+	Beats       uint8                   `json:"beats,omitempty"`
+	Grooves     groove.Settings         `json:"grooves,omitempty"`
+	Meter       *arrangement.Meter      `json:"meter,omitempty"`
+	Value       uint8                   `json:"value,omitempty"`
+	Overlay     *overlayRecord          `json:"overlay,omitempty"`
+	OverlayDiff *overlayDiffRecord      `json:"overlayDiff,omitempty"`
+	Arrangement *arrangement.UndoRecord `json:"arrangement,omitempty"`
+	Controls    []controlRecord         `json:"controls,omitempty"`
+	StateDiff   *StateDiff              `json:"stateDiff,omitempty"`
+	Snapshots   []sequence.Snapshot     `json:"snapshots,omitempty"`
+	Snapshot    *sequence.Snapshot      `json:"snapshot,omitempty"`
+}
+
+const (
+	undoTypeBeats         = "beats"
+	undoTypeGrooves       = "grooves"
+	undoTypeMeter         = "meter"
+	undoTypeSpecificValue = "specificValue"
+	undoTypeNewOverlay    = "newOverlay"
+	undoTypeRemoveOverlay = "removeOverlay"
+	undoTypeArrangement   = "arrangement"
+	undoTypeOverlayDiff   = "overlayDiff"
+	undoTypeControls      = "controls"
+	undoTypeStateDiff     = "stateDiff"
+	undoTypeSnapshots     = "snapshots"
+	undoTypeRecall        = "recall"
+)
+
+// locationRecord is the serializable form of the Location an undo returns to.
+type locationRecord struct {
+	OverlayKey overlayKey `json:"overlayKey"`
+	GridKey    gridKey    `json:"gridKey"`
+}
+
+type noteRecord struct {
+	GridKey gridKey `json:"gridKey"`
+	Note    note    `json:"note"`
+}
+
+type noteDiffRecord struct {
+	GridKey gridKey `json:"gridKey"`
+	OldNote note    `json:"oldNote"`
+	NewNote note    `json:"newNote"`
+}
+
+type overlayRecord struct {
+	Key       overlayKey           `json:"key"`
+	PressUp   bool                 `json:"pressUp"`
+	PressDown bool                 `json:"pressDown"`
+	Notes     []noteRecord         `json:"notes,omitempty"`
+	Chords    []overlays.GridChord `json:"chords,omitempty"`
+}
+
+type overlayDiffRecord struct {
+	AddedNotes    []noteRecord         `json:"addedNotes,omitempty"`
+	RemovedNotes  []noteRecord         `json:"removedNotes,omitempty"`
+	ModifiedNotes []noteDiffRecord     `json:"modifiedNotes,omitempty"`
+	AddedChords   []overlays.GridChord `json:"addedChords,omitempty"`
+	RemovedChords []overlays.GridChord `json:"removedChords,omitempty"`
+	// Modified chords are applied by the old chord alone
+	ModifiedChords   []overlays.GridChord `json:"modifiedChords,omitempty"`
+	PressUpChanged   bool                 `json:"pressUpChanged,omitempty"`
+	PressDownChanged bool                 `json:"pressDownChanged,omitempty"`
+}
+
+// Controls are kept by name so that the history survives changes to
+// the order of the commands
+type controlRecord struct {
+	Type      string `json:"type"`
+	Channel   uint8  `json:"channel"`
+	Number    uint8  `json:"number"`
+	Command   string `json:"command,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// UndoHistoryFile returns the hidden sidecar file that the undo history of a
+// sequence file is written to.
+func UndoHistoryFile(filename string) string {
+	dir, base := filepath.Split(filename)
+	return filepath.Join(dir, fmt.Sprintf(".%s.undo", base))
+}
+
+// WriteUndoHistory writes the undo tree next to the saved sequence file,
+// up to the history size of undos, of redos and of edits on other branches.
+func (m *model) WriteUndoHistory() error {
+	if m.undoHistory <= 0 || m.filename == "" {
+		return nil
+	}
+	fingerprint, err := fileFingerprint(m.filename)
+	if err != nil {
+		return err
+	}
+
+	ids := arrangement.NodeIDs{}
+	history := undoHistory{
+		Version:     undoHistoryVersion,
+		Fingerprint: fingerprint,
+		Root:        ids.ID(m.arrangement.Root),
+		Current:     m.undoStack.id,
+	}
+	redo := stackIDs(m.redoStack)
+	kept, base := keptEdits(m.undoTree, m.undoStack.id, redo, m.undoHistory)
+	for _, id := range redo {
+		if !kept[id] {
+			break
+		}
+		history.Redo = append(history.Redo, id)
+	}
+	for _, node := range m.undoTree.nodes {
+		if !kept[node.id] {
+			continue
+		}
+		entry := undoEntry{ID: node.id, Parent: node.parent, Time: node.time}
+		// The edits of the oldest kept state are undone back to the root
+		if entry.Parent == base {
+			entry.Parent = EmptyStack.id
+		}
+		if entry.Undo, err = recordUndoable(ids, node.undo); err != nil {
+			return err
+		}
+		if entry.Redo, err = recordUndoable(ids, node.redo); err != nil {
+			return err
+		}
+		history.Edits = append(history.Edits, entry)
+	}
+	history.Nodes = ids.Records()
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot write undo history", "Could not encode the undo history"))
+	}
+	if err := os.WriteFile(UndoHistoryFile(m.filename), data, 0644); err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot write undo history", fmt.Sprintf("Could not write the undo history of %s", m.filename)))
+	}
+	return nil
+}
+
+// LoadUndoHistory restores the undo tree written when the sequence file was
+// saved.  A missing history, or one written for another version of the file,
+// is left alone.
+func (m *model) LoadUndoHistory() error {
+	if m.undoHistory <= 0 || m.filename == "" {
+		return nil
+	}
+	data, err := os.ReadFile(UndoHistoryFile(m.filename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot read undo history", fmt.Sprintf("Could not read the undo history of %s", m.filename)))
+	}
+
+	var history undoHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return fault.Wrap(err, fmsg.WithDesc("cannot read undo history", fmt.Sprintf("The undo history of %s is not valid", m.filename)))
+	}
+	fingerprint, err := fileFingerprint(m.filename)
+	if err != nil {
+		return err
+	}
+	if history.Version != undoHistoryVersion || history.Fingerprint != fingerprint {
+		return nil
+	}
+
+	nodes, err := arrangement.ResolveNodes(history.Nodes, history.Root, m.arrangement.Root)
+	if err != nil {
+		return err
+	}
+	var tree UndoTree
+	for _, entry := range history.Edits {
+		if _, exists := tree.find(entry.Parent); !exists && entry.Parent != EmptyStack.id {
+			return invalidUndoHistory("edit %d follows the unknown edit %d", entry.ID, entry.Parent)
+		}
+		undo, err := restoreUndoable(nodes, entry.Undo)
+		if err != nil {
+			return err
+		}
+		redo, err := restoreUndoable(nodes, entry.Redo)
+		if err != nil {
+			return err
+		}
+		tree.Add(UndoNode{id: entry.ID, parent: entry.Parent, undo: undo, redo: redo, time: entry.Time})
+	}
+	if _, exists := tree.find(history.Current); !exists && history.Current != EmptyStack.id {
+		return invalidUndoHistory("the current edit %d is unknown", history.Current)
+	}
+	var redos []UndoNode
+	for _, id := range history.Redo {
+		redo, exists := tree.find(id)
+		if !exists {
+			return invalidUndoHistory("the redo edit %d is unknown", id)
+		}
+		redos = append(redos, redo)
+	}
+
+	m.undoTree = tree
+	m.undoStack, m.redoStack = EmptyStack, EmptyStack
+	for _, node := range slices.Backward(tree.Ancestors(history.Current)) {
+		m.PushUndo(node.stack())
+	}
+	for _, node := range slices.Backward(redos) {
+		m.PushRedo(node.stack())
+	}
+	m.needsWrite = m.undoStack.id
+	m.autosaved = m.undoStack.id
+	return nil
+}
+
+func fileFingerprint(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fault.Wrap(err, fmsg.WithDesc("cannot read file", fmt.Sprintf("Could not read %s", filename)))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func stackIDs(stack UndoStack) []int {
+	var ids []int
+	for current := &stack; current != nil && *current != EmptyStack; current = current.next {
+		ids = append(ids, current.id)
+	}
+	return ids
+}
+
+// keptEdits chooses the edits written to the history: up to size edits back
+// from the current edit, size redos and size of the most recent edits of
+// other branches.  Edits are only kept along with the edit before them, the
+// base, the state before the oldest kept edit, stands in for the root.
+func keptEdits(tree UndoTree, current int, redo []int, size int) (map[int]bool, int) {
+	kept := make(map[int]bool)
+	ancestors := tree.Ancestors(current)
+	ancestors = ancestors[:min(len(ancestors), size)]
+	base := EmptyStack.id
+	if len(ancestors) > 0 {
+		base = ancestors[len(ancestors)-1].parent
+	}
+	for _, node := range ancestors {
+		kept[node.id] = true
+	}
+	for _, id := range redo[:min(len(redo), size)] {
+		kept[id] = true
+	}
+
+	recent := make(map[int]bool)
+	for i := len(tree.nodes) - 1; i >= 0 && len(recent) < size; i-- {
+		if !kept[tree.nodes[i].id] {
+			recent[tree.nodes[i].id] = true
+		}
+	}
+	for _, node := range tree.nodes {
+		if recent[node.id] && (kept[node.parent] || node.parent == base) {
+			kept[node.id] = true
+		}
+	}
+	return kept, base
+}
+
+func recordUndoable(ids arrangement.NodeIDs, undoable Undoable) (undoRecord, error) {
+	switch undo := undoable.(type) {
+	case nil:
+		return undoRecord{}, nil
+	case UndoBeats:
+		return undoRecord{Type: undoTypeBeats, Cursor: ids.Cursor(undo.ArrCursor), Beats: undo.beats}, nil
+	case UndoGrooves:
+		return undoRecord{Type: undoTypeGrooves, Cursor: ids.Cursor(undo.ArrCursor), Grooves: undo.grooves}, nil
+	case UndoMeter:
+		return undoRecord{Type: undoTypeMeter, Cursor: ids.Cursor(undo.ArrCursor), Meter: &undo.meter}, nil
+	case UndoSpecificValue:
+		return undoRecord{
+			Type:     undoTypeSpecificValue,
+			Cursor:   ids.Cursor(undo.ArrCursor),
+			Location: &locationRecord{undo.overlayKey, undo.cursorPosition},
+			Value:    undo.specificValue,
+		}, nil
+	case UndoNewOverlay:
+		return undoRecord{
+			Type:     undoTypeNewOverlay,
+			Cursor:   ids.Cursor(undo.ArrCursor),
+			Location: &locationRecord{undo.overlayKey, undo.cursorPosition},
+		}, nil
+	case UndoRemoveOverlay:
+		return undoRecord{
+			Type:     undoTypeRemoveOverlay,
+			Cursor:   ids.Cursor(undo.ArrCursor),
+			Location: &locationRecord{undo.overlayKey, undo.cursorPosition},
+			Overlay:  recordOverlay(undo.overlay),
+		}, nil
+	case UndoArrangement:
+		arrUndo := ids.Record(undo.arrUndo)
+		return undoRecord{Type: undoTypeArrangement, Arrangement: &arrUndo}, nil
+	case UndoOverlayDiff:
+		return undoRecord{
+			Type:        undoTypeOverlayDiff,
+			Cursor:      ids.Cursor(undo.ArrCursor),
+			Location:    &locationRecord{undo.overlayKey, undo.cursorPosition},
+			OverlayDiff: recordOverlayDiff(undo.overlayDiff),
+		}, nil
+	case UndoControls:
+		return undoRecord{Type: undoTypeControls, Controls: recordControls(undo.controls)}, nil
+	case UndoStateDiff:
+		return undoRecord{Type: undoTypeStateDiff, StateDiff: &undo.stateDiff}, nil
+	case UndoSnapshots:
+		return undoRecord{Type: undoTypeSnapshots, Snapshots: undo.snapshots}, nil
+	case UndoRecall:
+		return undoRecord{Type: undoTypeRecall, Snapshot: &undo.snapshot}, nil
+	}
+	return undoRecord{}, fault.New(fmt.Sprintf("unknown undo %T", undoable), fmsg.WithDesc("cannot write undo history", fmt.Sprintf("Could not record an undo of type %T", undoable)))
+}
+
+func restoreUndoable(nodes arrangement.Nodes, record undoRecord) (Undoable, error) {
+	if record.Type == "" {
+		return nil, nil
+	}
+	arrCursor, err := nodes.Cursor(record.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	var location locationRecord
+	if record.Location != nil {
+		location = *record.Location
+	}
+
+	switch record.Type {
+	case undoTypeBeats:
+		return UndoBeats{beats: record.Beats, ArrCursor: arrCursor}, nil
+	case undoTypeGrooves:
+		return UndoGrooves{grooves: record.Grooves, ArrCursor: arrCursor}, nil
+	case undoTypeMeter:
+		if record.Meter == nil {
+			return nil, invalidUndoHistory("meter undo without a meter")
+		}
+		return UndoMeter{meter: *record.Meter, ArrCursor: arrCursor}, nil
+	case undoTypeSpecificValue:
+		return UndoSpecificValue{overlayKey: location.OverlayKey, cursorPosition: location.GridKey, ArrCursor: arrCursor, specificValue: record.Value}, nil
+	case undoTypeNewOverlay:
+		return UndoNewOverlay{overlayKey: location.OverlayKey, cursorPosition: location.GridKey, ArrCursor: arrCursor}, nil
+	case undoTypeRemoveOverlay:
+		return UndoRemoveOverlay{overlayKey: location.OverlayKey, overlay: restoreOverlay(record.Overlay), cursorPosition: location.GridKey, ArrCursor: arrCursor}, nil
+	case undoTypeArrangement:
+		if record.Arrangement == nil {
+			return nil, invalidUndoHistory("arrangement undo without an arrangement")
+		}
+		arrUndo, err := nodes.Undoable(*record.Arrangement)
+		if err != nil {
+			return nil, err
+		}
+		return UndoArrangement{arrUndo: arrUndo}, nil
+	case undoTypeOverlayDiff:
+		if record.OverlayDiff == nil {
+			return nil, invalidUndoHistory("overlay undo without a difference")
+		}
+		return UndoOverlayDiff{overlayKey: location.OverlayKey, cursorPosition: location.GridKey, ArrCursor: arrCursor, overlayDiff: restoreOverlayDiff(*record.OverlayDiff)}, nil
+	case undoTypeControls:
+		controls, err := restoreControls(record.Controls)
+		if err != nil {
+			return nil, err
+		}
+		return UndoControls{controls: controls}, nil
+	case undoTypeStateDiff:
+		if record.StateDiff == nil {
+			return nil, invalidUndoHistory("state undo without a difference")
+		}
+		return UndoStateDiff{stateDiff: *record.StateDiff}, nil
+	case undoTypeSnapshots:
+		return UndoSnapshots{snapshots: record.Snapshots}, nil
+	case undoTypeRecall:
+		if record.Snapshot == nil {
+			return nil, invalidUndoHistory("recall undo without a snapshot")
+		}
+		return UndoRecall{snapshot: *record.Snapshot}, nil
+	}
+	return nil, invalidUndoHistory("unknown undo %q", record.Type)
+}
+
+func invalidUndoHistory(format string, a ...any) error {
+	message := fmt.Sprintf(format, a...)
+	return fault.New(message, fmsg.WithDesc("invalid undo history", message))
+}
+
+func recordNotes(pattern grid.Pattern) []noteRecord {
+	records := make([]noteRecord, 0, len(pattern))
+	for gridKey, note := range pattern {
+		records = append(records, noteRecord{gridKey, note})
+	}
+	slices.SortFunc(records, func(a, b noteRecord) int { return grid.Compare(a.GridKey, b.GridKey) })
+	return records
+}
+
+func restoreNotes(records []noteRecord) grid.Pattern {
+	pattern := make(grid.Pattern, len(records))
+	for _, record := range records {
+		pattern[record.GridKey] = record.Note
+	}
+	return pattern
+}
+
+func recordOverlay(overlay *overlays.Overlay) *overlayRecord {
+	if overlay == nil {
+		return nil
+	}
+	record := &overlayRecord{
+		Key:       overlay.Key,
+		PressUp:   overlay.PressUp,
+		PressDown: overlay.PressDown,
+		Notes:     recordNotes(overlay.Notes),
+	}
+	for _, chord := range overlay.Chords {
+		record.Chords = append(record.Chords, *chord)
+	}
+	return record
+}
+
+func restoreOverlay(record *overlayRecord) *overlays.Overlay {
+	if record == nil {
+		return nil
+	}
+	overlay := overlays.InitOverlay(record.Key, nil)
+	overlay.PressUp = record.PressUp
+	overlay.PressDown = record.PressDown
+	overlay.Notes = restoreNotes(record.Notes)
+	for i := range record.Chords {
+		overlay.Chords = append(overlay.Chords, &record.Chords[i])
+	}
+	return overlay
+}
+
+func recordOverlayDiff(diff overlays.OverlayDiff) *overlayDiffRecord {
+	record := &overlayDiffRecord{
+		AddedNotes:       recordNotes(diff.AddedNotes),
+		RemovedNotes:     recordNotes(diff.RemovedNotes),
+		AddedChords:      diff.AddedChords,
+		RemovedChords:    diff.RemovedChords,
+		PressUpChanged:   diff.OptionsDiff.PressUpChanged,
+		PressDownChanged: diff.OptionsDiff.PressDownChanged,
+	}
+	for gridKey, noteDiff := range diff.ModifiedNotes {
+		record.ModifiedNotes = append(record.ModifiedNotes, noteDiffRecord{gridKey, noteDiff.OldNote, noteDiff.NewNote})
+	}
+	slices.SortFunc(record.ModifiedNotes, func(a, b noteDiffRecord) int { return grid.Compare(a.GridKey, b.GridKey) })
+	for _, chordDiff := range diff.ModifiedChords {
+		record.ModifiedChords = append(record.ModifiedChords, chordDiff.OldChord)
+	}
+	slices.SortFunc(record.ModifiedChords, func(a, b overlays.GridChord) int { return grid.Compare(a.Root, b.Root) })
+	return record
+}
+
+func restoreOverlayDiff(record overlayDiffRecord) overlays.OverlayDiff {
+	diff := overlays.InitDiff()
+	diff.AddedNotes = restoreNotes(record.AddedNotes)
+	diff.RemovedNotes = restoreNotes(record.RemovedNotes)
+	for _, noteDiff := range record.ModifiedNotes {
+		diff.ModifiedNotes[noteDiff.GridKey] = overlays.NoteDiff{OldNote: noteDiff.OldNote, NewNote: noteDiff.NewNote}
+	}
+	diff.AddedChords = record.AddedChords
+	diff.RemovedChords = record.RemovedChords
+	for i := range record.ModifiedChords {
+		diff.ModifiedChords[&record.ModifiedChords[i]] = overlays.ChordDiff{OldChord: record.ModifiedChords[i], Modified: true}
+	}
+	diff.OptionsDiff = overlays.OptionsDiff{PressUpChanged: record.PressUpChanged, PressDownChanged: record.PressDownChanged}
+	return diff
+}
+
+func recordControls(bindings controller.Bindings) []controlRecord {
+	var records []controlRecord
+	for _, binding := range bindings {
+		record := controlRecord{Type: binding.Control.Kind.String(), Channel: binding.Control.Channel, Number: binding.Control.Number}
+		if binding.Parameter != controller.ParameterNone {
+			record.Parameter = binding.Parameter.String()
+		} else {
+			record.Command = binding.Command.String()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func restoreControls(records []controlRecord) (controller.Bindings, error) {
+	var bindings controller.Bindings
+	for _, record := range records {
+		binding, ok := controller.NewBinding(record.Type, record.Channel, record.Number, record.Command, record.Parameter)
+		if !ok {
+			return nil, invalidUndoHistory("control %s %d/%d has no known command or parameter", record.Type, record.Channel, record.Number)
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// UndoNode is an edit of the undo tree.  The node stands for the state after
+// the edit, the state before any edit is the root, with the id of the empty
+// stack.
+type UndoNode struct {
+	id     int
+	parent int
+	undo   Undoable
+	redo   Undoable
+	time   time.Time
+}
+
+func (un UndoNode) stack() UndoStack {
+	return UndoStack{undo: un.undo, redo: un.redo, id: un.id}
+}
+
+// UndoTree keeps every edit in the order they were made, also the edits of
+// the branches left behind by undoing and then editing again.
+type UndoTree struct {
+	nodes []UndoNode
+	index map[int]int
+}
+
+// UndoBranch is the last edit of a branch of the undo tree.
+type UndoBranch struct {
+	Number  int
+	Changes int
+	Time    time.Time
+	Current bool
+}
+
+func (ut *UndoTree) Add(node UndoNode) {
+	if ut.index == nil {
+		ut.index = make(map[int]int)
+	}
+	ut.index[node.id] = len(ut.nodes)
+	ut.nodes = append(ut.nodes, node)
+}
+
+func (ut UndoTree) Len() int {
+	return len(ut.nodes)
+}
+
+func (ut UndoTree) find(id int) (UndoNode, bool) {
+	i, exists := ut.index[id]
+	if !exists {
+		return UndoNode{}, false
+	}
+	return ut.nodes[i], true
+}
+
+// Number is the position of an edit in the order edits were made, counting
+// from 1.  The root is 0.
+func (ut UndoTree) Number(id int) int {
+	i, exists := ut.index[id]
+	if !exists {
+		return 0
+	}
+	return i + 1
+}
+
+// Ancestors returns an edit and the edits before it on its branch, back to
+// the root.
+func (ut UndoTree) Ancestors(id int) []UndoNode {
+	var ancestors []UndoNode
+	for node, exists := ut.find(id); exists; node, exists = ut.find(node.parent) {
+		ancestors = append(ancestors, node)
+	}
+	return ancestors
+}
+
+// Branch returns the edits after an edit, following the most recent edit at
+// each step.
+func (ut UndoTree) Branch(id int) []UndoNode {
+	var branch []UndoNode
+	for next, exists := ut.latestChild(id); exists; next, exists = ut.latestChild(next.id) {
+		branch = append(branch, next)
+	}
+	return branch
+}
+
+func (ut UndoTree) latestChild(id int) (UndoNode, bool) {
+	for i := len(ut.nodes) - 1; i >= 0; i-- {
+		if ut.nodes[i].parent == id {
+			return ut.nodes[i], true
+		}
+	}
+	return UndoNode{}, false
+}
+
+// Earlier returns the edit made before an edit, whichever branch it is on.
+func (ut UndoTree) Earlier(id int) (int, bool) {
+	i, exists := ut.index[id]
+	switch {
+	case !exists:
+		return 0, false
+	case i == 0:
+		return EmptyStack.id, true
+	default:
+		return ut.nodes[i-1].id, true
+	}
+}
+
+// Later returns the edit made after an edit, whichever branch it is on.
+func (ut UndoTree) Later(id int) (int, bool) {
+	i, exists := ut.index[id]
+	if !exists {
+		i = -1
+	}
+	if i+1 >= len(ut.nodes) {
+		return 0, false
+	}
+	return ut.nodes[i+1].id, true
+}
+
+// Branches returns the last edit of each branch in the order they were made,
+// marking the branch that ends in tip.
+func (ut UndoTree) Branches(tip int) []UndoBranch {
+	var branches []UndoBranch
+	for i, node := range ut.nodes {
+		if slices.ContainsFunc(ut.nodes[i+1:], func(later UndoNode) bool { return later.parent == node.id }) {
+			continue
+		}
+		branches = append(branches, UndoBranch{
+			Number:  i + 1,
+			Changes: len(ut.Ancestors(node.id)),
+			Time:    node.time,
+			Current: node.id == tip,
+		})
+	}
+	return branches
+}
+
+// UndoBranchTip is the last edit of the current branch, the edit that
+// redoing every undone edit returns to.
+func (m model) UndoBranchTip() int {
+	tip := m.undoStack
+	for redo := m.redoStack; redo != EmptyStack; redo = *redo.next {
+		tip = redo
+		if redo.next == nil {
+			break
+		}
+	}
+	return tip.id
+}
+
+// StepUndo undoes the last edit, keeping it for redo.
+func (m *model) StepUndo() bool {
+	undoStack := m.Undo()
+	if undoStack == EmptyStack {
+		return false
+	}
+	m.PushRedo(undoStack)
+	return true
+}
+
+// StepRedo redoes the last undone edit, keeping it for undo.
+func (m *model) StepRedo() bool {
+	undoStack := m.Redo()
+	if undoStack == EmptyStack {
+		return false
+	}
+	m.PushUndo(undoStack)
+	return true
+}
+
+// TravelTo undoes edits back to the branch of an edit of the undo tree and
+// then redoes the edits of that branch up to the edit.
+func (m *model) TravelTo(id int) {
+	ancestors := m.undoTree.Ancestors(id)
+	onBranch := map[int]bool{EmptyStack.id: true}
+	for _, node := range ancestors {
+		onBranch[node.id] = true
+	}
+	for !onBranch[m.undoStack.id] && m.StepUndo() {
+	}
+
+	common := slices.IndexFunc(ancestors, func(node UndoNode) bool { return node.id == m.undoStack.id })
+	if common < 0 {
+		common = len(ancestors)
+	}
+	if common == 0 {
+		return
+	}
+
+	m.ResetRedo()
+	for _, node := range ancestors[:common] {
+		m.PushRedo(node.stack())
+	}
+	for range common {
+		m.StepRedo()
+	}
+	for _, node := range slices.Backward(m.undoTree.Branch(id)) {
+		m.PushRedo(node.stack())
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/chriserin/sq/internal/arrangement"
 	"github.com/chriserin/sq/internal/grid"
 	"github.com/chriserin/sq/internal/mappings"
 	"github.com/chriserin/sq/internal/operation"
@@ -569,6 +570,40 @@ func TestSetupCCChange(t *testing.T) {
 	}
 }
 
+func TestLineClockInputSwitch(t *testing.T) {
+	t.Run("Shorten the line and divide its clock", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.LineClockInputSwitch, mappings.Decrease, mappings.LineClockInputSwitch, mappings.Decrease}, m)
+		assert.Equal(t, operation.SelectSetupDivision, m.selectionIndicator)
+		line := m.definition.Lines[m.gridCursor.Line]
+		assert.Equal(t, m.CurrentPart().Beats-1, line.Length)
+		assert.Equal(t, grid.Division(2), line.Division)
+	})
+
+	t.Run("Set the length with digits", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.LineClockInputSwitch, mappings.Mapping{Command: mappings.NumberPattern, LastValue: "5"}}, m)
+		assert.Equal(t, uint8(5), m.definition.Lines[m.gridCursor.Line].Length)
+	})
+
+	t.Run("A line as long as the part has no length", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.LineClockInputSwitch, mappings.Decrease, mappings.Increase, mappings.Increase}, m)
+		assert.Equal(t, uint8(0), m.definition.Lines[m.gridCursor.Line].Length)
+	})
+
+	t.Run("Undo the length and division", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.LineClockInputSwitch, mappings.Decrease, mappings.LineClockInputSwitch, mappings.Increase, mappings.LineClockInputSwitch}, m)
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.Equal(t, grid.Division(-2), m.definition.Lines[m.gridCursor.Line].Division)
+
+		m, _ = processCommands([]any{mappings.Undo}, m)
+		assert.Equal(t, grid.LineDefinition{}.Division, m.definition.Lines[m.gridCursor.Line].Division)
+		assert.Equal(t, uint8(0), m.definition.Lines[m.gridCursor.Line].Length)
+	})
+}
+
 func TestBeatInputSwitch(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1075,3 +1110,34 @@ func TestMuteAll(t *testing.T) {
 		})
 	}
 }
+
+func TestMeterInputSwitch(t *testing.T) {
+	t.Run("A part without a meter starts at 4/4", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.MeterInputSwitch, mappings.Increase, mappings.Increase, mappings.Increase, mappings.MeterInputSwitch, mappings.Increase}, m)
+		assert.Equal(t, operation.SelectMeterDenominator, m.selectionIndicator)
+		assert.Equal(t, arrangement.Meter{Numerator: 6, Denominator: 8}, m.CurrentPart().Meter)
+	})
+
+	t.Run("Set the notes of a bar with digits", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.MeterInputSwitch, mappings.Mapping{Command: mappings.NumberPattern, LastValue: "7"}}, m)
+		assert.Equal(t, arrangement.Meter{Numerator: 7, Denominator: 4}, m.CurrentPart().Meter)
+	})
+
+	t.Run("Decrease below one note removes the meter", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.MeterInputSwitch, mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"}, mappings.Decrease}, m)
+		assert.False(t, m.CurrentPart().Meter.IsSet())
+	})
+
+	t.Run("Undo the meter", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.MeterInputSwitch, mappings.Increase, mappings.MeterInputSwitch, mappings.Increase, mappings.MeterInputSwitch}, m)
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.Equal(t, arrangement.Meter{Numerator: 4, Denominator: 8}, m.CurrentPart().Meter)
+
+		m, _ = processCommands([]any{mappings.Undo}, m)
+		assert.False(t, m.CurrentPart().Meter.IsSet())
+	})
+}
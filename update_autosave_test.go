@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/autosave"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/seqmidi"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+)
+
+func WithFilename(filename string) modelFunc {
+	return func(m *model) model {
+		m.filename = filename
+		return *m
+	}
+}
+
+func WithBackups(backups int) modelFunc {
+	return func(m *model) model {
+		m.backups = backups
+		return *m
+	}
+}
+
+func TestAutosave(t *testing.T) {
+	t.Run("Unsaved changes are written to the recovery file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename))
+
+		m.Autosave()
+		assert.NoFileExists(t, autosave.RecoveryFile(filename))
+
+		m, _ = processCommands([]any{mappings.NoteAdd}, m)
+		m.Autosave()
+
+		recovered, err := sequence.Read(autosave.RecoveryFile(filename))
+		assert.NoError(t, err)
+		assert.Contains(t, (*recovered.Parts)[0].Overlays.Notes, GK(0, 0))
+	})
+
+	t.Run("Saving removes the recovery file and keeps backups", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithBackups(2))
+
+		m, _ = processCommands([]any{mappings.Save, mappings.NoteAdd}, m)
+		m.Autosave()
+		assert.FileExists(t, autosave.RecoveryFile(filename))
+
+		m, _ = processCommands([]any{mappings.Save}, m)
+
+		assert.NoFileExists(t, autosave.RecoveryFile(filename))
+		assert.FileExists(t, autosave.BackupFile(filename, 1))
+		assert.False(t, m.NeedsWrite())
+	})
+
+	t.Run("A panic in update writes the recovery file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename))
+		m, _ = processCommands([]any{mappings.NoteAdd}, m)
+
+		// Adding a note without an overlay panics
+		m.currentOverlay = nil
+		_, cmd := m.Update(mappings.Mapping{Command: mappings.NoteAdd})
+
+		if assert.NotNil(t, cmd) {
+			assert.IsType(t, panicMsg{}, cmd())
+		}
+		recovered, err := sequence.Read(autosave.RecoveryFile(filename))
+		assert.NoError(t, err)
+		assert.Contains(t, (*recovered.Parts)[0].Overlays.Notes, GK(0, 0))
+	})
+
+	t.Run("A newer recovery file is offered on launch", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename))
+		m, _ = processCommands([]any{mappings.Save, mappings.NoteAdd}, m)
+		m.Autosave()
+
+		m = InitModel(filename, &seqmidi.MidiConnection{}, ProgramOptions{}, func() {})
+		m.ResetIterations()
+		assert.Equal(t, operation.SelectRecoverFile, m.selectionIndicator)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+
+		m, _ = processCommands([]any{mappings.ConfirmRecoverFile}, m)
+
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.Equal(t, filename, m.filename)
+		assert.True(t, m.NeedsWrite())
+	})
+
+	t.Run("Escape keeps the saved file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		assert.NoError(t, sequence.Write(sequence.InitSequence("Drums", "Standard"), filename))
+		assert.NoError(t, os.WriteFile(autosave.RecoveryFile(filename), []byte("Format Version: 2\n"), 0644))
+
+		m := InitModel(filename, &seqmidi.MidiConnection{}, ProgramOptions{}, func() {})
+		m.ResetIterations()
+		m, _ = processCommands([]any{mappings.Escape}, m)
+
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.FileExists(t, autosave.RecoveryFile(filename))
+	})
+}
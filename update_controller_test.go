@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/controller"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/recorder"
+	"github.com/stretchr/testify/assert"
+)
+
+func WithControls(bindings ...controller.Binding) modelFunc {
+	return func(m *model) model {
+		m.definition.Controls = bindings
+		return *m
+	}
+}
+
+func sendControl(m model, msg controller.ControlMsg) model {
+	updateModel, _ := m.Update(msg)
+	return updateModel.(model)
+}
+
+var pad = controller.Control{Kind: controller.KindNote, Channel: 10, Number: 36}
+var knob = controller.Control{Kind: controller.KindCC, Channel: 1, Number: 20}
+
+func TestLearnControl(t *testing.T) {
+	t.Run("The next command is bound to the next control", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.LearnControl, mappings.ToggleRecordMode}, m)
+		assert.Equal(t, recorder.Overdub, m.recordMode)
+
+		m = sendControl(m, controller.ControlMsg{Control: pad, Value: 100})
+
+		assert.False(t, m.learn.Active)
+		assert.Equal(t, controller.Bindings{{Control: pad, Command: mappings.ToggleRecordMode}}, m.definition.Controls)
+	})
+
+	t.Run("A selected tempo is bound to a knob", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.TempoInputSwitch, mappings.LearnControl}, m)
+		assert.Equal(t, operation.SelectTempo, m.selectionIndicator)
+		m = sendControl(m, controller.ControlMsg{Control: knob, Value: 64})
+
+		assert.Equal(t, controller.Bindings{{Control: knob, Parameter: controller.ParameterTempo}}, m.definition.Controls)
+	})
+
+	t.Run("Escape cancels", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.LearnControl, mappings.Escape}, m)
+		m = sendControl(m, controller.ControlMsg{Control: pad, Value: 100})
+
+		assert.False(t, m.learn.Active)
+		assert.Empty(t, m.definition.Controls)
+	})
+
+	t.Run("Learning is undoable", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.LearnControl, mappings.ToggleRecordMode}, m)
+		m = sendControl(m, controller.ControlMsg{Control: pad, Value: 100})
+		m, _ = processCommand(mappings.Undo, m)
+
+		assert.Empty(t, m.definition.Controls)
+	})
+}
+
+func TestControls(t *testing.T) {
+	t.Run("A pressed control runs its command", func(t *testing.T) {
+		m := createTestModel(WithControls(controller.Binding{Control: pad, Command: mappings.ToggleRecordMode}))
+
+		m = sendControl(m, controller.ControlMsg{Control: pad, Value: 100})
+
+		assert.Equal(t, recorder.Replace, m.recordMode)
+	})
+
+	t.Run("A released button does not run its command", func(t *testing.T) {
+		button := controller.Control{Kind: controller.KindCC, Channel: 1, Number: 64}
+		m := createTestModel(WithControls(controller.Binding{Control: button, Command: mappings.ToggleRecordMode}))
+
+		m = sendControl(m, controller.ControlMsg{Control: button, Value: 0})
+
+		assert.Equal(t, recorder.Overdub, m.recordMode)
+	})
+
+	t.Run("A knob sets the tempo", func(t *testing.T) {
+		m := createTestModel(WithControls(controller.Binding{Control: knob, Parameter: controller.ParameterTempo}))
+
+		m = sendControl(m, controller.ControlMsg{Control: knob, Value: 127})
+
+		assert.Equal(t, controller.MaxTempo, m.definition.Tempo)
+	})
+
+	t.Run("The accent end stays below the accent start", func(t *testing.T) {
+		m := createTestModel(WithControls(controller.Binding{Control: knob, Parameter: controller.ParameterAccentEnd}))
+		m.definition.Accents.Start = 100
+
+		m = sendControl(m, controller.ControlMsg{Control: knob, Value: 127})
+
+		assert.Equal(t, uint8(99), m.definition.Accents.End)
+	})
+
+	t.Run("The accent start stays above the accent end", func(t *testing.T) {
+		m := createTestModel(WithControls(controller.Binding{Control: knob, Parameter: controller.ParameterAccentStart}))
+		m.definition.Accents.End = 20
+
+		m = sendControl(m, controller.ControlMsg{Control: knob, Value: 5})
+
+		assert.Equal(t, uint8(21), m.definition.Accents.Start)
+	})
+}
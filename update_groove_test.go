@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/groove"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroove(t *testing.T) {
+	t.Run("Select a groove for the part", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.GrooveInputSwitch, mappings.Increase}, m)
+
+		assert.Equal(t, operation.SelectGroove, m.selectionIndicator)
+		assert.Equal(t, groove.Settings{{Name: "Swing 54%", Amount: groove.MaxAmount}}, m.CurrentPart().Grooves)
+	})
+
+	t.Run("Decrease wraps to the last groove", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.GrooveInputSwitch, mappings.Decrease}, m)
+
+		assert.Equal(t, groove.Settings{{Name: "Swing 71%", Amount: groove.MaxAmount}}, m.CurrentPart().Grooves)
+	})
+
+	t.Run("Change the amount", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.GrooveInputSwitch, mappings.Increase, mappings.GrooveInputSwitch, mappings.Decrease, mappings.Decrease}, m)
+
+		assert.Equal(t, operation.SelectGrooveAmount, m.selectionIndicator)
+		assert.Equal(t, groove.Settings{{Name: "Swing 54%", Amount: 90}}, m.CurrentPart().Grooves)
+	})
+
+	t.Run("No groove has no amount", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.GrooveInputSwitch, mappings.GrooveInputSwitch, mappings.Decrease}, m)
+
+		assert.Empty(t, m.CurrentPart().Grooves)
+	})
+
+	t.Run("A visual selection sets the groove of its lines", func(t *testing.T) {
+		m := createTestModel(WithGridCursor(GK(1, 0)))
+
+		m, _ = processCommands([]any{mappings.ToggleVisualLineMode, mappings.CursorDown, mappings.GrooveInputSwitch, mappings.Increase}, m)
+
+		assert.Equal(t, groove.Settings{{Name: "Swing 54%", Amount: groove.MaxAmount, Lines: []uint8{1, 2}}}, m.CurrentPart().Grooves)
+	})
+
+	t.Run("Undo restores the grooves", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.GrooveInputSwitch, mappings.Increase, mappings.GrooveInputSwitch, mappings.GrooveInputSwitch}, m)
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.Len(t, m.CurrentPart().Grooves, 1)
+
+		m, _ = processCommand(mappings.Undo, m)
+
+		assert.Empty(t, m.CurrentPart().Grooves)
+	})
+}
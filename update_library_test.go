@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/autosave"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/midifile"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/seqmidi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLibrary(t *testing.T) {
+	songs := func(t *testing.T) string {
+		dir := t.TempDir()
+		m := createTestModel(WithFilename(filepath.Join(dir, "a.sq")))
+		processCommands([]any{mappings.NoteAdd, mappings.Save}, m)
+		m = createTestModel(WithFilename(filepath.Join(dir, "b.sq")))
+		processCommands([]any{mappings.CursorRight, mappings.NoteAdd, mappings.Save}, m)
+		return dir
+	}
+	openDirectory := func(dir string) model {
+		m := InitModel(dir, &seqmidi.MidiConnection{}, ProgramOptions{}, func() {})
+		m.ResetIterations()
+		return m
+	}
+
+	t.Run("Started on a directory the sequences are listed", func(t *testing.T) {
+		dir := songs(t)
+		m := openDirectory(dir)
+		assert.NoError(t, m.currentError)
+		assert.Equal(t, operation.SelectLibrary, m.selectionIndicator)
+		assert.Equal(t, "", m.filename)
+		if assert.Len(t, m.libraryEntries, 2) {
+			assert.Equal(t, "a.sq", m.libraryEntries[0].Name())
+			assert.Equal(t, 120, m.libraryEntries[0].Tempo)
+		}
+	})
+
+	t.Run("Open a sequence of the directory", func(t *testing.T) {
+		dir := songs(t)
+		m := openDirectory(dir)
+		m, _ = processCommands([]any{mappings.Increase, mappings.ConfirmLibrary}, m)
+		assert.Equal(t, filepath.Join(dir, "b.sq"), m.filename)
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+		assert.False(t, m.NeedsWrite())
+	})
+
+	t.Run("Switching sequences keeps the unsaved changes", func(t *testing.T) {
+		dir := songs(t)
+		m := createTestModel(WithFilename(filepath.Join(dir, "a.sq")))
+		m, _ = processCommands([]any{mappings.CursorDown, mappings.NoteAdd, mappings.ShowLibrary}, m)
+		assert.Equal(t, 0, m.libraryIndex)
+
+		m, _ = processCommands([]any{mappings.Increase, mappings.ConfirmLibrary}, m)
+		assert.Equal(t, filepath.Join(dir, "b.sq"), m.filename)
+		assert.FileExists(t, autosave.RecoveryFile(filepath.Join(dir, "a.sq")))
+
+		m, _ = processCommands([]any{mappings.ShowLibrary, mappings.Decrease, mappings.ConfirmLibrary}, m)
+		assert.Equal(t, operation.SelectRecoverFile, m.selectionIndicator)
+	})
+
+	t.Run("Importing a midi file keeps the unsaved changes", func(t *testing.T) {
+		dir := songs(t)
+		midiFile := filepath.Join(dir, "song.mid")
+		m := createTestModel(WithFilename(filepath.Join(dir, "a.sq")))
+		m, _ = processCommands([]any{mappings.NoteAdd}, m)
+		assert.NoError(t, midifile.Export(m.definition, midiFile, 0))
+		m.playState.LineStates = m.playState.LineStates[:1]
+
+		t.Chdir(dir)
+		m, _ = processCommands([]any{mappings.CursorDown, mappings.NoteAdd, mappings.ImportMidi, TestKey{Keys: "song"}, mappings.ConfirmImportFile}, m)
+		assert.Equal(t, operation.SelectConfirmImport, m.selectionIndicator)
+		assert.Equal(t, filepath.Join(dir, "a.sq"), m.filename)
+
+		m, _ = processCommands([]any{mappings.ConfirmConfirmImport}, m)
+		assert.NoError(t, m.currentError)
+		assert.Equal(t, "song.sq", m.filename)
+		assert.True(t, m.NeedsWrite())
+		assert.FileExists(t, autosave.RecoveryFile(filepath.Join(dir, "a.sq")))
+		assert.Len(t, m.playState.LineStates, len(m.definition.Lines))
+	})
+
+	t.Run("An untitled sequence keeps its recovery file when importing", func(t *testing.T) {
+		dir := songs(t)
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.NoteAdd}, m)
+		assert.NoError(t, midifile.Export(m.definition, filepath.Join(dir, "song.mid"), 0))
+		m.playState.LineStates = m.playState.LineStates[:1]
+
+		t.Chdir(dir)
+		m, _ = processCommands([]any{mappings.ImportMidi, TestKey{Keys: "song"}, mappings.ConfirmImportFile, mappings.ConfirmConfirmImport}, m)
+		assert.NoError(t, m.currentError)
+		assert.NotEqual(t, autosave.RecoveryFile(""), autosave.RecoveryFile(m.filename))
+		assert.FileExists(t, autosave.RecoveryFile(""))
+	})
+
+	t.Run("A midi file is not imported over an existing sequence", func(t *testing.T) {
+		dir := songs(t)
+		m := createTestModel()
+		assert.NoError(t, midifile.Export(m.definition, filepath.Join(dir, "a.mid"), 0))
+
+		t.Chdir(dir)
+		m, _ = processCommands([]any{mappings.ImportMidi, TestKey{Keys: "a"}, mappings.ConfirmImportFile}, m)
+		assert.Error(t, m.currentError)
+		assert.Equal(t, "", m.filename)
+	})
+
+	t.Run("A sequence that cannot be read is not opened", func(t *testing.T) {
+		dir := songs(t)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "c.sq"), []byte("GridKey(0,0)"), 0644))
+		m := openDirectory(dir)
+		m, _ = processCommands([]any{mappings.Increase, mappings.Increase, mappings.ConfirmLibrary}, m)
+		assert.Error(t, m.currentError)
+		assert.Equal(t, "", m.filename)
+	})
+
+	t.Run("New sequences are saved in the directory", func(t *testing.T) {
+		dir := songs(t)
+		m := openDirectory(dir)
+		m, _ = processCommands([]any{mappings.Escape, mappings.SaveAs, TestKey{Keys: "c"}, mappings.ConfirmFileName}, m)
+		assert.FileExists(t, filepath.Join(dir, "c.sq"))
+	})
+}
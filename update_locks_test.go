@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocks(t *testing.T) {
+	digits := func(value string) []any {
+		var commands []any
+		for _, digit := range value {
+			commands = append(commands, mappings.Mapping{Command: mappings.NumberPattern, LastValue: string(digit)})
+		}
+		return commands
+	}
+
+	t.Run("Lock a CC to a value on a note", func(t *testing.T) {
+		m := createTestModel()
+		commands := []any{mappings.NoteAdd, mappings.LockInputSwitch}
+		commands = append(commands, digits("74")...)
+		commands = append(commands, mappings.LockInputSwitch)
+		commands = append(commands, digits("90")...)
+		m, _ = processCommands(commands, m)
+		assert.Equal(t, operation.SelectLockValue, m.selectionIndicator)
+
+		note, _ := m.CurrentNote()
+		value, locked := note.Locks.Value(74)
+		assert.True(t, locked)
+		assert.Equal(t, uint8(90), value)
+	})
+
+	t.Run("Increase moves through the named CCs", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.NoteAdd, mappings.LockInputSwitch, mappings.Increase, mappings.Increase}, m)
+		assert.Equal(t, uint8(2), m.lockControl)
+
+		m, _ = processCommands([]any{mappings.Increase, mappings.Decrease, mappings.Decrease}, m)
+		assert.Equal(t, uint8(1), m.lockControl)
+	})
+
+	t.Run("Change and remove a lock and undo it", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.NoteAdd, mappings.LockInputSwitch, mappings.LockInputSwitch, mappings.Increase, mappings.Increase}, m)
+		note, _ := m.CurrentNote()
+		value, _ := note.Locks.Value(0)
+		assert.Equal(t, uint8(2), value)
+
+		m, _ = processCommands([]any{mappings.RemoveLock}, m)
+		note, _ = m.CurrentNote()
+		assert.Equal(t, grid.Locks{}, note.Locks)
+
+		m, _ = processCommands([]any{mappings.Undo, mappings.Undo}, m)
+		note, _ = m.CurrentNote()
+		value, _ = note.Locks.Value(0)
+		assert.Equal(t, uint8(1), value)
+	})
+
+	t.Run("Actions have no locks", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.ActionAddLineReset, mappings.LockInputSwitch}, m)
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+	})
+}
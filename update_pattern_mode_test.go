@@ -4,6 +4,7 @@ import (
 	"slices"
 	"testing"
 
+	"github.com/chriserin/sq/internal/arrangement"
 	"github.com/chriserin/sq/internal/grid"
 	"github.com/chriserin/sq/internal/mappings"
 	"github.com/stretchr/testify/assert"
@@ -842,3 +843,125 @@ func TestPatternModeMonoFillSpace(t *testing.T) {
 		})
 	}
 }
+
+func TestPatternModeChanceAndTrig(t *testing.T) {
+	tests := []struct {
+		name               string
+		commands           []any
+		expectedChances    []uint8
+		expectedConditions []grid.Condition
+	}{
+		{
+			name: "Decrease chance of every note",
+			commands: []any{
+				mappings.ToggleChanceMode,
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"},
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"},
+			},
+			expectedChances:    []uint8{80, 80, 80},
+			expectedConditions: []grid.Condition{grid.ConditionNone, grid.ConditionNone, grid.ConditionNone},
+		},
+		{
+			name: "Decrease and increase chance of every other note",
+			commands: []any{
+				mappings.ToggleChanceNoteMode,
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"},
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"},
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "!"},
+			},
+			expectedChances:    []uint8{90, 90, 90},
+			expectedConditions: []grid.Condition{grid.ConditionNone, grid.ConditionNone, grid.ConditionNone},
+		},
+		{
+			name: "Increase trig condition of every other note",
+			commands: []any{
+				mappings.ToggleTrigNoteMode,
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "@"},
+			},
+			expectedChances:    []uint8{100, 100, 100},
+			expectedConditions: []grid.Condition{grid.ConditionFill, grid.ConditionNone, grid.ConditionFill},
+		},
+		{
+			name: "Decrease trig condition wraps around",
+			commands: []any{
+				mappings.ToggleTrigMode,
+				mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"},
+			},
+			expectedChances:    []uint8{100, 100, 100},
+			expectedConditions: []grid.Condition{grid.CycleCondition(8, 8), grid.CycleCondition(8, 8), grid.CycleCondition(8, 8)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := createTestModel(func(m *model) model {
+				m.currentOverlay.AddNote(grid.GK(0, 0), note{AccentIndex: 1})
+				m.currentOverlay.AddNote(grid.GK(0, 2), note{AccentIndex: 1})
+				m.currentOverlay.AddNote(grid.GK(0, 3), note{AccentIndex: 1})
+				return *m
+			})
+
+			m, _ = processCommands(tt.commands, m)
+
+			for i, gk := range []grid.GridKey{grid.GK(0, 0), grid.GK(0, 2), grid.GK(0, 3)} {
+				note, exists := m.currentOverlay.GetNote(gk)
+				assert.True(t, exists, "note should exist at grid location "+gk.String())
+				assert.Equal(t, tt.expectedChances[i], note.Chance(), "chance at grid location "+gk.String())
+				assert.Equal(t, tt.expectedConditions[i], note.Condition, "condition at grid location "+gk.String())
+			}
+		})
+	}
+}
+
+func TestToggleFill(t *testing.T) {
+	m := createTestModel()
+	m, _ = processCommands([]any{mappings.ToggleFill}, m)
+	assert.True(t, m.playState.Fill)
+	m, _ = processCommands([]any{mappings.ToggleFill}, m)
+	assert.False(t, m.playState.Fill)
+}
+
+func TestPatternModeBars(t *testing.T) {
+	t.Run("Fill every bar of the meter", func(t *testing.T) {
+		m := createTestModel(WithGridSize(32, 1))
+		(*m.definition.Parts)[0].Meter = arrangement.Meter{Numerator: 3, Denominator: 4}
+		m.definition.Subdivisions = 2
+		m, _ = processCommands([]any{
+			mappings.ToggleBarPattern,
+			mappings.Mapping{Command: mappings.NumberPattern, LastValue: "1"},
+		}, m)
+
+		// A bar of 3/4 is 6 beats of eighths
+		var noteBeats []uint8
+		for beat := range m.CurrentPart().Beats {
+			if _, exists := m.currentOverlay.Notes[grid.GK(0, beat)]; exists {
+				noteBeats = append(noteBeats, beat)
+			}
+		}
+		assert.Equal(t, []uint8{0, 6, 12, 18, 24, 30}, noteBeats)
+	})
+
+	t.Run("Fill spaces every bar of the meter", func(t *testing.T) {
+		m := createTestModel(WithGridSize(32, 1))
+		(*m.definition.Parts)[0].Meter = arrangement.Meter{Numerator: 3, Denominator: 4}
+		m.definition.Subdivisions = 2
+		m, _ = processCommands([]any{
+			mappings.ToggleBarPattern,
+			mappings.Mapping{Command: mappings.NumberPattern, LastValue: "!"},
+		}, m)
+
+		var noteBeats []uint8
+		for beat := range m.CurrentPart().Beats {
+			if _, exists := m.currentOverlay.Notes[grid.GK(0, beat)]; exists {
+				noteBeats = append(noteBeats, beat)
+			}
+		}
+		assert.Equal(t, []uint8{0, 6, 12, 18, 24, 30}, noteBeats)
+	})
+
+	t.Run("Count in beats without a meter", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands([]any{mappings.ToggleBarPattern}, m)
+		assert.Equal(t, uint8(3), m.PatternInterval(3))
+	})
+}
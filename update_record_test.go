@@ -0,0 +1,105 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/chriserin/sq/internal/beats"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/recorder"
+	"github.com/chriserin/sq/internal/seqmidi"
+	"github.com/stretchr/testify/assert"
+)
+
+func WithRecording() modelFunc {
+	return func(m *model) model {
+		m.midiConnection = &seqmidi.MidiConnection{Test: true}
+		m.playState.Playing = true
+		return *m
+	}
+}
+
+func playBeat(m model, beat uint8, at time.Time) model {
+	playState := m.playState
+	playState.LineStates = slices.Clone(m.playState.LineStates)
+	for i := range playState.LineStates {
+		playState.LineStates[i].CurrentBeat = beat
+	}
+	updateModel, _ := m.Update(beats.ModelPlayedMsg{PlayState: playState, Cursor: m.arrangement.Cursor, Time: at, Interval: 100 * time.Millisecond})
+	return updateModel.(model)
+}
+
+func sendNote(m model, msg recorder.NoteMsg) model {
+	updateModel, _ := m.Update(msg)
+	return updateModel.(model)
+}
+
+func TestRecordNotes(t *testing.T) {
+	start := time.Now()
+
+	t.Run("Notes played are written on the nearest beat of their line", func(t *testing.T) {
+		m := createTestModel(WithGridSize(8, 4), WithRecording())
+
+		m, _ = processCommand(mappings.RecordNotes, m)
+		m = playBeat(m, 2, start)
+		m = sendNote(m, recorder.NoteMsg{Channel: 0, Key: 3, Velocity: 127, On: true, Time: start.Add(110 * time.Millisecond)})
+		m = sendNote(m, recorder.NoteMsg{Channel: 0, Key: 3, Time: start.Add(310 * time.Millisecond)})
+
+		note, exists := m.CurrentPart().Overlays.Notes[GK(3, 3)]
+		assert.True(t, exists)
+		assert.Equal(t, uint8(1), note.AccentIndex)
+		assert.NotNil(t, m.take)
+	})
+
+	t.Run("Notes that match no line are ignored", func(t *testing.T) {
+		m := createTestModel(WithGridSize(8, 4), WithRecording())
+
+		m, _ = processCommand(mappings.RecordNotes, m)
+		m = playBeat(m, 0, start)
+		m = sendNote(m, recorder.NoteMsg{Channel: 0, Key: 90, Velocity: 100, On: true, Time: start})
+
+		assert.Empty(t, m.CurrentPart().Overlays.Notes)
+	})
+
+	t.Run("Ending the take makes the recording undoable", func(t *testing.T) {
+		m := createTestModel(WithGridSize(8, 4), WithRecording())
+
+		m, _ = processCommand(mappings.RecordNotes, m)
+		m = playBeat(m, 0, start)
+		m = sendNote(m, recorder.NoteMsg{Channel: 0, Key: 1, Velocity: 100, On: true, Time: start})
+		m = sendNote(m, recorder.NoteMsg{Channel: 0, Key: 2, Velocity: 100, On: true, Time: start})
+		m, _ = processCommand(mappings.RecordNotes, m)
+
+		assert.Nil(t, m.take)
+		assert.Len(t, m.CurrentPart().Overlays.Notes, 2)
+
+		m, _ = processCommand(mappings.Undo, m)
+		assert.Empty(t, m.CurrentPart().Overlays.Notes)
+
+		m, _ = processCommand(mappings.Redo, m)
+		assert.Len(t, m.CurrentPart().Overlays.Notes, 2)
+	})
+
+	t.Run("Replace mode removes the notes the cursor passes over", func(t *testing.T) {
+		m := createTestModel(WithGridSize(8, 4), WithGridCursor(GK(1, 4)), WithRecording())
+		m, _ = processCommand(mappings.NoteAdd, m)
+
+		m, _ = processCommands([]any{mappings.ToggleRecordMode, mappings.RecordNotes}, m)
+		m = playBeat(m, 3, start)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(1, 4))
+
+		m = playBeat(m, 4, start.Add(100*time.Millisecond))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(1, 4))
+	})
+
+	t.Run("Toggle record mode", func(t *testing.T) {
+		m := createTestModel()
+
+		assert.Equal(t, recorder.Overdub, m.recordMode)
+		m, _ = processCommand(mappings.ToggleRecordMode, m)
+		assert.Equal(t, recorder.Replace, m.recordMode)
+		m, _ = processCommand(mappings.ToggleRecordMode, m)
+		assert.Equal(t, recorder.Overdub, m.recordMode)
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/sequence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshots(t *testing.T) {
+	capture := func(command mappings.Command, name string) []any {
+		return []any{command, TestKey{Keys: name}, mappings.ConfirmSnapshotName}
+	}
+
+	t.Run("Capture and recall a snapshot of the sequence", func(t *testing.T) {
+		m := createTestModel()
+		commands := capture(mappings.CaptureSnapshot, "verse")
+		commands = append(commands, mappings.NoteAdd, mappings.TempoInputSwitch, mappings.Increase, mappings.Escape)
+		m, _ = processCommands(commands, m)
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+
+		m, _ = processCommands([]any{mappings.ShowSnapshots, mappings.ConfirmSnapshot}, m)
+		assert.Equal(t, operation.SelectSnapshot, m.selectionIndicator)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.Equal(t, 120, m.definition.Tempo)
+	})
+
+	t.Run("Undo a recall", func(t *testing.T) {
+		m := createTestModel()
+		commands := capture(mappings.CaptureSnapshot, "verse")
+		commands = append(commands, mappings.NoteAdd, mappings.ShowSnapshots, mappings.ConfirmSnapshot, mappings.Escape)
+		m, _ = processCommands(commands, m)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+
+		m, _ = processCommands([]any{mappings.Undo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+
+		m, _ = processCommands([]any{mappings.Redo}, m)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+	})
+
+	t.Run("Capture a snapshot of the current part", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(capture(mappings.CapturePartSnapshot, "dense"), m)
+
+		if assert.Len(t, m.definition.Snapshots, 1) {
+			assert.Equal(t, "dense", m.definition.Snapshots[0].Name)
+			assert.Equal(t, 0, m.definition.Snapshots[0].Part)
+		}
+	})
+
+	t.Run("A snapshot without a name is not captured", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(capture(mappings.CaptureSnapshot, " "), m)
+		assert.Empty(t, m.definition.Snapshots)
+	})
+
+	t.Run("Remove a snapshot", func(t *testing.T) {
+		m := createTestModel()
+		commands := capture(mappings.CaptureSnapshot, "verse")
+		commands = append(commands, capture(mappings.CaptureSnapshot, "chorus")...)
+		commands = append(commands, mappings.ShowSnapshots, mappings.Increase, mappings.RemoveSnapshot)
+		m, _ = processCommands(commands, m)
+		if assert.Len(t, m.definition.Snapshots, 1) {
+			assert.Equal(t, "verse", m.definition.Snapshots[0].Name)
+		}
+		assert.Equal(t, 0, m.snapshotIndex)
+
+		m, _ = processCommands([]any{mappings.Escape, mappings.Undo}, m)
+		assert.Len(t, m.definition.Snapshots, 2)
+	})
+
+	t.Run("A recall during playback is queued", func(t *testing.T) {
+		m := createTestModel()
+		commands := capture(mappings.CaptureSnapshot, "verse")
+		commands = append(commands, mappings.NoteAdd, mappings.ShowSnapshots)
+		m, _ = processCommands(commands, m)
+		m.playState.Playing = true
+		m, _ = processCommands([]any{mappings.ConfirmSnapshot}, m)
+		assert.NotNil(t, m.queuedSnapshot)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+
+		queued := m.QueuedSequence()
+		assert.NotContains(t, (*queued.Parts)[0].Overlays.Notes, GK(0, 0))
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+
+		m.PlayQueuedSnapshot()
+		assert.Nil(t, m.queuedSnapshot)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+	})
+
+	t.Run("Snapshots and their recalls are kept across sessions", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(10))
+		commands := capture(mappings.CaptureSnapshot, "verse")
+		commands = append(commands, mappings.NoteAdd, mappings.ShowSnapshots, mappings.ConfirmSnapshot, mappings.Escape, mappings.Save)
+		m, _ = processCommands(commands, m)
+
+		m = reopen(filename, 10)
+		assert.NoError(t, m.currentError)
+		if assert.Len(t, m.definition.Snapshots, 1) {
+			assert.Equal(t, sequence.WholeSequence, m.definition.Snapshots[0].Part)
+		}
+
+		m, _ = processCommands([]any{mappings.Undo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chriserin/sq/internal/grid"
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/chriserin/sq/internal/recorder"
+	"github.com/chriserin/sq/internal/seqmidi"
+	"github.com/chriserin/sq/internal/theory"
+	"github.com/stretchr/testify/assert"
+)
+
+// Piano lines descend from C5 like the Piano2 template
+func WithPianoLines(sequencerType operation.SequencerMode) modelFunc {
+	return func(m *model) model {
+		m.midiConnection = &seqmidi.MidiConnection{Test: true}
+		m.definition.TemplateSequencerType = sequencerType
+		m.definition.Lines = make([]grid.LineDefinition, 25)
+		for i := range m.definition.Lines {
+			m.definition.Lines[i] = grid.LineDefinition{
+				Channel: 1,
+				Note:    uint8(72 - i),
+				MsgType: grid.MessageTypeNote,
+				Name:    fmt.Sprintf("Line %d", i),
+			}
+		}
+		return *m
+	}
+}
+
+func playKeys(m model, keys ...uint8) model {
+	for _, key := range keys {
+		m = sendNote(m, recorder.NoteMsg{Key: key, Velocity: 100, On: true})
+	}
+	for _, key := range keys {
+		m = sendNote(m, recorder.NoteMsg{Key: key})
+	}
+	return m
+}
+
+func TestStepInput(t *testing.T) {
+	t.Run("A key puts a note on its line and advances the cursor", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeMono), WithGridCursor(GK(0, 2)))
+
+		m, _ = processCommand(mappings.ToggleStepInput, m)
+		m = playKeys(m, 67)
+
+		assert.Contains(t, m.currentOverlay.Notes, GK(5, 2))
+		assert.Equal(t, GK(5, 3), m.gridCursor)
+	})
+
+	t.Run("Keys are ignored when step input is off", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeMono))
+
+		m = playKeys(m, 67)
+
+		assert.Empty(t, m.currentOverlay.Notes)
+	})
+
+	t.Run("Mono mode keeps one note on the beat", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeMono), WithGridCursor(GK(0, 2)))
+
+		m, _ = processCommand(mappings.ToggleStepInput, m)
+		m = playKeys(m, 67)
+		m.gridCursor = GK(0, 2)
+		m = playKeys(m, 60)
+
+		assert.Equal(t, zeronote, m.currentOverlay.Notes[GK(5, 2)])
+		assert.Contains(t, m.currentOverlay.Notes, GK(12, 2))
+	})
+
+	t.Run("Held keys become a chord on the detected root", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeChord), WithGridCursor(GK(0, 1)))
+
+		m, _ = processCommand(mappings.ToggleStepInput, m)
+		m = playKeys(m, 64, 67, 72)
+
+		chord := theory.InitChord(theory.MajorTriad)
+		chord.NextInversion()
+		assert.Len(t, m.currentOverlay.Chords, 1)
+		assert.Equal(t, GK(12, 1), m.currentOverlay.Chords[0].Root)
+		assert.Equal(t, chord, m.currentOverlay.Chords[0].Chord)
+		assert.Equal(t, GK(12, 2), m.gridCursor)
+	})
+
+	t.Run("A chord on the same root is replaced", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeChord), WithGridCursor(GK(0, 1)))
+
+		m, _ = processCommand(mappings.ToggleStepInput, m)
+		m = playKeys(m, 60, 64, 67)
+		m.gridCursor = GK(0, 1)
+		m = playKeys(m, 60, 63, 67)
+
+		assert.Len(t, m.currentOverlay.Chords, 1)
+		assert.Equal(t, theory.MinorTriad, m.currentOverlay.Chords[0].Chord.Notes)
+	})
+
+	t.Run("A step is undone as one change", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeChord))
+
+		m, _ = processCommand(mappings.ToggleStepInput, m)
+		m = playKeys(m, 60, 64, 67)
+		m, _ = processCommand(mappings.Undo, m)
+
+		assert.Empty(t, m.currentOverlay.Chords)
+	})
+
+	t.Run("Toggling step input off", func(t *testing.T) {
+		m := createTestModel(WithPianoLines(operation.SeqModeChord))
+
+		m, _ = processCommands([]any{mappings.ToggleStepInput, mappings.ToggleStepInput}, m)
+
+		assert.Nil(t, m.step)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/chriserin/sq/internal/grid"
 	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/midifile"
 	"github.com/chriserin/sq/internal/operation"
 	"github.com/chriserin/sq/internal/overlaykey"
 	"github.com/chriserin/sq/internal/seqmidi"
@@ -296,6 +297,53 @@ func TestSaveAs(t *testing.T) {
 	}
 }
 
+func TestImportMidi(t *testing.T) {
+	//NOTE: Using this temp directory method to work around limits on file name length within the input
+	tempDir, err := os.MkdirTemp("./", "ex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	exported := createTestModel(WithGridCursor(GK(1, 2)))
+	exported, _ = processCommand(mappings.NoteAdd, exported)
+	err = midifile.Export(exported.definition, filepath.Join(tempDir, "drums.mid"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Import replaces the sequence", func(t *testing.T) {
+		m := createTestModel(func(m *model) model {
+			m.filename = filepath.Join(tempDir, "current.sq")
+			return *m
+		})
+
+		m, _ = processCommands([]any{mappings.ImportMidi, TestKey{Keys: filepath.Join(tempDir, "drums")}, mappings.Enter}, m)
+
+		assert.Equal(t, operation.SelectGrid, m.selectionIndicator)
+		assert.NoError(t, m.currentError)
+		assert.Equal(t, filepath.Join(tempDir, "drums.sq"), m.filename, "Imported sequence should be named like the midi file")
+		assert.True(t, m.NeedsWrite())
+		assert.Equal(t, []grid.LineDefinition{{Channel: 10, Note: 61, MsgType: grid.MessageTypeNote}}, m.definition.Lines)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 4))
+	})
+
+	t.Run("Import of a missing file shows an error", func(t *testing.T) {
+		m := createTestModel()
+
+		m, _ = processCommands([]any{mappings.ImportMidi, TestKey{Keys: filepath.Join(tempDir, "none")}, mappings.Enter}, m)
+
+		assert.Equal(t, operation.SelectError, m.selectionIndicator)
+		assert.Error(t, m.currentError)
+		assert.Len(t, m.definition.Lines, 8)
+	})
+}
+
 func TestSaveBeforeFilenameEscape(t *testing.T) {
 
 	tests := []struct {
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/seqmidi"
+	"github.com/stretchr/testify/assert"
+)
+
+func WithUndoHistory(size int) modelFunc {
+	return func(m *model) model {
+		m.undoHistory = size
+		return *m
+	}
+}
+
+func reopen(filename string, size int) model {
+	m := InitModel(filename, &seqmidi.MidiConnection{}, ProgramOptions{undoHistory: size}, func() {})
+	m.ResetIterations()
+	return m
+}
+
+func TestUndoHistory(t *testing.T) {
+	t.Run("Undo changes made before the file was reopened", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(10))
+		m, _ = processCommands([]any{mappings.NoteAdd, mappings.CursorRight, mappings.NoteAdd, mappings.AccentIncrease, mappings.Save}, m)
+
+		m = reopen(filename, 10)
+		assert.NoError(t, m.currentError)
+		assert.False(t, m.NeedsWrite())
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+
+		m, _ = processCommands([]any{mappings.Undo, mappings.Undo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+		assert.True(t, m.NeedsWrite())
+
+		m, _ = processCommands([]any{mappings.Redo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+	})
+
+	t.Run("Redo changes undone before the file was saved", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(10))
+		m, _ = processCommands([]any{mappings.NoteAdd, mappings.Undo, mappings.Save}, m)
+
+		m = reopen(filename, 10)
+		m, _ = processCommands([]any{mappings.Redo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+	})
+
+	t.Run("Undo arrangement changes made before the file was reopened", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(10))
+		m, _ = processCommands([]any{mappings.NewSectionAfter, mappings.Enter, mappings.Save}, m)
+		sections := m.arrangement.Root.CountEndNodes()
+
+		m = reopen(filename, 10)
+		assert.Equal(t, sections, m.arrangement.Root.CountEndNodes())
+
+		m, _ = processCommands([]any{mappings.Undo}, m)
+		assert.Equal(t, sections-1, m.arrangement.Root.CountEndNodes())
+
+		m, _ = processCommands([]any{mappings.Redo}, m)
+		assert.Equal(t, sections, m.arrangement.Root.CountEndNodes())
+	})
+
+	t.Run("The history is ignored once the file changes elsewhere", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(10))
+		m, _ = processCommands([]any{mappings.NoteAdd, mappings.Save}, m)
+
+		data, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filename, append(data, '\n'), 0644))
+
+		m = reopen(filename, 10)
+		assert.NoError(t, m.currentError)
+		assert.Equal(t, EmptyStack, m.undoStack)
+	})
+
+	t.Run("The history is capped to its size", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(2))
+		m, _ = processCommands([]any{mappings.NoteAdd, mappings.CursorRight, mappings.NoteAdd, mappings.CursorRight, mappings.NoteAdd, mappings.Save}, m)
+
+		data, err := os.ReadFile(UndoHistoryFile(filename))
+		assert.NoError(t, err)
+		var history undoHistory
+		assert.NoError(t, json.Unmarshal(data, &history))
+		assert.Len(t, history.Edits, 2)
+
+		m = reopen(filename, 2)
+		m, _ = processCommands([]any{mappings.Undo, mappings.Undo, mappings.Undo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+	})
+
+	t.Run("No history is kept when turned off", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename))
+		processCommands([]any{mappings.NoteAdd, mappings.Save}, m)
+
+		assert.NoFileExists(t, UndoHistoryFile(filename))
+	})
+}
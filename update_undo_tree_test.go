@@ -0,0 +1,102 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chriserin/sq/internal/mappings"
+	"github.com/chriserin/sq/internal/operation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoTree(t *testing.T) {
+	branches := []any{
+		mappings.NoteAdd,
+		mappings.Undo,
+		mappings.CursorRight,
+		mappings.NoteAdd,
+	}
+
+	t.Run("Editing after undo keeps the undone branch", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(branches, m)
+
+		assert.Equal(t, 2, m.undoTree.Len())
+		assert.Equal(t, EmptyStack, m.redoStack)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+	})
+
+	t.Run("Earlier goes back in time across branches", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(branches, m)
+
+		m, _ = processCommands([]any{mappings.UndoEarlier}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+
+		m, _ = processCommands([]any{mappings.UndoEarlier}, m)
+		assert.Empty(t, m.CurrentPart().Overlays.Notes)
+		assert.Equal(t, EmptyStack, m.undoStack)
+
+		m, _ = processCommands([]any{mappings.UndoEarlier}, m)
+		assert.Empty(t, m.CurrentPart().Overlays.Notes)
+	})
+
+	t.Run("Later goes forward in time across branches", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(branches, m)
+		m, _ = processCommands([]any{mappings.UndoEarlier, mappings.UndoEarlier, mappings.UndoLater}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+
+		m, _ = processCommands([]any{mappings.UndoLater}, m)
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+
+		m, _ = processCommands([]any{mappings.UndoLater}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+	})
+
+	t.Run("Undo and redo follow the branch travelled to", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(branches, m)
+		m, _ = processCommands([]any{mappings.UndoEarlier, mappings.Undo}, m)
+		assert.Empty(t, m.CurrentPart().Overlays.Notes)
+
+		m, _ = processCommands([]any{mappings.Redo}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+	})
+
+	t.Run("Lists the branches with the current branch marked", func(t *testing.T) {
+		m := createTestModel()
+		m, _ = processCommands(branches, m)
+		m, _ = processCommands([]any{mappings.ShowUndoTree}, m)
+
+		assert.Equal(t, operation.SelectUndoTree, m.selectionIndicator)
+		listed := m.undoTree.Branches(m.UndoBranchTip())
+		assert.Len(t, listed, 2)
+		assert.Equal(t, []int{1, 2}, []int{listed[0].Number, listed[1].Number})
+		assert.False(t, listed[0].Current)
+		assert.True(t, listed[1].Current)
+		assert.Contains(t, m.UndoTreeView(), "edit 2 of 2")
+
+		m, _ = processCommands([]any{mappings.UndoEarlier, mappings.Undo}, m)
+		listed = m.undoTree.Branches(m.UndoBranchTip())
+		assert.True(t, listed[0].Current)
+		assert.False(t, listed[1].Current)
+	})
+
+	t.Run("Branches are kept across sessions", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "song.sq")
+		m := createTestModel(WithFilename(filename), WithUndoHistory(10))
+		m, _ = processCommands(append(branches, mappings.Save), m)
+
+		m = reopen(filename, 10)
+		assert.Equal(t, 2, m.undoTree.Len())
+		m, _ = processCommands([]any{mappings.UndoEarlier}, m)
+		assert.Contains(t, m.CurrentPart().Overlays.Notes, GK(0, 0))
+		assert.NotContains(t, m.CurrentPart().Overlays.Notes, GK(0, 1))
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Southclaws/fault"
 	"github.com/Southclaws/fault/fmsg"
@@ -31,6 +32,7 @@ func (m model) View() (output string) {
 			stackTrace := make([]byte, 4096)
 			n := runtime.Stack(stackTrace, false)
 			msg := panicMsg{message: fmt.Sprintf("Caught View Panic: %v", r), stacktrace: stackTrace[:n]}
+			m.AutosaveAfterPanic()
 			m.LogString(fmt.Sprintf(" ------ Panic Message ------- \n%s\n", msg.message))
 			m.LogString(fmt.Sprintf(" ------ Stacktrace ---------- \n%s\n", msg.stacktrace))
 			output = fmt.Sprintf("View Layer Panic: %v\n", r)
@@ -57,7 +59,7 @@ func (m model) View() (output string) {
 	if m.patternMode == operation.PatternAccent || m.IsAccentSelector() {
 		sideView = m.AccentKeyView()
 	} else if (m.CurrentPart().Overlays.Key == overlaykey.ROOT && m.CurrentPart().Overlays.IsFresh() && len(*m.definition.Parts) == 1 && m.CurrentPartID() == 0) ||
-		slices.Contains([]operation.Selection{operation.SelectSetupValue, operation.SelectSetupMessageType, operation.SelectSetupChannel}, m.selectionIndicator) {
+		slices.Contains([]operation.Selection{operation.SelectSetupValue, operation.SelectSetupMessageType, operation.SelectSetupChannel, operation.SelectSetupLength, operation.SelectSetupDivision}, m.selectionIndicator) {
 		// NOTE: We want to show the setupView on the very initial screen,
 		// before any sequencing has begun OR a setup value is selected
 		sideView = m.SetupView(showLines)
@@ -169,6 +171,64 @@ func (m model) BeatsEditView() string {
 	return buf.String()
 }
 
+func (m model) MeterEditView() string {
+	meter := m.CurrentPart().Meter
+	numerator, denominator, grouping := "-", "-", "-"
+	if meter.IsSet() {
+		numerator = strconv.Itoa(int(meter.Numerator))
+		denominator = strconv.Itoa(int(meter.Denominator))
+		groups := make([]string, 0, len(meter.Grouping()))
+		for _, group := range meter.Grouping() {
+			groups = append(groups, strconv.Itoa(int(group)))
+		}
+		grouping = strings.Join(groups, "+")
+	}
+	numeratorInput := themes.NumberStyle.Render(numerator)
+	denominatorInput := themes.NumberStyle.Render(denominator)
+	switch m.selectionIndicator {
+	case operation.SelectMeterNumerator:
+		numeratorInput = themes.SelectedStyle.Render(numerator)
+	case operation.SelectMeterDenominator:
+		denominatorInput = themes.SelectedStyle.Render(denominator)
+	}
+	var buf strings.Builder
+	buf.WriteString(themes.AltArtStyle.Render(" Meter "))
+	buf.WriteString(numeratorInput)
+	buf.WriteString(themes.AltArtStyle.Render("/"))
+	buf.WriteString(denominatorInput)
+	buf.WriteString(themes.AltArtStyle.Render("  Grouping "))
+	buf.WriteString(themes.NumberStyle.Render(grouping))
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func (m model) GrooveEditView() string {
+	setting := m.GrooveSetting()
+	name := setting.Name
+	if name == "" {
+		name = "Straight"
+	}
+	grooveInput := themes.NumberStyle.Render(name)
+	amountInput := themes.NumberStyle.Render(fmt.Sprintf("%d%%", setting.Amount))
+	switch m.selectionIndicator {
+	case operation.SelectGroove:
+		grooveInput = themes.SelectedStyle.Render(name)
+	case operation.SelectGrooveAmount:
+		amountInput = themes.SelectedStyle.Render(fmt.Sprintf("%d%%", setting.Amount))
+	}
+	target := "Part"
+	if len(m.grooveLines) > 0 {
+		target = fmt.Sprintf("Lines %d-%d", m.grooveLines[0]+1, m.grooveLines[len(m.grooveLines)-1]+1)
+	}
+	var buf strings.Builder
+	buf.WriteString(themes.AltArtStyle.Render(fmt.Sprintf(" %s Groove ", target)))
+	buf.WriteString(grooveInput)
+	buf.WriteString(themes.AltArtStyle.Render("  Amount "))
+	buf.WriteString(amountInput)
+	buf.WriteString("\n")
+	return buf.String()
+}
+
 func (m model) TempoEditView() string {
 	var tempo, division string
 	tempo = themes.NumberStyle.Render(strconv.Itoa(m.definition.Tempo))
@@ -184,6 +244,10 @@ func (m model) TempoEditView() string {
 	buf.WriteString(tempo)
 	buf.WriteString(themes.AltArtStyle.Render("  Subdivisions "))
 	buf.WriteString(division)
+	if m.midiLoopMode == timing.MlmLink && m.linkTempo > 0 {
+		buf.WriteString(themes.AltArtStyle.Render("  Link "))
+		buf.WriteString(themes.NumberStyle.Render(strconv.Itoa(m.linkTempo)))
+	}
 	buf.WriteString("\n")
 	return buf.String()
 }
@@ -327,11 +391,38 @@ func (m model) SetupView(showLines []uint8) string {
 				buf.WriteString(themes.NumberStyle.Render(strconv.Itoa(int(line.Note))))
 			}
 		}
-		buf.WriteString(fmt.Sprintf(" %s\n", LineValueName(line, m.definition.Instrument)))
+		buf.WriteString(fmt.Sprintf(" %s", LineValueName(line, m.definition.Instrument)))
+
+		buf.WriteString(" LEN ")
+		length := strconv.Itoa(int(line.Steps(m.CurrentPart().Beats)))
+		if uint8(i) == m.gridCursor.Line && m.selectionIndicator == operation.SelectSetupLength {
+			buf.WriteString(themes.SelectedStyle.Render(length))
+		} else {
+			buf.WriteString(themes.NumberStyle.Render(length))
+		}
+
+		buf.WriteString(" ")
+		division := DivisionName(line.Division)
+		if uint8(i) == m.gridCursor.Line && m.selectionIndicator == operation.SelectSetupDivision {
+			buf.WriteString(themes.SelectedStyle.Render(division))
+		} else {
+			buf.WriteString(division)
+		}
+		buf.WriteString("\n")
 	}
 	return buf.String()
 }
 
+func DivisionName(division grid.Division) string {
+	switch {
+	case division.BeatsPerStep() > 1:
+		return fmt.Sprintf("÷%d", division.BeatsPerStep())
+	case division.StepsPerBeat() > 1:
+		return fmt.Sprintf("×%d", division.StepsPerBeat())
+	}
+	return "×1"
+}
+
 func NoteName(note uint8) string {
 	return fmt.Sprintf("%s%d", strings.ReplaceAll(midi.Note(note).Name(), "b", "♭"), int(midi.Note(note).Octave())-2)
 }
@@ -468,12 +559,30 @@ func (m model) SeqView(showLines []uint8) string {
 	} else if m.selectionIndicator == operation.SelectGrid && m.patternMode == operation.PatternNoteRatchet {
 		mode = " Ratchet Note "
 		buf.WriteString(PatternMode(mode))
+	} else if m.selectionIndicator == operation.SelectGrid && m.patternMode == operation.PatternProbability {
+		mode = fmt.Sprintf(" Chance %d%% ", currentNote.Note.Chance())
+		buf.WriteString(PatternMode(mode))
+	} else if m.selectionIndicator == operation.SelectGrid && m.patternMode == operation.PatternNoteProbability {
+		mode = fmt.Sprintf(" Chance Note %d%% ", currentNote.Note.Chance())
+		buf.WriteString(PatternMode(mode))
+	} else if m.selectionIndicator == operation.SelectGrid && m.patternMode == operation.PatternCondition {
+		mode = fmt.Sprintf(" Trig %s ", ConditionName(currentNote.Note.Condition))
+		buf.WriteString(PatternMode(mode))
+	} else if m.selectionIndicator == operation.SelectGrid && m.patternMode == operation.PatternNoteCondition {
+		mode = fmt.Sprintf(" Trig Note %s ", ConditionName(currentNote.Note.Condition))
+		buf.WriteString(PatternMode(mode))
 	} else if m.selectionIndicator == operation.SelectRatchets || m.selectionIndicator == operation.SelectRatchetSpan {
 		buf.WriteString(m.RatchetEditView())
+	} else if m.selectionIndicator == operation.SelectLockControl || m.selectionIndicator == operation.SelectLockValue {
+		buf.WriteString(m.LockEditView())
 	} else if m.selectionIndicator == operation.SelectTempo || m.selectionIndicator == operation.SelectTempoSubdivision {
 		buf.WriteString(m.TempoEditView())
 	} else if slices.Contains([]operation.Selection{operation.SelectBeats, operation.SelectStartBeats}, m.selectionIndicator) {
 		buf.WriteString(m.BeatsEditView())
+	} else if m.selectionIndicator == operation.SelectGroove || m.selectionIndicator == operation.SelectGrooveAmount {
+		buf.WriteString(m.GrooveEditView())
+	} else if m.selectionIndicator == operation.SelectMeterNumerator || m.selectionIndicator == operation.SelectMeterDenominator {
+		buf.WriteString(m.MeterEditView())
 	} else if slices.Contains([]operation.Selection{operation.SelectCycles, operation.SelectStartCycles}, m.selectionIndicator) {
 		buf.WriteString(m.CyclesEditView())
 	} else if m.selectionIndicator == operation.SelectPart {
@@ -484,12 +593,26 @@ func (m model) SeqView(showLines []uint8) string {
 		buf.WriteString(m.RenamePartView())
 	} else if m.selectionIndicator == operation.SelectFileName {
 		buf.WriteString(m.FileNameView())
+	} else if m.selectionIndicator == operation.SelectImportFile {
+		buf.WriteString(m.ImportFileView())
 	} else if m.selectionIndicator == operation.SelectConfirmNew {
 		buf.WriteString(m.ConfirmNewSequenceView())
 	} else if m.selectionIndicator == operation.SelectConfirmQuit {
 		buf.WriteString(m.ConfirmQuitView())
 	} else if m.selectionIndicator == operation.SelectConfirmReload {
 		buf.WriteString(m.ConfirmReloadView())
+	} else if m.selectionIndicator == operation.SelectConfirmImport {
+		buf.WriteString(m.ConfirmImportView())
+	} else if m.selectionIndicator == operation.SelectRecoverFile {
+		buf.WriteString(m.RecoverFileView())
+	} else if m.selectionIndicator == operation.SelectUndoTree {
+		buf.WriteString(m.UndoTreeView())
+	} else if m.selectionIndicator == operation.SelectSnapshotName {
+		buf.WriteString(m.SnapshotNameView())
+	} else if m.selectionIndicator == operation.SelectSnapshot {
+		buf.WriteString(m.SnapshotsView())
+	} else if m.selectionIndicator == operation.SelectLibrary {
+		buf.WriteString(m.LibraryView())
 	} else if m.selectionIndicator == operation.SelectSpecificValue {
 		buf.WriteString(m.SpecificValueEditView(currentNote.Note))
 	} else if m.selectionIndicator == operation.SelectEuclideanHits {
@@ -516,6 +639,14 @@ func (m model) SeqView(showLines []uint8) string {
 		buf.WriteString("  X")
 	} else if m.midiLoopMode == timing.MlmReceiver && m.transmitterConnected {
 		buf.WriteString("  ☨")
+	} else if m.midiLoopMode == timing.MlmClockReceiver && !m.transmitterConnected {
+		buf.WriteString("  X")
+	} else if m.midiLoopMode == timing.MlmClockReceiver && m.transmitterConnected {
+		buf.WriteString("  C")
+	} else if m.midiLoopMode == timing.MlmLink && m.linkPeers == 0 {
+		buf.WriteString("  l")
+	} else if m.midiLoopMode == timing.MlmLink && m.linkPeers > 0 {
+		buf.WriteString("  L")
 	} else {
 		buf.WriteString("   ")
 	}
@@ -546,11 +677,39 @@ func (m model) TopLine(beats uint8) string {
 		buf.WriteString(themes.NumberStyle.Render("<"))
 		buf.WriteString(themes.SeqBorderStyle.Render(strings.Repeat("─", max(int(beats)-int(m.playState.BoundedLoop.RightBound+2), 0))))
 		return buf.String()
+	} else if m.CurrentPart().Meter.IsSet() {
+		return fmt.Sprintf(" %s%s", themes.SeqBorderStyle.Render("┌"), m.BarLine(beats))
 	} else {
 		return fmt.Sprintf(" %s%s", themes.SeqBorderStyle.Render("┌"), themes.SeqBorderStyle.Render(strings.Repeat("─", max(32, int(beats)))))
 	}
 }
 
+// BarLine marks the downbeats of the bars of the current part with ┳ and the
+// pulses within a bar with ┬.  The marks are highlighted while the pattern
+// mode numbers count in bars.
+func (m model) BarLine(beats uint8) string {
+	markStyle := themes.SeqBorderStyle
+	if m.patternBars {
+		markStyle = themes.NumberStyle
+	}
+	meter := m.CurrentPart().Meter
+	subdivisions := m.MeterSubdivisions()
+	var buf strings.Builder
+	for i := uint8(0); i < beats; i++ {
+		downbeat, pulse := meter.Downbeat(i, subdivisions)
+		switch {
+		case downbeat:
+			buf.WriteString(markStyle.Render("┳"))
+		case pulse:
+			buf.WriteString(markStyle.Render("┬"))
+		default:
+			buf.WriteString(themes.SeqBorderStyle.Render("─"))
+		}
+	}
+	buf.WriteString(themes.SeqBorderStyle.Render(strings.Repeat("─", max(32-int(beats), 0))))
+	return buf.String()
+}
+
 func (m model) RenamePartView() string {
 	var buf strings.Builder
 	buf.WriteString(" Rename Part: ")
@@ -567,6 +726,14 @@ func (m model) FileNameView() string {
 	return buf.String()
 }
 
+func (m model) ImportFileView() string {
+	var buf strings.Builder
+	buf.WriteString(" Import Midi File: ")
+	buf.WriteString(m.textInput.View())
+	buf.WriteString("\n")
+	return buf.String()
+}
+
 func (m model) ChoosePartView() string {
 	var buf strings.Builder
 	buf.WriteString(" Choose Part: ")
@@ -605,6 +772,98 @@ func (m model) ConfirmReloadView() string {
 	return buf.String()
 }
 
+func (m model) ConfirmImportView() string {
+	var buf strings.Builder
+	buf.WriteString(" Import, unsaved changes are kept in the recovery file: ")
+	buf.WriteString(themes.SelectedStyle.Render("Confirm"))
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func (m model) RecoverFileView() string {
+	var buf strings.Builder
+	buf.WriteString(" Unsaved changes found, recover: ")
+	buf.WriteString(themes.SelectedStyle.Render("Confirm"))
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func (m model) UndoTreeView() string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf(" Undo tree, edit %d of %d\n", m.undoTree.Number(m.undoStack.id), m.undoTree.Len()))
+	for _, branch := range m.undoTree.Branches(m.UndoBranchTip()) {
+		line := fmt.Sprintf("  %4d  %3d changes  %s", branch.Number, branch.Changes, branch.Time.Format(time.TimeOnly))
+		if branch.Current {
+			buf.WriteString(themes.SelectedStyle.Render(line))
+		} else {
+			buf.WriteString(line)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func (m model) SnapshotNameView() string {
+	var buf strings.Builder
+	if m.snapshotPart == sequence.WholeSequence {
+		buf.WriteString(" Snapshot Name: ")
+	} else {
+		buf.WriteString(" Part Snapshot Name: ")
+	}
+	buf.WriteString(m.textInput.View())
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func (m model) SnapshotsView() string {
+	var buf strings.Builder
+	buf.WriteString(" Recall Snapshot\n")
+	if len(m.definition.Snapshots) == 0 {
+		buf.WriteString("  No snapshots\n")
+	}
+	for i, snapshot := range m.definition.Snapshots {
+		captured := "whole sequence"
+		if !snapshot.IsWhole() {
+			captured = fmt.Sprintf("part %d", snapshot.Part+1)
+		}
+		line := fmt.Sprintf("  %-20s %s", snapshot.Name, captured)
+		if m.queuedSnapshot != nil && m.queuedSnapshot.Name == snapshot.Name {
+			line += "  queued"
+		}
+		if i == m.snapshotIndex {
+			buf.WriteString(themes.SelectedStyle.Render(line))
+		} else {
+			buf.WriteString(line)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func (m model) LibraryView() string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf(" Open Sequence from %s\n", m.LibraryDirectory()))
+	if len(m.libraryEntries) == 0 {
+		buf.WriteString("  No sequences\n")
+	}
+	for i, entry := range m.libraryEntries {
+		var line string
+		if entry.Err != nil {
+			line = fmt.Sprintf("  %-24s cannot be read", entry.Name())
+		} else {
+			length := entry.Length.Round(time.Second)
+			line = fmt.Sprintf("  %-24s %3d bpm  %2d parts  %d:%02d", entry.Name(), entry.Tempo, entry.Parts, int(length.Minutes()), int(length.Seconds())%60)
+		}
+		if i == m.libraryIndex {
+			buf.WriteString(themes.SelectedStyle.Render(line))
+		} else {
+			buf.WriteString(line)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
 func (m model) RatchetEditView() string {
 	currentNote, _ := m.CurrentNote()
 
@@ -636,6 +895,42 @@ func (m model) RatchetEditView() string {
 	return buf.String()
 }
 
+func (m model) LockEditView() string {
+	currentNote, _ := m.CurrentNote()
+
+	var buf strings.Builder
+	buf.WriteString(" Lock CC ")
+	control := strconv.Itoa(int(m.lockControl))
+	if cc, exists := config.FindCC(m.lockControl, m.definition.Instrument); exists {
+		control = fmt.Sprintf("%s %s", control, cc.Name)
+	}
+	if m.selectionIndicator == operation.SelectLockControl {
+		buf.WriteString(themes.SelectedStyle.Render(control))
+	} else {
+		buf.WriteString(themes.NumberStyle.Render(control))
+	}
+
+	value := "--"
+	if lockValue, locked := currentNote.Locks.Value(m.lockControl); locked {
+		value = strconv.Itoa(int(lockValue))
+	}
+	buf.WriteString(" Value ")
+	if m.selectionIndicator == operation.SelectLockValue {
+		buf.WriteString(themes.SelectedStyle.Render(value))
+	} else {
+		buf.WriteString(themes.NumberStyle.Render(value))
+	}
+
+	for _, lock := range currentNote.Locks.All() {
+		if lock.Control != m.lockControl {
+			buf.WriteString(themes.MutedStyle.Render(fmt.Sprintf("  CC%d=%d", lock.Control, lock.Value)))
+		}
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
+
 func (m model) ViewOverlay() string {
 	return m.overlayKeyEdit.ViewOverlay()
 }
@@ -672,7 +967,40 @@ func (m model) CurrentOverlayView() string {
 
 	editOverlay := fmt.Sprintf("%s %s", editOverlayTitle, lipgloss.PlaceHorizontal(11, 0, m.ViewOverlay()))
 	playOverlay := fmt.Sprintf("%s %s", playOverlayTitle, lipgloss.PlaceHorizontal(11, 0, overlaykey.View(matchedKey)))
-	return fmt.Sprintf("   %s  %s  %s %s", monoIndicator, editOverlay, playOverlay, mappings.KeycomboView())
+	return fmt.Sprintf("   %s  %s  %s %s%s%s%s", monoIndicator, editOverlay, playOverlay, m.RecordView(), m.LearnView(), m.FillView(), mappings.KeycomboView())
+}
+
+func (m model) RecordView() string {
+	if m.take == nil && m.step != nil {
+		return lipgloss.NewStyle().Foreground(themes.ActivePlayingColor).Render("STEP ")
+	} else if m.take == nil {
+		return ""
+	}
+	var into = ""
+	if m.recordIntoOverlay {
+		into = " E"
+	}
+	return lipgloss.NewStyle().Foreground(themes.ActivePlayingColor).Render(fmt.Sprintf("REC %s%s ", m.take.Mode, into))
+}
+
+// LearnView shows the command a control will be bound to during midi learn,
+// or a question mark until a command is chosen.
+func (m model) LearnView() string {
+	if !m.learn.Active {
+		return ""
+	}
+	target := "?"
+	if m.learn.HasTarget {
+		target = m.learn.Target()
+	}
+	return lipgloss.NewStyle().Foreground(themes.ActivePlayingColor).Render(fmt.Sprintf("LEARN %s ", target))
+}
+
+func (m model) FillView() string {
+	if !m.playState.Fill {
+		return ""
+	}
+	return lipgloss.NewStyle().Foreground(themes.ActivePlayingColor).Render("FILL ")
 }
 
 func KeyLineIndicator(k uint8, l uint8) string {
@@ -761,6 +1089,8 @@ func lineView(lineNumber uint8, m model, visualCombinedPattern overlays.OverlayP
 
 	gateSpace := GateSpace{}
 	currentChord := m.CurrentChord()
+	meter := m.CurrentPart().Meter
+	subdivisions := m.MeterSubdivisions()
 	for i := uint8(0); i < m.CurrentPart().Beats; i++ {
 		currentGridKey := GK(uint8(lineNumber), i)
 		overlayNote, hasNote := visualCombinedPattern[currentGridKey]
@@ -775,7 +1105,10 @@ func lineView(lineNumber uint8, m model, visualCombinedPattern overlays.OverlayP
 			backgroundSeqColor = themes.SeqOverlayColor
 		} else if hasNote && !overlayNote.HighestOverlay && overlayNote.OverlayKey != overlaykey.ROOT {
 			backgroundSeqColor = themes.SeqMiddleOverlayColor
-		} else if i%8 > 3 {
+		} else if meter.BarSteps(subdivisions) > 0 && meter.Bar(i, subdivisions)%2 == 1 {
+			// With a time signature every other bar is shaded
+			backgroundSeqColor = themes.AltSeqBackgroundColor
+		} else if meter.BarSteps(subdivisions) == 0 && i%8 > 3 {
 			backgroundSeqColor = themes.AltSeqBackgroundColor
 		} else {
 			backgroundSeqColor = themes.SeqBackgroundColor
@@ -840,10 +1173,20 @@ func ViewNoteComponents(currentNote grid.Note) (string, lipgloss.Color) {
 	var char string
 	var foregroundColor lipgloss.Color
 	var waitShape string
+	var trigShape string
+	var lockShape string
 
 	if currentNote.WaitIndex > 0 {
 		waitShape = "\u0320"
 	}
+	// Notes that do not play every time are marked with a dot below
+	if currentNote.Chance() < 100 || currentNote.Condition != grid.ConditionNone {
+		trigShape = "\u0323"
+	}
+	// Notes with parameter locks are marked with a dot above
+	if currentNote.Locks.Count > 0 {
+		lockShape = "\u0307"
+	}
 
 	if currentAction == grid.ActionNothing && currentNote != zeronote {
 		currentAccentShape := themes.AccentIcons[currentNote.AccentIndex]
@@ -851,7 +1194,9 @@ func ViewNoteComponents(currentNote grid.Note) (string, lipgloss.Color) {
 		char = string(currentAccentShape) +
 			string(config.Ratchets[currentNote.Ratchets.Length]) +
 			ShortGate(currentNote) +
-			waitShape
+			waitShape +
+			trigShape +
+			lockShape
 		foregroundColor = lipgloss.Color(currentAccentColor)
 	} else {
 		lineaction := config.Lineactions[currentAction]
@@ -863,6 +1208,13 @@ func ViewNoteComponents(currentNote grid.Note) (string, lipgloss.Color) {
 	return char, foregroundColor
 }
 
+func ConditionName(condition grid.Condition) string {
+	if condition == grid.ConditionNone {
+		return "always"
+	}
+	return condition.String()
+}
+
 func ShortGate(note note) string {
 	if note.GateIndex < int16(len(config.ShortGates)) {
 		return string(config.ShortGates[note.GateIndex].Shape)